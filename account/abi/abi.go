@@ -21,6 +21,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
 )
 
 // The ABI holds information about a contract's context and available
@@ -30,6 +33,7 @@ type ABI struct {
 	Constructor Method
 	Methods     map[string]Method
 	Events      map[string]Event
+	Errors      map[string]Error
 }
 
 // JSON returns a parsed ABI interface and error if it failed.
@@ -91,15 +95,60 @@ func (abi ABI) Unpack(v interface{}, name string, output []byte) (err error) {
 	return fmt.Errorf("abi: could not locate named method or event")
 }
 
+// UnpackIntoMap unpacks a log or a method output into the provided map, keyed
+// by the argument names.
+func (abi ABI) UnpackIntoMap(v map[string]interface{}, name string, output []byte) (err error) {
+	if len(output) == 0 {
+		return fmt.Errorf("abi: unmarshalling empty output")
+	}
+	if method, ok := abi.Methods[name]; ok {
+		if len(output)%32 != 0 {
+			return fmt.Errorf("abi: improperly formatted output")
+		}
+		return method.Outputs.UnpackIntoMap(v, output)
+	} else if event, ok := abi.Events[name]; ok {
+		return event.Inputs.UnpackIntoMap(v, output)
+	}
+	return fmt.Errorf("abi: could not locate named method or event")
+}
+
+// UnpackLog unpacks both the indexed and non-indexed fields of a log into out.
+// The event is looked up by the first topic of the log, so anonymous events
+// must be unpacked through Event.UnpackLog instead.
+func (abi ABI) UnpackLog(out interface{}, log types.Log) error {
+	if len(log.Topics) == 0 {
+		return errNoEventSignature
+	}
+	event, err := abi.EventByID(log.Topics[0])
+	if err != nil {
+		return err
+	}
+	return event.UnpackLog(out, log)
+}
+
+// UnpackLogIntoMap unpacks both the indexed and non-indexed fields of a log
+// into the provided map, keyed by the argument names.
+func (abi ABI) UnpackLogIntoMap(out map[string]interface{}, log types.Log) error {
+	if len(log.Topics) == 0 {
+		return errNoEventSignature
+	}
+	event, err := abi.EventByID(log.Topics[0])
+	if err != nil {
+		return err
+	}
+	return event.UnpackLogIntoMap(out, log)
+}
+
 // UnmarshalJSON implements json.Unmarshaler interface
 func (abi *ABI) UnmarshalJSON(data []byte) error {
 	var fields []struct {
-		Type      string
-		Name      string
-		Constant  bool
-		Anonymous bool
-		Inputs    []Argument
-		Outputs   []Argument
+		Type            string
+		Name            string
+		Constant        bool
+		StateMutability string
+		Anonymous       bool
+		Inputs          []Argument
+		Outputs         []Argument
 	}
 
 	if err := json.Unmarshal(data, &fields); err != nil {
@@ -108,6 +157,7 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 
 	abi.Methods = make(map[string]Method)
 	abi.Events = make(map[string]Event)
+	abi.Errors = make(map[string]Error)
 	for _, field := range fields {
 		switch field.Type {
 		case "constructor":
@@ -118,7 +168,7 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 		case "function", "":
 			abi.Methods[field.Name] = Method{
 				Name:    field.Name,
-				Const:   field.Constant,
+				Const:   field.Constant || field.StateMutability == "view" || field.StateMutability == "pure",
 				Inputs:  field.Inputs,
 				Outputs: field.Outputs,
 			}
@@ -128,6 +178,11 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 				Anonymous: field.Anonymous,
 				Inputs:    field.Inputs,
 			}
+		case "error":
+			abi.Errors[field.Name] = Error{
+				Name:   field.Name,
+				Inputs: field.Inputs,
+			}
 		}
 	}
 
@@ -144,3 +199,14 @@ func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
 	}
 	return nil, fmt.Errorf("no method with id: %#x", sigdata[:4])
 }
+
+// EventByID looks up an event by the hash of its signature, the first topic
+// of the logs it emits.
+func (abi *ABI) EventByID(topic common.Hash) (*Event, error) {
+	for _, event := range abi.Events {
+		if event.Id() == topic {
+			return &event, nil
+		}
+	}
+	return nil, fmt.Errorf("no event with id: %s", topic.Hex())
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 The hpb-project Authors
+// This file is part of the hpb-project library.
+//
+// The hpb-project library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The hpb-project library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the hpb-project library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/config"
+	"github.com/hpb-project/go-hpb/consensus"
+	"github.com/hpb-project/go-hpb/consensus/prometheus"
+	"github.com/hpb-project/go-hpb/network/rpc"
+)
+
+// simEngine is a consensus engine accepting every header without any seal or
+// election checks. The rewards of the blocks are left to Prometheus.
+type simEngine struct {
+	rewards *prometheus.Prometheus
+}
+
+func (simEngine) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
+
+func (simEngine) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool, mode config.SyncMode) error {
+	return nil
+}
+
+func (simEngine) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool, mode config.SyncMode) (chan<- struct{}, <-chan error) {
+	abort, results := make(chan struct{}), make(chan error, len(headers))
+	for range headers {
+		results <- nil
+	}
+	return abort, results
+}
+
+func (simEngine) SetNetTopology(chain consensus.ChainReader, headers []*types.Header) {}
+
+func (simEngine) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	return nil
+}
+
+func (simEngine) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	return nil
+}
+
+// PrepareBlockHeader fills in an empty extra detail, so that the EVM can read
+// the (zero) random of the header.
+func (simEngine) PrepareBlockHeader(chain consensus.ChainReader, header *types.Header, state *state.StateDB) error {
+	extra, err := types.NewExtraDetail(0)
+	if err != nil {
+		return err
+	}
+	header.Extra = extra.ToBytes()
+	return nil
+}
+
+// Finalize pays out the block rewards through the Prometheus rules before
+// assembling the block. The simulated chain stays in the first Prometheus stage,
+// where the whole hpb node share of every block goes to its coinbase.
+func (e simEngine) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
+	uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	if err := e.rewards.CalculateRewards(chain, state, header, uncles); err != nil {
+		return nil, err
+	}
+	header.Root = state.IntermediateRoot(true)
+	header.UncleHash = types.CalcUncleHash(nil)
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+func (simEngine) GenBlockWithSig(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	return block, nil
+}
+
+func (simEngine) APIs(chain consensus.ChainReader) []rpc.API {
+	return nil
+}
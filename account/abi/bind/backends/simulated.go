@@ -0,0 +1,531 @@
+// Copyright 2016 The hpb-project Authors
+// This file is part of the hpb-project library.
+//
+// The hpb-project library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The hpb-project library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the hpb-project library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package backends contains contract backends usable by the abigen bindings.
+package backends
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/hpb-project/go-hpb/account/abi/bind"
+	bc "github.com/hpb-project/go-hpb/blockchain"
+	"github.com/hpb-project/go-hpb/blockchain/bloombits"
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/math"
+	"github.com/hpb-project/go-hpb/config"
+	"github.com/hpb-project/go-hpb/consensus/prometheus"
+	"github.com/hpb-project/go-hpb/event/sub"
+	"github.com/hpb-project/go-hpb/hvm"
+	"github.com/hpb-project/go-hpb/hvm/evm"
+	hpb_project "github.com/hpb-project/go-hpb/interface"
+	"github.com/hpb-project/go-hpb/network/rpc"
+	"github.com/hpb-project/go-hpb/node/filters"
+)
+
+// This nil assignment ensures compile time that SimulatedBackend implements bind.ContractBackend.
+var _ bind.ContractBackend = (*SimulatedBackend)(nil)
+
+var (
+	errBlockNumberUnsupported = errors.New("simulatedBackend cannot access blocks other than the latest block")
+	errGasEstimationFailed    = errors.New("gas required exceeds allowance or always failing transaction")
+)
+
+// simulatedBlockPeriod is the number of seconds between two committed blocks,
+// on top of any adjustment requested through AdjustTime.
+const simulatedBlockPeriod = 10
+
+// simulatedCoinbase seals the simulated blocks, collecting their rewards.
+var simulatedCoinbase = common.HexToAddress("0x00000000000000000000000000000000000c01b5")
+
+// SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
+// the background. Its main purpose is to allow easily testing contract bindings.
+// Transactions are collected into a pending block which is only imported into
+// the chain when Commit is called.
+type SimulatedBackend struct {
+	database   hpbdb.Database // In memory database to store our testing data
+	blockchain *bc.BlockChain // Hpb blockchain to handle the consensus
+	config     *config.ChainConfig
+
+	mu           sync.Mutex
+	pendingBlock *types.Block   // Currently pending block that will be imported on request
+	pendingState *state.StateDB // Currently pending state that will be the active on request
+	adjustment   time.Duration  // Time shift of the pending block requested by AdjustTime
+
+	events *filters.EventSystem // Event system for filtering log events live
+}
+
+// NewSimulatedBackend creates a new binding backend using a simulated blockchain
+// for testing purposes. The chain starts from a genesis block holding the given
+// allocations, its blocks are sealed by simulatedCoinbase and rewarded by the
+// Prometheus rules.
+func NewSimulatedBackend(alloc bc.GenesisAlloc) *SimulatedBackend {
+	database, _ := hpbdb.NewMemDatabase()
+	extra, _ := types.NewExtraDetail(0)
+	genesis := bc.Genesis{
+		Config:     simulatedChainConfig(),
+		ExtraData:  extra.ToBytes(),
+		GasLimit:   config.GenesisGasLimit.Uint64(),
+		Difficulty: big.NewInt(1),
+		Alloc:      alloc,
+	}
+	genesis.MustCommit(database)
+	engine := simEngine{rewards: prometheus.New(genesis.Config.Prometheus, database)}
+	blockchain, err := bc.NewBlockChainWithEngine(database, nil, genesis.Config, engine)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create simulated chain: %v", err))
+	}
+	backend := &SimulatedBackend{
+		database:   database,
+		blockchain: blockchain,
+		config:     genesis.Config,
+	}
+	backend.events = filters.NewEventSystem(new(sub.TypeMux), &filterBackend{database, blockchain}, false)
+	backend.rollback()
+	return backend
+}
+
+// Close terminates the underlying blockchain's update loop.
+func (b *SimulatedBackend) Close() error {
+	b.blockchain.Stop()
+	return nil
+}
+
+// Commit imports all the pending transactions as a single block and starts a
+// fresh new state.
+func (b *SimulatedBackend) Commit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.blockchain.InsertChain([]*types.Block{b.pendingBlock}); err != nil {
+		panic(err) // This cannot happen unless the simulator is wrong, fail in that case
+	}
+	b.rollback()
+}
+
+// Rollback aborts all pending transactions, reverting to the last committed state.
+func (b *SimulatedBackend) Rollback() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollback()
+}
+
+func (b *SimulatedBackend) rollback() {
+	b.adjustment = 0
+	block, statedb, err := b.buildBlock(nil)
+	if err != nil {
+		panic(err) // An empty block can't fail
+	}
+	b.pendingBlock, b.pendingState = block, statedb
+}
+
+// AdjustTime shifts the timestamp of the pending block by the given duration.
+// The pending transactions are replayed on top of the new timestamp, which is
+// kept until the next Commit or Rollback.
+func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous := b.adjustment
+	b.adjustment += adjustment
+	block, statedb, err := b.buildBlock(b.pendingBlock.Transactions())
+	if err != nil {
+		b.adjustment = previous
+		return err
+	}
+	b.pendingBlock, b.pendingState = block, statedb
+	return nil
+}
+
+// buildBlock executes the given transactions on top of the current head of the
+// chain, returning the resulting block together with its post state.
+func (b *SimulatedBackend) buildBlock(txs types.Transactions) (*types.Block, *state.StateDB, error) {
+	parent := b.blockchain.CurrentBlock()
+	statedb, err := b.blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, nil, err
+	}
+	period := simulatedBlockPeriod + int64(b.adjustment/time.Second)
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   simulatedCoinbase,
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   bc.CalcGasLimit(parent),
+		Time:       new(big.Int).Add(parent.Time(), big.NewInt(period)),
+		Difficulty: big.NewInt(1),
+	}
+	if err := b.blockchain.Engine().PrepareBlockHeader(b.blockchain, header, statedb); err != nil {
+		return nil, nil, err
+	}
+	var (
+		receipts types.Receipts
+		usedGas  = new(big.Int)
+		gp       = new(bc.GasPool).AddGas(header.GasLimit)
+	)
+	// Mirror the state processor, so that the block imports with the same result
+	newVersion := header.Number.Uint64() > b.config.NewContractVersion()
+	for i, tx := range txs {
+		var receipt *types.Receipt
+
+		statedb.Prepare(tx.Hash(), common.Hash{}, i)
+		contract := len(tx.Data()) > 0
+		if newVersion {
+			contract = (tx.To() == nil && len(tx.Data()) > 0) || (tx.To() != nil && len(statedb.GetCode(*tx.To())) > 0)
+		}
+		if contract {
+			receipt, _, err = bc.ApplyTransactionNonFinallize(b.config, b.blockchain, &header.Coinbase, gp, statedb, header, tx, usedGas)
+		} else {
+			receipt, _, err = bc.ApplyTransactionNonContractNonFinallize(b.config, b.blockchain, &header.Coinbase, gp, statedb, header, tx, usedGas)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	bc.ApplyTransactionFinalize(statedb)
+	header.GasUsed = usedGas
+
+	block, err := b.blockchain.Engine().Finalize(b.blockchain, header, statedb, txs, nil, receipts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, statedb, nil
+}
+
+// stateByBlockNumber retrieves the state of the given block, which must be the
+// latest one.
+func (b *SimulatedBackend) stateByBlockNumber(blockNumber *big.Int) (*state.StateDB, error) {
+	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
+		return nil, errBlockNumberUnsupported
+	}
+	return b.blockchain.State()
+}
+
+// CodeAt returns the code associated with a certain account in the blockchain.
+func (b *SimulatedBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statedb, err := b.stateByBlockNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetCode(contract), nil
+}
+
+// BalanceAt returns the wei balance of a certain account in the blockchain.
+func (b *SimulatedBackend) BalanceAt(ctx context.Context, contract common.Address, blockNumber *big.Int) (*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statedb, err := b.stateByBlockNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetBalance(contract), nil
+}
+
+// NonceAt returns the nonce of a certain account in the blockchain.
+func (b *SimulatedBackend) NonceAt(ctx context.Context, contract common.Address, blockNumber *big.Int) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statedb, err := b.stateByBlockNumber(blockNumber)
+	if err != nil {
+		return 0, err
+	}
+	return statedb.GetNonce(contract), nil
+}
+
+// StorageAt returns the value of key in the storage of an account in the blockchain.
+func (b *SimulatedBackend) StorageAt(ctx context.Context, contract common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statedb, err := b.stateByBlockNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	val := statedb.GetState(contract, key)
+	return val[:], nil
+}
+
+// TransactionReceipt returns the receipt of a transaction.
+func (b *SimulatedBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	receipt, _, _, _ := bc.GetReceipt(b.database, txHash)
+	if receipt == nil {
+		return nil, hpb_project.NotFound
+	}
+	return receipt, nil
+}
+
+// PendingCodeAt returns the code associated with an account in the pending state.
+func (b *SimulatedBackend) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.pendingState.GetCode(contract), nil
+}
+
+// PendingNonceAt implements PendingStateReader.PendingNonceAt, retrieving
+// the nonce currently pending for the account.
+func (b *SimulatedBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.pendingState.GetNonce(account), nil
+}
+
+// CallContract executes a contract call.
+func (b *SimulatedBackend) CallContract(ctx context.Context, call hpb_project.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statedb, err := b.stateByBlockNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), statedb)
+	return rval, err
+}
+
+// PendingCallContract executes a contract call on the pending state.
+func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call hpb_project.CallMsg) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rval, _, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState.Copy())
+	return rval, err
+}
+
+// SuggestGasPrice implements ContractTransactor.SuggestGasPrice. Since the simulated
+// chain doesn't have miners, we just return a gas price of 1 for any call.
+func (b *SimulatedBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+// EstimateGas executes the requested code against the pending block/state and
+// returns the used amount of gas.
+func (b *SimulatedBackend) EstimateGas(ctx context.Context, call hpb_project.CallMsg) (*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Binary search the gas requirement, as it may be higher than the amount used
+	var (
+		lo uint64 = config.TxGas - 1
+		hi uint64
+	)
+	if call.Gas != nil && call.Gas.Uint64() >= config.TxGas {
+		hi = call.Gas.Uint64()
+	} else {
+		hi = b.pendingBlock.GasLimit().Uint64()
+	}
+	executable := func(gas uint64) bool {
+		call.Gas = new(big.Int).SetUint64(gas)
+		_, _, failed, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState.Copy())
+		return err == nil && !failed
+	}
+	for lo+1 < hi {
+		mid := (hi + lo) / 2
+		if executable(mid) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	// Reject the transaction as invalid if it still fails at the highest allowance
+	if !executable(hi) {
+		return nil, errGasEstimationFailed
+	}
+	return new(big.Int).SetUint64(hi), nil
+}
+
+// callContract implements common code between normal and pending contract calls.
+// The state is modified during execution, make sure to copy it if necessary.
+func (b *SimulatedBackend) callContract(ctx context.Context, call hpb_project.CallMsg, block *types.Block, statedb *state.StateDB) ([]byte, *big.Int, bool, error) {
+	// Ensure message is initialized properly.
+	gas, gasPrice, value := call.Gas, call.GasPrice, call.Value
+	if gas == nil || gas.Sign() == 0 {
+		gas = block.GasLimit()
+	}
+	if gasPrice == nil {
+		gasPrice = big.NewInt(1)
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	// Set infinite balance to the fake caller account.
+	statedb.SetBalance(call.From, math.MaxBig256)
+
+	msg := types.NewMessage(call.From, call.To, 0, value, gas, gasPrice, call.Data, types.TxExdata{}, false)
+	context := hvm.NewEVMContext(msg, block.Header(), b.blockchain, nil)
+	vmenv := evm.NewEVM(context, statedb, b.config, evm.Config{})
+	gp := new(bc.GasPool).AddGas(math.MaxBig256)
+
+	return bc.ApplyMessage(vmenv, msg, gp)
+}
+
+// SendTransaction updates the pending block to include the given transaction.
+// It returns an error if the transaction is invalid.
+func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sender, err := types.Sender(types.MakeSigner(b.config), tx)
+	if err != nil {
+		return fmt.Errorf("invalid transaction: %v", err)
+	}
+	if nonce := b.pendingState.GetNonce(sender); tx.Nonce() != nonce {
+		return fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce)
+	}
+	txs := append(b.pendingBlock.Transactions(), tx)
+	block, statedb, err := b.buildBlock(txs)
+	if err != nil {
+		return err
+	}
+	b.pendingBlock, b.pendingState = block, statedb
+	return nil
+}
+
+// FilterLogs executes a log filter operation, blocking during execution and
+// returning all the results in one batch.
+func (b *SimulatedBackend) FilterLogs(ctx context.Context, query hpb_project.FilterQuery) ([]types.Log, error) {
+	// Initialize unset filter boundaries to run from genesis to chain head
+	from := int64(0)
+	if query.FromBlock != nil {
+		from = query.FromBlock.Int64()
+	}
+	to := int64(-1)
+	if query.ToBlock != nil {
+		to = query.ToBlock.Int64()
+	}
+	// Construct and execute the filter
+	filter := filters.New(&filterBackend{b.database, b.blockchain}, from, to, query.Addresses, query.Topics)
+
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]types.Log, len(logs))
+	for i, log := range logs {
+		res[i] = *log
+	}
+	return res, nil
+}
+
+// SubscribeFilterLogs creates a background log filtering operation, returning a
+// subscription immediately, which can be used to stream the found events.
+func (b *SimulatedBackend) SubscribeFilterLogs(ctx context.Context, query hpb_project.FilterQuery, ch chan<- types.Log) (hpb_project.Subscription, error) {
+	// Subscribe to contract events
+	sink := make(chan []*types.Log)
+
+	logsSub, err := b.events.SubscribeLogs(filters.FilterCriteria(query), sink)
+	if err != nil {
+		return nil, err
+	}
+	// Since we're getting logs in batches, we need to flatten them into a plain stream
+	return sub.NewSubscription(func(quit <-chan struct{}) error {
+		defer logsSub.Unsubscribe()
+		for {
+			select {
+			case logs := <-sink:
+				for _, log := range logs {
+					select {
+					case ch <- *log:
+					case err := <-logsSub.Err():
+						return err
+					case <-quit:
+						return nil
+					}
+				}
+			case err := <-logsSub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// filterBackend implements filters.Backend to support filtering for logs without
+// taking bloom-bits acceleration structures into account.
+type filterBackend struct {
+	db    hpbdb.Database
+	chain *bc.BlockChain
+}
+
+func (fb *filterBackend) ChainDb() hpbdb.Database { return fb.db }
+func (fb *filterBackend) EventMux() *sub.TypeMux  { panic("not supported") }
+
+func (fb *filterBackend) HeaderByNumber(ctx context.Context, block rpc.BlockNumber) (*types.Header, error) {
+	if block == rpc.LatestBlockNumber {
+		return fb.chain.CurrentBlock().Header(), nil
+	}
+	return fb.chain.GetHeaderByNumber(uint64(block.Int64())), nil
+}
+
+func (fb *filterBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return bc.GetBlockReceipts(fb.db, hash, bc.GetBlockNumber(fb.db, hash)), nil
+}
+
+func (fb *filterBackend) SubscribeTxPreEvent(ch chan<- bc.TxPreEvent) sub.Subscription {
+	return sub.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+func (fb *filterBackend) SubscribeChainEvent(ch chan<- bc.ChainEvent) sub.Subscription {
+	return fb.chain.SubscribeChainEvent(ch)
+}
+
+func (fb *filterBackend) SubscribeRemovedLogsEvent(ch chan<- bc.RemovedLogsEvent) sub.Subscription {
+	return fb.chain.SubscribeRemovedLogsEvent(ch)
+}
+
+func (fb *filterBackend) SubscribeLogsEvent(ch chan<- []*types.Log) sub.Subscription {
+	return fb.chain.SubscribeLogsEvent(ch)
+}
+
+func (fb *filterBackend) BloomStatus() (uint64, uint64) { return 4096, 0 }
+
+func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.MatcherSession) {
+	panic("not supported")
+}
+
+// simulatedChainConfig returns the rules of the simulated chain. It runs the
+// upgraded EVM from the genesis, while the Prometheus stages and the node and
+// vote contracts keep their mainnet switch blocks, so blocks are rewarded by
+// the stage I rules without any election or vote contract.
+func simulatedChainConfig() *config.ChainConfig {
+	return &config.ChainConfig{
+		ChainId:          big.NewInt(1337),
+		RealRandomBlock:  big.NewInt(0),
+		UpgradedEVMBlock: big.NewInt(0),
+		Prometheus: &config.PrometheusConfig{
+			Period: simulatedBlockPeriod,
+			Epoch:  config.DefaultPrometheusConfig.Epoch,
+		},
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 The hpb-project Authors
+// This file is part of the hpb-project library.
+//
+// The hpb-project library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The hpb-project library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the hpb-project library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hpb-project/go-hpb/account/abi"
+	"github.com/hpb-project/go-hpb/account/abi/bind"
+	bc "github.com/hpb-project/go-hpb/blockchain"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	hpb_project "github.com/hpb-project/go-hpb/interface"
+)
+
+// storeABI and storeBin describe a hand assembled contract storing a single
+// value: set(uint256) updates it and emits Set(uint256), get() returns it.
+const storeABI = `[
+	{"type":"function","name":"get","constant":true,"inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"set","inputs":[{"name":"value","type":"uint256"}],"outputs":[]},
+	{"type":"event","name":"Set","inputs":[{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+const storeBin = "0x604480600b6000396000f3" +
+	"3660041460385760043580600055600052" +
+	"7fdf7a95aebff315db1b7716215d602ab537373cdb769232aae6055c06e798425b" +
+	"60206000a1005b60005460005260206000f3"
+
+var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+func newTestBackend(t *testing.T) (*SimulatedBackend, *bind.TransactOpts) {
+	addr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := NewSimulatedBackend(bc.GenesisAlloc{addr: {Balance: big.NewInt(10000000000)}})
+	return sim, bind.NewKeyedTransactor(testKey, sim.config.ChainId)
+}
+
+func TestSimulatedBackendContract(t *testing.T) {
+	sim, auth := newTestBackend(t)
+	defer sim.Close()
+
+	parsed, err := abi.JSON(strings.NewReader(storeABI))
+	if err != nil {
+		t.Fatalf("failed to parse abi: %v", err)
+	}
+	addr, tx, contract, err := bind.DeployContract(auth, parsed, common.FromHex(storeBin), sim)
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	// The contract only exists in the pending state until committed
+	if code, _ := sim.CodeAt(context.Background(), addr, nil); len(code) != 0 {
+		t.Fatalf("contract code available before commit")
+	}
+	if code, _ := sim.PendingCodeAt(context.Background(), addr); len(code) == 0 {
+		t.Fatalf("contract code missing from pending state")
+	}
+	sim.Commit()
+
+	if deployed, err := bind.WaitDeployed(context.Background(), sim, tx); err != nil {
+		t.Fatalf("failed to wait for deployment: %v", err)
+	} else if deployed != addr {
+		t.Fatalf("deployed address mismatch: have %x, want %x", deployed, addr)
+	}
+	logs := make(chan types.Log, 1)
+	query := hpb_project.FilterQuery{Addresses: []common.Address{addr}}
+	logsSub, err := sim.SubscribeFilterLogs(context.Background(), query, logs)
+	if err != nil {
+		t.Fatalf("failed to subscribe to logs: %v", err)
+	}
+	defer logsSub.Unsubscribe()
+
+	if _, err := contract.Transact(auth, "set", big.NewInt(42)); err != nil {
+		t.Fatalf("failed to set value: %v", err)
+	}
+	var value *big.Int
+	if err := contract.Call(&bind.CallOpts{Pending: true}, &value, "get"); err != nil {
+		t.Fatalf("failed to call pending get: %v", err)
+	} else if value.Int64() != 42 {
+		t.Fatalf("pending value mismatch: have %v, want 42", value)
+	}
+	if err := contract.Call(nil, &value, "get"); err != nil {
+		t.Fatalf("failed to call get: %v", err)
+	} else if value.Sign() != 0 {
+		t.Fatalf("committed value mismatch: have %v, want 0", value)
+	}
+	sim.Commit()
+
+	if err := contract.Call(nil, &value, "get"); err != nil {
+		t.Fatalf("failed to call get: %v", err)
+	} else if value.Int64() != 42 {
+		t.Fatalf("committed value mismatch: have %v, want 42", value)
+	}
+	select {
+	case log := <-logs:
+		if new(big.Int).SetBytes(log.Data).Int64() != 42 {
+			t.Fatalf("streamed log data mismatch: have %x", log.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("log not streamed")
+	}
+	found, err := sim.FilterLogs(context.Background(), query)
+	if err != nil {
+		t.Fatalf("failed to filter logs: %v", err)
+	}
+	if len(found) != 1 || found[0].Topics[0] != parsed.Events["Set"].Id() {
+		t.Fatalf("filtered logs mismatch: %v", found)
+	}
+}
+
+func TestSimulatedBackendRollback(t *testing.T) {
+	sim, auth := newTestBackend(t)
+	defer sim.Close()
+
+	ctx := context.Background()
+	to := common.HexToAddress("0x0102")
+	tx := types.NewTransaction(0, to, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil, types.TxExdata{})
+	signed, err := auth.Signer(auth.From, tx)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err := sim.SendTransaction(ctx, signed); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	if nonce, _ := sim.PendingNonceAt(ctx, auth.From); nonce != 1 {
+		t.Fatalf("pending nonce mismatch: have %d, want 1", nonce)
+	}
+	if err := sim.SendTransaction(ctx, signed); err == nil {
+		t.Fatalf("duplicate transaction accepted")
+	}
+	sim.Rollback()
+
+	if nonce, _ := sim.PendingNonceAt(ctx, auth.From); nonce != 0 {
+		t.Fatalf("pending nonce mismatch after rollback: have %d, want 0", nonce)
+	}
+	// Shift the time of the next block and make sure the transfer is retained
+	if err := sim.SendTransaction(ctx, signed); err != nil {
+		t.Fatalf("failed to resend transaction: %v", err)
+	}
+	parent := sim.blockchain.CurrentBlock()
+	if err := sim.AdjustTime(time.Hour); err != nil {
+		t.Fatalf("failed to adjust time: %v", err)
+	}
+	sim.Commit()
+
+	head := sim.blockchain.CurrentBlock()
+	if diff := head.Time().Uint64() - parent.Time().Uint64(); diff != uint64(time.Hour/time.Second)+simulatedBlockPeriod {
+		t.Fatalf("block time shift mismatch: have %d", diff)
+	}
+	if balance, _ := sim.BalanceAt(ctx, to, nil); balance.Int64() != 1000 {
+		t.Fatalf("balance mismatch: have %v, want 1000", balance)
+	}
+	if _, err := sim.BalanceAt(ctx, to, common.Big0); err != errBlockNumberUnsupported {
+		t.Fatalf("historical state error mismatch: have %v, want %v", err, errBlockNumberUnsupported)
+	}
+}
+
+func TestSimulatedBackendRewards(t *testing.T) {
+	sim, _ := newTestBackend(t)
+	defer sim.Close()
+
+	// Stage I pays the coinbase 35% of two thirds of the yearly 3% issuance of
+	// 100M hpb, spread over the blocks of a year
+	blocks := new(big.Float).Quo(big.NewFloat(365*24*60*60), big.NewFloat(simulatedBlockPeriod))
+	want := new(big.Float).Quo(big.NewFloat(100000000*0.03), blocks)
+	want.Mul(want, big.NewFloat(2.0/3*0.35*1e18))
+
+	ctx := context.Background()
+	for i := int64(1); i <= 3; i++ {
+		sim.Commit()
+
+		balance, err := sim.BalanceAt(ctx, simulatedCoinbase, nil)
+		if err != nil {
+			t.Fatalf("failed to retrieve coinbase balance: %v", err)
+		}
+		expect := new(big.Float).Mul(want, big.NewFloat(float64(i)))
+		diff := new(big.Float).Sub(new(big.Float).SetInt(balance), expect)
+		if diff.Abs(diff).Cmp(big.NewFloat(1e6)) > 0 {
+			t.Fatalf("block %d: coinbase balance mismatch: have %v, want %v", i, balance, expect)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2016 The hpb-project Authors
+// This file is part of the hpb-project library.
+//
+// The hpb-project library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The hpb-project library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the hpb-project library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/account/abi"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/interface"
+	"github.com/hpb-project/go-hpb/network/rpc/hpbclient"
+)
+
+// The RPC client is the canonical contract backend.
+var _ ContractBackend = (*hpbclient.Client)(nil)
+
+const testContractABI = `
+[
+	{ "type" : "function", "name" : "balance", "stateMutability" : "view",
+	  "inputs" : [ { "name" : "owner", "type" : "address" } ], "outputs" : [ { "name" : "", "type" : "uint256" } ] },
+	{ "type" : "function", "name" : "info", "stateMutability" : "view",
+	  "inputs" : [], "outputs" : [ { "name" : "total", "type" : "uint64" }, { "name" : "", "type" : "string" } ] },
+	{ "type" : "function", "name" : "set", "stateMutability" : "nonpayable",
+	  "inputs" : [ { "name" : "value", "type" : "uint256" } ], "outputs" : [] },
+	{ "type" : "event", "name" : "Transfer", "anonymous" : false,
+	  "inputs" : [ { "name" : "from", "type" : "address", "indexed" : true }, { "name" : "value", "type" : "uint256", "indexed" : false } ] }
+]`
+
+// mockBackend is a contract backend returning canned answers and recording the
+// requests it receives.
+type mockBackend struct {
+	code   []byte
+	output []byte
+	nonce  uint64
+	logs   []types.Log
+
+	calls []hpb_project.CallMsg
+	sent  []*types.Transaction
+	query hpb_project.FilterQuery
+}
+
+func (b *mockBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return b.code, nil
+}
+
+func (b *mockBackend) CallContract(ctx context.Context, call hpb_project.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	b.calls = append(b.calls, call)
+	return b.output, nil
+}
+
+func (b *mockBackend) PendingCallContract(ctx context.Context, call hpb_project.CallMsg) ([]byte, error) {
+	b.calls = append(b.calls, call)
+	return b.output, nil
+}
+
+func (b *mockBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return b.code, nil
+}
+
+func (b *mockBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return b.nonce, nil
+}
+
+func (b *mockBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(18000000000), nil
+}
+
+func (b *mockBackend) EstimateGas(ctx context.Context, call hpb_project.CallMsg) (*big.Int, error) {
+	return big.NewInt(50000), nil
+}
+
+func (b *mockBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+func (b *mockBackend) FilterLogs(ctx context.Context, query hpb_project.FilterQuery) ([]types.Log, error) {
+	b.query = query
+	return b.logs, nil
+}
+
+func (b *mockBackend) SubscribeFilterLogs(ctx context.Context, query hpb_project.FilterQuery, ch chan<- types.Log) (hpb_project.Subscription, error) {
+	return nil, errors.New("not supported")
+}
+
+func newTestContract(t *testing.T, backend *mockBackend) (*BoundContract, abi.ABI) {
+	parsed, err := abi.JSON(strings.NewReader(testContractABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBoundContract(common.HexToAddress("0xc0de"), parsed, backend, backend, backend), parsed
+}
+
+func TestBoundContractCall(t *testing.T) {
+	backend := &mockBackend{code: []byte{0x60}}
+	contract, parsed := newTestContract(t, backend)
+
+	// Single return values are unpacked directly
+	backend.output, _ = parsed.Methods["balance"].Outputs.Pack(big.NewInt(42))
+	balance := new(*big.Int)
+	if err := contract.Call(nil, balance, "balance", common.HexToAddress("0x01")); err != nil {
+		t.Fatal(err)
+	}
+	if (*balance).Int64() != 42 {
+		t.Errorf("balance mismatch: have %v, want 42", *balance)
+	}
+	input, _ := parsed.Pack("balance", common.HexToAddress("0x01"))
+	if call := backend.calls[0]; *call.To != contract.Address() || !reflect.DeepEqual(call.Data, input) {
+		t.Errorf("call message mismatch: have %+v", call)
+	}
+	// Multiple return values are unpacked positionally
+	backend.output, _ = parsed.Methods["info"].Outputs.Pack(uint64(7), "hpb")
+	var (
+		total = new(uint64)
+		name  = new(string)
+	)
+	if err := contract.Call(nil, &[]interface{}{total, name}, "info"); err != nil {
+		t.Fatal(err)
+	}
+	if *total != 7 || *name != "hpb" {
+		t.Errorf("info mismatch: have %d, %q", *total, *name)
+	}
+	// Empty results from accounts without code must be reported
+	backend.output, backend.code = nil, nil
+	if err := contract.Call(nil, balance, "balance", common.Address{}); err != ErrNoCode {
+		t.Errorf("missing code error mismatch: have %v, want %v", err, ErrNoCode)
+	}
+	if err := contract.Call(&CallOpts{Pending: true}, balance, "balance", common.Address{}); err != ErrNoCode {
+		t.Errorf("missing pending code error mismatch: have %v, want %v", err, ErrNoCode)
+	}
+}
+
+func TestBoundContractTransact(t *testing.T) {
+	backend := &mockBackend{code: []byte{0x60}, nonce: 5}
+	contract, parsed := newTestContract(t, backend)
+
+	key, _ := crypto.GenerateKey()
+	opts := NewKeyedTransactor(key, big.NewInt(269))
+
+	tx, err := contract.Transact(opts, "set", big.NewInt(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.sent) != 1 || backend.sent[0] != tx {
+		t.Fatalf("transaction not sent to the backend")
+	}
+	input, _ := parsed.Pack("set", big.NewInt(3))
+	if tx.Nonce() != 5 || tx.Gas().Int64() != 50000 || *tx.To() != contract.Address() || !reflect.DeepEqual(tx.Data(), input) {
+		t.Errorf("transaction mismatch: nonce %d, gas %v, to %x, data %x", tx.Nonce(), tx.Gas(), tx.To(), tx.Data())
+	}
+	if _, r, s := tx.RawSignatureValues(); r.Sign() == 0 || s.Sign() == 0 {
+		t.Errorf("transaction not signed")
+	}
+	// Explicit options must be kept and others may not sign
+	opts.Nonce, opts.GasLimit = big.NewInt(9), big.NewInt(21000)
+	if tx, err = contract.Transact(opts, "set", big.NewInt(3)); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Nonce() != 9 || tx.Gas().Int64() != 21000 {
+		t.Errorf("explicit options ignored: nonce %d, gas %v", tx.Nonce(), tx.Gas())
+	}
+	opts.From = common.HexToAddress("0x01")
+	if _, err := contract.Transact(opts, "set", big.NewInt(3)); err == nil {
+		t.Errorf("signed a transaction for a foreign account")
+	}
+	// Deployments derive the contract address from the sender and nonce
+	opts = NewKeyedTransactor(key, big.NewInt(269))
+	address, tx, _, err := DeployContract(opts, parsed, []byte{0x60, 0x60}, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.To() != nil || address != crypto.CreateAddress(opts.From, 5) {
+		t.Errorf("deployment mismatch: to %v, address %x", tx.To(), address)
+	}
+}
+
+func TestBoundContractFilterLogs(t *testing.T) {
+	backend := new(mockBackend)
+	contract, parsed := newTestContract(t, backend)
+
+	event := parsed.Events["Transfer"]
+	from := common.HexToAddress("0x0102")
+	data, _ := event.Inputs.NonIndexed().Pack(big.NewInt(100))
+	backend.logs = []types.Log{{
+		Address: contract.Address(),
+		Topics:  []common.Hash{event.Id(), common.BytesToHash(from.Bytes())},
+		Data:    data,
+	}}
+	end := uint64(10)
+	logs, sub, err := contract.FilterLogs(&FilterOpts{Start: 1, End: &end}, "Transfer", []interface{}{from})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	want := [][]common.Hash{{event.Id()}, {common.BytesToHash(from.Bytes())}}
+	if !reflect.DeepEqual(backend.query.Topics, want) {
+		t.Errorf("filter topics mismatch: have %x, want %x", backend.query.Topics, want)
+	}
+	if backend.query.FromBlock.Uint64() != 1 || backend.query.ToBlock.Uint64() != 10 {
+		t.Errorf("filter range mismatch: have %v-%v", backend.query.FromBlock, backend.query.ToBlock)
+	}
+	var transfer struct {
+		From  common.Address
+		Value *big.Int
+	}
+	if err := contract.UnpackLog(&transfer, "Transfer", <-logs); err != nil {
+		t.Fatal(err)
+	}
+	if transfer.From != from || transfer.Value.Int64() != 100 {
+		t.Errorf("unpacked event mismatch: have %+v", transfer)
+	}
+	if _, _, err := contract.FilterLogs(nil, "Unknown"); err == nil {
+		t.Errorf("filtered unknown event")
+	}
+}
+
+func TestMakeTopics(t *testing.T) {
+	topics, err := makeTopics(
+		[]interface{}{common.HexToAddress("0x01"), big.NewInt(-1)},
+		[]interface{}{true, uint8(2), int32(-2)},
+		[]interface{}{"hpb", []byte("hpb"), [2]byte{0xca, 0xfe}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]common.Hash{
+		{common.HexToHash("0x01"), common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")},
+		{common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0xfffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe")},
+		{crypto.Keccak256Hash([]byte("hpb")), crypto.Keccak256Hash([]byte("hpb")), common.HexToHash("0xcafe000000000000000000000000000000000000000000000000000000000000")},
+	}
+	if !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics mismatch:\nhave %x\nwant %x", topics, want)
+	}
+	if _, err := makeTopics([]interface{}{struct{}{}}); err == nil {
+		t.Errorf("converted unsupported topic type")
+	}
+}
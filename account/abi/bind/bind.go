@@ -0,0 +1,261 @@
+// Copyright 2016 The hpb-project Authors
+// This file is part of the hpb-project library.
+//
+// The hpb-project library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The hpb-project library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the hpb-project library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bind generates Hpb contract Go bindings.
+//
+// The generated bindings operate on a ContractBackend, which hpbclient.Client
+// implements for a remote node.
+package bind
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/hpb-project/go-hpb/account/abi"
+)
+
+// Bind generates a Go wrapper around a contract ABI. This wrapper isn't meant
+// to be used as is in client code, but rather as an intermediate struct which
+// enforces compile time type safety and naming convention opposed to having to
+// manually maintain hard coded strings that break on runtime.
+func Bind(types []string, abis []string, bytecodes []string, pkg string) (string, error) {
+	if len(types) != len(abis) || len(types) != len(bytecodes) {
+		return "", fmt.Errorf("binding count mismatch: %d types, %d abis, %d bytecodes", len(types), len(abis), len(bytecodes))
+	}
+	// Process each individual contract requested binding
+	contracts := make(map[string]*tmplContract)
+	structs := make(map[string]*tmplStruct)
+
+	for i := 0; i < len(types); i++ {
+		// Parse the actual ABI to generate the binding for
+		evmABI, err := abi.JSON(strings.NewReader(abis[i]))
+		if err != nil {
+			return "", err
+		}
+		// Strip any whitespace from the JSON ABI
+		compact := new(bytes.Buffer)
+		if err := json.Compact(compact, []byte(abis[i])); err != nil {
+			return "", err
+		}
+		// Extract the call and transact methods; events; and sort them alphabetically
+		var (
+			calls     = make(map[string]*tmplMethod)
+			transacts = make(map[string]*tmplMethod)
+			events    = make(map[string]*tmplEvent)
+		)
+		for _, original := range evmABI.Methods {
+			// Normalize the method for capital cases and non-anonymous inputs/outputs
+			normalized := original
+			normalized.Name = capitalise(original.Name)
+
+			normalized.Inputs = normalizeArgs(original.Inputs, structs)
+			normalized.Outputs = make([]abi.Argument, len(original.Outputs))
+			copy(normalized.Outputs, original.Outputs)
+			for j, output := range normalized.Outputs {
+				if output.Name != "" {
+					normalized.Outputs[j].Name = capitalise(output.Name)
+				} else {
+					normalized.Outputs[j].Name = fmt.Sprintf("Arg%d", j)
+				}
+				bindStructType(output.Type, structs)
+			}
+			// Append the methods to the call or transact lists, constant methods
+			// without outputs have nothing to call for
+			if original.Const && len(original.Outputs) > 0 {
+				calls[original.Name] = &tmplMethod{Original: original, Normalized: normalized, Structured: len(normalized.Outputs) > 1}
+			} else {
+				transacts[original.Name] = &tmplMethod{Original: original, Normalized: normalized}
+			}
+		}
+		for _, original := range evmABI.Events {
+			// Normalize the event for capital cases and non-anonymous outputs
+			normalized := original
+			normalized.Name = capitalise(original.Name)
+
+			normalized.Inputs = make([]abi.Argument, len(original.Inputs))
+			copy(normalized.Inputs, original.Inputs)
+			for j, input := range normalized.Inputs {
+				// Indexed fields are input, non-indexed ones are outputs
+				if input.Name != "" {
+					normalized.Inputs[j].Name = capitalise(input.Name)
+				} else {
+					normalized.Inputs[j].Name = fmt.Sprintf("Arg%d", j)
+				}
+				bindStructType(input.Type, structs)
+			}
+			// Append the event to the accumulator list
+			events[original.Name] = &tmplEvent{Original: original, Normalized: normalized}
+		}
+		constructor := evmABI.Constructor
+		constructor.Inputs = normalizeArgs(constructor.Inputs, structs)
+
+		contracts[types[i]] = &tmplContract{
+			Type:        capitalise(types[i]),
+			InputABI:    strconv.Quote(compact.String()),
+			InputBin:    strings.TrimPrefix(strings.TrimSpace(bytecodes[i]), "0x"),
+			Constructor: constructor,
+			Calls:       calls,
+			Transacts:   transacts,
+			Events:      events,
+		}
+	}
+	// Generate the contract template data content and render it
+	data := &tmplData{
+		Package:   pkg,
+		Contracts: contracts,
+		Structs:   structs,
+	}
+	buffer := new(bytes.Buffer)
+
+	funcs := map[string]interface{}{
+		"bindtype":      bindType,
+		"bindtopictype": bindTopicType,
+		"capitalise":    capitalise,
+	}
+	tmpl := template.Must(template.New("").Funcs(funcs).Parse(tmplSource))
+	if err := tmpl.Execute(buffer, data); err != nil {
+		return "", err
+	}
+	// Pass the code through gofmt to clean it up
+	code, err := format.Source(buffer.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("%v\n%s", err, buffer)
+	}
+	return string(code), nil
+}
+
+// normalizeArgs names the anonymous arguments of a method and renames the ones
+// clashing with Go keywords, so they can be used as parameter names.
+func normalizeArgs(args abi.Arguments, structs map[string]*tmplStruct) abi.Arguments {
+	normalized := make([]abi.Argument, len(args))
+	copy(normalized, args)
+	for j, arg := range normalized {
+		if arg.Name == "" || token.Lookup(arg.Name).IsKeyword() {
+			normalized[j].Name = fmt.Sprintf("arg%d", j)
+		}
+		bindStructType(arg.Type, structs)
+	}
+	return normalized
+}
+
+// bindBasicType converts basic solidity types (except array, slice and tuple)
+// to Go ones.
+func bindBasicType(kind abi.Type) string {
+	switch kind.T {
+	case abi.AddressTy:
+		return "common.Address"
+	case abi.HashTy:
+		return "common.Hash"
+	case abi.IntTy, abi.UintTy:
+		switch kind.Kind {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return kind.Type.String()
+		}
+		return "*big.Int"
+	case abi.FixedBytesTy:
+		return fmt.Sprintf("[%d]byte", kind.Size)
+	case abi.BytesTy:
+		return "[]byte"
+	case abi.FunctionTy:
+		return "[24]byte"
+	default:
+		// string, bool types
+		return kind.String()
+	}
+}
+
+// bindType converts solidity types to Go ones, tuples are bound to the structs
+// collected by bindStructType.
+func bindType(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.TupleTy:
+		return structs[kind.TupleRawName+kind.String()].Name
+	case abi.ArrayTy:
+		return fmt.Sprintf("[%d]", kind.Size) + bindType(*kind.Elem, structs)
+	case abi.SliceTy:
+		return "[]" + bindType(*kind.Elem, structs)
+	default:
+		return bindBasicType(kind)
+	}
+}
+
+// bindTopicType converts solidity types to Go ones for indexed event arguments.
+// Dynamic types are only stored as the hash of their value in the topics.
+func bindTopicType(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return "common.Hash"
+	}
+	return bindType(kind, structs)
+}
+
+// bindStructType collects the Go structs needed to represent the tuples of a
+// solidity type, named after the solidity struct if known, and returns the Go
+// type of the solidity one.
+func bindStructType(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.TupleTy:
+		id := kind.TupleRawName + kind.String()
+		if s, exist := structs[id]; exist {
+			return s.Name
+		}
+		var fields []*tmplField
+		for i, elem := range kind.TupleElems {
+			fields = append(fields, &tmplField{
+				Type:    bindStructType(*elem, structs),
+				Name:    abi.ToCamelCase(kind.TupleRawNames[i]),
+				SolKind: *elem,
+			})
+		}
+		name := abi.ToCamelCase(kind.TupleRawName)
+		if name == "" {
+			name = "Struct"
+		}
+		for _, s := range structs {
+			if s.Name == name {
+				name = fmt.Sprintf("%s%d", name, len(structs))
+				break
+			}
+		}
+		structs[id] = &tmplStruct{Name: name, Fields: fields}
+		return name
+	case abi.ArrayTy:
+		return fmt.Sprintf("[%d]", kind.Size) + bindStructType(*kind.Elem, structs)
+	case abi.SliceTy:
+		return "[]" + bindStructType(*kind.Elem, structs)
+	default:
+		return bindBasicType(kind)
+	}
+}
+
+// capitalise makes a camel-case string which starts with an upper case character.
+func capitalise(input string) string {
+	for len(input) > 0 && input[0] == '_' {
+		input = input[1:]
+	}
+	if len(input) == 0 {
+		return ""
+	}
+	return string(unicode.ToUpper(rune(input[0]))) + input[1:]
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 The hpb-project Authors
+// This file is part of the hpb-project library.
+//
+// The hpb-project library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The hpb-project library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the hpb-project library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/consensus"
+)
+
+const structABI = `
+[
+	{ "type" : "constructor", "inputs" : [ { "name" : "type", "type" : "uint8" } ] },
+	{ "type" : "function", "name" : "getOrder", "stateMutability" : "view",
+	  "inputs" : [ { "name" : "id", "type" : "uint256" } ],
+	  "outputs" : [ { "name" : "", "type" : "tuple", "internalType" : "struct Shop.Order",
+	                  "components" : [ { "name" : "buyer", "type" : "address" },
+	                                   { "name" : "items", "type" : "tuple[]", "internalType" : "struct Shop.Item[]",
+	                                     "components" : [ { "name" : "sku", "type" : "bytes32" }, { "name" : "amount", "type" : "uint32" } ] } ] } ] },
+	{ "type" : "function", "name" : "place", "stateMutability" : "payable",
+	  "inputs" : [ { "name" : "items", "type" : "tuple[]", "internalType" : "struct Shop.Item[]",
+	                 "components" : [ { "name" : "sku", "type" : "bytes32" }, { "name" : "amount", "type" : "uint32" } ] } ],
+	  "outputs" : [] },
+	{ "type" : "event", "name" : "Placed", "anonymous" : false,
+	  "inputs" : [ { "name" : "buyer", "type" : "address", "indexed" : true },
+	               { "name" : "note", "type" : "string", "indexed" : true },
+	               { "name" : "total", "type" : "uint256", "indexed" : false } ] }
+]`
+
+// Tests that the generated bindings are valid Go and contain the typed methods
+// of the contracts.
+func TestBindGoSource(t *testing.T) {
+	code, err := Bind([]string{"ballot", "Shop"}, []string{consensus.FechHpbBallotAddrABI, structABI}, []string{"", "0x6060"}, "contracts")
+	if err != nil {
+		t.Fatalf("failed to generate bindings: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", code, parser.AllErrors); err != nil {
+		t.Fatalf("generated bindings are invalid: %v\n%s", err, code)
+	}
+	want := []string{
+		"package contracts",
+		"func NewBallot(address common.Address, backend bind.ContractBackend) (*Ballot, error)",
+		"func (_Ballot *BallotCaller) GetRoundNum(opts *bind.CallOpts) (*big.Int, error)",
+		"func (_Ballot *BallotTransactor) SetRoundNum(opts *bind.TransactOpts, _roundNum *big.Int) (*types.Transaction, error)",
+		"func (_Ballot *BallotFilterer) FilterSetFunStr(opts *bind.FilterOpts, From []common.Address, FunStr []common.Hash) (*BallotSetFunStrIterator, error)",
+		"func (_Ballot *BallotFilterer) WatchSetRoundNum(opts *bind.WatchOpts, sink chan<- *BallotSetRoundNum, RoundNum []*big.Int) (sub.Subscription, error)",
+		"func DeployShop(auth *bind.TransactOpts, backend bind.ContractBackend, arg0 uint8) (common.Address, *types.Transaction, *Shop, error)",
+		"type Order struct",
+		"Items []Item",
+		"func (_Shop *ShopCaller) GetOrder(opts *bind.CallOpts, id *big.Int) (Order, error)",
+		"func (_Shop *ShopTransactor) Place(opts *bind.TransactOpts, items []Item) (*types.Transaction, error)",
+		"func (_Shop *ShopFilterer) ParsePlaced(log types.Log) (*ShopPlaced, error)",
+	}
+	for _, decl := range want {
+		if !strings.Contains(code, decl) {
+			t.Errorf("generated bindings miss %q", decl)
+		}
+	}
+	if strings.Contains(code, "BallotBin") {
+		t.Errorf("deploy code generated without bytecode")
+	}
+}
+
+func TestBindCountMismatch(t *testing.T) {
+	if _, err := Bind([]string{"a", "b"}, []string{"[]"}, []string{""}, "contracts"); err == nil {
+		t.Errorf("bound mismatched inputs")
+	}
+}
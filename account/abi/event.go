@@ -17,13 +17,21 @@
 package abi
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 
+	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
 	"github.com/hpb-project/go-hpb/common/crypto"
 )
 
+var (
+	errNoEventSignature       = errors.New("no event signature")
+	errEventSignatureMismatch = errors.New("event signature mismatch")
+)
+
 // Event is an event potentially triggered by the EVM's LOG mechanism. The Event
 // holds type information (inputs) about the yielded output. Anonymous events
 // don't get the signature canonical representation as the first LOG topic.
@@ -55,3 +63,90 @@ func (e Event) Id() common.Hash {
 	}
 	return common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("%v(%v)", e.Name, strings.Join(types, ",")))))
 }
+
+// UnpackLog unpacks a log emitted by the event into out, a pointer to a struct.
+// The non-indexed arguments are decoded from the log data, the indexed ones
+// from the topics. Indexed arguments of dynamic types (strings, bytes, arrays
+// and tuples) are only stored as their hash, so they are unpacked as a hash.
+func (e Event) UnpackLog(out interface{}, log types.Log) error {
+	topics, err := e.logTopics(log)
+	if err != nil {
+		return err
+	}
+	if len(log.Data) > 0 && e.Inputs.LengthNonIndexed() > 0 {
+		if err := e.Inputs.Unpack(out, log.Data); err != nil {
+			return err
+		}
+	}
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("abi: cannot unpack log in to %T", out)
+	}
+	value = value.Elem()
+	for i, arg := range e.Inputs.Indexed() {
+		field := structFieldByArgName(value, arg.Name)
+		if !field.IsValid() {
+			return fmt.Errorf("abi: field %s can't be found in the given value", arg.Name)
+		}
+		topic, err := unpackTopic(arg.Type, topics[i])
+		if err != nil {
+			return err
+		}
+		if err := set(field, reflect.ValueOf(topic), arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnpackLogIntoMap unpacks a log emitted by the event into the provided map,
+// keyed by the argument names.
+func (e Event) UnpackLogIntoMap(out map[string]interface{}, log types.Log) error {
+	topics, err := e.logTopics(log)
+	if err != nil {
+		return err
+	}
+	if len(log.Data) > 0 && e.Inputs.LengthNonIndexed() > 0 {
+		if err := e.Inputs.UnpackIntoMap(out, log.Data); err != nil {
+			return err
+		}
+	}
+	for i, arg := range e.Inputs.Indexed() {
+		topic, err := unpackTopic(arg.Type, topics[i])
+		if err != nil {
+			return err
+		}
+		out[arg.Name] = topic
+	}
+	return nil
+}
+
+// logTopics checks that a log was emitted by the event and returns the topics
+// holding its indexed arguments.
+func (e Event) logTopics(log types.Log) ([]common.Hash, error) {
+	topics := log.Topics
+	if !e.Anonymous {
+		if len(topics) == 0 {
+			return nil, errNoEventSignature
+		}
+		if topics[0] != e.Id() {
+			return nil, errEventSignatureMismatch
+		}
+		topics = topics[1:]
+	}
+	if indexed := len(e.Inputs.Indexed()); len(topics) != indexed {
+		return nil, fmt.Errorf("abi: topic count mismatch: have %d, want %d", len(topics), indexed)
+	}
+	return topics, nil
+}
+
+// unpackTopic decodes the value of an indexed argument from its topic.
+func unpackTopic(t Type, topic common.Hash) (interface{}, error) {
+	switch t.T {
+	case StringTy, BytesTy, SliceTy, ArrayTy, TupleTy:
+		// dynamic and composite types are stored as the hash of their encoding
+		return topic, nil
+	default:
+		return toGoType(0, t, topic.Bytes())
+	}
+}
@@ -81,12 +81,63 @@ func set(dst, src reflect.Value, output Argument) error {
 		dst.Set(src)
 	case dstType.Kind() == reflect.Ptr:
 		return set(dst.Elem(), src, output)
+	case dstType.Kind() == reflect.Slice && srcType.Kind() == reflect.Slice:
+		// slices of tuples are unpacked into slices of the anonymous struct
+		slice := reflect.MakeSlice(dstType, src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			if err := set(slice.Index(i), src.Index(i), output); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+	case dstType.Kind() == reflect.Array && srcType.Kind() == reflect.Array && dst.Len() == src.Len():
+		for i := 0; i < src.Len(); i++ {
+			if err := set(dst.Index(i), src.Index(i), output); err != nil {
+				return err
+			}
+		}
+	case dstType.Kind() == reflect.Struct && srcType.Kind() == reflect.Struct:
+		return setStruct(dst, src, output)
 	default:
 		return fmt.Errorf("abi: cannot unmarshal %v in to %v", src.Type(), dst.Type())
 	}
 	return nil
 }
 
+// setStruct assigns the fields of a tuple to the matching fields of dst. The
+// fields of the anonymous tuple struct carry the raw component names as json
+// tags, which are looked up in dst like argument names.
+func setStruct(dst, src reflect.Value, output Argument) error {
+	srcType := src.Type()
+	for i := 0; i < srcType.NumField(); i++ {
+		name := srcType.Field(i).Tag.Get("json")
+		field := structFieldByArgName(dst, name)
+		if !field.IsValid() {
+			return fmt.Errorf("abi: field %s can't be found in the given value", name)
+		}
+		if err := set(field, src.Field(i), output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structFieldByArgName returns the field of a struct value an abi argument or
+// tuple component maps to: the one tagged with `abi:"name"`, or otherwise the
+// one with the camel cased or the capitalised name.
+func structFieldByArgName(value reflect.Value, name string) reflect.Value {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		if tag, ok := typ.Field(i).Tag.Lookup("abi"); ok && tag == name {
+			return value.Field(i)
+		}
+	}
+	if field := value.FieldByName(ToCamelCase(name)); field.IsValid() {
+		return field
+	}
+	return value.FieldByName(capitalise(name))
+}
+
 // requireAssignable assures that `dest` is a pointer and it's not an interface.
 func requireAssignable(dst, src reflect.Value) error {
 	if dst.Kind() != reflect.Ptr && dst.Kind() != reflect.Interface {
@@ -148,7 +199,7 @@ func mapAbiToStructFields(args Arguments, value reflect.Value) (map[string]strin
 
 		// check which argument field matches with the abi tag.
 		found := false
-		for _, abiField := range args.NonIndexed() {
+		for _, abiField := range args {
 			if abiField.Name == tagName {
 				if abi2struct[abiField.Name] != "" {
 					return nil, fmt.Errorf("struct: abi tag in '%s' already mapped", structFieldName)
@@ -0,0 +1,274 @@
+// Copyright 2016 The hpb-project Authors
+// This file is part of the hpb-project library.
+//
+// The hpb-project library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The hpb-project library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the hpb-project library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+)
+
+const tupleABI = `
+[
+	{ "type" : "function", "name" : "simple", "stateMutability" : "pure",
+	  "inputs" : [ { "name" : "s", "type" : "tuple", "internalType" : "struct Test.Simple",
+	                 "components" : [ { "name" : "a", "type" : "uint256" }, { "name" : "b", "type" : "string" } ] } ],
+	  "outputs" : [ { "name" : "s", "type" : "tuple", "internalType" : "struct Test.Simple",
+	                 "components" : [ { "name" : "a", "type" : "uint256" }, { "name" : "b", "type" : "string" } ] } ] },
+	{ "type" : "function", "name" : "nested", "stateMutability" : "view",
+	  "inputs" : [ { "name" : "n", "type" : "tuple", "internalType" : "struct Test.Nested",
+	                 "components" : [ { "name" : "id", "type" : "uint64" },
+	                                  { "name" : "entries", "type" : "tuple[]", "internalType" : "struct Test.Entry[]",
+	                                    "components" : [ { "name" : "owner", "type" : "address" }, { "name" : "amounts", "type" : "uint8[]" } ] },
+	                                  { "name" : "pair", "type" : "tuple[2]", "internalType" : "struct Test.Pair[2]",
+	                                    "components" : [ { "name" : "x", "type" : "int32" }, { "name" : "y", "type" : "bool" } ] } ] },
+	               { "name" : "flag", "type" : "bool" } ],
+	  "outputs" : [ { "name" : "n", "type" : "tuple", "internalType" : "struct Test.Nested",
+	                 "components" : [ { "name" : "id", "type" : "uint64" },
+	                                  { "name" : "entries", "type" : "tuple[]", "internalType" : "struct Test.Entry[]",
+	                                    "components" : [ { "name" : "owner", "type" : "address" }, { "name" : "amounts", "type" : "uint8[]" } ] },
+	                                  { "name" : "pair", "type" : "tuple[2]", "internalType" : "struct Test.Pair[2]",
+	                                    "components" : [ { "name" : "x", "type" : "int32" }, { "name" : "y", "type" : "bool" } ] } ] },
+	               { "name" : "flag", "type" : "bool" } ] },
+	{ "type" : "event", "name" : "Transfer", "anonymous" : false,
+	  "inputs" : [ { "name" : "from", "type" : "address", "indexed" : true },
+	               { "name" : "to", "type" : "address", "indexed" : true },
+	               { "name" : "memo", "type" : "string", "indexed" : true },
+	               { "name" : "value", "type" : "uint256", "indexed" : false },
+	               { "name" : "note", "type" : "string", "indexed" : false } ] },
+	{ "type" : "error", "name" : "Insufficient",
+	  "inputs" : [ { "name" : "available", "type" : "uint256" }, { "name" : "required", "type" : "uint256" } ] }
+]`
+
+type tupleSimple struct {
+	A *big.Int
+	B string
+}
+
+type tupleEntry struct {
+	Owner   common.Address
+	Amounts []uint8
+}
+
+type tuplePair struct {
+	X int32
+	Y bool `abi:"y"`
+}
+
+type tupleNested struct {
+	Id      uint64
+	Entries []tupleEntry
+	Pair    [2]tuplePair
+}
+
+func TestTupleTypes(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig := abi.Methods["simple"].Sig(); sig != "simple((uint256,string))" {
+		t.Errorf("simple signature mismatch: have %s", sig)
+	}
+	if sig := abi.Methods["nested"].Sig(); sig != "nested((uint64,(address,uint8[])[],(int32,bool)[2]),bool)" {
+		t.Errorf("nested signature mismatch: have %s", sig)
+	}
+	if !abi.Methods["simple"].Const || !abi.Methods["nested"].Const {
+		t.Errorf("pure and view methods should be constant")
+	}
+	if name := abi.Methods["simple"].Inputs[0].Type.TupleRawName; name != "Simple" {
+		t.Errorf("tuple raw name mismatch: have %q, want %q", name, "Simple")
+	}
+}
+
+func TestTuplePack(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed, err := abi.Pack("simple", tupleSimple{A: big.NewInt(1), B: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000020" + // tuple offset
+		"0000000000000000000000000000000000000000000000000000000000000001" + // a
+		"0000000000000000000000000000000000000000000000000000000000000040" + // b offset within tuple
+		"0000000000000000000000000000000000000000000000000000000000000003" + // b length
+		"6162630000000000000000000000000000000000000000000000000000000000") // b
+	if !bytes.Equal(packed[:4], abi.Methods["simple"].Id()) {
+		t.Fatalf("method id mismatch: have %x", packed[:4])
+	}
+	if !bytes.Equal(packed[4:], want) {
+		t.Fatalf("packed tuple mismatch:\nhave %x\nwant %x", packed[4:], want)
+	}
+	var out tupleSimple
+	if err := abi.Unpack(&out, "simple", packed[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if out.A.Cmp(big.NewInt(1)) != 0 || out.B != "abc" {
+		t.Fatalf("unpacked tuple mismatch: have %+v", out)
+	}
+}
+
+func TestNestedTupleRoundTrip(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := tupleNested{
+		Id: 7,
+		Entries: []tupleEntry{
+			{Owner: common.HexToAddress("0x01"), Amounts: []uint8{1, 2, 3}},
+			{Owner: common.HexToAddress("0x02"), Amounts: []uint8{}},
+		},
+		Pair: [2]tuplePair{{X: -5, Y: true}, {X: 9, Y: false}},
+	}
+	packed, err := abi.Pack("nested", in, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		N    tupleNested
+		Flag bool
+	}
+	if err := abi.Unpack(&out, "nested", packed[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.N, in) || !out.Flag {
+		t.Fatalf("round trip mismatch:\nhave %+v\nwant %+v", out.N, in)
+	}
+	values := make(map[string]interface{})
+	if err := abi.UnpackIntoMap(values, "nested", packed[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if values["flag"] != true {
+		t.Errorf("flag mismatch: have %v", values["flag"])
+	}
+	if id := reflect.ValueOf(values["n"]).FieldByName("Id").Uint(); id != 7 {
+		t.Errorf("id mismatch: have %d", id)
+	}
+}
+
+func TestUnpackLog(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := abi.Events["Transfer"]
+	data, err := event.Inputs.NonIndexed().Pack(big.NewInt(1000), "thanks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		from = common.HexToAddress("0x0102")
+		to   = common.HexToAddress("0x0304")
+		memo = crypto.Keccak256Hash([]byte("rent"))
+	)
+	log := types.Log{
+		Topics: []common.Hash{event.Id(), common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes()), memo},
+		Data:   data,
+	}
+	var out struct {
+		From  common.Address
+		To    common.Address
+		Memo  common.Hash
+		Value *big.Int
+		Text  string `abi:"note"`
+	}
+	if err := abi.UnpackLog(&out, log); err != nil {
+		t.Fatal(err)
+	}
+	if out.From != from || out.To != to || out.Memo != memo || out.Value.Int64() != 1000 || out.Text != "thanks" {
+		t.Fatalf("unpacked log mismatch: have %+v", out)
+	}
+	values := make(map[string]interface{})
+	if err := abi.UnpackLogIntoMap(values, log); err != nil {
+		t.Fatal(err)
+	}
+	if values["from"] != from || values["memo"] != memo || values["note"] != "thanks" {
+		t.Fatalf("unpacked log map mismatch: have %v", values)
+	}
+	// Logs of other events and truncated topics must be rejected
+	log.Topics[0] = common.Hash{}
+	if err := event.UnpackLog(&out, log); err != errEventSignatureMismatch {
+		t.Errorf("signature mismatch error: have %v, want %v", err, errEventSignatureMismatch)
+	}
+	log.Topics = []common.Hash{event.Id(), common.BytesToHash(from.Bytes())}
+	if err := event.UnpackLog(&out, log); err == nil {
+		t.Errorf("expected error for missing topics")
+	}
+}
+
+func TestUnpackRevert(t *testing.T) {
+	tests := []struct {
+		data   string
+		reason string
+		fail   bool
+	}{
+		// Error(string) with "insufficient balance"
+		{"08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"0000000000000000000000000000000000000000000000000000000000000014" +
+			"696e73756666696369656e742062616c616e6365000000000000000000000000", "insufficient balance", false},
+		// Panic(uint256) with a division by zero
+		{"4e487b71" + "0000000000000000000000000000000000000000000000000000000000000012", "division or modulo by zero", false},
+		{"4e487b71" + "00000000000000000000000000000000000000000000000000000000000000ff", "unknown panic code: 0xff", false},
+		{"", "", true},
+		{"deadbeef", "", true},
+	}
+	for i, tt := range tests {
+		data, _ := hex.DecodeString(tt.data)
+		reason, err := UnpackRevert(data)
+		if (err != nil) != tt.fail {
+			t.Errorf("test %d: error mismatch: %v", i, err)
+			continue
+		}
+		if reason != tt.reason {
+			t.Errorf("test %d: reason mismatch: have %q, want %q", i, reason, tt.reason)
+		}
+	}
+}
+
+func TestCustomError(t *testing.T) {
+	abi, err := JSON(strings.NewReader(tupleABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := abi.Errors["Insufficient"]
+	args, err := custom.Inputs.Pack(big.NewInt(1), big.NewInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(crypto.Keccak256([]byte("Insufficient(uint256,uint256)"))[:4], args...)
+	found, err := abi.ErrorById(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := found.Unpack(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].(*big.Int).Int64() != 1 || values[1].(*big.Int).Int64() != 2 {
+		t.Fatalf("error arguments mismatch: have %v", values)
+	}
+}
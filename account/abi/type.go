@@ -22,6 +22,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 // Type enumerator
@@ -38,6 +39,7 @@ const (
 	HashTy
 	FixedPointTy
 	FunctionTy
+	TupleTy
 )
 
 // Type is the reflection of the supported argument type
@@ -50,6 +52,11 @@ type Type struct {
 	T    byte // Our own type checking
 
 	stringKind string // holds the unparsed string for deriving signatures
+
+	// Tuple relative fields
+	TupleRawName  string   // Raw struct name defined in source code, may be empty.
+	TupleElems    []*Type  // Type information of all tuple fields
+	TupleRawNames []string // Raw field name of all tuple fields
 }
 
 var (
@@ -59,6 +66,14 @@ var (
 
 // NewType creates a new reflection type of abi type given in t.
 func NewType(t string) (typ Type, err error) {
+	return NewComponentType(t, "", nil)
+}
+
+// NewComponentType creates a new reflection type of abi type given in t. The
+// components describe the fields of tuple types (or arrays of tuples), while the
+// internal type is the type name assigned by the compiler, used to derive the
+// name of the struct backing a tuple.
+func NewComponentType(t string, internalType string, components []ArgumentMarshaling) (typ Type, err error) {
 	// check that array brackets are equal if they exist
 	if strings.Count(t, "[") != strings.Count(t, "]") {
 		return Type{}, fmt.Errorf("invalid arg type in abi")
@@ -70,13 +85,19 @@ func NewType(t string) (typ Type, err error) {
 	// recursively create the type
 	if strings.Count(t, "[") != 0 {
 		i := strings.LastIndex(t, "[")
+		// The internal type of the elements drops the array suffix too
+		if j := strings.LastIndex(internalType, "["); j >= 0 {
+			internalType = internalType[:j]
+		}
 		// recursively embed the type
-		embeddedType, err := NewType(t[:i])
+		embeddedType, err := NewComponentType(t[:i], internalType, components)
 		if err != nil {
 			return Type{}, err
 		}
 		// grab the last cell and create a type from there
 		sliced := t[i:]
+		// tuples are represented by their components in the signature
+		typ.stringKind = embeddedType.stringKind + sliced
 		// grab the slice size with regexp
 		re := regexp.MustCompile("[0-9]+")
 		intz := re.FindAllString(sliced, -1)
@@ -163,6 +184,52 @@ func NewType(t string) (typ Type, err error) {
 		typ.T = FunctionTy
 		typ.Size = 24
 		typ.Type = reflect.ArrayOf(24, reflect.TypeOf(byte(0)))
+	case "tuple":
+		var (
+			fields []reflect.StructField
+			elems  []*Type
+			names  []string
+			kinds  []string
+			used   = make(map[string]bool)
+		)
+		for idx, c := range components {
+			cType, err := NewComponentType(c.Type, c.InternalType, c.Components)
+			if err != nil {
+				return Type{}, err
+			}
+			fieldName := ToCamelCase(c.Name)
+			if !isValidFieldName(fieldName) {
+				return Type{}, fmt.Errorf("abi: tuple field %d has invalid name %q", idx, c.Name)
+			}
+			if used[fieldName] {
+				return Type{}, fmt.Errorf("abi: duplicate tuple field name %q", fieldName)
+			}
+			used[fieldName] = true
+
+			fields = append(fields, reflect.StructField{
+				Name: fieldName,
+				Type: cType.Type,
+				Tag:  reflect.StructTag(fmt.Sprintf("json:%q", c.Name)),
+			})
+			elems = append(elems, &cType)
+			names = append(names, c.Name)
+			kinds = append(kinds, cType.stringKind)
+		}
+		typ.Kind = reflect.Struct
+		typ.Type = reflect.StructOf(fields)
+		typ.TupleElems = elems
+		typ.TupleRawNames = names
+		typ.T = TupleTy
+		typ.stringKind = "(" + strings.Join(kinds, ",") + ")"
+
+		// The compiler names struct types as "struct Contract.Name"
+		if strings.HasPrefix(internalType, "struct ") {
+			name := strings.TrimPrefix(internalType, "struct ")
+			if i := strings.LastIndex(name, "."); i >= 0 {
+				name = name[i+1:]
+			}
+			typ.TupleRawName = name
+		}
 	default:
 		return Type{}, fmt.Errorf("unsupported arg type: %s", t)
 	}
@@ -183,21 +250,64 @@ func (t Type) pack(v reflect.Value) ([]byte, error) {
 		return nil, err
 	}
 
-	if t.T == SliceTy || t.T == ArrayTy {
-		var packed []byte
+	switch t.T {
+	case SliceTy, ArrayTy:
+		var ret []byte
 
+		if t.requiresLengthPrefix() {
+			// append length
+			ret = append(ret, packNum(reflect.ValueOf(v.Len()))...)
+		}
+		// Dynamic elements are referenced by their offset, with the contents
+		// appended after the heads of all the elements
+		var (
+			offset    = 0
+			offsetReq = isDynamicType(*t.Elem)
+			tail      []byte
+		)
+		if offsetReq {
+			offset = getTypeSize(*t.Elem) * v.Len()
+		}
 		for i := 0; i < v.Len(); i++ {
 			val, err := t.Elem.pack(v.Index(i))
 			if err != nil {
 				return nil, err
 			}
-			packed = append(packed, val...)
+			if !offsetReq {
+				ret = append(ret, val...)
+				continue
+			}
+			ret = append(ret, packNum(reflect.ValueOf(offset))...)
+			offset += len(val)
+			tail = append(tail, val...)
+		}
+		return append(ret, tail...), nil
+
+	case TupleTy:
+		// The heads of all fields come first, followed by the dynamic contents
+		offset := 0
+		for _, elem := range t.TupleElems {
+			offset += getTypeSize(*elem)
 		}
-		if t.T == SliceTy {
-			return packBytesSlice(packed, v.Len()), nil
-		} else if t.T == ArrayTy {
-			return packed, nil
+		var ret, tail []byte
+		for i, elem := range t.TupleElems {
+			field := structFieldByArgName(v, t.TupleRawNames[i])
+			if !field.IsValid() {
+				return nil, fmt.Errorf("abi: field %s for tuple not found in the given struct", t.TupleRawNames[i])
+			}
+			val, err := elem.pack(field)
+			if err != nil {
+				return nil, err
+			}
+			if isDynamicType(*elem) {
+				ret = append(ret, packNum(reflect.ValueOf(offset))...)
+				tail = append(tail, val...)
+				offset += len(val)
+			} else {
+				ret = append(ret, val...)
+			}
 		}
+		return append(ret, tail...), nil
 	}
 	return packElement(t, v), nil
 }
@@ -207,3 +317,53 @@ func (t Type) pack(v reflect.Value) ([]byte, error) {
 func (t Type) requiresLengthPrefix() bool {
 	return t.T == StringTy || t.T == BytesTy || t.T == SliceTy
 }
+
+// isValidFieldName checks if a string is a valid exported Go identifier, the
+// name of a field of the struct backing a tuple.
+func isValidFieldName(fieldName string) bool {
+	for i, c := range fieldName {
+		if i == 0 && !unicode.IsUpper(c) {
+			return false
+		}
+		if !(unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_') {
+			return false
+		}
+	}
+	return len(fieldName) > 0
+}
+
+// isDynamicType returns true if the type is dynamic, meaning its encoding is
+// referenced by an offset instead of being stored in place. The dynamic types
+// are strings, bytes, slices, arrays of dynamic types and tuples containing a
+// dynamic type.
+func isDynamicType(t Type) bool {
+	if t.T == TupleTy {
+		for _, elem := range t.TupleElems {
+			if isDynamicType(*elem) {
+				return true
+			}
+		}
+		return false
+	}
+	return t.T == StringTy || t.T == BytesTy || t.T == SliceTy || (t.T == ArrayTy && isDynamicType(*t.Elem))
+}
+
+// getTypeSize returns the size of the type when stored in place. Static arrays
+// and tuples take the size of all their elements, everything else (including
+// the offset of dynamic types) is a single 32 byte word.
+func getTypeSize(t Type) int {
+	if t.T == ArrayTy && !isDynamicType(*t.Elem) {
+		// Recursively calculate type size if it is a nested array
+		if t.Elem.T == ArrayTy || t.Elem.T == TupleTy {
+			return t.Size * getTypeSize(*t.Elem)
+		}
+		return t.Size * 32
+	} else if t.T == TupleTy && !isDynamicType(t) {
+		total := 0
+		for _, elem := range t.TupleElems {
+			total += getTypeSize(*elem)
+		}
+		return total
+	}
+	return 32
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tests that HD derivation paths can be correctly parsed into our internal binary
+// representation.
+func TestHDPathParsing(t *testing.T) {
+	tests := []struct {
+		input  string
+		output DerivationPath
+	}{
+		// Plain absolute derivation paths
+		{"m/44'/269'/0'/0", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0}},
+		{"m/44'/269'/0'/128", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 128}},
+		{"m/44'/269'/0'/0'", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0x80000000 + 0}},
+		{"m/44'/269'/0'/128'", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0x80000000 + 128}},
+		{"m/2147483692/2147483917/2147483648/0", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0}},
+		{"m/2147483692/2147483917/2147483648/2147483648", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0x80000000 + 0}},
+
+		// Plain relative derivation paths
+		{"0", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0, 0}},
+		{"128", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0, 128}},
+		{"0'", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0, 0x80000000 + 0}},
+		{"128'", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0, 0x80000000 + 128}},
+
+		// Hexadecimal absolute derivation paths
+		{"m/0x2C'/0x10D'/0x00'/0x00", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0}},
+		{"m/0x8000002C/0x8000010D/0x80000000/0x80000000", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0x80000000 + 0}},
+
+		// Weird inputs just to ensure they work
+		{"	m  /   44			'\n/\n   269	\n\n\t'   /\n0 ' /\t\t	0", DerivationPath{0x80000000 + 44, 0x80000000 + 269, 0x80000000 + 0, 0}},
+
+		// Invaid derivation paths
+		{"", nil},               // Empty relative derivation path
+		{"m", nil},              // Empty absolute derivation path
+		{"m/", nil},             // Missing last derivation component
+		{"/44'/269'/0'/0", nil}, // Absolute path without m prefix, might be user error
+		{"m/2147483648'", nil},  // Overflows 32 bit integer
+		{"m/-1'", nil},          // Cannot contain negative number
+	}
+	for i, tt := range tests {
+		if path, err := ParseDerivationPath(tt.input); !reflect.DeepEqual(path, tt.output) {
+			t.Errorf("test %d: parse mismatch: have %v (%v), want %v", i, path, err, tt.output)
+		} else if path == nil && err == nil {
+			t.Errorf("test %d: nil path and error: %v", i, err)
+		}
+	}
+}
+
+// Tests that derivation paths are printed in their canonical form and can be
+// parsed back.
+func TestHDPathString(t *testing.T) {
+	if have, want := DefaultBaseDerivationPath.String(), "m/44'/269'/0'/0/0"; have != want {
+		t.Fatalf("base path mismatch: have %s, want %s", have, want)
+	}
+	path, err := ParseDerivationPath(DefaultRootDerivationPath.String())
+	if err != nil {
+		t.Fatalf("failed to parse root path: %v", err)
+	}
+	if !reflect.DeepEqual(path, DefaultRootDerivationPath) {
+		t.Fatalf("root path mismatch: have %v, want %v", path, DefaultRootDerivationPath)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	accounts "github.com/hpb-project/go-hpb/account"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/common/math"
+)
+
+// hardenedKeyStart is the index of the first hardened child key.
+const hardenedKeyStart = 0x80000000
+
+var (
+	// errInvalidSeed is returned if a seed is too short or too long for BIP-32.
+	errInvalidSeed = errors.New("seed must be 128 to 512 bits")
+
+	// errUnusableKey is returned for the (astronomically unlikely) derivations
+	// which don't result in a valid private key.
+	errUnusableKey = errors.New("derived key is unusable")
+)
+
+// hdKey is a BIP-32 extended private key.
+type hdKey struct {
+	key       []byte // Private key, 32 bytes
+	chainCode []byte // Chain code, 32 bytes
+}
+
+// newMasterKey derives the master extended key of a wallet seed.
+func newMasterKey(seed []byte) (*hdKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errInvalidSeed
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	if k := new(big.Int).SetBytes(sum[:32]); k.Sign() == 0 || k.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, errUnusableKey
+	}
+	return &hdKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// child derives the extended child key at the given index, hardened if the
+// index is at least hardenedKeyStart.
+func (k *hdKey) child(index uint32) (*hdKey, error) {
+	data := make([]byte, 0, 37)
+	if index >= hardenedKeyStart {
+		data = append(append(data, 0), k.key...)
+	} else {
+		data = append(data, compressPubkey(k.key)...)
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	child := new(big.Int).SetBytes(sum[:32])
+	if child.Cmp(n) >= 0 {
+		return nil, errUnusableKey
+	}
+	child.Add(child, new(big.Int).SetBytes(k.key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, errUnusableKey
+	}
+	return &hdKey{key: math.PaddedBigBytes(child, 32), chainCode: sum[32:]}, nil
+}
+
+// derive derives the extended key at the given path below this key.
+func (k *hdKey) derive(path accounts.DerivationPath) (*hdKey, error) {
+	key := k
+	for _, index := range path {
+		child, err := key.child(index)
+		if key != k {
+			key.zero()
+		}
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	if key == k {
+		key = &hdKey{key: append([]byte{}, k.key...), chainCode: append([]byte{}, k.chainCode...)}
+	}
+	return key, nil
+}
+
+// address derives the account address at the given path below this key.
+func (k *hdKey) address(path accounts.DerivationPath) (common.Address, error) {
+	key, err := k.derive(path)
+	if err != nil {
+		return common.Address{}, err
+	}
+	defer key.zero()
+
+	priv := key.privateKey()
+	defer zeroKey(priv)
+
+	return crypto.PubkeyToAddress(priv.PublicKey), nil
+}
+
+// privateKey converts the extended key into a signing key.
+func (k *hdKey) privateKey() *ecdsa.PrivateKey {
+	return crypto.ToECDSAUnsafe(k.key)
+}
+
+// zero wipes the extended key from memory.
+func (k *hdKey) zero() {
+	for i := range k.key {
+		k.key[i] = 0
+	}
+	for i := range k.chainCode {
+		k.chainCode[i] = 0
+	}
+}
+
+// compressPubkey returns the 33 byte compressed public key of a private key.
+func compressPubkey(key []byte) []byte {
+	x, y := crypto.S256().ScalarBaseMult(key)
+
+	pub := make([]byte, 33)
+	pub[0] = 2 + byte(y.Bit(0))
+	math.ReadBits(x, pub[1:])
+	return pub
+}
@@ -0,0 +1,227 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+
+	accounts "github.com/hpb-project/go-hpb/account"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/event/sub"
+	"github.com/pborman/uuid"
+)
+
+// ErrHDWalletExists is returned when importing a seed which is already stored.
+var ErrHDWalletExists = errors.New("hd wallet already exists")
+
+// HDKeyStoreType is the reflect type of a hierarchical deterministic keystore
+// backend.
+var HDKeyStoreType = reflect.TypeOf(&HDKeyStore{})
+
+// HDKeyStoreScheme is the protocol scheme prefixing HD wallet and account URLs.
+var HDKeyStoreScheme = "hdkeystore"
+
+// encryptedSeedJSON is the on-disk format of a hierarchical deterministic wallet
+// seed. The seed is encrypted just like the keys of the plain keystore, and the
+// address of the first account at the base path identifies the wallet.
+type encryptedSeedJSON struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	Id      string     `json:"id"`
+	Version int        `json:"version"`
+	HDPath  string     `json:"hdpath"`
+}
+
+// HDKeyStore manages a directory of encrypted hierarchical deterministic wallet
+// seeds. Each seed file is exposed as a single wallet, deriving its accounts on
+// demand.
+//
+// Unlike the plain keystore, the directory is only scanned on startup, wallets
+// are expected to be added through the HDKeyStore itself.
+type HDKeyStore struct {
+	keydir  string // Directory holding the encrypted seeds
+	scryptN int    // Scrypt N parameter of newly encrypted seeds
+	scryptP int    // Scrypt P parameter of newly encrypted seeds
+
+	wallets     []accounts.Wallet     // Wallets of the stored seeds, sorted by URL
+	updateFeed  sub.Feed              // Event feed to notify wallet additions/openings
+	updateScope sub.SubscriptionScope // Subscription scope tracking current live listeners
+
+	mu sync.RWMutex
+}
+
+// NewHDKeyStore creates a keystore of hierarchical deterministic wallets for the
+// given directory.
+func NewHDKeyStore(keydir string, scryptN, scryptP int) *HDKeyStore {
+	keydir, _ = filepath.Abs(keydir)
+	hs := &HDKeyStore{
+		keydir:  keydir,
+		scryptN: scryptN,
+		scryptP: scryptP,
+	}
+	files, err := ioutil.ReadDir(keydir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warn("Failed to scan HD keystore", "dir", keydir, "err", err)
+	}
+	for _, fi := range files {
+		if skipKeyFile(fi) {
+			continue
+		}
+		path := filepath.Join(keydir, fi.Name())
+		wallet, err := loadHDWallet(hs, path)
+		if err != nil {
+			log.Warn("Failed to load HD wallet", "path", path, "err", err)
+			continue
+		}
+		hs.wallets = append(hs.wallets, wallet)
+	}
+	sort.Slice(hs.wallets, func(i, j int) bool { return hs.wallets[i].URL().Cmp(hs.wallets[j].URL()) < 0 })
+	return hs
+}
+
+// Wallets implements accounts.Backend, returning all the HD wallets from the
+// keystore directory.
+func (hs *HDKeyStore) Wallets() []accounts.Wallet {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+
+	cpy := make([]accounts.Wallet, len(hs.wallets))
+	copy(cpy, hs.wallets)
+	return cpy
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the addition or opening of HD wallets.
+func (hs *HDKeyStore) Subscribe(sink chan<- accounts.WalletEvent) sub.Subscription {
+	return hs.updateScope.Track(hs.updateFeed.Subscribe(sink))
+}
+
+// NewWallet generates a new random mnemonic and stores its seed encrypted with
+// the passphrase. The mnemonic is returned for the user to back up, it is not
+// stored anywhere.
+func (hs *HDKeyStore) NewWallet(passphrase string) (string, accounts.Wallet, error) {
+	mnemonic, err := NewMnemonic(MnemonicEntropyBits)
+	if err != nil {
+		return "", nil, err
+	}
+	wallet, err := hs.ImportMnemonic(mnemonic, "", passphrase)
+	if err != nil {
+		return "", nil, err
+	}
+	return mnemonic, wallet, nil
+}
+
+// ImportMnemonic stores the seed of a BIP-39 mnemonic, derived along with the
+// optional mnemonic passphrase, encrypted with the passphrase.
+func (hs *HDKeyStore) ImportMnemonic(mnemonic, mnemonicPassphrase, passphrase string) (accounts.Wallet, error) {
+	seed, err := MnemonicToSeed(mnemonic, mnemonicPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+
+	return hs.importSeed(seed, passphrase)
+}
+
+// importSeed encrypts and stores a wallet seed, identifying the wallet by the
+// first account at the default base derivation path.
+func (hs *HDKeyStore) importSeed(seed []byte, passphrase string) (accounts.Wallet, error) {
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	defer master.zero()
+
+	address, err := master.address(accounts.DefaultBaseDerivationPath)
+	if err != nil {
+		return nil, err
+	}
+	cryptoStruct, err := encryptDataV3(seed, passphrase, hs.scryptN, hs.scryptP)
+	if err != nil {
+		return nil, err
+	}
+	seedJSON, err := json.Marshal(encryptedSeedJSON{
+		Address: hex.EncodeToString(address[:]),
+		Crypto:  cryptoStruct,
+		Id:      uuid.NewRandom().String(),
+		Version: version,
+		HDPath:  accounts.DefaultBaseDerivationPath.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	hs.mu.Lock()
+	for _, wallet := range hs.wallets {
+		if wallet.(*hdWallet).address == address {
+			hs.mu.Unlock()
+			return nil, ErrHDWalletExists
+		}
+	}
+	path := filepath.Join(hs.keydir, keyFileName(address))
+	if err := writeKeyFile(path, seedJSON); err != nil {
+		hs.mu.Unlock()
+		return nil, err
+	}
+	wallet := newHDWallet(hs, path, address, accounts.DefaultBaseDerivationPath, cryptoStruct)
+
+	n := sort.Search(len(hs.wallets), func(i int) bool { return hs.wallets[i].URL().Cmp(wallet.URL()) >= 0 })
+	hs.wallets = append(hs.wallets[:n], append([]accounts.Wallet{wallet}, hs.wallets[n:]...)...)
+	hs.mu.Unlock()
+
+	hs.updateFeed.Send(accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletArrived})
+	return wallet, nil
+}
+
+// loadHDWallet reads an encrypted seed file into a closed HD wallet.
+func loadHDWallet(hs *HDKeyStore, path string) (*hdWallet, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seedJSON := new(encryptedSeedJSON)
+	if err := json.Unmarshal(blob, seedJSON); err != nil {
+		return nil, err
+	}
+	if seedJSON.Version != version {
+		return nil, fmt.Errorf("version not supported: %v", seedJSON.Version)
+	}
+	if !common.IsHexAddress(seedJSON.Address) {
+		return nil, fmt.Errorf("invalid address %q", seedJSON.Address)
+	}
+	base, err := accounts.ParseDerivationPath(seedJSON.HDPath)
+	if err != nil {
+		return nil, err
+	}
+	return newHDWallet(hs, path, common.HexToAddress(seedJSON.Address), base, seedJSON.Crypto), nil
+}
+
+// zeroBytes wipes a secret from memory.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
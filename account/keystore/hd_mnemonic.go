@@ -0,0 +1,131 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// MnemonicEntropyBits is the entropy of the mnemonics generated for new
+	// hierarchical deterministic wallets, resulting in 24 words.
+	MnemonicEntropyBits = 256
+
+	// mnemonicSeedIterations is the number of PBKDF2 rounds stretching a mnemonic
+	// into a wallet seed as defined by BIP-39.
+	mnemonicSeedIterations = 2048
+)
+
+var (
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	ErrMnemonicEntropy = errors.New("mnemonic entropy must be 128 to 256 bits in steps of 32")
+
+	// errMnemonicPassphrase is returned for mnemonic passphrases requiring the
+	// unicode normalization of BIP-39, which is not supported.
+	errMnemonicPassphrase = errors.New("mnemonic passphrase must be ASCII")
+)
+
+// mnemonicIndex is the reverse lookup of the mnemonic wordlist.
+var mnemonicIndex = make(map[string]int, len(mnemonicWords))
+
+func init() {
+	for i, word := range mnemonicWords {
+		mnemonicIndex[word] = i
+	}
+}
+
+// NewMnemonic generates a random BIP-39 mnemonic encoding the given number of
+// bits of entropy.
+func NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrMnemonicEntropy
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := io.ReadFull(rand.Reader, entropy); err != nil {
+		return "", err
+	}
+	return entropyToMnemonic(entropy), nil
+}
+
+// MnemonicToSeed validates a BIP-39 mnemonic and stretches it, along with the
+// optional mnemonic passphrase, into a hierarchical deterministic wallet seed.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := mnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(passphrase); i++ {
+		if passphrase[i] >= 0x80 {
+			return nil, errMnemonicPassphrase
+		}
+	}
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+passphrase), mnemonicSeedIterations, 64, sha512.New), nil
+}
+
+// entropyToMnemonic encodes the entropy and its checksum into mnemonic words of
+// 11 bits each.
+func entropyToMnemonic(entropy []byte) string {
+	checksum := sha256.Sum256(entropy)
+	data := append(append([]byte{}, entropy...), checksum[0])
+
+	words := make([]string, len(entropy)*3/4)
+	for i := range words {
+		index := 0
+		for bit := i * 11; bit < (i+1)*11; bit++ {
+			index = index<<1 | int(data[bit/8]>>(7-uint(bit%8))&1)
+		}
+		words[i] = mnemonicWords[index]
+	}
+	return strings.Join(words, " ")
+}
+
+// mnemonicToEntropy decodes the entropy of a mnemonic, verifying its checksum.
+func mnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, ErrInvalidMnemonic
+	}
+	data := make([]byte, (len(words)*11+7)/8)
+	for i, word := range words {
+		index, ok := mnemonicIndex[word]
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown word %q", ErrInvalidMnemonic, word)
+		}
+		for j := 0; j < 11; j++ {
+			if index>>(10-uint(j))&1 == 1 {
+				bit := i*11 + j
+				data[bit/8] |= 1 << (7 - uint(bit%8))
+			}
+		}
+	}
+	// Every 3 words hold 32 bits of entropy and 1 bit of checksum
+	entropy, bits := data[:len(words)*4/3], uint(len(words)/3)
+
+	checksum := sha256.Sum256(entropy)
+	if data[len(entropy)]>>(8-bits) != checksum[0]>>(8-bits) {
+		return nil, fmt.Errorf("%v: checksum mismatch", ErrInvalidMnemonic)
+	}
+	return entropy, nil
+}
@@ -0,0 +1,352 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	accounts "github.com/hpb-project/go-hpb/account"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+)
+
+// Tests mnemonic encoding and seed stretching against the BIP-39 reference
+// vectors, https://github.com/trezor/python-mnemonic/blob/master/vectors.json
+func TestMnemonicVectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"80808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+			"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+	}
+	for i, tt := range tests {
+		entropy, _ := hex.DecodeString(tt.entropy)
+		if mnemonic := entropyToMnemonic(entropy); mnemonic != tt.mnemonic {
+			t.Errorf("test %d: mnemonic mismatch: have %q, want %q", i, mnemonic, tt.mnemonic)
+		}
+		if decoded, err := mnemonicToEntropy(tt.mnemonic); err != nil || hex.EncodeToString(decoded) != tt.entropy {
+			t.Errorf("test %d: entropy mismatch: have %x (%v), want %s", i, decoded, err, tt.entropy)
+		}
+		seed, err := MnemonicToSeed(tt.mnemonic, "TREZOR")
+		if err != nil || hex.EncodeToString(seed) != tt.seed {
+			t.Errorf("test %d: seed mismatch: have %x (%v), want %s", i, seed, err, tt.seed)
+		}
+	}
+}
+
+// Tests that malformed mnemonics are rejected and generated ones accepted.
+func TestMnemonicValidation(t *testing.T) {
+	invalid := []string{
+		"",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", // Bad checksum
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",         // 11 words
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon hpb",     // Unknown word
+	}
+	for i, mnemonic := range invalid {
+		if _, err := MnemonicToSeed(mnemonic, ""); err == nil {
+			t.Errorf("test %d: invalid mnemonic accepted: %q", i, mnemonic)
+		}
+	}
+	if _, err := MnemonicToSeed(invalid[0]+"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "pässword"); err != errMnemonicPassphrase {
+		t.Errorf("non ASCII passphrase error mismatch: have %v, want %v", err, errMnemonicPassphrase)
+	}
+	for _, bits := range []int{128, 160, 192, 224, 256} {
+		mnemonic, err := NewMnemonic(bits)
+		if err != nil {
+			t.Fatalf("failed to generate %d bit mnemonic: %v", bits, err)
+		}
+		if words := len(strings.Fields(mnemonic)); words != bits*3/32 {
+			t.Errorf("%d bit mnemonic word count mismatch: have %d, want %d", bits, words, bits*3/32)
+		}
+		if _, err := MnemonicToSeed(mnemonic, ""); err != nil {
+			t.Errorf("generated %d bit mnemonic rejected: %v", bits, err)
+		}
+	}
+	if _, err := NewMnemonic(100); err != ErrMnemonicEntropy {
+		t.Errorf("invalid entropy size error mismatch: have %v, want %v", err, ErrMnemonicEntropy)
+	}
+}
+
+// Tests key derivation against the first BIP-32 reference vector.
+func TestHDKeyDerivation(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := newMasterKey(seed)
+	if err != nil {
+		t.Fatalf("failed to create master key: %v", err)
+	}
+	tests := []struct {
+		path      string
+		key       string
+		chainCode string
+	}{
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368", "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19"},
+		{"m/0'/1/2'", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca", "04466b9cc8e161e966409ca52986c584f07e9dc81f735db683c3ff6ec7b1503f"},
+		{"m/0'/1/2'/2", "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4", "cfb71883f01676f587d023cc53a35bc7f88f724b1f8c2892ac1275ac822a3edd"},
+		{"m/0'/1/2'/2/1000000000", "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8", "c783e67b921d2beb8f6b389cc646d7263b4145701dadd2161548a8b078e65e9e"},
+	}
+	if have := hex.EncodeToString(master.key); have != "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35" {
+		t.Fatalf("master key mismatch: have %s", have)
+	}
+	for i, tt := range tests {
+		path, err := accounts.ParseDerivationPath(tt.path)
+		if err != nil {
+			t.Fatalf("test %d: failed to parse path: %v", i, err)
+		}
+		key, err := master.derive(path)
+		if err != nil {
+			t.Fatalf("test %d: failed to derive key: %v", i, err)
+		}
+		if have := hex.EncodeToString(key.key); have != tt.key {
+			t.Errorf("test %d: key mismatch: have %s, want %s", i, have, tt.key)
+		}
+		if have := hex.EncodeToString(key.chainCode); have != tt.chainCode {
+			t.Errorf("test %d: chain code mismatch: have %s, want %s", i, have, tt.chainCode)
+		}
+	}
+}
+
+const testMnemonic = "legal winner thank year wave sausage worth useful legal winner thank yellow"
+
+func tmpHDKeyStore(t *testing.T) (string, *HDKeyStore) {
+	dir, err := ioutil.TempDir("", "hpb-hdkeystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, NewHDKeyStore(dir, veryLightScryptN, veryLightScryptP)
+}
+
+// Tests the storing, opening, derivation and signing of HD wallets.
+func TestHDWallet(t *testing.T) {
+	dir, hs := tmpHDKeyStore(t)
+	defer os.RemoveAll(dir)
+
+	wallet, err := hs.ImportMnemonic(testMnemonic, "", "foo")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	if _, err := hs.ImportMnemonic(testMnemonic, "", "bar"); err != ErrHDWalletExists {
+		t.Fatalf("duplicate import error mismatch: have %v, want %v", err, ErrHDWalletExists)
+	}
+	if wallets := hs.Wallets(); len(wallets) != 1 || wallets[0] != wallet {
+		t.Fatalf("wallet list mismatch: have %v, want [%v]", wallets, wallet)
+	}
+	if wallet.URL().Scheme != HDKeyStoreScheme {
+		t.Fatalf("wallet scheme mismatch: have %s, want %s", wallet.URL().Scheme, HDKeyStoreScheme)
+	}
+	first := wallet.Accounts()[0]
+
+	// A closed wallet can't derive or sign without a passphrase
+	if _, err := wallet.Derive(accounts.DefaultBaseDerivationPath, false); err != accounts.ErrWalletClosed {
+		t.Fatalf("closed derivation error mismatch: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+	if _, err := wallet.SignHash(first, testSigData); err != ErrLocked {
+		t.Fatalf("closed signing error mismatch: have %v, want %v", err, ErrLocked)
+	}
+	if err := wallet.Open("bar"); err != ErrDecrypt {
+		t.Fatalf("wrong passphrase error mismatch: have %v, want %v", err, ErrDecrypt)
+	}
+	if err := wallet.Open("foo"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	if status, _ := wallet.Status(); status != "Unlocked" {
+		t.Fatalf("wallet status mismatch: have %s, want Unlocked", status)
+	}
+	// Derive and pin a second account, sign with it and check the sender
+	path, _ := accounts.ParseDerivationPath("m/44'/269'/0'/0/1")
+	second, err := wallet.Derive(path, true)
+	if err != nil {
+		t.Fatalf("failed to derive account: %v", err)
+	}
+	if !wallet.Contains(second) || len(wallet.Accounts()) != 2 {
+		t.Fatalf("derived account not pinned: %v", wallet.Accounts())
+	}
+	signer := types.NewBoeSigner(big.NewInt(1))
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil, types.TxExdata{})
+
+	signed, err := wallet.SignTx(second, tx, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if from, err := signer.Sender(signed); err != nil || from != second.Address {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", from, err, second.Address)
+	}
+	sig, err := wallet.SignHash(first, testSigData)
+	if err != nil {
+		t.Fatalf("failed to sign hash: %v", err)
+	}
+	if pub, err := crypto.SigToPub(testSigData, sig); err != nil || crypto.PubkeyToAddress(*pub) != first.Address {
+		t.Fatalf("signature recovery mismatch: %v", err)
+	}
+	if _, err := wallet.SignHash(accounts.Account{Address: common.Address{1}}, testSigData); err != accounts.ErrUnknownAccount {
+		t.Fatalf("unknown account error mismatch: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+	// Close the wallet and sign with the passphrase instead
+	if err := wallet.Close(); err != nil {
+		t.Fatalf("failed to close wallet: %v", err)
+	}
+	if _, err := wallet.SignTxWithPassphrase(second, "bar", tx, big.NewInt(1)); err != ErrDecrypt {
+		t.Fatalf("wrong passphrase error mismatch: have %v, want %v", err, ErrDecrypt)
+	}
+	if signed, err = wallet.SignTxWithPassphrase(second, "foo", tx, big.NewInt(1)); err != nil {
+		t.Fatalf("failed to sign transaction with passphrase: %v", err)
+	}
+	if from, err := signer.Sender(signed); err != nil || from != second.Address {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", from, err, second.Address)
+	}
+	// Reload the keystore and ensure the wallet is still there
+	reloaded := NewHDKeyStore(dir, veryLightScryptN, veryLightScryptP).Wallets()
+	if len(reloaded) != 1 || reloaded[0].URL() != wallet.URL() || reloaded[0].Accounts()[0] != first {
+		t.Fatalf("reloaded wallets mismatch: have %v", reloaded)
+	}
+	if err := reloaded[0].Open("foo"); err != nil {
+		t.Fatalf("failed to open reloaded wallet: %v", err)
+	}
+}
+
+// Tests that the seed is stored in the version 3 keystore format, decryptable
+// by the plain keystore routines.
+func TestHDWalletSeedFormat(t *testing.T) {
+	dir, hs := tmpHDKeyStore(t)
+	defer os.RemoveAll(dir)
+
+	mnemonic, wallet, err := hs.NewWallet("foo")
+	if err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	if words := len(strings.Fields(mnemonic)); words != 24 {
+		t.Fatalf("mnemonic word count mismatch: have %d, want 24", words)
+	}
+	blob, err := ioutil.ReadFile(wallet.URL().Path)
+	if err != nil {
+		t.Fatalf("failed to read seed file: %v", err)
+	}
+	key := new(encryptedKeyJSONV3)
+	if err := json.Unmarshal(blob, key); err != nil {
+		t.Fatalf("failed to parse seed file: %v", err)
+	}
+	if key.Version != version || key.Crypto.KDF != keyHeaderKDF {
+		t.Fatalf("seed file format mismatch: version %d, kdf %s", key.Version, key.Crypto.KDF)
+	}
+	seed, _, err := decryptKeyV3(key, "foo")
+	if err != nil {
+		t.Fatalf("failed to decrypt seed: %v", err)
+	}
+	want, _ := MnemonicToSeed(mnemonic, "")
+	if !bytes.Equal(seed, want) {
+		t.Fatalf("seed mismatch: have %x, want %x", seed, want)
+	}
+}
+
+// testChainState is a chain state reader with a set of used accounts.
+type testChainState struct {
+	nonces map[common.Address]uint64
+}
+
+func (s *testChainState) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	return new(big.Int), nil
+}
+
+func (s *testChainState) StorageAt(ctx context.Context, account common.Address, key common.Hash, number *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (s *testChainState) CodeAt(ctx context.Context, account common.Address, number *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (s *testChainState) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	return s.nonces[account], nil
+}
+
+// Tests that account discovery pins the used accounts within the gap limit and
+// the first unused account after them.
+func TestHDWalletSelfDerive(t *testing.T) {
+	dir, hs := tmpHDKeyStore(t)
+	defer os.RemoveAll(dir)
+
+	wallet, err := hs.ImportMnemonic(testMnemonic, "", "foo")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	if err := wallet.Open("foo"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	derive := func(index uint32) common.Address {
+		path := append(accounts.DerivationPath{}, accounts.DefaultRootDerivationPath...)
+		account, err := wallet.Derive(append(path, index), false)
+		if err != nil {
+			t.Fatalf("failed to derive account %d: %v", index, err)
+		}
+		return account.Address
+	}
+	chain := &testChainState{nonces: make(map[common.Address]uint64)}
+	for _, index := range []uint32{1, 3, 22, 50} {
+		chain.nonces[derive(index)] = 1
+	}
+	w := wallet.(*hdWallet)
+	w.lock.Lock()
+	w.deriveBase, w.deriveChain = accounts.DefaultBaseDerivationPath, chain
+	w.lock.Unlock()
+	w.selfDerive()
+
+	// Accounts 1, 3 and 22 are within the gap limit, account 23 is the next fresh one
+	want := []common.Address{derive(0), derive(1), derive(3), derive(22), derive(23)}
+	have := wallet.Accounts()
+	if len(have) != len(want) {
+		t.Fatalf("discovered account count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i, account := range have {
+		if account.Address != want[i] {
+			t.Errorf("account %d mismatch: have %x, want %x", i, account.Address, want[i])
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	accounts "github.com/hpb-project/go-hpb/account"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/interface"
+)
+
+const (
+	// selfDeriveGapLimit is the number of consecutive unused accounts after which
+	// account discovery stops, as recommended by BIP-44.
+	selfDeriveGapLimit = 20
+
+	// selfDeriveTimeout is the maximum time account discovery may take to query
+	// the chain state.
+	selfDeriveTimeout = time.Minute
+)
+
+// hdWallet implements the accounts.Wallet interface for a hierarchical
+// deterministic wallet whose seed is stored encrypted in an HD keystore.
+type hdWallet struct {
+	store   *HDKeyStore    // Keystore where the seed originates from
+	url     accounts.URL   // Location of the seed file
+	address common.Address // First account at the base path, identifying the wallet
+	crypto  cryptoJSON     // Encrypted seed
+
+	master   *hdKey                                     // Master key, nil while the wallet is closed
+	accounts []accounts.Account                         // Accounts pinned to the wallet
+	paths    map[common.Address]accounts.DerivationPath // Derivation paths of the pinned accounts
+
+	deriveBase  accounts.DerivationPath      // Base path of the account discovery
+	deriveChain hpb_project.ChainStateReader // Chain state to discover used accounts on
+
+	lock sync.RWMutex
+}
+
+// newHDWallet creates a closed HD wallet with the first account pinned.
+func newHDWallet(store *HDKeyStore, path string, address common.Address, base accounts.DerivationPath, seed cryptoJSON) *hdWallet {
+	url := accounts.URL{Scheme: HDKeyStoreScheme, Path: path}
+	return &hdWallet{
+		store:    store,
+		url:      url,
+		address:  address,
+		crypto:   seed,
+		accounts: []accounts.Account{{Address: address, URL: url}},
+		paths:    map[common.Address]accounts.DerivationPath{address: base},
+	}
+}
+
+// URL implements accounts.Wallet, returning the URL of the seed file.
+func (w *hdWallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning whether the seed of the wallet
+// is decrypted or not.
+func (w *hdWallet) Status() (string, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.master != nil {
+		return "Unlocked", nil
+	}
+	return "Locked", nil
+}
+
+// Open implements accounts.Wallet, decrypting the seed of the wallet so that
+// accounts can be derived and transactions signed without a passphrase.
+func (w *hdWallet) Open(passphrase string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.master != nil {
+		return accounts.ErrWalletAlreadyOpen
+	}
+	master, err := w.decrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	w.master = master
+
+	if w.deriveChain != nil {
+		go w.selfDerive()
+	}
+	go w.store.updateFeed.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletOpened})
+	return nil
+}
+
+// Close implements accounts.Wallet, wiping the decrypted seed from memory. The
+// pinned accounts stay tracked.
+func (w *hdWallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.master == nil {
+		return accounts.ErrWalletClosed
+	}
+	w.master.zero()
+	w.master = nil
+	return nil
+}
+
+// Accounts implements accounts.Wallet, returning the list of accounts pinned to
+// the wallet.
+func (w *hdWallet) Accounts() []accounts.Account {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not pinned into this wallet instance.
+func (w *hdWallet) Contains(account accounts.Account) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, exists := w.paths[account.Address]
+	return exists && (account.URL == (accounts.URL{}) || account.URL == w.url)
+}
+
+// Derive implements accounts.Wallet, deriving a new account at the specific
+// derivation path. If pin is set to true, the account will be added to the list
+// of tracked accounts.
+func (w *hdWallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.master == nil {
+		return accounts.Account{}, accounts.ErrWalletClosed
+	}
+	address, err := w.master.address(path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	account := accounts.Account{Address: address, URL: w.url}
+	if pin {
+		w.pin(account, path)
+	}
+	return account, nil
+}
+
+// SelfDerive implements accounts.Wallet, setting the base path from which the
+// wallet discovers the accounts with on-chain activity whenever it is opened.
+func (w *hdWallet) SelfDerive(base accounts.DerivationPath, chain hpb_project.ChainStateReader) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.deriveBase = append(accounts.DerivationPath{}, base...)
+	w.deriveChain = chain
+
+	if w.master != nil && chain != nil {
+		go w.selfDerive()
+	}
+}
+
+// selfDerive scans the derivation indexes from the self derivation base path
+// for accounts with on-chain activity. Every used account is pinned along with
+// the first unused one after them, ready to receive funds. The scan stops after
+// selfDeriveGapLimit unused accounts in a row.
+func (w *hdWallet) selfDerive() {
+	// Copy the master key, the wallet might get closed meanwhile
+	w.lock.RLock()
+	if w.master == nil || w.deriveChain == nil || len(w.deriveBase) == 0 {
+		w.lock.RUnlock()
+		return
+	}
+	master := &hdKey{key: append([]byte{}, w.master.key...), chainCode: append([]byte{}, w.master.chainCode...)}
+	path, chain := append(accounts.DerivationPath{}, w.deriveBase...), w.deriveChain
+	w.lock.RUnlock()
+
+	defer master.zero()
+
+	ctx, cancel := context.WithTimeout(context.Background(), selfDeriveTimeout)
+	defer cancel()
+
+	var (
+		used   []accounts.DerivationPath
+		unused accounts.DerivationPath
+		gap    int
+	)
+	for ; gap < selfDeriveGapLimit; path[len(path)-1]++ {
+		address, err := master.address(path)
+		if err != nil {
+			log.Warn("HD wallet account derivation failed", "url", w.url, "path", path, "err", err)
+			break
+		}
+		balance, err := chain.BalanceAt(ctx, address, nil)
+		if err != nil {
+			log.Warn("HD wallet balance retrieval failed", "url", w.url, "err", err)
+			return
+		}
+		nonce, err := chain.NonceAt(ctx, address, nil)
+		if err != nil {
+			log.Warn("HD wallet nonce retrieval failed", "url", w.url, "err", err)
+			return
+		}
+		if balance.Sign() != 0 || nonce != 0 {
+			used, unused, gap = append(used, append(accounts.DerivationPath{}, path...)), nil, 0
+			continue
+		}
+		if unused == nil {
+			unused = append(accounts.DerivationPath{}, path...)
+		}
+		gap++
+	}
+	if unused != nil {
+		used = append(used, unused)
+	}
+	// Pin all the discovered accounts
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, path := range used {
+		address, err := master.address(path)
+		if err != nil {
+			continue
+		}
+		if _, known := w.paths[address]; !known {
+			log.Info("HD wallet discovered account", "url", w.url, "address", address, "path", path)
+		}
+		w.pin(accounts.Account{Address: address, URL: w.url}, path)
+	}
+}
+
+// pin adds an account to the tracked accounts if not yet tracked. The lock must
+// be held by the caller.
+func (w *hdWallet) pin(account accounts.Account, path accounts.DerivationPath) {
+	if _, ok := w.paths[account.Address]; ok {
+		return
+	}
+	w.paths[account.Address] = append(accounts.DerivationPath{}, path...)
+	w.accounts = append(w.accounts, account)
+}
+
+// SignHash implements accounts.Wallet, signing the given hash with the given
+// account if the wallet is open.
+func (w *hdWallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.master == nil {
+		return nil, ErrLocked
+	}
+	return w.signHash(w.master, account, hash)
+}
+
+// SignTx implements accounts.Wallet, signing the given transaction with the
+// given account if the wallet is open.
+func (w *hdWallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.master == nil {
+		return nil, ErrLocked
+	}
+	return w.signTx(w.master, account, tx, chainID)
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, signing the given hash with
+// the given account, decrypting the seed with the passphrase only for the
+// duration of the signing.
+func (w *hdWallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	master, err := w.decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer master.zero()
+
+	return w.signHash(master, account, hash)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, signing the given transaction
+// with the given account, decrypting the seed with the passphrase only for the
+// duration of the signing.
+func (w *hdWallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	master, err := w.decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer master.zero()
+
+	return w.signTx(master, account, tx, chainID)
+}
+
+// signHash signs a hash with the key of a pinned account. The lock must be held
+// by the caller.
+func (w *hdWallet) signHash(master *hdKey, account accounts.Account, hash []byte) ([]byte, error) {
+	key, err := w.accountKey(master, account)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return crypto.Sign(hash, key)
+}
+
+// signTx signs a transaction with the key of a pinned account. The lock must be
+// held by the caller.
+func (w *hdWallet) signTx(master *hdKey, account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.accountKey(master, account)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return types.SignTx(tx, types.NewBoeSigner(chainID), key)
+}
+
+// accountKey derives the private key of a pinned account. The lock must be held
+// by the caller.
+func (w *hdWallet) accountKey(master *hdKey, account accounts.Account) (*ecdsa.PrivateKey, error) {
+	path, ok := w.paths[account.Address]
+	if !ok || (account.URL != (accounts.URL{}) && account.URL != w.url) {
+		return nil, accounts.ErrUnknownAccount
+	}
+	child, err := master.derive(path)
+	if err != nil {
+		return nil, err
+	}
+	defer child.zero()
+
+	key := child.privateKey()
+	if crypto.PubkeyToAddress(key.PublicKey) != account.Address {
+		zeroKey(key)
+		return nil, accounts.ErrUnknownAccount
+	}
+	return key, nil
+}
+
+// decrypt decrypts the seed of the wallet into its master key, verifying that
+// it derives the address identifying the wallet.
+func (w *hdWallet) decrypt(passphrase string) (*hdKey, error) {
+	seed, err := decryptDataV3(w.crypto, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	if address, err := master.address(w.paths[w.address]); err != nil || address != w.address {
+		master.zero()
+		return nil, fmt.Errorf("seed content mismatch: have account %x, want %x", address, w.address)
+	}
+	return master, nil
+}
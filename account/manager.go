@@ -17,6 +17,7 @@
 package accounts
 
 import (
+	"reflect"
 	"sort"
 	"sync"
 
@@ -30,10 +31,11 @@ var INSTANCE = atomic.Value{}
 // Manager is an overarching account manager that can communicate with various
 // backends for signing transactions.
 type Manager struct {
-	store   Backend          // Index of backends currently registered
-	updater sub.Subscription // Wallet update subscriptions for all backends
-	updates chan WalletEvent // Subscription sink for backend wallet changes
-	wallets []Wallet         // Cache of all wallets from all registered backends
+	store    Backend                    // Keystore backend, the first one registered
+	backends map[reflect.Type][]Backend // Index of backends currently registered
+	updaters []sub.Subscription         // Wallet update subscriptions for all backends
+	updates  chan WalletEvent           // Subscription sink for backend wallet changes
+	wallets  []Wallet                   // Cache of all wallets from all registered backends
 
 	feed sub.Feed // Wallet feed notifying of arrivals/departures
 
@@ -42,23 +44,37 @@ type Manager struct {
 }
 
 // NewManager creates a generic account manager to sign transaction via various
-// supported backends.
-func NewManager(back Backend) *Manager {
+// supported backends. The first backend is expected to be the keystore.
+func NewManager(backends ...Backend) *Manager {
 	if INSTANCE.Load() != nil {
 		return GetManager()
 	}
+	// Retrieve the initial list of wallets from the backends and sort by URL
+	var wallets []Wallet
+	for _, backend := range backends {
+		wallets = merge(wallets, backend.Wallets()...)
+	}
 	// Subscribe to wallet notifications from all backends
-	updates := make(chan WalletEvent, 4)
-
-	sub := back.Subscribe(updates)
+	updates := make(chan WalletEvent, 4*len(backends))
 
+	subs := make([]sub.Subscription, len(backends))
+	for i, backend := range backends {
+		subs[i] = backend.Subscribe(updates)
+	}
 	// Assemble the account manager and return
 	am := &Manager{
-		store:   back,
-		updater: sub,
-		updates: updates,
-		wallets: back.Wallets(),
-		quit:    make(chan chan error),
+		backends: make(map[reflect.Type][]Backend),
+		updaters: subs,
+		updates:  updates,
+		wallets:  wallets,
+		quit:     make(chan chan error),
+	}
+	if len(backends) > 0 {
+		am.store = backends[0]
+	}
+	for _, backend := range backends {
+		kind := reflect.TypeOf(backend)
+		am.backends[kind] = append(am.backends[kind], backend)
 	}
 	go am.update()
 	INSTANCE.Store(am)
@@ -78,8 +94,10 @@ func (am *Manager) update() {
 	// Close all subscriptions when the manager terminates
 	defer func() {
 		am.lock.Lock()
-		am.updater.Unsubscribe()
-		am.updater = nil
+		for _, sub := range am.updaters {
+			sub.Unsubscribe()
+		}
+		am.updaters = nil
 		am.lock.Unlock()
 	}()
 
@@ -109,11 +127,16 @@ func (am *Manager) update() {
 	}
 }
 
-// Backends retrieves the backend(s) with the given type from the account manager.
+// KeyStore retrieves the keystore backend from the account manager.
 func (am *Manager) KeyStore() Backend {
 	return am.store
 }
 
+// Backends retrieves the backend(s) with the given type from the account manager.
+func (am *Manager) Backends(kind reflect.Type) []Backend {
+	return am.backends[kind]
+}
+
 // Wallets returns all signer accounts registered under this account manager.
 func (am *Manager) Wallets() []Wallet {
 	am.lock.RLock()
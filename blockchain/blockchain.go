@@ -27,6 +27,7 @@ import (
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/hpb-project/go-hpb/blockchain/state"
+	"github.com/hpb-project/go-hpb/blockchain/state/snapshot"
 	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
@@ -40,12 +41,15 @@ import (
 	"github.com/hpb-project/go-hpb/consensus"
 	"github.com/hpb-project/go-hpb/event/sub"
 	"github.com/hpb-project/go-hpb/node/db"
+	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
 var (
 	once             sync.Once
 	bcInstance       *BlockChain
 	blockInsertTimer = metrics.NewTimer("chain/inserts")
+	blockReorgMeter  = metrics.NewMeter("chain/reorg/executes")
+	blockReorgDepth  = metrics.NewHistogram("chain/reorg/depth") // Number of canonical blocks dropped by a reorg
 	errNoGenesis     = errors.New("Genesis is not found in the chain.")
 )
 
@@ -55,11 +59,45 @@ const (
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
+	triesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
-	BlockChainVersion = 3
+	// Version 4 moves the finalized blocks into the ancient store, which older
+	// binaries can't read.
+	BlockChainVersion = 4
+
+	// LegacyBlockChainVersion is the last version without the ancient store, its
+	// databases are upgraded in place to BlockChainVersion.
+	LegacyBlockChainVersion = 3
 )
 
+// CacheConfig contains the configuration values for the trie caching/pruning
+// that's resident in a blockchain.
+type CacheConfig struct {
+	Disabled      bool          // Whether to disable trie write caching (archive node)
+	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
+	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	SnapshotLimit int           // Memory allowance (MB) to use for caching snapshot entries in memory, 0 disables snapshots
+}
+
+// defaultCacheConfig is used when the caller doesn't provide a cache configuration.
+var defaultCacheConfig = &CacheConfig{
+	TrieNodeLimit: 256,
+	TrieTimeLimit: 5 * time.Minute,
+	SnapshotLimit: 256,
+}
+
+// DefaultCacheConfig returns the trie caching/pruning settings of the node
+// configuration.
+func DefaultCacheConfig(cfg *config.Nodeconfig) *CacheConfig {
+	return &CacheConfig{
+		Disabled:      cfg.NoPruning,
+		TrieNodeLimit: cfg.TrieCache,
+		TrieTimeLimit: cfg.TrieTimeout,
+		SnapshotLimit: cfg.SnapshotCache,
+	}
+}
+
 // BlockChain represents the canonical chain given a database with a genesis
 // block. The Blockchain manages chain imports, reverts, chain reorganisations.
 //
@@ -75,7 +113,8 @@ const (
 // included in the canonical one where as GetBlockByNumber always represents the
 // canonical chain.
 type BlockChain struct {
-	config *config.ChainConfig // chain & network configuration
+	config      *config.ChainConfig // chain & network configuration
+	cacheConfig *CacheConfig        // Cache configuration for pruning
 
 	hc            *HeaderChain
 	chainDb       hpbdb.Database
@@ -95,6 +134,10 @@ type BlockChain struct {
 	currentFastBlock *types.Block // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
+	snaps        *snapshot.Tree // Snapshot tree for fast state reads, nil if disabled
+	triegc       *prque.Prque   // Priority queue mapping block numbers to tries to gc
+	gcproc       time.Duration  // Accumulates canonical block processing for trie dumping
+	lastWrite    uint64         // Number of the last block whose state was flushed to disk
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
@@ -116,7 +159,8 @@ type BlockChain struct {
 // InstanceBlockChain returns the singleton of BlockChain.
 func InstanceBlockChain() *BlockChain {
 	once.Do(func() {
-		bcInstance = NewBlockChain(db.GetHpbDbInstance(), &config.GetHpbConfigInstance().BlockChain)
+		hpbconf := config.GetHpbConfigInstance()
+		bcInstance = NewBlockChain(db.GetHpbDbInstance(), DefaultCacheConfig(&hpbconf.Node), &hpbconf.BlockChain)
 	})
 	return bcInstance
 }
@@ -138,16 +182,23 @@ func (bc *BlockChain) InitWithEngine(engine consensus.Engine) (*BlockChain, erro
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	bc.openSnapshot()
 
 	// Take ownership of this particular state
 	go bc.update()
+
+	bc.wg.Add(1)
+	go bc.freeze()
 	return bc, nil
 }
 
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Hpb Validator and
 // Processor.
-func NewBlockChain(chainDb hpbdb.Database, config *config.ChainConfig) *BlockChain {
+func NewBlockChain(chainDb hpbdb.Database, cacheConfig *CacheConfig, config *config.ChainConfig) *BlockChain {
+	if cacheConfig == nil {
+		cacheConfig = defaultCacheConfig
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
@@ -156,8 +207,10 @@ func NewBlockChain(chainDb hpbdb.Database, config *config.ChainConfig) *BlockCha
 
 	bc := &BlockChain{
 		config:       config,
+		cacheConfig:  cacheConfig,
 		chainDb:      chainDb,
 		stateCache:   state.NewDatabase(chainDb),
+		triegc:       prque.New(),
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
 		bodyRLPCache: bodyRLPCache,
@@ -172,7 +225,10 @@ func NewBlockChain(chainDb hpbdb.Database, config *config.ChainConfig) *BlockCha
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Hpb Validator and
 // Processor.
-func NewBlockChainWithEngine(chainDb hpbdb.Database, config *config.ChainConfig, engine consensus.Engine) (*BlockChain, error) {
+func NewBlockChainWithEngine(chainDb hpbdb.Database, cacheConfig *CacheConfig, config *config.ChainConfig, engine consensus.Engine) (*BlockChain, error) {
+	if cacheConfig == nil {
+		cacheConfig = defaultCacheConfig
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
@@ -181,8 +237,10 @@ func NewBlockChainWithEngine(chainDb hpbdb.Database, config *config.ChainConfig,
 
 	bc := &BlockChain{
 		config:       config,
+		cacheConfig:  cacheConfig,
 		chainDb:      chainDb,
 		stateCache:   state.NewDatabase(chainDb),
+		triegc:       prque.New(),
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
 		bodyRLPCache: bodyRLPCache,
@@ -207,9 +265,13 @@ func NewBlockChainWithEngine(chainDb hpbdb.Database, config *config.ChainConfig,
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	bc.openSnapshot()
 
 	// Take ownership of this particular state
 	go bc.update()
+
+	bc.wg.Add(1)
+	go bc.freeze()
 	return bc, nil
 }
 
@@ -237,8 +299,10 @@ func (bc *BlockChain) loadLastState() error {
 	// Make sure the state associated with the block is available
 	if _, err := state.New(currentBlock.Root(), bc.stateCache); err != nil {
 		// Dangling block without a state associated, init from scratch
-		log.Warn("Head state missing, resetting chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
-		return bc.Reset()
+		log.Warn("Head state missing, repairing chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
+		if err := bc.repair(&currentBlock); err != nil {
+			return err
+		}
 	}
 	// Everything seems to be fine, set as the head block
 	bc.currentBlock = currentBlock
@@ -272,6 +336,29 @@ func (bc *BlockChain) loadLastState() error {
 	return nil
 }
 
+// repair tries to repair the current blockchain by rolling back the current block
+// until one with associated state is found. This is needed to fix incomplete db
+// writes caused either by crashes/power outages, or simply non-committed tries
+// when running with the pruning garbage collector.
+//
+// This method only rolls back the current block. The current header and current
+// fast block are left intact.
+func (bc *BlockChain) repair(head **types.Block) error {
+	for {
+		// Abort if we've rewound to a head block that does have associated state
+		if _, err := state.New((*head).Root(), bc.stateCache); err == nil {
+			log.Info("Rewound blockchain to past state", "number", (*head).Number(), "hash", (*head).Hash())
+			return WriteHeadBlockHash(bc.chainDb, (*head).Hash())
+		}
+		// Otherwise rewind one block and recheck state availability there
+		parent := bc.GetBlock((*head).ParentHash(), (*head).NumberU64()-1)
+		if parent == nil {
+			return fmt.Errorf("missing block %d [%x]", (*head).NumberU64()-1, (*head).ParentHash())
+		}
+		*head = parent
+	}
+}
+
 // SetHead rewinds the local chain to a new head. In the case of headers, everything
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
@@ -288,6 +375,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	}
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
+	bc.truncateAncients(currentHeader.Number.Uint64())
 
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
@@ -301,8 +389,11 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	}
 	if bc.currentBlock != nil {
 		if _, err := state.New(bc.currentBlock.Root(), bc.stateCache); err != nil {
-			// Rewound state missing, rolled back to before pivot, reset to genesis
-			bc.currentBlock = nil
+			// Rewound state missing, possibly pruned, try to find an older one
+			if err := bc.repair(&bc.currentBlock); err != nil {
+				// Rolled back to before pivot, reset to genesis
+				bc.currentBlock = nil
+			}
 		}
 	}
 	// Rewind the fast block in a simpleton way to the target head
@@ -322,6 +413,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	if err := WriteHeadFastBlockHash(bc.chainDb, bc.currentFastBlock.Hash()); err != nil {
 		log.Crit("Failed to reset head fast block", "err", err)
 	}
+	bc.checkSnapshot(bc.currentBlock)
 	return bc.loadLastState()
 }
 
@@ -339,6 +431,7 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	// If all checks out, manually set the head block
 	bc.mu.Lock()
 	bc.currentBlock = block
+	bc.checkSnapshot(block)
 	bc.mu.Unlock()
 
 	log.Info("Committed new head block", "number", block.Number(), "hash", hash)
@@ -423,7 +516,12 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshot(root, bc.stateCache, bc.snaps)
+}
+
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -560,8 +658,7 @@ func (bc *BlockChain) HasBlock(hash common.Hash, number uint64) bool {
 	if bc.blockCache.Contains(hash) {
 		return true
 	}
-	ok, _ := bc.chainDb.Has(blockBodyKey(hash, number))
-	return ok
+	return HasBody(bc.chainDb, hash, number)
 }
 
 // HasBlockAndState checks if a block and associated state trie is fully present
@@ -646,6 +743,33 @@ func (bc *BlockChain) Stop() {
 	atomic.StoreInt32(&bc.procInterrupt, 1)
 
 	bc.wg.Wait()
+
+	// Persist the snapshot while the tries it might still be generated from are alive
+	bc.closeSnapshot()
+
+	// Ensure the state of a recent block is also stored to disk before exiting.
+	// The head, the one before it and the oldest in memory are flushed, so a
+	// restart can resume from the head or survive a small reorg.
+	if !bc.cacheConfig.Disabled {
+		triedb := bc.stateCache.TrieDB()
+
+		for _, offset := range []uint64{0, 1, triesInMemory - 1} {
+			if number := bc.CurrentBlock().NumberU64(); number > offset {
+				recent := bc.GetBlockByNumber(number - offset)
+
+				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
+				if err := triedb.Commit(recent.Root(), true); err != nil {
+					log.Error("Failed to commit recent state trie", "err", err)
+				}
+			}
+		}
+		for !bc.triegc.Empty() {
+			triedb.Dereference(bc.triegc.PopItem().(common.Hash))
+		}
+		if size := triedb.Size(); size != 0 {
+			log.Error("Dangling trie nodes after full cleanup", "size", size)
+		}
+	}
 	log.Info("Blockchain manager stopped")
 }
 
@@ -822,6 +946,68 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 	return 0, nil
 }
 
+// commitState writes the state of the block into the in-memory trie database,
+// flushing the trie of an old enough block to disk whenever the memory or time
+// allowance is exceeded, and garbage collecting the tries not needed any more.
+func (bc *BlockChain) commitState(block *types.Block, statedb *state.StateDB) error {
+	triedb := bc.stateCache.TrieDB()
+
+	root, err := statedb.CommitTo(triedb, true)
+	if err != nil {
+		return err
+	}
+	triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
+	bc.triegc.Push(root, -float32(block.NumberU64()))
+	bc.capSnapshot(root)
+
+	current := block.NumberU64()
+	if current <= triesInMemory {
+		return nil
+	}
+	// Find the next state trie we need to commit
+	chosen := current - triesInMemory
+	header := bc.GetHeaderByNumber(chosen)
+	if header == nil {
+		return nil
+	}
+	// Only write to disk if we exceeded our memory allowance *and* also have at
+	// least a given number of tries gapped.
+	var (
+		size  = triedb.Size()
+		limit = common.StorageSize(bc.cacheConfig.TrieNodeLimit) * 1024 * 1024
+	)
+	if size > limit || bc.gcproc > bc.cacheConfig.TrieTimeLimit {
+		// If we're exceeding limits but haven't reached a large enough memory gap,
+		// warn the user that the system is becoming unstable.
+		if chosen < bc.lastWrite+triesInMemory {
+			switch {
+			case size >= 2*limit:
+				log.Warn("State memory usage too high, committing", "size", size, "limit", limit, "optimum", float64(chosen-bc.lastWrite)/triesInMemory)
+			case bc.gcproc >= 2*bc.cacheConfig.TrieTimeLimit:
+				log.Info("State in memory for too long, committing", "time", bc.gcproc, "allowance", bc.cacheConfig.TrieTimeLimit, "optimum", float64(chosen-bc.lastWrite)/triesInMemory)
+			}
+		}
+		// If optimum or critical limits reached, write to disk
+		if chosen >= bc.lastWrite+triesInMemory || size >= 2*limit || bc.gcproc >= 2*bc.cacheConfig.TrieTimeLimit {
+			if err := triedb.Commit(header.Root, true); err != nil {
+				return err
+			}
+			bc.lastWrite = chosen
+			bc.gcproc = 0
+		}
+	}
+	// Garbage collect anything below our required write retention
+	for !bc.triegc.Empty() {
+		root, number := bc.triegc.Pop()
+		if uint64(-number) > chosen {
+			bc.triegc.Push(root, number)
+			break
+		}
+		triedb.Dereference(root.(common.Hash))
+	}
+	return nil
+}
+
 // WriteBlock writes the block to the chain.
 func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.Receipt, state *state.StateDB) (status WriteStatus, err error) {
 	bc.wg.Add(1)
@@ -848,7 +1034,14 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	if err := WriteBlock(batch, block); err != nil {
 		return NonStatTy, err
 	}
-	if _, err := state.CommitTo(batch, true); err != nil {
+	if bc.cacheConfig.Disabled {
+		// Archive mode, every state goes straight to disk
+		root, err := state.CommitTo(batch, true)
+		if err != nil {
+			return NonStatTy, err
+		}
+		bc.capSnapshot(root)
+	} else if err := bc.commitState(block, state); err != nil {
 		return NonStatTy, err
 	}
 	if err := WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
@@ -917,6 +1110,7 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+		bc.checkSnapshot(block)
 	}
 
 	bc.futureBlocks.Remove(block.Hash())
@@ -975,6 +1169,13 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		lastCanon     *types.Block
 		coalescedLogs []*types.Log
 	)
+	// Start recovering the senders of all the blocks ahead of their processing
+	txs := make([]*types.Transaction, 0, len(chain))
+	for _, block := range chain {
+		txs = append(txs, block.Transactions()...)
+	}
+	go types.RecoverSenders(types.MakeSigner(bc.config), txs)
+
 	// Start the parallel header verifier
 	headers := make([]*types.Header, len(chain))
 	seals := make([]bool, len(chain))
@@ -1045,7 +1246,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		} else {
 			parent = chain[i-1]
 		}
-		state, err := state.New(parent.Root(), bc.stateCache)
+		state, err := state.NewWithSnapshot(parent.Root(), bc.stateCache, bc.snaps)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
@@ -1061,6 +1262,8 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
+		proctime := time.Since(bstart)
+
 		// Write the block to the chain and get the status.
 		log.Info("----> Write Block and State From Outside", "number", block.Number(), "hash", block.Hash(), "difficulty", block.Difficulty())
 		status, err := bc.WriteBlockAndState(block, receipts, state)
@@ -1079,6 +1282,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			events = append(events, ChainEvent{block, block.Hash(), logs})
 			lastCanon = block
 
+			// Only count canonical blocks for GC processing time
+			bc.gcproc += proctime
+
 		case SideStatTy:
 			log.Debug("Inserted forked block", "number", block.Number(), "hash", block.Hash(), "diff", block.Difficulty(), "elapsed",
 				common.PrettyDuration(time.Since(bstart)), "txs", len(block.Transactions()), "gas", block.GasUsed(), "uncles", len(block.Uncles()))
@@ -1224,6 +1430,8 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		}
 		logFn("Chain split detected", "number", commonBlock.Number(), "hash", commonBlock.Hash(),
 			"drop", len(oldChain), "dropfrom", oldChain[0].Hash(), "add", len(newChain), "addfrom", newChain[0].Hash())
+		blockReorgMeter.Mark(1)
+		blockReorgDepth.Update(int64(len(oldChain)))
 	} else {
 		log.Debug("old and new Chain length", "len(oldChain)", len(oldChain), "len(newChain)", len(newChain))
 		log.Debug("Impossible reorg, ", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
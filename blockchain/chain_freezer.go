@@ -0,0 +1,185 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package bc
+
+import (
+	"fmt"
+	"time"
+
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/consensus"
+)
+
+const (
+	// FreezerThreshold is the number of blocks behind the last Hpb node checkpoint
+	// after which chain segments are considered final and moved out of LevelDB
+	// into the ancient store.
+	FreezerThreshold = 90000
+
+	// freezerRecheckInterval is the frequency to check the chain progression
+	// that might permit new blocks to be frozen into the ancient store.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks moved into the ancient
+	// store while holding the chain lock.
+	freezerBatchLimit = 2048
+)
+
+// FreezeLimit returns the number of the first block which must stay in the
+// key-value store given the current chain head. Everything below it lies more
+// than FreezerThreshold blocks behind the last Hpb node checkpoint.
+func FreezeLimit(head uint64) uint64 {
+	checkpoint := head - head%consensus.HpbNodeCheckpointInterval
+	if checkpoint <= FreezerThreshold {
+		return 0
+	}
+	return checkpoint - FreezerThreshold
+}
+
+// freeze is a background loop periodically migrating finalized chain segments
+// from the key-value store into the ancient store, if the database has one.
+func (bc *BlockChain) freeze() {
+	defer bc.wg.Done()
+
+	store, ok := bc.chainDb.(hpbdb.AncientStore)
+	if !ok {
+		return
+	}
+	if _, err := store.Ancients(); err != nil {
+		return
+	}
+	ticker := time.NewTicker(freezerRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := bc.FreezeBlocks(FreezeLimit(bc.CurrentBlock().NumberU64())); err != nil {
+			log.Error("Failed to freeze ancient blocks", "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// FreezeBlocks moves all the canonical blocks below limit from the key-value
+// store into the ancient store, returning the number of blocks migrated. Only
+// the canonical chain is retained, the hash to number mappings stay in the
+// key-value store so that frozen blocks can still be looked up by hash.
+func (bc *BlockChain) FreezeBlocks(limit uint64) (int, error) {
+	store, ok := bc.chainDb.(hpbdb.AncientStore)
+	if !ok {
+		return 0, hpbdb.ErrNoAncientStore
+	}
+	frozen, err := store.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	if head := bc.CurrentBlock().NumberU64(); limit > head {
+		limit = head
+	}
+	var (
+		start = time.Now()
+		first = frozen
+	)
+	for frozen < limit {
+		select {
+		case <-bc.quit:
+			return int(frozen - first), nil
+		default:
+		}
+		last := limit
+		if last-frozen > freezerBatchLimit {
+			last = frozen + freezerBatchLimit
+		}
+		bc.mu.RLock()
+		hashes, err := bc.appendAncients(store, frozen, last)
+		bc.mu.RUnlock()
+
+		if serr := store.SyncAncients(); serr != nil && err == nil {
+			err = serr
+		}
+		// Only wipe the key-value copies once the ancients are safely on disk,
+		// genesis is kept in both stores for the database bootstrap checks.
+		for i, hash := range hashes {
+			if number := frozen + uint64(i); number > 0 && err == nil {
+				DeleteFrozenBlock(bc.chainDb, hash, number)
+			}
+		}
+		frozen += uint64(len(hashes))
+		if err != nil {
+			return int(frozen - first), err
+		}
+		if frozen < last {
+			// Some block data is not (yet) available, retry later
+			break
+		}
+	}
+	if frozen > first {
+		log.Info("Moved blocks into ancient store", "blocks", frozen-first, "frozen", frozen, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return int(frozen - first), nil
+}
+
+// appendAncients copies the canonical blocks [from, to) into the ancient store,
+// stopping early at the first block with incomplete data. The hashes of the
+// appended blocks are returned.
+func (bc *BlockChain) appendAncients(store hpbdb.AncientStore, from, to uint64) ([]common.Hash, error) {
+	var hashes []common.Hash
+	for number := from; number < to; number++ {
+		hash := GetCanonicalHash(bc.chainDb, number)
+		if hash == (common.Hash{}) {
+			log.Warn("Canonical hash missing, can't freeze", "number", number)
+			return hashes, nil
+		}
+		var (
+			header   = GetHeaderRLP(bc.chainDb, hash, number)
+			body     = GetBodyRLP(bc.chainDb, hash, number)
+			receipts = GetBlockReceiptsRLP(bc.chainDb, hash, number)
+			td       = GetTdRLP(bc.chainDb, hash, number)
+		)
+		if len(header) == 0 || len(body) == 0 || len(receipts) == 0 || len(td) == 0 {
+			log.Debug("Block data incomplete, can't freeze", "number", number, "hash", hash)
+			return hashes, nil
+		}
+		if err := store.AppendAncient(number, hash.Bytes(), header, body, receipts, td); err != nil {
+			return hashes, fmt.Errorf("failed to freeze block #%d [%x…]: %v", number, hash[:4], err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// truncateAncients drops all ancient blocks above the given head, so that the
+// ancient store never extends past the canonical chain.
+func (bc *BlockChain) truncateAncients(head uint64) {
+	store, ok := bc.chainDb.(hpbdb.AncientStore)
+	if !ok {
+		return
+	}
+	frozen, err := store.Ancients()
+	if err != nil || frozen <= head+1 {
+		return
+	}
+	log.Warn("Truncating ancient store", "frozen", frozen, "head", head)
+	if err := store.TruncateAncients(head + 1); err != nil {
+		log.Crit("Failed to truncate ancient store", "err", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package bc
+
+import (
+	"github.com/hpb-project/go-hpb/blockchain/state/snapshot"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/log"
+)
+
+// snapshotLayers is the number of diff layers kept in memory on top of the
+// snapshot disk layer. It's one less than the number of tries in memory, so the
+// disk layer always points to a state trie that is still referenced, which a
+// running generator might be reading.
+const snapshotLayers = triesInMemory - 1
+
+// openSnapshot loads the state snapshot of the current head, regenerating it in
+// the background if it's missing or out of date. Snapshots are disabled if no
+// cache allowance is configured.
+func (bc *BlockChain) openSnapshot() {
+	if bc.cacheConfig.SnapshotLimit <= 0 {
+		return
+	}
+	bc.snaps = snapshot.New(bc.chainDb, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, bc.CurrentBlock().Root())
+}
+
+// Snapshots returns the state snapshot tree of the chain, nil if disabled.
+func (bc *BlockChain) Snapshots() *snapshot.Tree {
+	return bc.snaps
+}
+
+// capSnapshot flattens the snapshot diff layers too far below the given state
+// root into the disk layer. It must be called before the in-memory tries are
+// garbage collected.
+func (bc *BlockChain) capSnapshot(root common.Hash) {
+	if bc.snaps == nil || bc.snaps.Snapshot(root) == nil {
+		return
+	}
+	if err := bc.snaps.Cap(root, snapshotLayers); err != nil {
+		log.Warn("Failed to cap snapshot tree", "root", root, "err", err)
+	}
+}
+
+// checkSnapshot makes sure the state of the given head block is covered by the
+// snapshot tree. A head without a snapshot layer, e.g. after a reorg deeper than
+// the diff layers, a rewind or a fast sync, requires a full regeneration.
+func (bc *BlockChain) checkSnapshot(head *types.Block) {
+	if bc.snaps == nil || bc.snaps.Snapshot(head.Root()) != nil {
+		return
+	}
+	log.Warn("Head state missing from snapshot, regenerating", "number", head.Number(), "hash", head.Hash(), "root", head.Root())
+	bc.snaps.Rebuild(head.Root())
+}
+
+// closeSnapshot flattens all the diff layers of the head into the disk layer and
+// stops the generator, so the snapshot can be reused on the next startup.
+func (bc *BlockChain) closeSnapshot() {
+	if bc.snaps == nil {
+		return
+	}
+	if root := bc.CurrentBlock().Root(); bc.snaps.Snapshot(root) != nil {
+		if err := bc.snaps.Cap(root, 0); err != nil {
+			log.Error("Failed to flatten snapshot tree", "root", root, "err", err)
+		}
+	}
+	bc.snaps.Close()
+}
@@ -95,12 +95,42 @@ func encodeBlockNumber(number uint64) []byte {
 // GetCanonicalHash retrieves a hash assigned to a canonical block number.
 func GetCanonicalHash(db DatabaseReader, number uint64) common.Hash {
 	data, _ := db.Get(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
+	if len(data) == 0 {
+		data = readAncient(db, hpbdb.FreezerHashTable, number)
+	}
 	if len(data) == 0 {
 		return common.Hash{}
 	}
 	return common.BytesToHash(data)
 }
 
+// readAncient retrieves an item from the ancient store if the database has one
+// attached, nil otherwise.
+func readAncient(db DatabaseReader, kind string, number uint64) []byte {
+	ancients, ok := db.(hpbdb.AncientReader)
+	if !ok {
+		return nil
+	}
+	data, _ := ancients.Ancient(kind, number)
+	return data
+}
+
+// isAncient reports whether the block with the given hash and number has been
+// moved into the ancient store.
+func isAncient(db DatabaseReader, hash common.Hash, number uint64) bool {
+	data := readAncient(db, hpbdb.FreezerHashTable, number)
+	return len(data) != 0 && common.BytesToHash(data) == hash
+}
+
+// readAncientByHash retrieves an item from the ancient store, checking that the
+// frozen block at the given number is indeed the one requested.
+func readAncientByHash(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	if !isAncient(db, hash, number) {
+		return nil
+	}
+	return readAncient(db, kind, number)
+}
+
 func StoreCadNodes(db hpbdb.Putter, blob []byte, Hash common.Hash) error {
 	return db.Put(append([]byte("codnodesnap-"), Hash[:]...), blob)
 }
@@ -206,9 +236,21 @@ func GetHeadFastBlockHash(db DatabaseReader) common.Hash {
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(hash, number))
+	if len(data) == 0 {
+		data = readAncientByHash(db, hpbdb.FreezerHeaderTable, hash, number)
+	}
 	return data
 }
 
+// HasHeader verifies the existence of a block header corresponding to the hash,
+// either in the key-value store or in the ancient store.
+func HasHeader(db hpbdb.Database, hash common.Hash, number uint64) bool {
+	if ok, _ := db.Has(headerKey(hash, number)); ok {
+		return true
+	}
+	return isAncient(db, hash, number)
+}
+
 // GetHeader retrieves the block header corresponding to the hash, nil if none
 // found.
 func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header {
@@ -227,9 +269,21 @@ func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(hash, number))
+	if len(data) == 0 {
+		data = readAncientByHash(db, hpbdb.FreezerBodiesTable, hash, number)
+	}
 	return data
 }
 
+// HasBody verifies the existence of a block body corresponding to the hash,
+// either in the key-value store or in the ancient store.
+func HasBody(db hpbdb.Database, hash common.Hash, number uint64) bool {
+	if ok, _ := db.Has(blockBodyKey(hash, number)); ok {
+		return true
+	}
+	return isAncient(db, hash, number)
+}
+
 func headerKey(hash common.Hash, number uint64) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
@@ -256,7 +310,7 @@ func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
 // GetTd retrieves a block's total difficulty corresponding to the hash, nil if
 // none found.
 func GetTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	data := GetTdRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -291,7 +345,7 @@ func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
-	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	data := GetBlockReceiptsRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -307,6 +361,25 @@ func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.
 	return receipts
 }
 
+// GetBlockReceiptsRLP retrieves the receipts of a block in their raw RLP storage
+// encoding.
+func GetBlockReceiptsRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	if len(data) == 0 {
+		data = readAncientByHash(db, hpbdb.FreezerReceiptTable, hash, number)
+	}
+	return data
+}
+
+// GetTdRLP retrieves a block's total difficulty in its raw RLP database encoding.
+func GetTdRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	if len(data) == 0 {
+		data = readAncientByHash(db, hpbdb.FreezerDifficultyTable, hash, number)
+	}
+	return data
+}
+
 // GetTxLookupEntry retrieves the positional metadata associated with a transaction
 // hash to allow retrieving the transaction or receipt by hash.
 func GetTxLookupEntry(db DatabaseReader, hash common.Hash) (common.Hash, uint64, uint64) {
@@ -584,6 +657,17 @@ func DeleteBlockReceipts(db DatabaseDeleter, hash common.Hash, number uint64) {
 	db.Delete(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
 }
 
+// DeleteFrozenBlock removes the key-value copy of a block which has been moved
+// into the ancient store. The hash to number mapping is retained so the block
+// can still be looked up by hash.
+func DeleteFrozenBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
+	DeleteCanonicalHash(db, number)
+	db.Delete(headerKey(hash, number))
+	DeleteBody(db, hash, number)
+	DeleteTd(db, hash, number)
+	DeleteBlockReceipts(db, hash, number)
+}
+
 // DeleteTxLookupEntry removes all transaction data associated with a hash.
 func DeleteTxLookupEntry(db DatabaseDeleter, hash common.Hash) {
 	db.Delete(append(lookupPrefix, hash.Bytes()...))
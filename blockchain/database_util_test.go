@@ -17,6 +17,9 @@
 package bc
 
 import (
+	"io/ioutil"
+	"math/big"
+	"os"
 	"testing"
 
 	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
@@ -48,3 +51,65 @@ func TestVoteResultStorage(t *testing.T) {
 		t.Fatalf("Deleted vote returned: %v", entry)
 	}
 }
+
+// Tests that block data moved into the ancient store is transparently served by
+// the database accessors.
+func TestAncientBlockStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ancient-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := hpbdb.NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	freezer, err := hpbdb.OpenFreezer(dir)
+	if err != nil {
+		t.Fatalf("Failed to create freezer: %v", err)
+	}
+	db.SetFreezer(freezer)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: common.Big0, Extra: []byte("ancient block")})
+	receipts := types.Receipts{&types.Receipt{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: big.NewInt(1), Logs: []*types.Log{}}}
+	hash, number := block.Hash(), block.NumberU64()
+
+	WriteBlock(db, block)
+	WriteTd(db, hash, number, big.NewInt(42))
+	WriteBlockReceipts(db, hash, number, receipts)
+	WriteCanonicalHash(db, hash, number)
+
+	if err := db.AppendAncient(number, hash.Bytes(), GetHeaderRLP(db, hash, number), GetBodyRLP(db, hash, number),
+		GetBlockReceiptsRLP(db, hash, number), GetTdRLP(db, hash, number)); err != nil {
+		t.Fatalf("Failed to freeze block: %v", err)
+	}
+	DeleteFrozenBlock(db, hash, number)
+	if ok, _ := db.Has(headerKey(hash, number)); ok {
+		t.Fatalf("Frozen header still in key-value store")
+	}
+	if entry := GetCanonicalHash(db, number); entry != hash {
+		t.Fatalf("Canonical hash mismatch: have %x, want %x", entry, hash)
+	}
+	if entry := GetBlock(db, hash, number); entry == nil || entry.Hash() != hash {
+		t.Fatalf("Frozen block mismatch: have %v, want %v", entry, block)
+	}
+	if td := GetTd(db, hash, number); td == nil || td.Int64() != 42 {
+		t.Fatalf("Frozen total difficulty mismatch: have %v, want 42", td)
+	}
+	if entry := GetBlockReceipts(db, hash, number); len(entry) != 1 || entry[0].CumulativeGasUsed.Int64() != 1 {
+		t.Fatalf("Frozen receipts mismatch: have %v, want %v", entry, receipts)
+	}
+	if !HasHeader(db, hash, number) || !HasBody(db, hash, number) {
+		t.Fatalf("Frozen block reported missing")
+	}
+	// Lookups of a different block at the same height must not hit the ancients
+	other := common.HexToHash("0x01")
+	if GetHeader(db, other, number) != nil || GetBody(db, other, number) != nil || GetTd(db, other, number) != nil {
+		t.Fatalf("Ancient data returned for a non-canonical hash")
+	}
+	if HasHeader(db, other, number) {
+		t.Fatalf("Non-canonical header reported present")
+	}
+}
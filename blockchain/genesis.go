@@ -29,6 +29,7 @@ import (
 	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
 	"github.com/hpb-project/go-hpb/common/hexutil"
 	"github.com/hpb-project/go-hpb/common/log"
 	"github.com/hpb-project/go-hpb/common/math"
@@ -143,6 +144,11 @@ func SetupGenesisBlock(db hpbdb.Database, genesis *Genesis) (*config.ChainConfig
 	if genesis != nil && genesis.Config == nil {
 		return config.MainnetChainConfig, common.Hash{}, errGenesisNoConfig
 	}
+	if genesis != nil {
+		if err := genesis.Config.CheckRandomSource(); err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+	}
 
 	// Just commit the new block if there is no stored genesis block.
 	stored := GetCanonicalHash(db, 0)
@@ -316,14 +322,26 @@ func DefaultTestnetGenesisBlock() *Genesis {
 	}
 }
 
-// DevGenesisBlock returns the 'geth --dev' genesis block.
-func DevGenesisBlock() *Genesis {
+// DeveloperGenesisBlock returns the genesis block of a single node developer
+// chain, the faucet is prefunded and is the only hpb node of the chain.
+func DeveloperGenesisBlock(period uint64, faucet common.Address) *Genesis {
+	extra, _ := types.NewExtraDetail(0)
+	extra.SetNodes(common.Addresses{faucet})
+
+	alloc := GenesisAlloc{
+		faucet: {Balance: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(9))},
+	}
+	// Precompiles get a single wei so they are never deleted as empty accounts
+	for i := int64(1); i <= 8; i++ {
+		alloc[common.BigToAddress(big.NewInt(i))] = GenesisAccount{Balance: big.NewInt(1)}
+	}
 	return &Genesis{
-		Config:     config.MainnetChainConfig,
-		Nonce:      42,
-		GasLimit:   4712388,
-		Difficulty: big.NewInt(131072),
-		Alloc:      decodePrealloc(devAllocData),
+		Config:         config.DeveloperChainConfig(period),
+		ExtraData:      extra.ToBytes(),
+		HardwareRandom: crypto.Keccak256(faucet.Bytes()),
+		GasLimit:       config.GenesisGasLimit.Uint64(),
+		Difficulty:     big.NewInt(1),
+		Alloc:          alloc,
 	}
 }
 
@@ -0,0 +1,216 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/hpb-project/go-hpb/common"
+)
+
+// storageKey identifies a single storage slot of an account.
+type storageKey struct {
+	addr common.Address
+	slot common.Hash
+}
+
+// AccessSet is a set of accounts and storage slots of the state.
+type AccessSet struct {
+	accounts map[common.Address]struct{} // Accounts accessed as a whole (balance, nonce, code, existence)
+	slots    map[storageKey]struct{}     // Individual storage slots accessed
+	storages map[common.Address]struct{} // Accounts whose storage is accessed in its entirety
+}
+
+// NewAccessSet creates an empty access set.
+func NewAccessSet() *AccessSet {
+	return &AccessSet{
+		accounts: make(map[common.Address]struct{}),
+		slots:    make(map[storageKey]struct{}),
+		storages: make(map[common.Address]struct{}),
+	}
+}
+
+// merge adds all the items of another set into this one.
+func (set *AccessSet) merge(other *AccessSet) {
+	for addr := range other.accounts {
+		set.accounts[addr] = struct{}{}
+	}
+	for key := range other.slots {
+		set.slots[key] = struct{}{}
+	}
+	for addr := range other.storages {
+		set.storages[addr] = struct{}{}
+	}
+}
+
+// storageTouched reports whether any storage slot of the account is in the set.
+func (set *AccessSet) storageTouched(addr common.Address) bool {
+	if _, ok := set.storages[addr]; ok {
+		return true
+	}
+	for key := range set.slots {
+		if key.addr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessRecord tracks the state read by a transaction and the modifications it
+// made. A transaction executed against a private copy of the state can be moved
+// over into another state by replaying its modifications, as long as nothing it
+// read was changed in between.
+//
+// Reads are tracked at the granularity of whole accounts and storage slots.
+// Modifications are replayed as the original operations, so commutative ones
+// like balance additions (e.g. fee payments to the coinbase) don't conflict.
+type AccessRecord struct {
+	reads  *AccessSet
+	writes *AccessSet
+	ops    []func(*StateDB) // Modifications still in effect, in execution order
+}
+
+// NewAccessRecord creates an empty access record.
+func NewAccessRecord() *AccessRecord {
+	return &AccessRecord{
+		reads:  NewAccessSet(),
+		writes: NewAccessSet(),
+	}
+}
+
+// Overlaps reports whether the record read any of the state items in written.
+func (rec *AccessRecord) Overlaps(written *AccessSet) bool {
+	for addr := range rec.reads.accounts {
+		if _, ok := written.accounts[addr]; ok {
+			return true
+		}
+	}
+	for key := range rec.reads.slots {
+		if _, ok := written.slots[key]; ok {
+			return true
+		}
+		if _, ok := written.storages[key.addr]; ok {
+			return true
+		}
+	}
+	for addr := range rec.reads.storages {
+		if written.storageTouched(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replay applies the recorded modifications onto the given state, which should
+// be prepared for the same transaction the record was made for.
+func (rec *AccessRecord) Replay(db *StateDB) {
+	for _, op := range rec.ops {
+		op(db)
+	}
+}
+
+// AddWrites adds the items modified by the recorded operations to the set.
+func (rec *AccessRecord) AddWrites(set *AccessSet) {
+	set.merge(rec.writes)
+}
+
+// SetAccessRecord starts tracking all accesses to the state into rec. A nil
+// record stops the tracking.
+func (self *StateDB) SetAccessRecord(rec *AccessRecord) {
+	self.record = rec
+}
+
+// readAccount tracks a read of the account as a whole.
+func (self *StateDB) readAccount(addr common.Address) {
+	if self.record != nil {
+		self.record.reads.accounts[addr] = struct{}{}
+	}
+}
+
+// readSlot tracks a read of a single storage slot.
+func (self *StateDB) readSlot(addr common.Address, slot common.Hash) {
+	if self.record != nil {
+		self.record.reads.slots[storageKey{addr, slot}] = struct{}{}
+	}
+}
+
+// readStorage tracks a read of the entire storage of an account.
+func (self *StateDB) readStorage(addr common.Address) {
+	if self.record != nil {
+		self.record.reads.accounts[addr] = struct{}{}
+		self.record.reads.storages[addr] = struct{}{}
+	}
+}
+
+// writeAccount tracks a modification of the account as a whole.
+func (self *StateDB) writeAccount(addr common.Address, op func(*StateDB)) {
+	if self.record != nil {
+		self.record.writes.accounts[addr] = struct{}{}
+		self.record.ops = append(self.record.ops, op)
+	}
+}
+
+// writeBalance tracks a balance change of the account. Changes by zero only
+// touch the account, which modifies it only if it's empty.
+func (self *StateDB) writeBalance(addr common.Address, amount *big.Int, op func(*StateDB)) {
+	if self.record == nil {
+		return
+	}
+	if amount.Sign() == 0 && !self.Empty(addr) {
+		return
+	}
+	self.writeAccount(addr, op)
+}
+
+// writeSlot tracks a modification of a single storage slot.
+func (self *StateDB) writeSlot(addr common.Address, slot common.Hash, op func(*StateDB)) {
+	if self.record != nil {
+		self.record.writes.slots[storageKey{addr, slot}] = struct{}{}
+		self.record.ops = append(self.record.ops, op)
+	}
+}
+
+// writeCreate tracks the (re)creation of an account, resetting its storage.
+func (self *StateDB) writeCreate(addr common.Address, op func(*StateDB)) {
+	if self.record != nil {
+		self.record.writes.accounts[addr] = struct{}{}
+		self.record.writes.storages[addr] = struct{}{}
+		self.record.ops = append(self.record.ops, op)
+	}
+}
+
+// writeOther tracks a modification outside of the account state, like logs.
+func (self *StateDB) writeOther(op func(*StateDB)) {
+	if self.record != nil {
+		self.record.ops = append(self.record.ops, op)
+	}
+}
+
+// recordedOps returns the number of recorded modifications.
+func (self *StateDB) recordedOps() int {
+	if self.record == nil {
+		return 0
+	}
+	return len(self.record.ops)
+}
+
+// revertRecord drops the recorded modifications undone by a revert.
+func (self *StateDB) revertRecord(ops int) {
+	if self.record != nil && ops <= len(self.record.ops) {
+		self.record.ops = self.record.ops[:ops]
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+)
+
+// Tests that the modifications recorded on a copy of the state can be replayed
+// onto the original, skipping the reverted ones.
+func TestAccessRecordReplay(t *testing.T) {
+	db, _ := hpbdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	var (
+		addr  = common.BytesToAddress([]byte{0x01})
+		other = common.BytesToAddress([]byte{0x02})
+		slot  = common.BytesToHash([]byte{0x03})
+	)
+	state.SetBalance(addr, big.NewInt(10))
+	state.SetState(other, slot, common.BytesToHash([]byte{0x04}))
+
+	fork := state.Copy()
+	rec := NewAccessRecord()
+	fork.SetAccessRecord(rec)
+
+	amount := big.NewInt(5)
+	fork.AddBalance(addr, amount)
+	amount.SetInt64(100) // callers may reuse the arguments
+	fork.GetState(other, slot)
+
+	id := fork.Snapshot()
+	fork.SetState(addr, slot, common.BytesToHash([]byte{0x05}))
+	fork.RevertToSnapshot(id)
+
+	// Modify the original in between, the addition must commute
+	state.AddBalance(addr, big.NewInt(1))
+	rec.Replay(state)
+
+	if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(16)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 16", balance)
+	}
+	if value := state.GetState(addr, slot); value != (common.Hash{}) {
+		t.Errorf("reverted slot replayed: %x", value)
+	}
+}
+
+// Tests that records overlap with the modifications of the items they read.
+func TestAccessRecordOverlaps(t *testing.T) {
+	db, _ := hpbdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	var (
+		addr  = common.BytesToAddress([]byte{0x01})
+		other = common.BytesToAddress([]byte{0x02})
+		slot  = common.BytesToHash([]byte{0x03})
+	)
+	record := func(ops func(*StateDB)) *AccessRecord {
+		rec := NewAccessRecord()
+		state.SetAccessRecord(rec)
+		ops(state)
+		state.SetAccessRecord(nil)
+		return rec
+	}
+	written := NewAccessSet()
+	record(func(db *StateDB) {
+		db.AddBalance(addr, big.NewInt(1))
+		db.SetState(other, slot, common.BytesToHash([]byte{0x01}))
+	}).AddWrites(written)
+
+	tests := []struct {
+		ops     func(*StateDB)
+		overlap bool
+	}{
+		{func(db *StateDB) { db.GetBalance(addr) }, true},
+		{func(db *StateDB) { db.GetNonce(other) }, false},
+		{func(db *StateDB) { db.GetState(other, slot) }, true},
+		{func(db *StateDB) { db.GetState(other, common.Hash{}) }, false},
+		{func(db *StateDB) { db.GetState(addr, slot) }, false},
+		{func(db *StateDB) { db.ForEachStorage(other, func(common.Hash, common.Hash) bool { return true }) }, true},
+		{func(db *StateDB) { db.AddBalance(addr, big.NewInt(1)) }, false},
+	}
+	for i, tt := range tests {
+		if overlap := record(tt.ops).Overlaps(written); overlap != tt.overlap {
+			t.Errorf("test %d: overlap mismatch: have %v, want %v", i, overlap, tt.overlap)
+		}
+	}
+	// Recreating an account invalidates all its storage
+	record(func(db *StateDB) { db.CreateAccount(addr) }).AddWrites(written)
+	if !record(func(db *StateDB) { db.GetState(addr, slot) }).Overlaps(written) {
+		t.Errorf("storage read of recreated account not overlapping")
+	}
+}
@@ -23,6 +23,7 @@ import (
 	lru "github.com/hashicorp/golang-lru"
 	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
 	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/rlp"
 	"github.com/hpb-project/go-hpb/common/trie"
 )
 
@@ -50,6 +51,8 @@ type Database interface {
 	ContractCodeSize(addrHash, codeHash common.Hash) (int, error)
 	// CopyTrie returns an independent copy of the given trie.
 	CopyTrie(Trie) Trie
+	// TrieDB retrieves the low level trie node database used for data storage.
+	TrieDB() *trie.NodeDatabase
 }
 
 // Trie is a Hpb Merkle Trie.
@@ -61,22 +64,40 @@ type Trie interface {
 	Hash() common.Hash
 	NodeIterator(startKey []byte) trie.NodeIterator
 	GetKey([]byte) []byte
+	Prove(key []byte) []rlp.RawValue
 }
 
 // NewDatabase creates a backing store for state. The returned database is safe for
-// concurrent use and retains cached trie nodes in memory.
+// concurrent use and retains cached trie nodes in memory. Tries committed into
+// its TrieDB are kept in memory until they are explicitly flushed to db.
 func NewDatabase(db hpbdb.Database) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
-	return &cachingDB{db: db, codeSizeCache: csc}
+	cdb := &cachingDB{db: db, codeSizeCache: csc}
+	cdb.triedb = trie.NewNodeDatabase(db, cdb.referenceAccount)
+	return cdb
 }
 
 type cachingDB struct {
 	db            hpbdb.Database
+	triedb        *trie.NodeDatabase
 	mu            sync.Mutex
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
 }
 
+// referenceAccount keeps the storage trie and the code of an account alive as
+// long as the account trie node holding it is.
+func (db *cachingDB) referenceAccount(leaf []byte, parent common.Hash) error {
+	var account Account
+	if err := rlp.DecodeBytes(leaf, &account); err != nil {
+		// Not an account, e.g. a storage slot value.
+		return nil
+	}
+	db.triedb.Reference(account.Root, parent)
+	db.triedb.Reference(common.BytesToHash(account.CodeHash), parent)
+	return nil
+}
+
 func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -86,7 +107,7 @@ func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
 			return cachedTrie{db.pastTries[i].Copy(), db}, nil
 		}
 	}
-	tr, err := trie.NewSecure(root, db.db, MaxTrieCacheGen)
+	tr, err := trie.NewSecure(root, db.triedb, MaxTrieCacheGen)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +127,7 @@ func (db *cachingDB) pushTrie(t *trie.SecureTrie) {
 }
 
 func (db *cachingDB) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
-	return trie.NewSecure(root, db.db, 0)
+	return trie.NewSecure(root, db.triedb, 0)
 }
 
 func (db *cachingDB) CopyTrie(t Trie) Trie {
@@ -121,7 +142,7 @@ func (db *cachingDB) CopyTrie(t Trie) Trie {
 }
 
 func (db *cachingDB) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
-	code, err := db.db.Get(codeHash[:])
+	code, err := db.triedb.Get(codeHash[:])
 	if err == nil {
 		db.codeSizeCache.Add(codeHash, len(code))
 	}
@@ -139,6 +160,10 @@ func (db *cachingDB) ContractCodeSize(addrHash, codeHash common.Hash) (int, erro
 	return len(code), err
 }
 
+func (db *cachingDB) TrieDB() *trie.NodeDatabase {
+	return db.triedb
+}
+
 // cachedTrie inserts its trie into a cachingDB on commit.
 type cachedTrie struct {
 	*trie.SecureTrie
@@ -0,0 +1,99 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var (
+	snapshotRootKey      = []byte("SnapshotRoot")      // snapshotRootKey -> state root the disk layer belongs to
+	snapshotGeneratorKey = []byte("SnapshotGenerator") // snapshotGeneratorKey -> last account hash generated, missing once done
+
+	snapshotAccountPrefix = []byte("a") // snapshotAccountPrefix + account hash -> account trie value
+	snapshotStoragePrefix = []byte("o") // snapshotStoragePrefix + account hash + storage hash -> storage trie value
+
+	// errNoIterator is returned if the snapshot data is attempted to be wiped
+	// from a database that doesn't support iterating over its keys.
+	errNoIterator = errors.New("database does not support iteration")
+)
+
+const (
+	accountKeyLength = 1 + common.HashLength
+	storageKeyLength = 1 + 2*common.HashLength
+)
+
+// accountKey = snapshotAccountPrefix + hash
+func accountKey(hash common.Hash) []byte {
+	return append(append([]byte{}, snapshotAccountPrefix...), hash.Bytes()...)
+}
+
+// storageKey = snapshotStoragePrefix + account hash + storage hash
+func storageKey(accountHash, storageHash common.Hash) []byte {
+	key := append(append([]byte{}, snapshotStoragePrefix...), accountHash.Bytes()...)
+	return append(key, storageHash.Bytes()...)
+}
+
+// iterateKeys calls fn for every key of the database starting with prefix and
+// having the given length, until fn returns false. Trie nodes are stored in the
+// same key space keyed by their hashes, so the length check is what separates
+// snapshot entries from anything else sharing the prefix.
+func iterateKeys(db hpbdb.Database, prefix []byte, length int, fn func(key []byte) bool) error {
+	switch db := db.(type) {
+	case *hpbdb.LDBDatabase:
+		it := db.LDB().NewIterator(util.BytesPrefix(prefix), nil)
+		defer it.Release()
+
+		for it.Next() {
+			if key := it.Key(); len(key) == length && !fn(common.CopyBytes(key)) {
+				break
+			}
+		}
+		return it.Error()
+
+	case *hpbdb.MemDatabase:
+		for _, key := range db.Keys() {
+			if len(key) == length && bytes.HasPrefix(key, prefix) && !fn(key) {
+				break
+			}
+		}
+		return nil
+	}
+	return errNoIterator
+}
+
+// wipeStorage deletes all the snapshot storage slots of an account, calling
+// onDelete for every removed key.
+func wipeStorage(db hpbdb.Database, accountHash common.Hash, onDelete func(key []byte)) error {
+	var err error
+	prefix := append(append([]byte{}, snapshotStoragePrefix...), accountHash.Bytes()...)
+	if ierr := iterateKeys(db, prefix, storageKeyLength, func(key []byte) bool {
+		if err = db.Delete(key); err != nil {
+			return false
+		}
+		onDelete(key)
+		return true
+	}); ierr != nil {
+		return ierr
+	}
+	return err
+}
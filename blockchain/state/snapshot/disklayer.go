@@ -0,0 +1,242 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/trie"
+)
+
+// cacheItemSize is the approximate memory footprint of a cached snapshot entry,
+// used to turn the cache allowance into an item count.
+const cacheItemSize = 128
+
+// diskLayer is a low level persistent snapshot built on top of a key-value store.
+type diskLayer struct {
+	diskdb hpbdb.Database     // Key-value store containing the base snapshot
+	triedb *trie.NodeDatabase // Trie node cache for reconstruction purposes
+	cache  *lru.Cache         // Cache to avoid hitting the disk for direct access
+
+	root  common.Hash // Root hash of the base snapshot
+	stale bool        // Signals that the layer became stale (state progressed)
+
+	genMarker []byte             // Marker for the state that's indexed during initial layer generation
+	genAbort  chan chan struct{} // Notification channel to abort generating the snapshot in this layer
+
+	lock sync.RWMutex
+}
+
+// newDiskLayer creates a disk layer for the given root, sharing the read cache
+// of a previous layer if one is given.
+func newDiskLayer(diskdb hpbdb.Database, triedb *trie.NodeDatabase, cache *lru.Cache, root common.Hash, marker []byte) *diskLayer {
+	return &diskLayer{
+		diskdb:    diskdb,
+		triedb:    triedb,
+		cache:     cache,
+		root:      root,
+		genMarker: marker,
+	}
+}
+
+// newCache creates the read cache of the disk layers for the given allowance
+// in megabytes.
+func newCache(size int) *lru.Cache {
+	items := size * 1024 * 1024 / cacheItemSize
+	if items < 1 {
+		items = 1
+	}
+	cache, _ := lru.New(items)
+	return cache
+}
+
+// loadSnapshot loads the disk layer persisted in the database, together with
+// the progress of a generation still in flight.
+func loadSnapshot(diskdb hpbdb.Database, triedb *trie.NodeDatabase, cache int) (*diskLayer, error) {
+	blob, _ := diskdb.Get(snapshotRootKey)
+	if len(blob) != common.HashLength {
+		return nil, errors.New("missing or corrupted snapshot")
+	}
+	var marker []byte
+	if blob, err := diskdb.Get(snapshotGeneratorKey); err == nil {
+		marker = append([]byte{}, blob...)
+	}
+	return newDiskLayer(diskdb, triedb, newCache(cache), common.BytesToHash(blob), marker), nil
+}
+
+// Root returns root hash for which this snapshot was made.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent always returns nil as there's no layer below the disk.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale return whether this layer has become stale (was flattened across) or if
+// it's still live.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// markStale sets the stale flag as true.
+func (dl *diskLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// covered reports whether the entries of the given account were already
+// generated into the disk layer.
+func covered(marker []byte, accountHash common.Hash) bool {
+	return marker == nil || bytes.Compare(accountHash[:], marker) <= 0
+}
+
+// Account directly retrieves the account RLP associated with a particular
+// hash in the snapshot.
+func (dl *diskLayer) Account(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	// If the layer was flattened into, consider it invalid (any live reference to
+	// the original should be marked as unusable).
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	// If the layer is being generated, ensure the requested hash has already been
+	// covered by the generator.
+	if !covered(dl.genMarker, hash) {
+		return nil, ErrNotCoveredYet
+	}
+	return dl.get(accountKey(hash)), nil
+}
+
+// Storage directly retrieves the storage data associated with a particular hash,
+// within a particular account.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !covered(dl.genMarker, accountHash) {
+		return nil, ErrNotCoveredYet
+	}
+	return dl.get(storageKey(accountHash, storageHash)), nil
+}
+
+// get retrieves a snapshot entry from the cache, falling back to the database.
+func (dl *diskLayer) get(key []byte) []byte {
+	if blob, found := dl.cache.Get(string(key)); found {
+		return blob.([]byte)
+	}
+	blob, _ := dl.diskdb.Get(key)
+	dl.cache.Add(string(key), blob)
+	return blob
+}
+
+// flatten pushes the contents of the given diff layer, which must sit directly
+// on top of this disk layer, into the database. The returned disk layer replaces
+// the current one, which becomes stale along with the flattened diff.
+func (dl *diskLayer) flatten(bottom *diffLayer) (*diskLayer, error) {
+	// The generator must not write concurrently, pause it until the new layer
+	// is created. Entries it didn't reach yet are skipped, it will pick up their
+	// new values from the new root.
+	dl.stopGeneration()
+
+	dl.lock.RLock()
+	marker := dl.genMarker
+	dl.lock.RUnlock()
+
+	// Drop the root marker first, so a crash midway forces a regeneration
+	if err := dl.diskdb.Delete(snapshotRootKey); err != nil {
+		return nil, err
+	}
+	// The contents of a diff layer are never modified after its creation, only
+	// its parent link and staleness change, so no need to hold its lock.
+	for hash := range bottom.destructSet {
+		if !covered(marker, hash) {
+			continue
+		}
+		key := accountKey(hash)
+		if err := dl.diskdb.Delete(key); err != nil {
+			return nil, err
+		}
+		dl.cache.Remove(string(key))
+
+		if err := wipeStorage(dl.diskdb, hash, func(key []byte) { dl.cache.Remove(string(key)) }); err != nil {
+			return nil, err
+		}
+	}
+	batch := dl.diskdb.NewBatch()
+	write := func(key []byte, data []byte) error {
+		dl.cache.Add(string(key), data)
+		if len(data) == 0 {
+			return dl.diskdb.Delete(key)
+		}
+		return batch.Put(key, data)
+	}
+	for hash, data := range bottom.accountData {
+		if !covered(marker, hash) {
+			continue
+		}
+		if err := write(accountKey(hash), data); err != nil {
+			return nil, err
+		}
+	}
+	for accountHash, storage := range bottom.storageData {
+		if !covered(marker, accountHash) {
+			continue
+		}
+		for storageHash, data := range storage {
+			if err := write(storageKey(accountHash, storageHash), data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if marker != nil {
+		if err := batch.Put(snapshotGeneratorKey, marker); err != nil {
+			return nil, err
+		}
+	}
+	if err := batch.Put(snapshotRootKey, bottom.root[:]); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	res := newDiskLayer(dl.diskdb, dl.triedb, dl.cache, bottom.root, marker)
+
+	dl.markStale()
+	bottom.markStale()
+
+	if marker != nil {
+		res.startGeneration()
+	}
+	return res, nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"time"
+
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/common/rlp"
+	"github.com/hpb-project/go-hpb/common/trie"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+)
+
+// generatorLogInterval is the frequency of the generation progress reports.
+const generatorLogInterval = 8 * time.Second
+
+// account mirrors the consensus representation of a state account, only the
+// storage root is needed by the generator.
+type account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// generateSnapshot regenerates a brand new snapshot based on an existing state
+// database and head block, asynchronously. The snapshot is returned immediately
+// and generation is continued in the background until done.
+func generateSnapshot(diskdb hpbdb.Database, triedb *trie.NodeDatabase, cache int, root common.Hash) *diskLayer {
+	batch := diskdb.NewBatch()
+	batch.Put(snapshotRootKey, root[:])
+	batch.Put(snapshotGeneratorKey, []byte{})
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write initialized state marker", "err", err)
+	}
+	base := newDiskLayer(diskdb, triedb, newCache(cache), root, []byte{})
+	base.startGeneration()
+	return base
+}
+
+// startGeneration launches the background generator of the layer.
+func (dl *diskLayer) startGeneration() {
+	dl.genAbort = make(chan chan struct{})
+	go dl.generate(dl.genAbort)
+}
+
+// stopGeneration aborts the background generator of the layer, if any, and waits
+// for it to persist its progress. The caller must hold the tree lock.
+func (dl *diskLayer) stopGeneration() {
+	if dl.genAbort == nil {
+		return
+	}
+	abort := make(chan struct{})
+	dl.genAbort <- abort
+	<-abort
+	dl.genAbort = nil
+}
+
+// generate is a background thread that iterates over the state and storage tries
+// and constructs the state snapshot. All the arguments are purely for statistics
+// gathering and logging, since the method surfs the blocks as they arrive, often
+// being restarted. Once done or failed, it waits for the abort signal so that
+// stopping a layer never blocks.
+func (dl *diskLayer) generate(abort chan chan struct{}) {
+	dl.lock.RLock()
+	marker := dl.genMarker
+	dl.lock.RUnlock()
+
+	var (
+		start    = time.Now()
+		logged   = start
+		accounts int
+		slots    int
+	)
+	fail := func(msg string, err error) {
+		log.Error(msg, "root", dl.root, "err", err)
+		(<-abort) <- struct{}{}
+	}
+	// Generating from scratch, wipe any leftovers of an older snapshot first
+	if len(marker) == 0 {
+		log.Info("Wiping previous state snapshot", "root", dl.root)
+		aborted, err := wipeSnapshot(dl.diskdb, abort)
+		if aborted != nil {
+			aborted <- struct{}{}
+			return
+		}
+		if err != nil {
+			fail("Failed to wipe previous snapshot", err)
+			return
+		}
+	}
+	accTrie, err := trie.NewSecure(dl.root, dl.triedb, 0)
+	if err != nil {
+		fail("Generator failed to access account trie", err)
+		return
+	}
+	var (
+		batch = dl.diskdb.NewBatch()
+		it    = trie.NewIterator(accTrie.NodeIterator(marker))
+	)
+	for it.Next() {
+		// The marker itself was already generated before a restart
+		if len(marker) > 0 && bytes.Compare(it.Key, marker) <= 0 {
+			continue
+		}
+		accountHash := common.BytesToHash(it.Key)
+		batch.Put(accountKey(accountHash), common.CopyBytes(it.Value))
+
+		var acc account
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			fail("Generator failed to decode account", err)
+			return
+		}
+		// Storage is generated in the same batch as its account, the marker only
+		// ever points to fully generated accounts.
+		if acc.Root != emptyRoot && acc.Root != (common.Hash{}) {
+			storeTrie, err := trie.NewSecure(acc.Root, dl.triedb, 0)
+			if err != nil {
+				fail("Generator failed to access storage trie", err)
+				return
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+			for storeIt.Next() {
+				batch.Put(storageKey(accountHash, common.BytesToHash(storeIt.Key)), common.CopyBytes(storeIt.Value))
+				slots++
+			}
+			if storeIt.Err != nil {
+				fail("Generator failed to iterate storage trie", storeIt.Err)
+				return
+			}
+		}
+		accounts++
+
+		if batch.ValueSize() > hpbdb.IdealBatchSize {
+			if err := dl.checkpoint(batch, accountHash[:]); err != nil {
+				fail("Failed to persist snapshot generation", err)
+				return
+			}
+			batch = dl.diskdb.NewBatch()
+		}
+		select {
+		case aborted := <-abort:
+			if err := dl.checkpoint(batch, accountHash[:]); err != nil {
+				log.Error("Failed to persist snapshot generation", "root", dl.root, "err", err)
+			}
+			log.Debug("Aborted state snapshot generation", "root", dl.root, "at", accountHash, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			aborted <- struct{}{}
+			return
+		default:
+		}
+		if time.Since(logged) > generatorLogInterval {
+			log.Info("Generating state snapshot", "root", dl.root, "at", accountHash, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if it.Err != nil {
+		fail("Generator failed to iterate account trie", it.Err)
+		return
+	}
+	// Snapshot fully generated, drop the progress marker
+	if err := batch.Write(); err != nil {
+		fail("Failed to persist snapshot generation", err)
+		return
+	}
+	if err := dl.diskdb.Delete(snapshotGeneratorKey); err != nil {
+		fail("Failed to remove snapshot generation marker", err)
+		return
+	}
+	dl.lock.Lock()
+	dl.genMarker = nil
+	dl.lock.Unlock()
+
+	log.Info("Generated state snapshot", "root", dl.root, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	(<-abort) <- struct{}{}
+}
+
+// checkpoint flushes the generated entries along with the progress marker and
+// makes them visible to readers.
+func (dl *diskLayer) checkpoint(batch hpbdb.Batch, marker []byte) error {
+	if err := batch.Put(snapshotGeneratorKey, marker); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	dl.lock.Lock()
+	dl.genMarker = common.CopyBytes(marker)
+	dl.lock.Unlock()
+	return nil
+}
+
+// wipeSnapshot deletes all the snapshot entries from the database. If an abort
+// request arrives meanwhile, the wipe is interrupted and the request returned.
+func wipeSnapshot(db hpbdb.Database, abort chan chan struct{}) (chan struct{}, error) {
+	var (
+		aborted chan struct{}
+		err     error
+	)
+	wipe := func(key []byte) bool {
+		select {
+		case aborted = <-abort:
+			return false
+		default:
+		}
+		err = db.Delete(key)
+		return err == nil
+	}
+	for _, kind := range []struct {
+		prefix []byte
+		length int
+	}{
+		{snapshotAccountPrefix, accountKeyLength},
+		{snapshotStoragePrefix, storageKeyLength},
+	} {
+		if ierr := iterateKeys(db, kind.prefix, kind.length, wipe); ierr != nil {
+			return nil, ierr
+		}
+		if aborted != nil || err != nil {
+			break
+		}
+	}
+	return aborted, err
+}
@@ -0,0 +1,259 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat, hash indexed view of the account and
+// storage tries, layered as a persistent disk layer with in-memory diff layers
+// on top of it, one for every recent block.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/common/trie"
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the underlying snapshot
+	// is being generated currently and the requested data item is not yet in the
+	// range of accounts covered.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+// Accounts and storage slots are indexed by the hash of their keys and are
+// returned in the same RLP encoding the state tries hold them in.
+type Snapshot interface {
+	// Root returns the root hash for which this snapshot was made.
+	Root() common.Hash
+
+	// Account directly retrieves the RLP encoded account associated with a
+	// particular hash in the snapshot. A nil blob means the account doesn't exist.
+	Account(hash common.Hash) ([]byte, error)
+
+	// Storage directly retrieves the RLP encoded storage slot associated with a
+	// particular account and slot hash in the snapshot.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports
+// some additional methods compared to the public API.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of a snapshot, or nil if the base was
+	// reached.
+	Parent() snapshot
+
+	// Stale returns whether this layer has become stale (was flattened across)
+	// or if it's still live.
+	Stale() bool
+}
+
+// Tree is a hpb state snapshot tree. It consists of one persistent base layer
+// backed by a key-value store, on top of which arbitrarily many in-memory diff
+// layers are topped. The memory diffs can form a tree with branching, but the
+// disk layer is singleton and common to all. If a reorg goes deeper than the
+// disk layer, everything needs to be regenerated.
+type Tree struct {
+	diskdb hpbdb.Database           // Persistent database to store the snapshot
+	triedb *trie.NodeDatabase       // In-memory cache to access the tries through
+	cache  int                      // Megabytes permitted to use for read caches
+	layers map[common.Hash]snapshot // Collection of all known layers
+	lock   sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store. Diff layers are not journalled, they are flattened into the disk layer
+// on shutdown instead. If the snapshot is missing or does not match the given
+// head root, it is rebuilt from the tries in the background.
+func New(diskdb hpbdb.Database, triedb *trie.NodeDatabase, cache int, root common.Hash) *Tree {
+	snap := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		cache:  cache,
+		layers: make(map[common.Hash]snapshot),
+	}
+	base, err := loadSnapshot(diskdb, triedb, cache)
+	if err != nil || base.root != root {
+		if err == nil {
+			err = fmt.Errorf("head doesn't match snapshot: have %#x, want %#x", base.root, root)
+		}
+		log.Warn("Failed to load snapshot, regenerating", "err", err)
+		snap.Rebuild(root)
+		return snap
+	}
+	snap.layers[base.root] = base
+	if base.genMarker != nil {
+		base.startGeneration()
+	}
+	return snap
+}
+
+// Snapshot retrieves a snapshot belonging to the given block root, or nil if no
+// snapshot is maintained for that block.
+func (t *Tree) Snapshot(blockRoot common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.layers[blockRoot]
+}
+
+// Update adds a new snapshot into the tree, if that can be linked to an existing
+// old parent. It is disallowed to insert a disk layer (the origin of all).
+func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	// Reject noop updates to avoid self-loops in the snapshot tree. This is a
+	// special case that can only happen for blocks not changing the state.
+	if blockRoot == parentRoot {
+		return errSnapshotCycle
+	}
+	// Generate a new snapshot on top of the parent
+	parent := t.Snapshot(parentRoot)
+	if parent == nil {
+		return fmt.Errorf("parent [%#x] snapshot missing", parentRoot)
+	}
+	snap := newDiffLayer(parent.(snapshot), blockRoot, destructs, accounts, storage)
+
+	// Save the new snapshot for later
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.layers[blockRoot]; !ok {
+		t.layers[blockRoot] = snap
+	}
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from a head block hash until the
+// number of allowed layers are crossed. All layers beyond the permitted number
+// are flattened downwards into the disk layer.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	// Retrieve the head snapshot to cap from
+	snap := t.Snapshot(root)
+	if snap == nil {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := snap.(*diffLayer)
+	if !ok {
+		return nil // Disk layer, nothing to flatten
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Walk down to the bottom-most layer that needs to be retained and collect
+	// all the layers below it that need to be pushed into the disk layer.
+	if layers > 0 {
+		for i := 0; i < layers-1; i++ {
+			parent, ok := diff.Parent().(*diffLayer)
+			if !ok {
+				return nil // Not enough layers to cap
+			}
+			diff = parent
+		}
+	}
+	var flatten []*diffLayer
+	for bottom := snapshot(diff); bottom != nil; bottom = bottom.Parent() {
+		if layer, ok := bottom.(*diffLayer); ok && (layers == 0 || layer != diff) {
+			flatten = append(flatten, layer)
+		}
+	}
+	if len(flatten) == 0 {
+		return nil
+	}
+	// Push the collected layers into the disk one by one, oldest first
+	for i := len(flatten) - 1; i >= 0; i-- {
+		base := flatten[i].Parent().(*diskLayer)
+		disk, err := base.flatten(flatten[i])
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			flatten[i-1].setParent(disk)
+		} else if layers > 0 {
+			diff.setParent(disk)
+		}
+		t.layers[disk.root] = disk
+	}
+	// Drop all the layers that no longer link to the live disk layer
+	for root, layer := range t.layers {
+		if isStale(layer) {
+			if diff, ok := layer.(*diffLayer); ok {
+				diff.markStale()
+			}
+			delete(t.layers, root)
+		}
+	}
+	return nil
+}
+
+// isStale reports whether a layer or any of its ancestors got invalidated.
+func isStale(layer snapshot) bool {
+	for ; layer != nil; layer = layer.Parent() {
+		if layer.Stale() {
+			return true
+		}
+	}
+	return false
+}
+
+// Rebuild wipes all available snapshot data from the persistent database and
+// discards all caches and diff layers. Afterwards, it starts a new snapshot
+// generator with the given root hash.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Invalidate all the layers, aborting a generator still running
+	for _, layer := range t.layers {
+		switch layer := layer.(type) {
+		case *diskLayer:
+			layer.stopGeneration()
+			layer.markStale()
+		case *diffLayer:
+			layer.markStale()
+		}
+	}
+	// Start generating a new snapshot from scratch on a background thread
+	log.Info("Rebuilding state snapshot", "root", root)
+	base := generateSnapshot(t.diskdb, t.triedb, t.cache, root)
+	t.layers = map[common.Hash]snapshot{root: base}
+}
+
+// Close stops a running background generation, persisting its progress so it
+// can be resumed on the next startup.
+func (t *Tree) Close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, layer := range t.layers {
+		if disk, ok := layer.(*diskLayer); ok {
+			disk.stopGeneration()
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/common/rlp"
+	"github.com/hpb-project/go-hpb/common/trie"
+)
+
+// testState is a small account and storage trie set committed to a database.
+type testState struct {
+	diskdb   *hpbdb.MemDatabase
+	triedb   *trie.NodeDatabase
+	root     common.Hash
+	accounts map[common.Hash][]byte
+	storage  map[common.Hash]map[common.Hash][]byte
+}
+
+// newTestState creates a state of a handful of accounts, every second of them
+// having some storage slots.
+func newTestState(t *testing.T) *testState {
+	diskdb, _ := hpbdb.NewMemDatabase()
+	s := &testState{
+		diskdb:   diskdb,
+		triedb:   trie.NewNodeDatabase(diskdb, nil),
+		accounts: make(map[common.Hash][]byte),
+		storage:  make(map[common.Hash]map[common.Hash][]byte),
+	}
+	accTrie, _ := trie.NewSecure(common.Hash{}, s.triedb, 0)
+	for i := byte(1); i <= 16; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		acc := account{Nonce: uint64(i), Balance: big.NewInt(int64(i) * 100), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)}
+
+		if i%2 == 0 {
+			storeTrie, _ := trie.NewSecure(common.Hash{}, s.triedb, 0)
+			slots := make(map[common.Hash][]byte)
+			for j := byte(1); j <= i; j++ {
+				key := common.Hash{j}
+				value, _ := rlp.EncodeToBytes([]byte{i, j})
+				storeTrie.Update(key[:], value)
+				slots[crypto.Keccak256Hash(key[:])] = value
+			}
+			root, err := storeTrie.CommitTo(s.triedb)
+			if err != nil {
+				t.Fatalf("failed to commit storage trie: %v", err)
+			}
+			acc.Root = root
+			s.storage[crypto.Keccak256Hash(addr[:])] = slots
+		}
+		blob, _ := rlp.EncodeToBytes(acc)
+		accTrie.Update(addr[:], blob)
+		s.accounts[crypto.Keccak256Hash(addr[:])] = blob
+	}
+	root, err := accTrie.CommitTo(s.triedb)
+	if err != nil {
+		t.Fatalf("failed to commit account trie: %v", err)
+	}
+	s.root = root
+	return s
+}
+
+// waitGeneration blocks until the disk layer of the tree is fully generated.
+func waitGeneration(t *testing.T, tree *Tree, root common.Hash) *diskLayer {
+	disk, ok := tree.Snapshot(root).(*diskLayer)
+	if !ok {
+		t.Fatalf("snapshot %x is not a disk layer", root)
+	}
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		disk.lock.RLock()
+		done := disk.genMarker == nil
+		disk.lock.RUnlock()
+		if done {
+			return disk
+		}
+	}
+	t.Fatalf("snapshot generation timed out")
+	return nil
+}
+
+// Tests that a snapshot generated from the tries serves the same data.
+func TestSnapshotGeneration(t *testing.T) {
+	state := newTestState(t)
+
+	tree := New(state.diskdb, state.triedb, 16, state.root)
+	defer tree.Close()
+
+	disk := waitGeneration(t, tree, state.root)
+	for hash, want := range state.accounts {
+		if blob, err := disk.Account(hash); err != nil || !bytes.Equal(blob, want) {
+			t.Errorf("account %x: have %x/%v, want %x", hash, blob, err, want)
+		}
+	}
+	for accountHash, slots := range state.storage {
+		for hash, want := range slots {
+			if blob, err := disk.Storage(accountHash, hash); err != nil || !bytes.Equal(blob, want) {
+				t.Errorf("slot %x/%x: have %x/%v, want %x", accountHash, hash, blob, err, want)
+			}
+		}
+	}
+	if blob, err := disk.Account(common.Hash{0xff}); err != nil || blob != nil {
+		t.Errorf("missing account: have %x/%v, want nil", blob, err)
+	}
+	// A reopened tree must reuse the generated snapshot
+	tree.Close()
+	tree = New(state.diskdb, state.triedb, 16, state.root)
+	if disk, ok := tree.Snapshot(state.root).(*diskLayer); !ok || disk.genMarker != nil {
+		t.Fatalf("persisted snapshot not loaded")
+	}
+}
+
+// Tests that diff layers shadow their parents and are flattened into the disk
+// layer on capping, dropping the branches that don't link to it any more.
+func TestSnapshotDiffLayers(t *testing.T) {
+	state := newTestState(t)
+
+	tree := New(state.diskdb, state.triedb, 16, state.root)
+	defer tree.Close()
+	waitGeneration(t, tree, state.root)
+
+	var (
+		changed   = crypto.Keccak256Hash(common.BytesToAddress([]byte{1}).Bytes())
+		destroyed = crypto.Keccak256Hash(common.BytesToAddress([]byte{2}).Bytes())
+		slot      = crypto.Keccak256Hash(common.Hash{1}.Bytes())
+	)
+	// Chain of three layers, plus a side branch forking off the disk layer
+	if err := tree.Update(common.Hash{0x01}, state.root, nil, map[common.Hash][]byte{changed: {0x01}}, nil); err != nil {
+		t.Fatalf("failed to add layer 1: %v", err)
+	}
+	if err := tree.Update(common.Hash{0x02}, common.Hash{0x01}, map[common.Hash]struct{}{destroyed: {}}, nil, nil); err != nil {
+		t.Fatalf("failed to add layer 2: %v", err)
+	}
+	if err := tree.Update(common.Hash{0x03}, common.Hash{0x02}, nil, map[common.Hash][]byte{changed: {0x03}}, nil); err != nil {
+		t.Fatalf("failed to add layer 3: %v", err)
+	}
+	if err := tree.Update(common.Hash{0x0a}, state.root, nil, nil, nil); err != nil {
+		t.Fatalf("failed to add side layer: %v", err)
+	}
+	if err := tree.Update(common.Hash{0x04}, common.Hash{0xff}, nil, nil, nil); err == nil {
+		t.Fatalf("layer without parent accepted")
+	}
+	head := tree.Snapshot(common.Hash{0x03})
+	if blob, _ := head.Account(changed); !bytes.Equal(blob, []byte{0x03}) {
+		t.Errorf("changed account mismatch: have %x, want 03", blob)
+	}
+	if blob, _ := tree.Snapshot(common.Hash{0x01}).Account(changed); !bytes.Equal(blob, []byte{0x01}) {
+		t.Errorf("changed account in layer 1 mismatch: have %x, want 01", blob)
+	}
+	if blob, _ := head.Account(destroyed); blob != nil {
+		t.Errorf("destroyed account still present: %x", blob)
+	}
+	if blob, _ := head.Storage(destroyed, slot); blob != nil {
+		t.Errorf("destroyed storage still present: %x", blob)
+	}
+	if blob, _ := tree.Snapshot(common.Hash{0x01}).Storage(destroyed, slot); blob == nil {
+		t.Errorf("storage missing below the destruction")
+	}
+	// Keep only the head layer in memory, the rest goes to disk
+	if err := tree.Cap(common.Hash{0x03}, 1); err != nil {
+		t.Fatalf("failed to cap tree: %v", err)
+	}
+	if _, ok := tree.Snapshot(common.Hash{0x02}).(*diskLayer); !ok {
+		t.Fatalf("layer 2 not flattened into disk layer")
+	}
+	if tree.Snapshot(common.Hash{0x0a}) != nil || tree.Snapshot(state.root) != nil {
+		t.Errorf("stale layers retained")
+	}
+	if _, err := tree.Snapshot(common.Hash{0x03}).Account(changed); err != nil {
+		t.Errorf("head layer unusable after cap: %v", err)
+	}
+	if _, err := head.Account(common.Hash{0xee}); err != nil {
+		t.Errorf("head layer lost its parent: %v", err)
+	}
+	if blob, _ := state.diskdb.Get(accountKey(changed)); !bytes.Equal(blob, []byte{0x01}) {
+		t.Errorf("flattened account mismatch: have %x, want 01", blob)
+	}
+	if ok, _ := state.diskdb.Has(accountKey(destroyed)); ok {
+		t.Errorf("destroyed account still on disk")
+	}
+	if ok, _ := state.diskdb.Has(storageKey(destroyed, slot)); ok {
+		t.Errorf("destroyed storage still on disk")
+	}
+	if blob, _ := state.diskdb.Get(snapshotRootKey); common.BytesToHash(blob) != (common.Hash{0x02}) {
+		t.Errorf("disk root mismatch: have %x, want %x", blob, common.Hash{0x02})
+	}
+}
+
+// Tests that a rebuild wipes any stale snapshot data and invalidates all the
+// layers existing before.
+func TestSnapshotRebuild(t *testing.T) {
+	state := newTestState(t)
+
+	// Leave some junk behind that the rebuild needs to get rid of
+	junk := common.Hash{0xde, 0xad}
+	state.diskdb.Put(accountKey(junk), []byte{0x01})
+	state.diskdb.Put(storageKey(junk, junk), []byte{0x01})
+
+	tree := New(state.diskdb, state.triedb, 16, state.root)
+	defer tree.Close()
+	disk := waitGeneration(t, tree, state.root)
+
+	if blob, _ := disk.Account(junk); blob != nil {
+		t.Errorf("junk account survived rebuild: %x", blob)
+	}
+	if ok, _ := state.diskdb.Has(storageKey(junk, junk)); ok {
+		t.Errorf("junk storage survived rebuild")
+	}
+	if ok, _ := state.diskdb.Has(snapshotGeneratorKey); ok {
+		t.Errorf("generator marker left behind")
+	}
+	// Rebuilding invalidates all the existing layers
+	tree.Update(common.Hash{0x01}, state.root, nil, nil, nil)
+	old := tree.Snapshot(common.Hash{0x01})
+
+	tree.Rebuild(state.root)
+	if _, err := old.Account(junk); err != ErrSnapshotStale {
+		t.Errorf("old layer error mismatch: have %v, want %v", err, ErrSnapshotStale)
+	}
+	waitGeneration(t, tree, state.root)
+	for hash, want := range state.accounts {
+		if blob, _ := tree.Snapshot(state.root).Account(hash); !bytes.Equal(blob, want) {
+			t.Errorf("account %x: have %x, want %x", hash, blob, want)
+		}
+	}
+}
+
+// Tests that flattening into a disk layer still being generated only persists
+// the entries already covered by the generator, it picks up the rest later.
+func TestSnapshotFlattenGenerating(t *testing.T) {
+	diskdb, _ := hpbdb.NewMemDatabase()
+	marker := common.Hash{0x80}
+	base := newDiskLayer(diskdb, trie.NewNodeDatabase(diskdb, nil), newCache(1), common.Hash{0x01}, marker[:])
+
+	var (
+		done    = common.Hash{0x10}
+		pending = common.Hash{0x90}
+		slot    = common.Hash{0x01}
+	)
+	diff := newDiffLayer(base, common.Hash{0x02}, nil,
+		map[common.Hash][]byte{done: {0x01}, pending: {0x02}},
+		map[common.Hash]map[common.Hash][]byte{done: {slot: {0x01}}, pending: {slot: {0x02}}},
+	)
+	disk, err := base.flatten(diff)
+	if err != nil {
+		t.Fatalf("failed to flatten layer: %v", err)
+	}
+	defer disk.stopGeneration()
+
+	if !base.Stale() || !diff.Stale() {
+		t.Errorf("flattened layers not marked stale")
+	}
+	if blob, err := disk.Account(done); err != nil || !bytes.Equal(blob, []byte{0x01}) {
+		t.Errorf("covered account: have %x/%v, want 01", blob, err)
+	}
+	if _, err := disk.Account(pending); err != ErrNotCoveredYet {
+		t.Errorf("uncovered account error mismatch: have %v, want %v", err, ErrNotCoveredYet)
+	}
+	if ok, _ := diskdb.Has(storageKey(done, slot)); !ok {
+		t.Errorf("covered storage not flattened")
+	}
+	if ok, _ := diskdb.Has(accountKey(pending)); ok {
+		t.Errorf("uncovered account flattened")
+	}
+	if ok, _ := diskdb.Has(storageKey(pending, slot)); ok {
+		t.Errorf("uncovered storage flattened")
+	}
+	if blob, _ := diskdb.Get(snapshotGeneratorKey); !bytes.Equal(blob, marker[:]) {
+		t.Errorf("generator marker mismatch: have %x, want %x", blob, marker)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"sort"
 	"sync"
 
+	"github.com/hpb-project/go-hpb/blockchain/state/snapshot"
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
 	"github.com/hpb-project/go-hpb/common/crypto"
@@ -34,6 +35,7 @@ import (
 type revision struct {
 	id           int
 	journalIndex int
+	recordIndex  int
 }
 
 // StateDBs within the hpb protocol are used to store anything
@@ -45,6 +47,14 @@ type StateDB struct {
 	db   Database
 	trie Trie
 
+	// Flat snapshot of the state the trie was opened at, consulted before the
+	// trie, and the changes to push into the snapshot tree on commit.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
@@ -72,24 +82,49 @@ type StateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// Tracker of the state accessed, nil if not recording.
+	record *AccessRecord
+
 	lock sync.Mutex
 }
 
 // Create a new state from a given trie
 func New(root common.Hash, db Database) (*StateDB, error) {
+	return NewWithSnapshot(root, db, nil)
+}
+
+// NewWithSnapshot creates a new state from a given trie, serving reads from the
+// flat snapshot of the root if the snapshot tree maintains one.
+func NewWithSnapshot(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error) {
 	tr, err := db.OpenTrie(root)
 	if err != nil {
 		return nil, err
 	}
-	return &StateDB{
+	sdb := &StateDB{
 		db:                db,
 		trie:              tr,
+		snaps:             snaps,
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		refund:            new(big.Int),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
-	}, nil
+	}
+	sdb.openSnapshot(root)
+	return sdb, nil
+}
+
+// openSnapshot attaches the snapshot of the given root, if there's any.
+func (self *StateDB) openSnapshot(root common.Hash) {
+	self.snap, self.snapDestructs, self.snapAccounts, self.snapStorage = nil, nil, nil, nil
+	if self.snaps == nil {
+		return
+	}
+	if self.snap = self.snaps.Snapshot(root); self.snap != nil {
+		self.snapDestructs = make(map[common.Hash]struct{})
+		self.snapAccounts = make(map[common.Hash][]byte)
+		self.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
 }
 
 // setError remembers the first non-nil error it is called with.
@@ -111,6 +146,7 @@ func (self *StateDB) Reset(root common.Hash) error {
 		return err
 	}
 	self.trie = tr
+	self.openSnapshot(root)
 	self.stateObjects = make(map[common.Address]*stateObject)
 	self.stateObjectsDirty = make(map[common.Address]struct{})
 	self.thash = common.Hash{}
@@ -124,6 +160,7 @@ func (self *StateDB) Reset(root common.Hash) error {
 }
 
 func (self *StateDB) AddLog(log *types.Log) {
+	self.writeOther(func(db *StateDB) { db.AddLog(log) })
 	self.journal = append(self.journal, addLogChange{txhash: self.thash})
 
 	log.TxHash = self.thash
@@ -148,6 +185,10 @@ func (self *StateDB) Logs() []*types.Log {
 
 // AddPreimage records a SHA3 preimage seen by the VM.
 func (self *StateDB) AddPreimage(hash common.Hash, preimage []byte) {
+	if self.record != nil {
+		preimage := common.CopyBytes(preimage)
+		self.writeOther(func(db *StateDB) { db.AddPreimage(hash, preimage) })
+	}
 	if _, ok := self.preimages[hash]; !ok {
 		self.journal = append(self.journal, addPreimageChange{hash: hash})
 		pi := make([]byte, len(preimage))
@@ -169,12 +210,14 @@ func (self *StateDB) AddRefund(gas *big.Int) {
 // Exist reports whether the given account address exists in the state.
 // Notably this also returns true for suicided accounts.
 func (self *StateDB) Exist(addr common.Address) bool {
+	self.readAccount(addr)
 	return self.getStateObject(addr) != nil
 }
 
 // Empty returns whether the state object is either non-existent
 // or empty according to the EIP161 specification (balance = nonce = code = 0)
 func (self *StateDB) Empty(addr common.Address) bool {
+	self.readAccount(addr)
 	so := self.getStateObject(addr)
 	return so == nil || so.empty()
 }
@@ -183,6 +226,7 @@ func (self *StateDB) Empty(addr common.Address) bool {
 func (self *StateDB) GetBalance(addr common.Address) *big.Int {
 	self.lock.Lock()
 	defer self.lock.Unlock()
+	self.readAccount(addr)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Balance()
@@ -193,6 +237,7 @@ func (self *StateDB) GetBalance(addr common.Address) *big.Int {
 func (self *StateDB) GetNonce(addr common.Address) uint64 {
 	self.lock.Lock()
 	defer self.lock.Unlock()
+	self.readAccount(addr)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Nonce()
@@ -204,6 +249,7 @@ func (self *StateDB) GetNonce(addr common.Address) uint64 {
 func (self *StateDB) GetCode(addr common.Address) []byte {
 	self.lock.Lock()
 	defer self.lock.Unlock()
+	self.readAccount(addr)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Code(self.db)
@@ -214,6 +260,7 @@ func (self *StateDB) GetCode(addr common.Address) []byte {
 func (self *StateDB) GetCodeSize(addr common.Address) int {
 	self.lock.Lock()
 	defer self.lock.Unlock()
+	self.readAccount(addr)
 	stateObject := self.getStateObject(addr)
 	if stateObject == nil {
 		return 0
@@ -231,6 +278,7 @@ func (self *StateDB) GetCodeSize(addr common.Address) int {
 func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
 	self.lock.Lock()
 	defer self.lock.Unlock()
+	self.readAccount(addr)
 	stateObject := self.getStateObject(addr)
 	if stateObject == nil {
 		return common.Hash{}
@@ -241,6 +289,7 @@ func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
 func (self *StateDB) GetState(a common.Address, b common.Hash) common.Hash {
 	self.lock.Lock()
 	defer self.lock.Unlock()
+	self.readSlot(a, b)
 	stateObject := self.getStateObject(a)
 	if stateObject != nil {
 		return stateObject.GetState(self.db, b)
@@ -261,9 +310,36 @@ func (self *StateDB) StorageTrie(a common.Address) Trie {
 	return cpy.updateTrie(self.db)
 }
 
+// GetProof returns the Merkle proof of an account against the state root.
+func (self *StateDB) GetProof(a common.Address) ([]rlp.RawValue, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	proof := self.trie.Prove(a.Bytes())
+	if proof == nil {
+		return nil, fmt.Errorf("failed to prove account %x", a)
+	}
+	return proof, nil
+}
+
+// GetStorageProof returns the Merkle proof of a storage slot against the storage
+// root of an account. The proof is empty for non-existent accounts.
+func (self *StateDB) GetStorageProof(a common.Address, key common.Hash) ([]rlp.RawValue, error) {
+	tr := self.StorageTrie(a)
+	if tr == nil {
+		return []rlp.RawValue{}, nil
+	}
+	proof := tr.Prove(key.Bytes())
+	if proof == nil {
+		return nil, fmt.Errorf("failed to prove storage slot %x of account %x", key, a)
+	}
+	return proof, nil
+}
+
 func (self *StateDB) HasSuicided(addr common.Address) bool {
 	self.lock.Lock()
 	defer self.lock.Unlock()
+	self.readAccount(addr)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.suicided
@@ -277,6 +353,10 @@ func (self *StateDB) HasSuicided(addr common.Address) bool {
 
 // AddBalance adds amount to the account associated with addr
 func (self *StateDB) AddBalance(addr common.Address, amount *big.Int) {
+	if self.record != nil {
+		amount := new(big.Int).Set(amount)
+		self.writeBalance(addr, amount, func(db *StateDB) { db.AddBalance(addr, amount) })
+	}
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.AddBalance(amount)
@@ -286,6 +366,10 @@ func (self *StateDB) AddBalance(addr common.Address, amount *big.Int) {
 // AddBatchBalance is a batch version of AddBalance
 func (self *StateDB) AddBatchBalance(addrRwd map[common.Address]*big.Int) {
 	for addr, amount := range addrRwd {
+		if self.record != nil {
+			addr, amount := addr, new(big.Int).Set(amount)
+			self.writeBalance(addr, amount, func(db *StateDB) { db.AddBalance(addr, amount) })
+		}
 		stateObject := self.GetOrNewStateObject(addr)
 		if stateObject != nil {
 			stateObject.AddBalance(amount)
@@ -295,6 +379,10 @@ func (self *StateDB) AddBatchBalance(addrRwd map[common.Address]*big.Int) {
 
 // SubBalance subtracts amount from the account associated with addr
 func (self *StateDB) SubBalance(addr common.Address, amount *big.Int) {
+	if self.record != nil {
+		amount := new(big.Int).Set(amount)
+		self.writeBalance(addr, amount, func(db *StateDB) { db.SubBalance(addr, amount) })
+	}
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SubBalance(amount)
@@ -302,6 +390,10 @@ func (self *StateDB) SubBalance(addr common.Address, amount *big.Int) {
 }
 
 func (self *StateDB) SetBalance(addr common.Address, amount *big.Int) {
+	if self.record != nil {
+		amount := new(big.Int).Set(amount)
+		self.writeAccount(addr, func(db *StateDB) { db.SetBalance(addr, amount) })
+	}
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetBalance(amount)
@@ -309,6 +401,7 @@ func (self *StateDB) SetBalance(addr common.Address, amount *big.Int) {
 }
 
 func (self *StateDB) SetNonce(addr common.Address, nonce uint64) {
+	self.writeAccount(addr, func(db *StateDB) { db.SetNonce(addr, nonce) })
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetNonce(nonce)
@@ -316,6 +409,10 @@ func (self *StateDB) SetNonce(addr common.Address, nonce uint64) {
 }
 
 func (self *StateDB) SetCode(addr common.Address, code []byte) {
+	if self.record != nil {
+		code := common.CopyBytes(code)
+		self.writeAccount(addr, func(db *StateDB) { db.SetCode(addr, code) })
+	}
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetCode(crypto.Keccak256Hash(code), code)
@@ -323,6 +420,7 @@ func (self *StateDB) SetCode(addr common.Address, code []byte) {
 }
 
 func (self *StateDB) SetState(addr common.Address, key common.Hash, value common.Hash) {
+	self.writeSlot(addr, key, func(db *StateDB) { db.SetState(addr, key, value) })
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetState(self.db, key, value)
@@ -337,6 +435,7 @@ func (self *StateDB) SetState(addr common.Address, key common.Hash, value common
 func (self *StateDB) Suicide(addr common.Address) bool {
 	self.lock.Lock()
 	defer self.lock.Unlock()
+	self.readAccount(addr)
 	stateObject := self.getStateObject(addr)
 	if stateObject == nil {
 		return false
@@ -346,6 +445,7 @@ func (self *StateDB) Suicide(addr common.Address) bool {
 		prev:        stateObject.suicided,
 		prevbalance: new(big.Int).Set(stateObject.Balance()),
 	})
+	self.writeAccount(addr, func(db *StateDB) { db.Suicide(addr) })
 	stateObject.markSuicided()
 	stateObject.data.Balance = new(big.Int)
 
@@ -364,6 +464,11 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+
+	// Track the account change for the snapshot diff of the block
+	if self.snap != nil {
+		self.snapAccounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -371,6 +476,13 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	// Track the destruction for the snapshot diff of the block
+	if self.snap != nil {
+		self.snapDestructs[stateObject.addrHash] = struct{}{}
+		delete(self.snapAccounts, stateObject.addrHash)
+		delete(self.snapStorage, stateObject.addrHash)
+	}
 }
 func (self *StateDB) GetStateObjects() []common.Address {
 	stateAddress := []common.Address{}
@@ -390,8 +502,17 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot if available, from the database otherwise.
+	var (
+		enc []byte
+		err error
+	)
+	if self.snap != nil {
+		enc, err = self.snapAccount(crypto.Keccak256Hash(addr[:]))
+	}
+	if self.snap == nil || err != nil {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -407,6 +528,30 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 	return obj
 }
 
+// snapAccount retrieves an account from the snapshot, taking the changes already
+// flushed into the trie by this state into account.
+func (self *StateDB) snapAccount(addrHash common.Hash) ([]byte, error) {
+	if data, ok := self.snapAccounts[addrHash]; ok {
+		return data, nil
+	}
+	if _, ok := self.snapDestructs[addrHash]; ok {
+		return nil, nil
+	}
+	return self.snap.Account(addrHash)
+}
+
+// snapSlot retrieves a storage slot from the snapshot, taking the changes already
+// flushed into the storage tries by this state into account.
+func (self *StateDB) snapSlot(addrHash, slotHash common.Hash) ([]byte, error) {
+	if data, ok := self.snapStorage[addrHash][slotHash]; ok {
+		return data, nil
+	}
+	if _, ok := self.snapDestructs[addrHash]; ok {
+		return nil, nil
+	}
+	return self.snap.Storage(addrHash, slotHash)
+}
+
 func (self *StateDB) setStateObject(object *stateObject) {
 	self.stateObjects[object.Address()] = object
 }
@@ -432,12 +577,21 @@ func (self *StateDB) MarkStateObjectDirty(addr common.Address) {
 // the given address, it is overwritten and returned as the second return value.
 func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
 	prev = self.getStateObject(addr)
+
+	// An overwritten account loses its storage, the snapshot must drop it too
+	var prevdestruct bool
+	if self.snap != nil && prev != nil {
+		_, prevdestruct = self.snapDestructs[prev.addrHash]
+		if !prevdestruct {
+			self.snapDestructs[prev.addrHash] = struct{}{}
+		}
+	}
 	newobj = newObject(self, addr, Account{}, self.MarkStateObjectDirty)
 	newobj.setNonce(0) // sets the object to dirty
 	if prev == nil {
 		self.journal = append(self.journal, createObjectChange{account: &addr})
 	} else {
-		self.journal = append(self.journal, resetObjectChange{prev: prev})
+		self.journal = append(self.journal, resetObjectChange{prev: prev, prevdestruct: prevdestruct})
 	}
 	self.setStateObject(newobj)
 	return newobj, prev
@@ -454,6 +608,7 @@ func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObjec
 //
 // Carrying over the balance ensures that Ether doesn't disappear.
 func (self *StateDB) CreateAccount(addr common.Address) {
+	self.writeCreate(addr, func(db *StateDB) { db.CreateAccount(addr) })
 	self.lock.Lock()
 	defer self.lock.Unlock()
 	new, prev := self.createObject(addr)
@@ -465,6 +620,7 @@ func (self *StateDB) CreateAccount(addr common.Address) {
 func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common.Hash) bool) {
 	db.lock.Lock()
 	defer db.lock.Unlock()
+	db.readStorage(addr)
 	so := db.getStateObject(addr)
 	if so == nil {
 		return
@@ -494,7 +650,9 @@ func (self *StateDB) Copy() *StateDB {
 	// Copy all the basic fields, initialize the memory ones
 	state := &StateDB{
 		db:                self.db,
-		trie:              self.trie,
+		trie:              self.db.CopyTrie(self.trie),
+		snaps:             self.snaps,
+		snap:              self.snap,
 		stateObjects:      make(map[common.Address]*stateObject, len(self.stateObjectsDirty)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.stateObjectsDirty)),
 		refund:            new(big.Int).Set(self.refund),
@@ -514,6 +672,23 @@ func (self *StateDB) Copy() *StateDB {
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	if self.snap != nil {
+		state.snapDestructs = make(map[common.Hash]struct{}, len(self.snapDestructs))
+		for hash := range self.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(self.snapAccounts))
+		for hash, data := range self.snapAccounts {
+			state.snapAccounts[hash] = data
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(self.snapStorage))
+		for hash, storage := range self.snapStorage {
+			state.snapStorage[hash] = make(map[common.Hash][]byte, len(storage))
+			for key, data := range storage {
+				state.snapStorage[hash][key] = data
+			}
+		}
+	}
 	return state
 }
 
@@ -521,7 +696,7 @@ func (self *StateDB) Copy() *StateDB {
 func (self *StateDB) Snapshot() int {
 	id := self.nextRevisionId
 	self.nextRevisionId++
-	self.validRevisions = append(self.validRevisions, revision{id, len(self.journal)})
+	self.validRevisions = append(self.validRevisions, revision{id, len(self.journal), self.recordedOps()})
 	return id
 }
 
@@ -548,6 +723,7 @@ func (self *StateDB) RevertToSnapshot(revid int) {
 		self.journal[i].undo(self)
 	}
 	self.journal = self.journal[:snapshot]
+	self.revertRecord(self.validRevisions[idx].recordIndex)
 
 	// Remove invalidated snapshots from the stack.
 	self.validRevisions = self.validRevisions[:idx]
@@ -661,5 +837,18 @@ func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (ro
 	// Write trie changes.
 	root, err = s.trie.CommitTo(dbw)
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
-	return root, err
+	if err != nil {
+		return root, err
+	}
+	// Push the changes of the block as a new diff layer into the snapshot tree.
+	// The committed state is not tracked by the old snapshot any more.
+	if s.snap != nil {
+		if parent := s.snap.Root(); parent != root {
+			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+				log.Warn("Failed to update snapshot tree", "from", parent, "to", root, "err", err)
+			}
+		}
+		s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
+	}
+	return root, nil
 }
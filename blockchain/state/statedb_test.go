@@ -30,9 +30,11 @@ import (
 
 	check "gopkg.in/check.v1"
 
+	"github.com/hpb-project/go-hpb/blockchain/state/snapshot"
 	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -372,3 +374,121 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+// Tests that the storage tries and code committed into the trie database are
+// kept alive by the account trie, even after the root of the state which last
+// modified them is garbage collected.
+func TestTrieDBGarbageCollection(t *testing.T) {
+	db, _ := hpbdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+	triedb := sdb.TrieDB()
+
+	contract := common.BytesToAddress([]byte{0x01})
+	state, _ := New(common.Hash{}, sdb)
+	state.SetCode(contract, bytes.Repeat([]byte{0x60}, 64))
+	for i := byte(0); i < 32; i++ {
+		state.SetState(contract, common.BytesToHash([]byte{i}), common.BytesToHash(bytes.Repeat([]byte{i + 1}, 32)))
+	}
+	first, _ := state.CommitTo(triedb, false)
+	triedb.Reference(first, common.Hash{})
+
+	// Modify an unrelated account, so the contract leaf is shared between roots
+	state, _ = New(first, sdb)
+	state.AddBalance(common.BytesToAddress([]byte{0x02}), big.NewInt(1))
+	second, _ := state.CommitTo(triedb, false)
+	triedb.Reference(second, common.Hash{})
+
+	triedb.Dereference(first)
+	if len(db.Keys()) != 0 {
+		t.Fatalf("state leaked to disk before flush")
+	}
+	state, err := New(second, sdb)
+	if err != nil {
+		t.Fatalf("failed to open second state: %v", err)
+	}
+	if code := state.GetCode(contract); len(code) != 64 {
+		t.Fatalf("contract code collected: have %d bytes, want 64", len(code))
+	}
+	for i := byte(0); i < 32; i++ {
+		want := common.BytesToHash(bytes.Repeat([]byte{i + 1}, 32))
+		if have := state.GetState(contract, common.BytesToHash([]byte{i})); have != want {
+			t.Fatalf("storage slot %d collected: have %x, want %x", i, have, want)
+		}
+	}
+	triedb.Dereference(second)
+	if nodes := triedb.Nodes(); len(nodes) != 0 {
+		t.Fatalf("dangling trie nodes after full dereference: %d", len(nodes))
+	}
+}
+
+// Tests that a state backed by a snapshot serves the same data as the tries and
+// pushes its changes into the snapshot tree on commit.
+func TestSnapshotBackedState(t *testing.T) {
+	db, _ := hpbdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+
+	var (
+		addr1 = common.BytesToAddress([]byte{0x01})
+		addr2 = common.BytesToAddress([]byte{0x02})
+		addr3 = common.BytesToAddress([]byte{0x03})
+		key   = common.Hash{0x01}
+	)
+	state, _ := New(common.Hash{}, sdb)
+	state.SetBalance(addr1, big.NewInt(42))
+	state.SetState(addr1, key, common.Hash{0x02})
+	state.SetNonce(addr2, 7)
+	root, err := state.CommitTo(sdb.TrieDB(), false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	snaps := snapshot.New(db, sdb.TrieDB(), 16, root)
+	defer snaps.Close()
+
+	state, _ = NewWithSnapshot(root, sdb, snaps)
+	if state.snap == nil {
+		t.Fatalf("snapshot not attached to state")
+	}
+	// Overwriting an account and reverting it must not wipe its storage
+	id := state.Snapshot()
+	state.CreateAccount(addr1)
+	state.RevertToSnapshot(id)
+
+	if balance := state.GetBalance(addr1); balance.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 42", balance)
+	}
+	if value := state.GetState(addr1, key); value != (common.Hash{0x02}) {
+		t.Errorf("storage mismatch: have %x, want %x", value, common.Hash{0x02})
+	}
+	if nonce := state.GetNonce(addr2); nonce != 7 {
+		t.Errorf("nonce mismatch: have %d, want 7", nonce)
+	}
+	state.SetState(addr1, key, common.Hash{0x03})
+	state.Suicide(addr2)
+	state.SetBalance(addr3, big.NewInt(1))
+
+	next, err := state.CommitTo(sdb.TrieDB(), false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	snap := snaps.Snapshot(next)
+	if snap == nil {
+		t.Fatalf("committed state missing from snapshot tree")
+	}
+	if blob, _ := snap.Account(crypto.Keccak256Hash(addr2[:])); blob != nil {
+		t.Errorf("suicided account present in snapshot: %x", blob)
+	}
+	if blob, _ := snap.Account(crypto.Keccak256Hash(addr3[:])); blob == nil {
+		t.Errorf("new account missing from snapshot")
+	}
+	// A state on top of the new root must read through the diff layer
+	state, _ = NewWithSnapshot(next, sdb, snaps)
+	if value := state.GetState(addr1, key); value != (common.Hash{0x03}) {
+		t.Errorf("storage mismatch: have %x, want %x", value, common.Hash{0x03})
+	}
+	if state.Exist(addr2) {
+		t.Errorf("suicided account still exists")
+	}
+	if balance := state.GetBalance(addr3); balance.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 1", balance)
+	}
+}
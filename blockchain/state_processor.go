@@ -37,9 +37,10 @@ import (
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
-	config *config.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
-	engine consensus.Engine    // Consensus engine used for block rewards
+	config   *config.ChainConfig // Chain configuration options
+	bc       *BlockChain         // Canonical block chain
+	engine   consensus.Engine    // Consensus engine used for block rewards
+	parallel int                 // Number of goroutines executing transactions in parallel, 1 or less runs them sequentially
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -59,68 +60,100 @@ func NewStateProcessor(config *config.ChainConfig, bc *BlockChain, engine consen
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
 func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (types.Receipts, []*types.Log, *big.Int, error) {
+	// Queue the senders for recovery, a no-op if the import already did so
+	go types.RecoverSenders(types.MakeSigner(p.config), block.Transactions())
+
+	author, _ := p.engine.Author(block.Header())
+
+	// Iterate over and process the individual transactions
+	var (
+		receipts     types.Receipts
+		allLogs      []*types.Log
+		totalUsedGas *big.Int
+		err          error
+	)
+	if p.parallel > 1 && len(block.Transactions()) > 1 {
+		receipts, allLogs, totalUsedGas, err = p.applyTransactionsParallel(block, statedb, author)
+	} else {
+		receipts, allLogs, totalUsedGas, err = p.applyTransactions(block, statedb, author)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ApplyTransactionFinalize(statedb)
+
+	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
+	if _, errfinalize := p.engine.Finalize(p.bc, block.Header(), statedb, block.Transactions(), block.Uncles(), receipts); nil != errfinalize {
+		return nil, nil, nil, errfinalize
+	}
+
+	return receipts, allLogs, totalUsedGas, nil
+}
+
+// applyTransactions runs all the transactions of the block one after the other
+// on top of statedb.
+func (p *StateProcessor) applyTransactions(block *types.Block, statedb *state.StateDB, author common.Address) (types.Receipts, []*types.Log, *big.Int, error) {
 	var (
 		receipts     types.Receipts
-		receipt      *types.Receipt
-		errs         error
 		totalUsedGas = big.NewInt(0)
 		header       = block.Header()
 		allLogs      []*types.Log
 		gp           = new(GasPool).AddGas(block.GasLimit())
 	)
-	bNewVersion := block.Number().Uint64() > consensus.NewContractVersion
-	synsigner := types.MakeSigner(p.config)
-	go func(txs types.Transactions) {
-		for _, tx := range txs {
-			types.ASynSender(synsigner, tx)
+	for i := range block.Transactions() {
+		receipt, err := p.applyTransaction(block, i, author, gp, statedb, header, totalUsedGas)
+		if err != nil {
+			return nil, nil, nil, err
 		}
-	}(block.Transactions())
-
-	// Iterate over and process the individual transactions
-	author, _ := p.engine.Author(block.Header())
-	for i, tx := range block.Transactions() {
-		for try := 2; try > 0; try-- { // try to run tx twice, using hardware once and software once.
-			statedb.Prepare(tx.Hash(), block.Hash(), i)
-			//the tx without contract
-			if bNewVersion {
-				if (tx.To() == nil && len(tx.Data()) > 0) || (tx.To() != nil && len(statedb.GetCode(*tx.To())) > 0) {
-					receipt, _, errs = ApplyTransactionNonFinallize(p.config, p.bc, &author, gp, statedb, header, tx, totalUsedGas)
-				} else {
-					receipt, _, errs = ApplyTransactionNonContractNonFinallize(p.config, p.bc, &author, gp, statedb, header, tx, totalUsedGas)
-				}
-			} else {
-				if len(tx.Data()) > 0 {
-					receipt, _, errs = ApplyTransactionNonFinallize(p.config, p.bc, &author, gp, statedb, header, tx, totalUsedGas)
-				} else {
-					receipt, _, errs = ApplyTransactionNonContractNonFinallize(p.config, p.bc, &author, gp, statedb, header, tx, totalUsedGas)
-				}
-			}
-
-			if errs == ErrNonceTooHigh {
-				// maybe hardware mistake, retry with software.
-				types.Sendercache.Delete(tx.Hash())
-				tx.ClearFromCache()
-				boe.BoeGetInstance().Sleep()
-				continue
-			}
-			break
-		}
-
-		if errs != nil {
-			return nil, nil, nil, errs
-		}
-
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
 	}
-	ApplyTransactionFinalize(statedb)
+	return receipts, allLogs, totalUsedGas, nil
+}
 
-	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
-	if _, errfinalize := p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts); nil != errfinalize {
-		return nil, nil, nil, errfinalize
+// applyTransaction runs the i-th transaction of the block on top of statedb.
+func (p *StateProcessor) applyTransaction(block *types.Block, i int, author common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, usedGas *big.Int) (*types.Receipt, error) {
+	var (
+		receipt *types.Receipt
+		errs    error
+		tx      = block.Transactions()[i]
+	)
+	for try := 2; try > 0; try-- { // try to run tx twice, using hardware once and software once.
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		receipt, errs = p.apply(block, tx, author, gp, statedb, header, usedGas)
+
+		if errs == ErrNonceTooHigh {
+			// maybe hardware mistake, retry with software.
+			types.Sendercache.Delete(tx.Hash())
+			tx.ClearFromCache()
+			boe.BoeGetInstance().Sleep()
+			continue
+		}
+		break
 	}
+	return receipt, errs
+}
 
-	return receipts, allLogs, totalUsedGas, nil
+// apply runs a transaction of the block on top of statedb, the native transfer
+// path is used for the transactions not involving contracts.
+func (p *StateProcessor) apply(block *types.Block, tx *types.Transaction, author common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, usedGas *big.Int) (receipt *types.Receipt, err error) {
+	bNewVersion := block.Number().Uint64() > p.config.NewContractVersion()
+
+	//the tx without contract
+	if bNewVersion {
+		if (tx.To() == nil && len(tx.Data()) > 0) || (tx.To() != nil && len(statedb.GetCode(*tx.To())) > 0) {
+			receipt, _, err = ApplyTransactionNonFinallize(p.config, p.bc, &author, gp, statedb, header, tx, usedGas)
+		} else {
+			receipt, _, err = ApplyTransactionNonContractNonFinallize(p.config, p.bc, &author, gp, statedb, header, tx, usedGas)
+		}
+	} else {
+		if len(tx.Data()) > 0 {
+			receipt, _, err = ApplyTransactionNonFinallize(p.config, p.bc, &author, gp, statedb, header, tx, usedGas)
+		} else {
+			receipt, _, err = ApplyTransactionNonContractNonFinallize(p.config, p.bc, &author, gp, statedb, header, tx, usedGas)
+		}
+	}
+	return receipt, err
 }
 
 // ApplyTransaction attempts to apply a transaction to the given state database
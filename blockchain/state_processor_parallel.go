@@ -0,0 +1,159 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package bc
+
+import (
+	"math/big"
+
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/config"
+	"github.com/hpb-project/go-hpb/consensus"
+)
+
+// speculation is the outcome of executing a transaction against a private copy
+// of the pre-state of its block.
+type speculation struct {
+	receipt *types.Receipt
+	gas     *big.Int            // Gas used by the transaction
+	record  *state.AccessRecord // State read and modifications made by the transaction
+	err     error
+}
+
+// NewParallelStateProcessor initialises a new StateProcessor running the
+// transactions of a block optimistically in parallel on the given number of
+// goroutines.
+func NewParallelStateProcessor(config *config.ChainConfig, bc *BlockChain, engine consensus.Engine, parallel int) *StateProcessor {
+	processor := NewStateProcessor(config, bc, engine)
+	processor.parallel = parallel
+	return processor
+}
+
+// applyTransactionsParallel runs the transactions of the block concurrently, each
+// against its own copy of the pre-state, recording the state they access. The
+// outcomes are then merged into statedb in block order: a transaction whose
+// reads weren't modified by the ones before it has its modifications replayed,
+// any other one is executed again on top of the merged state. The receipts and
+// the resulting state are identical to the ones of the sequential execution.
+func (p *StateProcessor) applyTransactionsParallel(block *types.Block, statedb *state.StateDB, author common.Address) (types.Receipts, []*types.Log, *big.Int, error) {
+	var (
+		txs     = block.Transactions()
+		header  = block.Header()
+		forks   = make([]*state.StateDB, len(txs))
+		results = make([]chan *speculation, len(txs))
+		tasks   = make(chan int, len(txs))
+		abort   = make(chan struct{})
+	)
+	defer close(abort)
+
+	// Copy the pre-state for every transaction before the merging modifies it
+	for i := range txs {
+		forks[i] = statedb.Copy()
+		results[i] = make(chan *speculation, 1)
+		tasks <- i
+	}
+	close(tasks)
+
+	workers := p.parallel
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range tasks {
+				select {
+				case <-abort:
+					return
+				default:
+				}
+				results[i] <- p.speculate(block, i, author, header, forks[i])
+				forks[i] = nil
+			}
+		}()
+	}
+	var (
+		receipts     types.Receipts
+		allLogs      []*types.Log
+		totalUsedGas = big.NewInt(0)
+		gp           = new(GasPool).AddGas(block.GasLimit())
+		written      = state.NewAccessSet()
+		reexecuted   int
+	)
+	for i, tx := range txs {
+		result := <-results[i]
+
+		var receipt *types.Receipt
+		if result.err == nil && (*big.Int)(gp).Cmp(tx.Gas()) >= 0 && !result.record.Overlaps(written) {
+			// Nothing the transaction read was changed, take over its modifications
+			statedb.Prepare(tx.Hash(), block.Hash(), i)
+			result.record.Replay(statedb)
+			statedb.ClearRefund()
+
+			gp.SubGas(tx.Gas())
+			gp.AddGas(new(big.Int).Sub(tx.Gas(), result.gas))
+			totalUsedGas.Add(totalUsedGas, result.gas)
+
+			receipt = result.receipt
+			receipt.CumulativeGasUsed = new(big.Int).Set(totalUsedGas)
+			receipt.Logs = statedb.GetLogs(tx.Hash())
+			result.record.AddWrites(written)
+		} else {
+			// The transaction ran on stale state, execute it again on the merged one
+			record := state.NewAccessRecord()
+			statedb.SetAccessRecord(record)
+			r, err := p.applyTransaction(block, i, author, gp, statedb, header, totalUsedGas)
+			statedb.SetAccessRecord(nil)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			receipt = r
+			record.AddWrites(written)
+			reexecuted++
+		}
+		receipts = append(receipts, receipt)
+		allLogs = append(allLogs, receipt.Logs...)
+	}
+	log.Debug("Executed transactions in parallel", "number", block.Number(), "txs", len(txs), "reexecuted", reexecuted)
+	return receipts, allLogs, totalUsedGas, nil
+}
+
+// speculate runs the i-th transaction of the block against statedb, a private
+// copy of the pre-state of the block, recording the state it accesses.
+func (p *StateProcessor) speculate(block *types.Block, i int, author common.Address, header *types.Header, statedb *state.StateDB) *speculation {
+	var (
+		tx     = block.Transactions()[i]
+		record = state.NewAccessRecord()
+		gas    = new(big.Int)
+	)
+	statedb.SetAccessRecord(record)
+
+	// Transactions following others of the same sender can't run on the pre-state,
+	// leave them to the merging instead of failing noisily.
+	from, err := types.Sender(types.MakeSigner(p.config), tx)
+	if err != nil {
+		return &speculation{err: err}
+	}
+	if statedb.GetNonce(from) != tx.Nonce() {
+		return &speculation{err: ErrNonceTooHigh}
+	}
+	statedb.Prepare(tx.Hash(), block.Hash(), i)
+	receipt, err := p.apply(block, tx, author, new(GasPool).AddGas(block.GasLimit()), statedb, header, gas)
+
+	return &speculation{receipt: receipt, gas: gas, record: record, err: err}
+}
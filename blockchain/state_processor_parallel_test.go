@@ -0,0 +1,238 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package bc
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/common/rlp"
+	"github.com/hpb-project/go-hpb/config"
+)
+
+var (
+	parallelTestConfig   = config.DeveloperChainConfig(0)
+	parallelTestSigner   = types.MakeSigner(parallelTestConfig)
+	parallelTestCoinbase = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+
+	// parallelTestCounter increments a global counter in slot 0 and emits a log.
+	parallelTestCounter     = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	parallelTestCounterCode = common.FromHex("0x600054600101600055600060006000a000")
+
+	// parallelTestPerCaller increments a counter in the slot of the caller.
+	parallelTestPerCaller     = common.HexToAddress("0x00000000000000000000000000000000000000c2")
+	parallelTestPerCallerCode = common.FromHex("0x3354600101335500")
+
+	// parallelTestInitCode deploys an empty contract with slot 0 set.
+	parallelTestInitCode = common.FromHex("0x600160005500")
+)
+
+// parallelTestEnv is a pre-state shared by a sequential and a parallel processor.
+type parallelTestEnv struct {
+	db   state.Database
+	root common.Hash
+	keys []*ecdsa.PrivateKey
+	// addrs are the possible recipients: the senders, a few fresh accounts,
+	// the coinbase and the contracts.
+	addrs []common.Address
+}
+
+func newParallelTestEnv(t testing.TB, senders int) *parallelTestEnv {
+	memdb, _ := hpbdb.NewMemDatabase()
+	env := &parallelTestEnv{db: state.NewDatabase(memdb)}
+
+	statedb, _ := state.New(common.Hash{}, env.db)
+	for i := 0; i < senders; i++ {
+		key, _ := crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		statedb.AddBalance(addr, new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+
+		env.keys = append(env.keys, key)
+		env.addrs = append(env.addrs, addr)
+	}
+	statedb.SetCode(parallelTestCounter, parallelTestCounterCode)
+	statedb.SetCode(parallelTestPerCaller, parallelTestPerCallerCode)
+
+	root, err := statedb.CommitTo(memdb, true)
+	if err != nil {
+		t.Fatalf("failed to commit pre-state: %v", err)
+	}
+	env.root = root
+	env.addrs = append(env.addrs, common.HexToAddress("0x00000000000000000000000000000000000000f1"), common.HexToAddress("0x00000000000000000000000000000000000000f2"))
+	env.addrs = append(env.addrs, parallelTestCoinbase, parallelTestCounter, parallelTestPerCaller)
+	return env
+}
+
+// generate creates a block of transactions from the bytes of data, five bytes
+// per transaction: sender, kind, recipient, value and gas price.
+func (env *parallelTestEnv) generate(t testing.TB, data []byte) *types.Block {
+	var (
+		nonces = make([]uint64, len(env.keys))
+		txs    types.Transactions
+	)
+	for ; len(data) >= 5 && len(txs) < 64; data = data[5:] {
+		var (
+			sender = int(data[0]) % len(env.keys)
+			to     = env.addrs[int(data[2])%len(env.addrs)]
+			value  = big.NewInt(int64(data[3]))
+			price  = big.NewInt(int64(data[4]%4 + 1))
+			nonce  = nonces[sender]
+			tx     *types.Transaction
+		)
+		switch data[1] % 6 {
+		case 0, 1:
+			tx = types.NewTransaction(nonce, to, value, big.NewInt(21000), price, nil, types.TxExdata{})
+		case 2:
+			tx = types.NewTransaction(nonce, parallelTestCounter, value, big.NewInt(100000), price, nil, types.TxExdata{})
+		case 3:
+			tx = types.NewTransaction(nonce, parallelTestPerCaller, value, big.NewInt(100000), price, nil, types.TxExdata{})
+		case 4:
+			tx = types.NewContractCreation(nonce, value, big.NewInt(100000), price, parallelTestInitCode, types.TxExdata{})
+		case 5:
+			// Runs out of gas in the contract, reverting its modifications
+			tx = types.NewTransaction(nonce, parallelTestCounter, value, big.NewInt(22000), price, nil, types.TxExdata{})
+		}
+		if data[1] == 0xff {
+			// Leave a nonce gap, invalidating the block
+			tx = types.NewTransaction(nonce+1, to, value, big.NewInt(21000), price, nil, types.TxExdata{})
+		}
+		signed, err := types.SignTx(tx, parallelTestSigner, env.keys[sender])
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		txs = append(txs, signed)
+		nonces[sender]++
+	}
+	header := &types.Header{
+		Number:     big.NewInt(1),
+		GasLimit:   big.NewInt(100000000),
+		Difficulty: big.NewInt(1),
+		Time:       big.NewInt(0),
+		Coinbase:   parallelTestCoinbase,
+		Extra:      make([]byte, types.ExtraVanityLength+types.ExtraSealLength),
+	}
+	return types.NewBlock(header, txs, nil, nil)
+}
+
+// check runs the block both sequentially and in parallel and ensures the
+// outcomes are identical.
+func (env *parallelTestEnv) check(t testing.TB, block *types.Block, workers int) {
+	var (
+		sequential = &StateProcessor{config: parallelTestConfig}
+		parallel   = &StateProcessor{config: parallelTestConfig, parallel: workers}
+	)
+	seqdb, _ := state.New(env.root, env.db)
+	pardb, _ := state.New(env.root, env.db)
+
+	seqReceipts, seqLogs, seqGas, seqErr := sequential.applyTransactions(block, seqdb, parallelTestCoinbase)
+	parReceipts, parLogs, parGas, parErr := parallel.applyTransactionsParallel(block, pardb, parallelTestCoinbase)
+	if seqErr != parErr {
+		t.Fatalf("error mismatch: sequential %v, parallel %v", seqErr, parErr)
+	}
+	if seqErr != nil {
+		return
+	}
+	if seqGas.Cmp(parGas) != 0 {
+		t.Errorf("used gas mismatch: sequential %v, parallel %v", seqGas, parGas)
+	}
+	seqBlob, _ := rlp.EncodeToBytes(seqReceipts)
+	parBlob, _ := rlp.EncodeToBytes(parReceipts)
+	if string(seqBlob) != string(parBlob) {
+		t.Errorf("receipts mismatch")
+	}
+	for i := range seqReceipts {
+		seq, par := seqReceipts[i], parReceipts[i]
+		if seq.TxHash != par.TxHash || seq.ContractAddress != par.ContractAddress || seq.GasUsed.Cmp(par.GasUsed) != 0 {
+			t.Errorf("receipt %d mismatch: sequential %v, parallel %v", i, seq, par)
+		}
+	}
+	if len(seqLogs) != len(parLogs) {
+		t.Fatalf("log count mismatch: sequential %d, parallel %d", len(seqLogs), len(parLogs))
+	}
+	for i := range seqLogs {
+		if !reflect.DeepEqual(seqLogs[i], parLogs[i]) {
+			t.Errorf("log %d mismatch: sequential %v, parallel %v", i, seqLogs[i], parLogs[i])
+		}
+	}
+	ApplyTransactionFinalize(seqdb)
+	ApplyTransactionFinalize(pardb)
+	if seqRoot, parRoot := seqdb.IntermediateRoot(true), pardb.IntermediateRoot(true); seqRoot != parRoot {
+		t.Errorf("state root mismatch: sequential %x, parallel %x", seqRoot, parRoot)
+	}
+}
+
+// Tests that transactions without any dependencies are executed in parallel
+// with the same outcome as sequentially.
+func TestParallelProcessIndependent(t *testing.T) {
+	env := newParallelTestEnv(t, 8)
+
+	var data []byte
+	for i := 0; i < 8; i++ {
+		data = append(data, byte(i), 3, 0, 0, 1) // per caller counter
+	}
+	env.check(t, env.generate(t, data), 4)
+}
+
+// Tests that conflicting transactions are detected and re-executed.
+func TestParallelProcessConflicts(t *testing.T) {
+	env := newParallelTestEnv(t, 4)
+
+	var data []byte
+	for i := 0; i < 16; i++ {
+		data = append(data, byte(i), 2, 0, byte(i), byte(i)) // global counter
+		data = append(data, byte(i), 0, byte(i+1), 7, 1)     // transfers among the senders
+		data = append(data, byte(i), 5, 0, 0, 1)             // out of gas
+		data = append(data, byte(i), 4, 0, 3, 2)             // contract creations
+	}
+	env.check(t, env.generate(t, data), 8)
+}
+
+// Tests that an invalid block is rejected the same way as sequentially.
+func TestParallelProcessInvalid(t *testing.T) {
+	env := newParallelTestEnv(t, 4)
+	env.check(t, env.generate(t, []byte{0, 0, 1, 1, 1, 1, 0xff, 2, 1, 1, 2, 0, 3, 1, 1}), 4)
+}
+
+// Tests random blocks against the sequential execution.
+func TestParallelProcessRandom(t *testing.T) {
+	env := newParallelTestEnv(t, 16)
+	rand := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 50; i++ {
+		data := make([]byte, 5*(1+rand.Intn(48)))
+		rand.Read(data)
+		env.check(t, env.generate(t, data), 1+rand.Intn(8))
+	}
+}
+
+func FuzzParallelProcess(f *testing.F) {
+	f.Add([]byte{0, 0, 1, 1, 1, 1, 2, 0, 1, 1, 2, 3, 0, 0, 1})
+	f.Add([]byte{0, 2, 0, 1, 1, 1, 2, 0, 1, 1, 0, 5, 0, 0, 1, 1, 4, 0, 3, 2})
+
+	env := newParallelTestEnv(f, 4)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		env.check(t, env.generate(t, data), 4)
+	})
+}
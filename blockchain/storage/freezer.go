@@ -0,0 +1,337 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package hpbdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hpb-project/go-hpb/common/log"
+)
+
+// The ancient tables maintained by the freezer, one flat file pair per kind.
+const (
+	FreezerHeaderTable     = "headers"  // FreezerHeaderTable stores the RLP encoded block headers.
+	FreezerHashTable       = "hashes"   // FreezerHashTable stores the canonical block hashes.
+	FreezerBodiesTable     = "bodies"   // FreezerBodiesTable stores the RLP encoded block bodies.
+	FreezerReceiptTable    = "receipts" // FreezerReceiptTable stores the RLP encoded storage receipts.
+	FreezerDifficultyTable = "diffs"    // FreezerDifficultyTable stores the RLP encoded total difficulties.
+)
+
+// FreezerTables lists every table of the freezer in the order items are appended.
+var FreezerTables = []string{
+	FreezerHashTable,
+	FreezerHeaderTable,
+	FreezerBodiesTable,
+	FreezerReceiptTable,
+	FreezerDifficultyTable,
+}
+
+var (
+	// ErrNoAncientStore is returned if ancient data is requested from a database
+	// which has no freezer attached.
+	ErrNoAncientStore = errors.New("no ancient store attached")
+
+	errUnknownTable      = errors.New("unknown ancient table")
+	errOutOfBounds       = errors.New("ancient item out of bounds")
+	errOutOrderInsertion = errors.New("ancient items must be appended in order")
+	errFreezerClosed     = errors.New("freezer closed")
+)
+
+// Freezer is an append-only flat file store holding the immutable part of the
+// chain. Every item is addressed by its block number, the tables are kept at
+// the same length so that a block is either fully frozen or not at all.
+type Freezer struct {
+	frozen uint64 // Number of blocks already frozen, accessed atomically
+
+	datadir string
+	tables  map[string]*freezerTable
+	lock    sync.RWMutex // Serialises appends and truncations, guards closing
+
+	log log.Logger
+}
+
+// NewFreezer opens (or creates) the ancient store in the given directory and
+// repairs any inconsistency left behind by an unclean shutdown.
+func NewFreezer(datadir string) (*Freezer, error) {
+	if err := os.MkdirAll(datadir, 0755); err != nil {
+		return nil, err
+	}
+	freezer := &Freezer{
+		datadir: datadir,
+		tables:  make(map[string]*freezerTable),
+		log:     log.New("ancient", datadir),
+	}
+	for _, name := range FreezerTables {
+		table, err := newFreezerTable(datadir, name)
+		if err != nil {
+			freezer.closeTables()
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		freezer.closeTables()
+		return nil, err
+	}
+	freezer.log.Info("Opened ancient store", "blocks", freezer.frozen)
+	return freezer, nil
+}
+
+// Path returns the directory the freezer lives in.
+func (f *Freezer) Path() string {
+	return f.datadir
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists.
+func (f *Freezer) HasAncient(kind string, number uint64) (bool, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if f.tables == nil {
+		return false, errFreezerClosed
+	}
+	table := f.tables[kind]
+	if table == nil {
+		return false, errUnknownTable
+	}
+	return number < atomic.LoadUint64(&f.frozen), nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *Freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if f.tables == nil {
+		return nil, errFreezerClosed
+	}
+	table := f.tables[kind]
+	if table == nil {
+		return nil, errUnknownTable
+	}
+	if number >= atomic.LoadUint64(&f.frozen) {
+		return nil, errOutOfBounds
+	}
+	return table.retrieve(number)
+}
+
+// Ancients returns the number of blocks frozen into the ancient store.
+func (f *Freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AncientSize returns the data size of the specified table in bytes.
+func (f *Freezer) AncientSize(kind string) (uint64, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if f.tables == nil {
+		return 0, errFreezerClosed
+	}
+	table := f.tables[kind]
+	if table == nil {
+		return 0, errUnknownTable
+	}
+	return table.dataSize(), nil
+}
+
+// AppendAncient injects all binary blobs belonging to a block at the end of the
+// append-only immutable table files. If any table fails, all of them are rolled
+// back to the previous length so the store stays consistent.
+func (f *Freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.tables == nil {
+		return errFreezerClosed
+	}
+	frozen := atomic.LoadUint64(&f.frozen)
+	if number != frozen {
+		return errOutOrderInsertion
+	}
+	blobs := map[string][]byte{
+		FreezerHashTable:       hash,
+		FreezerHeaderTable:     header,
+		FreezerBodiesTable:     body,
+		FreezerReceiptTable:    receipts,
+		FreezerDifficultyTable: td,
+	}
+	for _, name := range FreezerTables {
+		if err := f.tables[name].append(number, blobs[name]); err != nil {
+			f.log.Error("Failed to append ancient item", "table", name, "number", number, "err", err)
+			for _, name := range FreezerTables {
+				f.tables[name].truncate(frozen)
+			}
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, frozen+1)
+	return nil
+}
+
+// TruncateAncients discards all but the first n ancient blocks.
+func (f *Freezer) TruncateAncients(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.tables == nil {
+		return errFreezerClosed
+	}
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, name := range FreezerTables {
+		if err := f.tables[name].truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// SyncAncients flushes all the tables to stable storage.
+func (f *Freezer) SyncAncients() error {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if f.tables == nil {
+		return errFreezerClosed
+	}
+	for _, table := range f.tables {
+		if err := table.sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close terminates the freezer, flushing and closing all the table files.
+func (f *Freezer) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.tables == nil {
+		return nil
+	}
+	var err error
+	for _, table := range f.tables {
+		if serr := table.sync(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	f.closeTables()
+	return err
+}
+
+// closeTables releases the file handles of all the opened tables.
+func (f *Freezer) closeTables() {
+	for _, table := range f.tables {
+		table.close()
+	}
+	f.tables = nil
+}
+
+// repair truncates all the tables to the length of the shortest one, dropping
+// partially frozen blocks left behind by a crash.
+func (f *Freezer) repair() error {
+	min := ^uint64(0)
+	for _, table := range f.tables {
+		if items := table.length(); items < min {
+			min = items
+		}
+	}
+	for name, table := range f.tables {
+		if table.length() > min {
+			f.log.Warn("Truncating dangling ancient items", "table", name, "items", table.length(), "limit", min)
+		}
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// Ancients returns the number of frozen blocks, or ErrNoAncientStore if the
+// database runs without a freezer.
+func (db *LDBDatabase) Ancients() (uint64, error) {
+	if db.ancients == nil {
+		return 0, ErrNoAncientStore
+	}
+	return db.ancients.Ancients()
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists.
+func (db *LDBDatabase) HasAncient(kind string, number uint64) (bool, error) {
+	if db.ancients == nil {
+		return false, ErrNoAncientStore
+	}
+	return db.ancients.HasAncient(kind, number)
+}
+
+// Ancient retrieves an ancient binary blob from the attached freezer.
+func (db *LDBDatabase) Ancient(kind string, number uint64) ([]byte, error) {
+	if db.ancients == nil {
+		return nil, ErrNoAncientStore
+	}
+	return db.ancients.Ancient(kind, number)
+}
+
+// AppendAncient injects the binary blobs of a block into the attached freezer.
+func (db *LDBDatabase) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	if db.ancients == nil {
+		return ErrNoAncientStore
+	}
+	return db.ancients.AppendAncient(number, hash, header, body, receipts, td)
+}
+
+// TruncateAncients discards all but the first n ancient blocks.
+func (db *LDBDatabase) TruncateAncients(items uint64) error {
+	if db.ancients == nil {
+		return ErrNoAncientStore
+	}
+	return db.ancients.TruncateAncients(items)
+}
+
+// SyncAncients flushes the attached freezer to stable storage.
+func (db *LDBDatabase) SyncAncients() error {
+	if db.ancients == nil {
+		return ErrNoAncientStore
+	}
+	return db.ancients.SyncAncients()
+}
+
+// SetFreezer attaches an ancient store to the database. The freezer is closed
+// together with the database.
+func (db *LDBDatabase) SetFreezer(freezer *Freezer) {
+	db.ancients = freezer
+}
+
+// Freezer returns the ancient store attached to the database, if any.
+func (db *LDBDatabase) Freezer() *Freezer {
+	return db.ancients
+}
+
+// OpenFreezer opens the ancient store belonging to the LevelDB database at the
+// given path.
+func OpenFreezer(dbpath string) (*Freezer, error) {
+	return NewFreezer(filepath.Join(dbpath, "ancient"))
+}
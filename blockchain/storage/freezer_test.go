@@ -0,0 +1,146 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package hpbdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestFreezer(t *testing.T) (*Freezer, string) {
+	dir, err := ioutil.TempDir("", "freezer-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	freezer, err := NewFreezer(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	return freezer, dir
+}
+
+func testBlob(kind string, number uint64) []byte {
+	return []byte(fmt.Sprintf("%s-%d", kind, number))
+}
+
+func appendTestBlocks(t *testing.T, freezer *Freezer, from, to uint64) {
+	for i := from; i < to; i++ {
+		err := freezer.AppendAncient(i, testBlob(FreezerHashTable, i), testBlob(FreezerHeaderTable, i),
+			testBlob(FreezerBodiesTable, i), testBlob(FreezerReceiptTable, i), testBlob(FreezerDifficultyTable, i))
+		if err != nil {
+			t.Fatalf("failed to append block %d: %v", i, err)
+		}
+	}
+}
+
+func checkTestBlocks(t *testing.T, freezer *Freezer, items uint64) {
+	if frozen, _ := freezer.Ancients(); frozen != items {
+		t.Fatalf("frozen count mismatch: have %d, want %d", frozen, items)
+	}
+	for i := uint64(0); i < items; i++ {
+		for _, kind := range FreezerTables {
+			blob, err := freezer.Ancient(kind, i)
+			if err != nil {
+				t.Fatalf("failed to retrieve %s %d: %v", kind, i, err)
+			}
+			if !bytes.Equal(blob, testBlob(kind, i)) {
+				t.Fatalf("%s %d mismatch: have %q, want %q", kind, i, blob, testBlob(kind, i))
+			}
+		}
+	}
+	if _, err := freezer.Ancient(FreezerHeaderTable, items); err == nil {
+		t.Fatalf("retrieved item past the end of the store")
+	}
+}
+
+func TestFreezerAppendRetrieve(t *testing.T) {
+	freezer, dir := newTestFreezer(t)
+	defer os.RemoveAll(dir)
+
+	appendTestBlocks(t, freezer, 0, 100)
+	if err := freezer.AppendAncient(200, nil, nil, nil, nil, nil); err != errOutOrderInsertion {
+		t.Fatalf("out of order append error mismatch: have %v, want %v", err, errOutOrderInsertion)
+	}
+	checkTestBlocks(t, freezer, 100)
+
+	// Reopen the store and ensure everything is still there
+	if err := freezer.Close(); err != nil {
+		t.Fatalf("failed to close freezer: %v", err)
+	}
+	freezer, err := NewFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen freezer: %v", err)
+	}
+	defer freezer.Close()
+	checkTestBlocks(t, freezer, 100)
+}
+
+func TestFreezerTruncate(t *testing.T) {
+	freezer, dir := newTestFreezer(t)
+	defer os.RemoveAll(dir)
+	defer freezer.Close()
+
+	appendTestBlocks(t, freezer, 0, 50)
+	if err := freezer.TruncateAncients(20); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	checkTestBlocks(t, freezer, 20)
+
+	// Appending must continue right after the truncation point
+	appendTestBlocks(t, freezer, 20, 30)
+	checkTestBlocks(t, freezer, 30)
+}
+
+// Tests that a block partially written into the tables is dropped on reopen.
+func TestFreezerRepair(t *testing.T) {
+	freezer, dir := newTestFreezer(t)
+	defer os.RemoveAll(dir)
+
+	appendTestBlocks(t, freezer, 0, 10)
+	freezer.Close()
+
+	// Simulate a crash after the header of block 10 but before the others
+	table, err := newFreezerTable(dir, FreezerHeaderTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.append(10, testBlob(FreezerHeaderTable, 10)); err != nil {
+		t.Fatal(err)
+	}
+	table.close()
+
+	// Simulate a torn data write on the bodies table
+	data, err := os.OpenFile(filepath.Join(dir, FreezerBodiesTable+".dat"), os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, _ := data.Stat()
+	data.Truncate(stat.Size() - 1)
+	data.Close()
+
+	freezer, err = NewFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen freezer: %v", err)
+	}
+	defer freezer.Close()
+	checkTestBlocks(t, freezer, 9)
+}
@@ -42,3 +42,37 @@ type Batch interface {
 	ValueSize() int // amount of data in the batch
 	Write() error
 }
+
+// AncientReader contains the methods required to read from the immutable
+// ancient part of the chain.
+type AncientReader interface {
+	// HasAncient returns an indicator whether the specified ancient data exists.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an ancient binary blob from the append-only immutable files.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of blocks frozen into the ancient store.
+	Ancients() (uint64, error)
+}
+
+// AncientWriter contains the methods required to move chain data into the
+// immutable ancient store.
+type AncientWriter interface {
+	// AppendAncient injects all binary blobs belonging to a block at the end of
+	// the append-only immutable table files.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first n ancient blocks.
+	TruncateAncients(n uint64) error
+
+	// SyncAncients flushes all in-memory ancient data to disk.
+	SyncAncients() error
+}
+
+// AncientStore contains all the methods required to read from and write to
+// the ancient store.
+type AncientStore interface {
+	AncientReader
+	AncientWriter
+}
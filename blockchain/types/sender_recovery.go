@@ -0,0 +1,190 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/hpb-project/go-hpb/boe"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/common/metrics"
+	"github.com/hpb-project/go-hpb/config"
+)
+
+const (
+	// senderWaitTimeout is the maximum time Sender waits for an in-flight
+	// recovery of the sender before recovering it itself.
+	senderWaitTimeout = 200 * time.Millisecond
+
+	// maxPendingSenders is the number of in-flight recoveries above which the
+	// expired ones are forgotten, in case their results got lost.
+	maxPendingSenders = 100000
+
+	// pendingSenderExpiry is the age after which an in-flight recovery is
+	// considered lost.
+	pendingSenderExpiry = time.Minute
+)
+
+var (
+	senderHitMeter     = metrics.NewMeter("txs/sender/hit")     // Senders found in the caches
+	senderWaitMeter    = metrics.NewMeter("txs/sender/wait")    // Senders delivered by an in-flight recovery
+	senderMissMeter    = metrics.NewMeter("txs/sender/miss")    // Senders recovered synchronously
+	senderTimeoutMeter = metrics.NewMeter("txs/sender/timeout") // In-flight recoveries not finished in time
+)
+
+// pendingSender is an in-flight recovery of the sender of a transaction.
+type pendingSender struct {
+	done chan struct{} // Closed when the recovery finished
+	time time.Time     // Time the recovery was requested
+}
+
+var (
+	pendingSenders   = make(map[common.Hash]*pendingSender)
+	pendingSendersMu sync.Mutex
+)
+
+// RecoverSenders queues the sender recovery of the transactions to the BOE in
+// a single batch, ahead of their processing. Transactions whose sender is
+// cached or already being recovered are skipped. Sender waits for the queued
+// recoveries instead of doing them again.
+//
+// RecoverSenders blocks while the BOE is saturated with recoveries.
+func RecoverSenders(signer Signer, txs []*Transaction) {
+	boeSigner, ok := signer.(BoeSigner)
+	if !ok {
+		return
+	}
+	batch := make([]boe.RecoverPubkey, 0, len(txs))
+
+	pendingSendersMu.Lock()
+	if len(pendingSenders) >= maxPendingSenders {
+		for hash, pending := range pendingSenders {
+			if time.Since(pending.time) > pendingSenderExpiry {
+				delete(pendingSenders, hash)
+			}
+		}
+	}
+	for _, tx := range txs {
+		if sc := tx.from.Load(); sc != nil && sc.(sigCache).signer.Equal(signer) {
+			continue
+		}
+		hash := tx.Hash()
+		if _, ok := pendingSenders[hash]; ok {
+			continue
+		}
+		if _, err := Sendercache.Get(hash); err == nil {
+			continue
+		}
+		// Invalid signatures are left for Sender to report
+		rs, err := boeSigner.recoverRequest(tx)
+		if err != nil {
+			continue
+		}
+		pendingSenders[hash] = &pendingSender{done: make(chan struct{}), time: time.Now()}
+		batch = append(batch, rs)
+	}
+	pendingSendersMu.Unlock()
+
+	if len(batch) > 0 {
+		boe.BoeGetInstance().ASyncValidateSignBatch(batch)
+	}
+}
+
+// senderPending reports whether the sender of a transaction is being recovered.
+func senderPending(hash common.Hash) bool {
+	pendingSendersMu.Lock()
+	defer pendingSendersMu.Unlock()
+
+	_, ok := pendingSenders[hash]
+	return ok
+}
+
+// senderRecovered marks the in-flight recovery of the sender of a transaction
+// finished, successfully or not.
+func senderRecovered(hash common.Hash) {
+	pendingSendersMu.Lock()
+	defer pendingSendersMu.Unlock()
+
+	if pending, ok := pendingSenders[hash]; ok {
+		close(pending.done)
+		delete(pendingSenders, hash)
+	}
+}
+
+// waitSender waits for the in-flight recovery of the sender of a transaction,
+// if there's any, and returns the recovered sender.
+func waitSender(hash common.Hash) (common.Address, bool) {
+	pendingSendersMu.Lock()
+	pending := pendingSenders[hash]
+	pendingSendersMu.Unlock()
+
+	if pending == nil {
+		return common.Address{}, false
+	}
+	timer := time.NewTimer(senderWaitTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-pending.done:
+		addr, err := Sendercache.Get(hash)
+		return addr, err == nil
+	case <-timer.C:
+		senderTimeoutMeter.Mark(1)
+		return common.Address{}, false
+	}
+}
+
+// recoverRequest assembles the BOE pubkey recovery request of the signature of
+// the transaction.
+func (s BoeSigner) recoverRequest(tx *Transaction) (boe.RecoverPubkey, error) {
+	if !CheckChainIdCompatible(tx.ChainId()) && (tx.ChainId().Cmp(s.chainId) != 0) {
+		return boe.RecoverPubkey{}, ErrInvalidChainId
+	}
+	var (
+		sighash common.Hash
+		V       *big.Int
+	)
+	if compableV(tx.data.V) {
+		compableChainIdMul := new(big.Int).Mul(config.CompatibleChainId, big.NewInt(2))
+		sighash, V = s.CompableHash(tx), new(big.Int).Sub(tx.data.V, compableChainIdMul)
+	} else {
+		sighash, V = s.Hash(tx), new(big.Int).Sub(tx.data.V, s.chainIdMul)
+	}
+	V.Sub(V, big8)
+	if V.BitLen() > 8 {
+		return boe.RecoverPubkey{}, ErrInvalidSig
+	}
+	v := byte(V.Uint64() - 27)
+	if !crypto.ValidateSignatureValues(v, tx.data.R, tx.data.S, true) {
+		return boe.RecoverPubkey{}, ErrInvalidSig
+	}
+	rs := boe.RecoverPubkey{
+		TxHash: tx.Hash().Bytes(),
+		Hash:   sighash.Bytes(),
+		Sig:    make([]byte, 65),
+		Pub:    make([]byte, 65),
+	}
+	r, sb := tx.data.R.Bytes(), tx.data.S.Bytes()
+	copy(rs.Sig[32-len(r):32], r)
+	copy(rs.Sig[64-len(sb):64], sb)
+	rs.Sig[64] = v
+
+	return rs, nil
+}
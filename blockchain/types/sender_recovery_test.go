@@ -0,0 +1,80 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/config"
+)
+
+// Tests that batched sender recovery fills the sender cache, without any BOE
+// board attached, and that Sender picks the results up.
+func TestRecoverSenders(t *testing.T) {
+	signer := NewBoeSigner(config.MainnetChainConfig.ChainId)
+
+	var (
+		txs   = make([]*Transaction, 64)
+		addrs = make([]common.Address, len(txs))
+	)
+	for i := range txs {
+		key, _ := crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(key.PublicKey)
+		txs[i] = makeTransaction(uint64(i), big.NewInt(21000), big.NewInt(1), key)
+	}
+	RecoverSenders(signer, txs)
+
+	// Wait for the background recoveries to land in the cache
+	deadline := time.Now().Add(5 * time.Second)
+	for i, tx := range txs {
+		for senderPending(tx.Hash()) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		addr, err := Sendercache.Get(tx.Hash())
+		if err != nil {
+			t.Fatalf("tx %d: sender not cached: %v", i, err)
+		}
+		if addr != addrs[i] {
+			t.Fatalf("tx %d: cached sender mismatch: have %x, want %x", i, addr, addrs[i])
+		}
+		if from, err := Sender(signer, tx); err != nil || from != addrs[i] {
+			t.Fatalf("tx %d: sender mismatch: have %x (%v), want %x", i, from, err, addrs[i])
+		}
+	}
+}
+
+// Tests that transactions with invalid signatures are not queued for recovery
+// and are still rejected by Sender.
+func TestRecoverSendersInvalid(t *testing.T) {
+	signer := NewBoeSigner(config.MainnetChainConfig.ChainId)
+
+	key, _ := crypto.GenerateKey()
+	tx := makeTransaction(0, big.NewInt(21000), big.NewInt(1), key)
+	tx.data.S = new(big.Int).Set(crypto.S256().Params().N)
+
+	RecoverSenders(signer, []*Transaction{tx})
+	if senderPending(tx.Hash()) {
+		t.Fatalf("invalid signature queued for recovery")
+	}
+	if _, err := Sender(signer, tx); err == nil {
+		t.Fatalf("invalid signature accepted")
+	}
+}
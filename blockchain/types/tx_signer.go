@@ -71,15 +71,24 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 		// call is not the same as used current, invalidate
 		// the cache.2
 		if sigCache.signer.Equal(signer) {
+			senderHitMeter.Mark(1)
 			return sigCache.from, nil
 		}
 	}
 	txhash := tx.Hash()
 	address, err := Sendercache.Get(txhash)
 	if err == nil {
+		senderHitMeter.Mark(1)
 		tx.from.Store(sigCache{signer: signer, from: address})
 		return address, nil
 	}
+	// Don't duplicate the work of a recovery already in flight
+	if address, ok := waitSender(txhash); ok {
+		senderWaitMeter.Mark(1)
+		tx.from.Store(sigCache{signer: signer, from: address})
+		return address, nil
+	}
+	senderMissMeter.Mark(1)
 	addr, err := signer.Sender(tx)
 	if err != nil {
 		return common.Address{}, err
@@ -103,6 +112,9 @@ func ASynSender(signer Signer, tx *Transaction) (common.Address, error) {
 		tx.from.Store(sigCache{signer: signer, from: asynAddress})
 		return asynAddress, nil
 	}
+	if senderPending(tx.Hash()) {
+		return common.Address{}, ErrInvalidAsynsinger
+	}
 	return signer.ASynSender(tx)
 }
 
@@ -299,18 +311,21 @@ func deriveChainId(v *big.Int) *big.Int {
 }
 
 func boecallback(rs boe.RecoverPubkey, err error) {
+	var comhash common.Hash
+	copy(comhash[0:], rs.TxHash[0:])
+	defer senderRecovered(comhash)
+
 	if err != nil {
 		log.Error("boecallback boe validatesign error")
+		return
 	}
 	if len(rs.Pub) == 0 || rs.Pub[0] != 4 {
 		log.Error("boecallback boe invalid public key")
+		return
 	}
 
 	var addr = common.Address{}
 	copy(addr[:], crypto.Keccak256(rs.Pub[1:])[12:])
 
-	var comhash common.Hash
-	copy(comhash[0:], rs.TxHash[0:])
-
 	Sendercache.GetOrSet(comhash, addr)
 }
@@ -0,0 +1,81 @@
+// Copyright 2018 The go-hpb Authors
+// This file is part of the go-hpb.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package boe
+
+import (
+	"runtime"
+	"time"
+)
+
+// batchQueueSize is the number of batched signatures that may wait for software
+// recovery before submitters are blocked.
+const batchQueueSize = 4096
+
+// ASyncValidateSignBatch queues the pubkey recovery of a batch of signatures.
+// With a board present the signatures are handed to the hardware, otherwise
+// they are spread over a pool of software recovery goroutines. The results are
+// posted to the registered recover callback like the ones of ASyncValidateSign.
+//
+// The call blocks while the software pool is saturated, throttling submitters
+// to the rate signatures can be recovered.
+func (boe *BoeHandle) ASyncValidateSignBatch(batch []RecoverPubkey) {
+	recoverBatchMeter.Mark(int64(len(batch)))
+	async_call += uint32(len(batch))
+
+	for _, rs := range batch {
+		if boe.boeInit && !boe.sleep && boe.asyncHardRecover(rs.TxHash, rs.Hash, rs.Sig[:32], rs.Sig[32:64], rs.Sig[64]) {
+			continue
+		}
+		boe.postToBatch(rs)
+	}
+}
+
+// postToBatch queues a signature for software recovery, waiting for room in the
+// queue if it's full.
+func (boe *BoeHandle) postToBatch(rs RecoverPubkey) {
+	boe.batchOnce.Do(func() {
+		boe.batchQueue = make(chan RecoverPubkey, batchQueueSize)
+		for i := 0; i < runtime.NumCPU(); i++ {
+			go boe.batchSoftRecoverTask()
+		}
+	})
+	select {
+	case boe.batchQueue <- rs:
+	default:
+		start := time.Now()
+		boe.batchQueue <- rs
+		recoverStallTimer.UpdateSince(start)
+	}
+	recoverQueueGauge.Update(int64(len(boe.batchQueue)))
+}
+
+// batchSoftRecoverTask recovers the pubkeys of the batched signatures in software.
+func (boe *BoeHandle) batchSoftRecoverTask() {
+	for rs := range boe.batchQueue {
+		start := time.Now()
+		pub, err := softRecoverPubkey(rs.Hash, rs.Sig[:32], rs.Sig[32:64], rs.Sig[64])
+		if err == nil {
+			copy(rs.Pub, pub)
+		}
+		recoverSoftTimer.UpdateSince(start)
+		soft_cnt++
+
+		if boe.rpFunc != nil {
+			boe.rpFunc(rs, err)
+		}
+	}
+}
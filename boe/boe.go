@@ -203,6 +203,9 @@ type BoeHandle struct {
 	rboeCh    chan struct{}
 	idx       int
 	sleep     bool
+
+	batchOnce  sync.Once
+	batchQueue chan RecoverPubkey // Signatures of the batch API waiting for software recovery
 }
 
 var (
@@ -269,11 +272,13 @@ func PostRecoverPubkey(boe *BoeHandle) {
 					copy(rs.Sig[32:64], fullsig[32:64])
 					rs.Sig[64] = fullsig[96]
 					hard_cnt++
+					recoverHardMeter.Mark(1)
 					copy(rs.Pub, pubkey65)
 					// post to external module
 					boe.postResult(&rs, err)
 				} else {
 					// hardware recover failed, and then post to use soft ecc-recover.
+					recoverFailMeter.Mark(1)
 					copy(rs.Hash, fullsig[64:96])
 					copy(rs.Sig[0:32], fullsig[0:32])
 					copy(rs.Sig[32:64], fullsig[32:64])
@@ -339,10 +344,12 @@ func (boe *BoeHandle) asyncSoftRecoverPubTask(queue chan RecoverPubkey) {
 			if !ok {
 				return
 			}
+			start := time.Now()
 			pub, err := crypto.Ecrecover(rs.Hash, rs.Sig)
 			if err == nil {
 				copy(rs.Pub, pub)
 			}
+			recoverSoftTimer.UpdateSince(start)
 			soft_cnt++
 			boe.postResult(&rs, err)
 		}
@@ -417,7 +424,10 @@ func (boe *BoeHandle) Init() error {
 }
 
 func (boe *BoeHandle) Release() error {
-
+	// Nothing to release if the handle was never initialised, like on developer chains
+	if !boe.bcontinue {
+		return nil
+	}
 	boe.bcontinue = false
 	close(boe.rboeCh)
 	for i := 0; i < boe.maxThNum; i++ {
@@ -618,19 +628,7 @@ func (boe *BoeHandle) ASyncValidateSign(txhash []byte, hash []byte, r []byte, s
 		return nil
 	}
 
-	var (
-		m_sig   = make([]byte, 97)
-		c_sig   = (*C.uchar)(unsafe.Pointer(&m_sig[0]))
-		c_param = (*C.uchar)(unsafe.Pointer(&txhash[0]))
-	)
-
-	copy(m_sig[32-len(r):32], r)
-	copy(m_sig[64-len(s):64], s)
-	copy(m_sig[96-len(hash):96], hash)
-	m_sig[96] = v
-
-	c_ret := C.boe_valid_sign_recover_pub_async(c_sig, c_param, (C.int)(32))
-	if c_ret == C.BOE_OK {
+	if boe.asyncHardRecover(txhash, hash, r, s, v) {
 		return nil
 	} else {
 		rs := RecoverPubkey{TxHash: make([]byte, 32), Hash: make([]byte, 32), Sig: make([]byte, 65), Pub: make([]byte, 65)}
@@ -645,8 +643,27 @@ func (boe *BoeHandle) ASyncValidateSign(txhash []byte, hash []byte, r []byte, s
 	}
 }
 
+// asyncHardRecover hands a signature to the board for pubkey recovery, the
+// result is posted to the registered recover callback.
+func (boe *BoeHandle) asyncHardRecover(txhash []byte, hash []byte, r []byte, s []byte, v byte) bool {
+	var (
+		m_sig   = make([]byte, 97)
+		c_sig   = (*C.uchar)(unsafe.Pointer(&m_sig[0]))
+		c_param = (*C.uchar)(unsafe.Pointer(&txhash[0]))
+	)
+
+	copy(m_sig[32-len(r):32], r)
+	copy(m_sig[64-len(s):64], s)
+	copy(m_sig[96-len(hash):96], hash)
+	m_sig[96] = v
+
+	c_ret := C.boe_valid_sign_recover_pub_async(c_sig, c_param, (C.int)(32))
+	return c_ret == C.BOE_OK
+}
+
 func (boe *BoeHandle) ValidateSign(hash []byte, r []byte, s []byte, v byte) ([]byte, error) {
 	sync_call = sync_call + 1
+	defer recoverSyncTimer.UpdateSince(time.Now())
 	return softRecoverPubkey(hash, r, s, v)
 }
 
@@ -0,0 +1,34 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the boe.
+
+package boe
+
+import (
+	"github.com/hpb-project/go-hpb/common/metrics"
+)
+
+var (
+	recoverSyncTimer = metrics.NewTimer("boe/recover/sync") // Latency of the synchronous pubkey recoveries
+	recoverSoftTimer = metrics.NewTimer("boe/recover/soft") // Latency of the asynchronous software recoveries
+	recoverHardMeter = metrics.NewMeter("boe/recover/hard") // Asynchronous recoveries done by the hardware
+	recoverFailMeter = metrics.NewMeter("boe/recover/fail") // Recoveries failed in hardware, retried in software
+
+	recoverBatchMeter = metrics.NewMeter("boe/recover/batch/in")    // Signatures queued through the batch API
+	recoverQueueGauge = metrics.NewGauge("boe/recover/batch/queue") // Batched signatures waiting for software recovery
+	recoverStallTimer = metrics.NewTimer("boe/recover/batch/stall") // Time batch submitters were blocked by a full software queue
+)
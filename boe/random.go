@@ -0,0 +1,115 @@
+// Copyright 2018 The go-hpb Authors
+// This file is part of the go-hpb.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package boe
+
+import (
+	"bytes"
+	"crypto/rand"
+
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+)
+
+// RandomSource produces and verifies the random hash chain carried in the
+// HardwareRandom field of block headers, and the real random sealed into the
+// header extra data. The BOE board is the production source.
+type RandomSource interface {
+	// HWCheck reports whether the source is available.
+	HWCheck() bool
+
+	// GetNextHash derives the next random of the chain, as used before the new
+	// hash algorithm was enabled.
+	GetNextHash(hash []byte) ([]byte, error)
+
+	// GetNextHash_v2 derives the next random of the chain with the new hash
+	// algorithm.
+	GetNextHash_v2(hash []byte) ([]byte, error)
+
+	// HashVerify checks that next was derived from old by GetNextHash_v2.
+	HashVerify(old []byte, next []byte) error
+
+	// GetRandom returns a fresh 32 byte real random.
+	GetRandom() ([]byte, error)
+}
+
+var (
+	_ RandomSource = (*BoeHandle)(nil)
+	_ RandomSource = (*SoftRandom)(nil)
+)
+
+// Domain separators of the software hash chain versions.
+var (
+	softHashTag   = []byte("hpb-soft-random-v1")
+	softHashTagV2 = []byte("hpb-soft-random-v2")
+)
+
+// SoftRandom is a deterministic software random source for developer chains,
+// which run without BOE boards. Every hash of the chain is the keccak256 of its
+// parent keyed with a network wide seed, so any node knowing the seed verifies
+// the chain with the same semantics as the board's HashVerify. The seed is part
+// of the genesis config, which makes the chain predictable: it must not be used
+// outside of developer chains, see config.ChainConfig.CheckRandomSource.
+type SoftRandom struct {
+	seed []byte
+}
+
+// NewSoftRandom creates a software random source keyed with the given seed.
+func NewSoftRandom(seed []byte) *SoftRandom {
+	return &SoftRandom{seed: common.CopyBytes(seed)}
+}
+
+// HWCheck always succeeds, the software source needs no hardware.
+func (s *SoftRandom) HWCheck() bool {
+	return true
+}
+
+// GetNextHash derives the next random of the chain with the original algorithm.
+func (s *SoftRandom) GetNextHash(hash []byte) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, ErrGetNextHashFailed
+	}
+	return crypto.Keccak256(softHashTag, s.seed, hash), nil
+}
+
+// GetNextHash_v2 derives the next random of the chain with the new algorithm.
+func (s *SoftRandom) GetNextHash_v2(hash []byte) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, ErrInvalidParams
+	}
+	return crypto.Keccak256(softHashTagV2, s.seed, hash), nil
+}
+
+// HashVerify checks that next follows old in the software hash chain.
+func (s *SoftRandom) HashVerify(old []byte, next []byte) error {
+	if len(old) != 32 || len(next) != 32 {
+		return ErrInvalidParams
+	}
+	want, _ := s.GetNextHash_v2(old)
+	if !bytes.Equal(want, next) {
+		return ErrHashVerifyFailed
+	}
+	return nil
+}
+
+// GetRandom returns 32 bytes read from the operating system's random source.
+func (s *SoftRandom) GetRandom() ([]byte, error) {
+	result := make([]byte, 32)
+	if _, err := rand.Read(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2018 The go-hpb Authors
+// This file is part of the go-hpb.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package boe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSoftRandomChain(t *testing.T) {
+	var (
+		source = NewSoftRandom([]byte("devnet"))
+		other  = NewSoftRandom([]byte("testnet"))
+		hash   = make([]byte, 32)
+	)
+	for i := 0; i < 16; i++ {
+		next, err := source.GetNextHash_v2(hash)
+		if err != nil {
+			t.Fatalf("step %d: failed to derive hash: %v", i, err)
+		}
+		if again, _ := source.GetNextHash_v2(hash); !bytes.Equal(next, again) {
+			t.Fatalf("step %d: chain is not deterministic: %x != %x", i, next, again)
+		}
+		if err := source.HashVerify(hash, next); err != nil {
+			t.Fatalf("step %d: valid hash rejected: %v", i, err)
+		}
+		if err := other.HashVerify(hash, next); err != ErrHashVerifyFailed {
+			t.Fatalf("step %d: hash verified with a different seed: %v", i, err)
+		}
+		if legacy, _ := source.GetNextHash(hash); bytes.Equal(legacy, next) {
+			t.Fatalf("step %d: hash versions collide", i)
+		}
+		hash = next
+	}
+	if _, err := source.GetNextHash_v2(hash[:31]); err != ErrInvalidParams {
+		t.Fatalf("short hash error mismatch: have %v, want %v", err, ErrInvalidParams)
+	}
+	if err := source.HashVerify(hash, make([]byte, 32)); err != ErrHashVerifyFailed {
+		t.Fatalf("forged hash error mismatch: have %v, want %v", err, ErrHashVerifyFailed)
+	}
+}
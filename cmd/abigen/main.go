@@ -0,0 +1,91 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+// abigen generates typed Go bindings for Hpb contracts from their ABI.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hpb-project/go-hpb/account/abi/bind"
+)
+
+var (
+	abiFlag = flag.String("abi", "", "Path to the contract ABI json to bind, - for STDIN")
+	binFlag = flag.String("bin", "", "Path to the compiled contract bytecode to generate deploy method (optional)")
+	typFlag = flag.String("type", "", "Go struct name for the binding (default = package name)")
+	pkgFlag = flag.String("pkg", "", "Package name to generate the binding into")
+	outFlag = flag.String("out", "", "Output file for the generated binding (default = stdout)")
+)
+
+func main() {
+	// Parse and ensure all needed inputs are specified
+	flag.Parse()
+
+	if *abiFlag == "" {
+		fatalf("No contract ABI specified (--abi)")
+	}
+	if *pkgFlag == "" {
+		fatalf("No destination package specified (--pkg)")
+	}
+	// Load the contract ABI and the optional bytecode
+	var (
+		abi []byte
+		err error
+	)
+	if *abiFlag == "-" {
+		abi, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		abi, err = ioutil.ReadFile(*abiFlag)
+	}
+	if err != nil {
+		fatalf("Failed to read input ABI: %v", err)
+	}
+	bin := []byte{}
+	if *binFlag != "" {
+		if bin, err = ioutil.ReadFile(*binFlag); err != nil {
+			fatalf("Failed to read input bytecode: %v", err)
+		}
+	}
+	kind := *typFlag
+	if kind == "" {
+		kind = *pkgFlag
+	}
+	// Generate the contract binding
+	code, err := bind.Bind([]string{kind}, []string{string(abi)}, []string{strings.TrimSpace(string(bin))}, *pkgFlag)
+	if err != nil {
+		fatalf("Failed to generate ABI binding: %v", err)
+	}
+	// Either flush it out to a file or display on the standard output
+	if *outFlag == "" {
+		fmt.Printf("%s\n", code)
+		return
+	}
+	if err := ioutil.WriteFile(*outFlag, []byte(code), 0600); err != nil {
+		fatalf("Failed to write ABI binding: %v", err)
+	}
+}
+
+// fatalf prints the error to standard error and exits, abigen doesn't link the
+// node packages cmd/utils depends on.
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Fatal: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,234 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	bc "github.com/hpb-project/go-hpb/blockchain"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/cmd/utils"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	freezerTruncateFlag = cli.BoolFlag{
+		Name:  "truncate",
+		Usage: "Truncate the ancient store at the first broken block",
+	}
+	freezerCommand = cli.Command{
+		Name:     "freezer",
+		Usage:    "Inspect and repair the ancient block store",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Finalized chain segments are moved out of LevelDB into a flat file append-only
+store located in <DATADIR>/ghpb/chaindata/ancient. These commands allow
+inspecting its contents and repairing it after a crash or disk failure.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "inspect",
+				Usage:     "Print a summary of the ancient store",
+				ArgsUsage: "[<blockNum>...]",
+				Action:    utils.MigrateFlags(inspectFreezer),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+    ghpb freezer inspect [<blockNum>...]
+
+Prints the number of frozen blocks and the size of every ancient table. If block
+numbers are given, the frozen header of each of them is printed too.`,
+			},
+			{
+				Name:      "repair",
+				Usage:     "Verify the ancient store and drop corrupted items",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(repairFreezer),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					freezerTruncateFlag,
+				},
+				Description: `
+    ghpb freezer repair [--truncate]
+
+Checks that every frozen block is complete, hashes to the recorded canonical hash
+and links to its parent. The first broken block is only reported, unless
+--truncate is given: the store is then truncated at that block, permanently
+discarding the frozen blocks from there on.`,
+			},
+		},
+	}
+)
+
+// openFreezer opens the chain database of the node and returns its ancient store.
+func openFreezer(ctx *cli.Context) (hpbdb.Database, *hpbdb.Freezer) {
+	// The ancient store is inspected offline, only the data directory of the
+	// node configuration is needed, not a fully assembled node.
+	MakeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, nil)
+	ldb, ok := chainDb.(*hpbdb.LDBDatabase)
+	if !ok || ldb.Freezer() == nil {
+		utils.Fatalf("The chain database has no ancient store")
+	}
+	return chainDb, ldb.Freezer()
+}
+
+func inspectFreezer(ctx *cli.Context) error {
+	chainDb, freezer := openFreezer(ctx)
+	defer chainDb.Close()
+
+	frozen, _ := freezer.Ancients()
+	fmt.Printf("Ancient store:  %s\n", freezer.Path())
+	fmt.Printf("Frozen blocks:  %d\n", frozen)
+	hash := bc.GetHeadBlockHash(chainDb)
+	if head := bc.GetHeader(chainDb, hash, bc.GetBlockNumber(chainDb, hash)); head != nil {
+		number := head.Number.Uint64()
+		fmt.Printf("Chain head:     %d (freeze limit %d)\n", number, bc.FreezeLimit(number))
+	}
+	var total uint64
+	for _, kind := range hpbdb.FreezerTables {
+		size, err := freezer.AncientSize(kind)
+		if err != nil {
+			utils.Fatalf("Failed to inspect table %s: %v", kind, err)
+		}
+		total += size
+		fmt.Printf("  %-10s %s\n", kind, common.StorageSize(size))
+	}
+	fmt.Printf("  %-10s %s\n", "total", common.StorageSize(total))
+
+	for _, arg := range ctx.Args() {
+		number, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			utils.Fatalf("Invalid block number %q: %v", arg, err)
+		}
+		header, err := ancientHeader(freezer, number)
+		if err != nil {
+			fmt.Printf("Block #%d: %v\n", number, err)
+			continue
+		}
+		fmt.Printf("Block #%d: hash %x, parent %x, root %x, time %v\n", number, header.Hash(), header.ParentHash, header.Root, header.Time)
+	}
+	return nil
+}
+
+func repairFreezer(ctx *cli.Context) error {
+	chainDb, freezer := openFreezer(ctx)
+	defer chainDb.Close()
+
+	frozen, _ := freezer.Ancients()
+	var (
+		parent common.Hash
+		valid  uint64
+	)
+	for ; valid < frozen; valid++ {
+		header, err := ancientHeader(freezer, valid)
+		if err != nil {
+			fmt.Printf("Block #%d is corrupted: %v\n", valid, err)
+			break
+		}
+		if valid > 0 && header.ParentHash != parent {
+			fmt.Printf("Block #%d does not link to its parent: have %x, want %x\n", valid, header.ParentHash, parent)
+			break
+		}
+		if err := checkAncientBlock(freezer, valid); err != nil {
+			fmt.Printf("Block #%d is corrupted: %v\n", valid, err)
+			break
+		}
+		parent = header.Hash()
+	}
+	if valid == frozen {
+		fmt.Printf("Ancient store is consistent, %d blocks verified\n", frozen)
+		return nil
+	}
+	// Frozen blocks are usually gone from LevelDB, truncating discards them
+	recoverable := bc.GetCanonicalHash(chainDb, valid) != (common.Hash{})
+	if !ctx.Bool(freezerTruncateFlag.Name) {
+		fmt.Printf("Ancient store is broken from block #%d, %d of %d blocks are valid\n", valid, valid, frozen)
+		if !recoverable {
+			fmt.Printf("Truncating it would discard blocks #%d-#%d, not available elsewhere locally\n", valid, frozen-1)
+		}
+		fmt.Printf("Rerun with --%s to truncate the store at block #%d\n", freezerTruncateFlag.Name, valid)
+		return nil
+	}
+	if err := freezer.TruncateAncients(valid); err != nil {
+		utils.Fatalf("Failed to truncate ancient store: %v", err)
+	}
+	fmt.Printf("Truncated ancient store from %d to %d blocks\n", frozen, valid)
+
+	// The dropped blocks are only recoverable if LevelDB still holds them
+	if !recoverable {
+		fmt.Printf("Blocks #%d-#%d are no longer available locally, remove the database and resync\n", valid, frozen-1)
+	}
+	return nil
+}
+
+// ancientHeader retrieves a frozen header and checks it against the recorded
+// canonical hash.
+func ancientHeader(freezer *hpbdb.Freezer, number uint64) (*types.Header, error) {
+	blob, err := freezer.Ancient(hpbdb.FreezerHeaderTable, number)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := freezer.Ancient(hpbdb.FreezerHashTable, number)
+	if err != nil {
+		return nil, err
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(blob, header); err != nil {
+		return nil, err
+	}
+	if header.Number.Uint64() != number {
+		return nil, fmt.Errorf("header number mismatch: have %d", header.Number)
+	}
+	if header.Hash() != common.BytesToHash(hash) {
+		return nil, fmt.Errorf("header hash mismatch: have %x, want %x", header.Hash(), hash)
+	}
+	return header, nil
+}
+
+// checkAncientBlock verifies that the non-header items of a frozen block decode.
+func checkAncientBlock(freezer *hpbdb.Freezer, number uint64) error {
+	blob, err := freezer.Ancient(hpbdb.FreezerBodiesTable, number)
+	if err != nil {
+		return err
+	}
+	if err := rlp.DecodeBytes(blob, new(types.Body)); err != nil {
+		return fmt.Errorf("invalid body: %v", err)
+	}
+	if blob, err = freezer.Ancient(hpbdb.FreezerReceiptTable, number); err != nil {
+		return err
+	}
+	if err := rlp.DecodeBytes(blob, new([]*types.ReceiptForStorage)); err != nil {
+		return fmt.Errorf("invalid receipts: %v", err)
+	}
+	if blob, err = freezer.Ancient(hpbdb.FreezerDifficultyTable, number); err != nil {
+		return err
+	}
+	if err := rlp.DecodeBytes(blob, new(big.Int)); err != nil {
+		return fmt.Errorf("invalid total difficulty: %v", err)
+	}
+	return nil
+}
@@ -27,6 +27,7 @@ import (
 
 	accounts "github.com/hpb-project/go-hpb/account"
 	"github.com/hpb-project/go-hpb/account/keystore"
+	bc "github.com/hpb-project/go-hpb/blockchain"
 	"github.com/hpb-project/go-hpb/cmd/utils"
 	"github.com/hpb-project/go-hpb/common/console"
 	"github.com/hpb-project/go-hpb/common/log"
@@ -58,6 +59,9 @@ var (
 		utils.DataDirFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
+		utils.TxPoolNoLocalsFlag,
+		utils.TxPoolJournalFlag,
+		utils.TxPoolRejournalFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
 		utils.TxPoolAccountSlotsFlag,
@@ -73,8 +77,14 @@ var (
 		utils.LightKDFFlag,
 		utils.CacheFlag,
 		utils.TrieCacheGenFlag,
+		utils.SnapshotCacheFlag,
+		utils.ParallelTxsFlag,
+		utils.GCModeFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
+		utils.PeerLimitFlag,
+		utils.BWTestRateFlag,
+		utils.BWTestDurationFlag,
 		utils.MaxPendingPeersFlag,
 		utils.HpberbaseFlag,
 		utils.GasPriceFlag,
@@ -88,6 +98,7 @@ var (
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DevModeFlag,
+		utils.DevPeriodFlag,
 		utils.TestnetFlag,
 		utils.RinkebyFlag,
 		utils.VMEnableDebugFlag,
@@ -95,12 +106,18 @@ var (
 		utils.RPCCORSDomainFlag,
 		utils.HpbStatsURLFlag,
 		utils.MetricsEnabledFlag,
+		utils.MetricsAddrFlag,
+		utils.MetricsNamespaceFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
 		utils.ExtraDataFlag,
+		utils.MinerTxOrderFlag,
+		utils.MinerSenderTxCapFlag,
+		utils.MinerPriorityContractsFlag,
 		utils.NodeTypeFlag,
+		utils.P2PAuthFlag,
 		utils.TestModeFlag,
 		utils.RPCVirtualHostsFlag,
 		utils.TestCodeStageFlag,
@@ -117,6 +134,7 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.GraphQLEnabledFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -141,6 +159,8 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		// See freezercmd.go:
+		freezerCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -240,6 +260,9 @@ func startNode(ctx *cli.Context, stack *node.Node, conf *config.HpbConfig) {
 		}
 		conf.Node.DefaultAddress = account.Address
 	}
+	if ctx.GlobalBool(utils.DevModeFlag.Name) {
+		setupDeveloper(stack, ks, passwords, conf)
+	}
 
 	// Start up the node itself
 	utils.StartNode(stack)
@@ -274,11 +297,46 @@ func startNode(ctx *cli.Context, stack *node.Node, conf *config.HpbConfig) {
 	}()
 
 	// Start auxiliary services if enabled
-	if ctx.GlobalBool(utils.MiningEnabledFlag.Name) && (conf.Network.RoleType == "") {
+	if (ctx.GlobalBool(utils.MiningEnabledFlag.Name) || ctx.GlobalBool(utils.DevModeFlag.Name)) && (conf.Network.RoleType == "") {
 		// Set the gas price to the limits from the CLI and start mining
-		stack.TxPool().SetGasPrice(utils.GlobalBig(ctx, utils.GasPriceFlag.Name))
+		gasprice := utils.GlobalBig(ctx, utils.GasPriceFlag.Name)
+		if ctx.GlobalBool(utils.DevModeFlag.Name) && !ctx.GlobalIsSet(utils.GasPriceFlag.Name) {
+			gasprice = conf.Node.GasPrice
+		}
+		stack.TxPool().SetGasPrice(gasprice)
 		if err := stack.StartMining(true); err != nil {
 			utils.Fatalf("Failed to start mining: %v", err)
 		}
 	}
 }
+
+// setupDeveloper prepares the single node developer chain. The first account of
+// the keystore, or a new one, is unlocked and becomes the coinbase as well as
+// the only hpb node of the developer genesis block.
+func setupDeveloper(stack *node.Node, ks *keystore.KeyStore, passwords []string, conf *config.HpbConfig) {
+	var passphrase string
+	if len(passwords) > 0 {
+		passphrase = passwords[0]
+	}
+	var developer accounts.Account
+	if existing := ks.Accounts(); len(existing) > 0 {
+		developer = existing[0]
+	} else {
+		account, err := ks.NewAccount(passphrase)
+		if err != nil {
+			utils.Fatalf("Failed to create developer account: %v", err)
+		}
+		developer = account
+	}
+	if err := ks.Unlock(developer, passphrase); err != nil {
+		utils.Fatalf("Failed to unlock developer account: %v", err)
+	}
+	log.Info("Using developer account", "address", developer.Address)
+
+	genesis := bc.DeveloperGenesisBlock(conf.Prometheus.Period, developer.Address)
+	if _, _, err := bc.SetupGenesisBlock(stack.HpbDb, genesis); err != nil {
+		utils.Fatalf("Failed to write developer genesis block: %v", err)
+	}
+	stack.SetHpberbase(developer.Address)
+	conf.Node.DefaultAddress = developer.Address
+}
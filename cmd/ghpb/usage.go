@@ -73,6 +73,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TestnetFlag,
 			utils.RinkebyFlag,
 			utils.DevModeFlag,
+			utils.DevPeriodFlag,
 			utils.SyncModeFlag,
 			utils.HpbStatsURLFlag,
 			utils.IdentityFlag,
@@ -84,6 +85,9 @@ var AppHelpFlagGroups = []flagGroup{
 	{
 		Name: "TRANSACTION POOL",
 		Flags: []cli.Flag{
+			utils.TxPoolNoLocalsFlag,
+			utils.TxPoolJournalFlag,
+			utils.TxPoolRejournalFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
 			utils.TxPoolAccountSlotsFlag,
@@ -98,6 +102,9 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.CacheFlag,
 			utils.TrieCacheGenFlag,
+			utils.SnapshotCacheFlag,
+			utils.ParallelTxsFlag,
+			utils.GCModeFlag,
 		},
 	},
 	{
@@ -114,6 +121,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
+			utils.GraphQLEnabledFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
@@ -135,9 +143,13 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.BootnodesV5Flag,
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
+			utils.PeerLimitFlag,
+			utils.BWTestRateFlag,
+			utils.BWTestDurationFlag,
 			utils.MaxPendingPeersFlag,
 			utils.NATFlag,
 			utils.NodeTypeFlag,
+			utils.P2PAuthFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
 			utils.NetrestrictFlag,
@@ -162,6 +174,9 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.MinerTxOrderFlag,
+			utils.MinerSenderTxCapFlag,
+			utils.MinerPriorityContractsFlag,
 		},
 	},
 	{
@@ -181,6 +196,8 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "LOGGING AND DEBUGGING",
 		Flags: append([]cli.Flag{
 			utils.MetricsEnabledFlag,
+			utils.MetricsAddrFlag,
+			utils.MetricsNamespaceFlag,
 			utils.FakePoWFlag,
 			utils.NoCompactionFlag,
 		}, debug.Flags...),
@@ -134,7 +134,11 @@ var (
 	}
 	DevModeFlag = cli.BoolFlag{
 		Name:  "dev",
-		Usage: "Developer mode: pre-configured private network with several debugging flags",
+		Usage: "Ephemeral single node developer chain with a pre-funded developer account, mining enabled",
+	}
+	DevPeriodFlag = cli.IntFlag{
+		Name:  "dev.period",
+		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
 	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
@@ -175,6 +179,20 @@ var (
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
 	// Transaction pool settings
+	TxPoolNoLocalsFlag = cli.BoolFlag{
+		Name:  "txpool.nolocals",
+		Usage: "Disables price exemptions for locally submitted transactions",
+	}
+	TxPoolJournalFlag = cli.StringFlag{
+		Name:  "txpool.journal",
+		Usage: "Disk journal for local transaction to survive node restarts",
+		Value: config.DefaultTxPoolConfig.Journal,
+	}
+	TxPoolRejournalFlag = cli.DurationFlag{
+		Name:  "txpool.rejournal",
+		Usage: "Time interval to regenerate the local transaction journal",
+		Value: config.DefaultTxPoolConfig.Rejournal,
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum gas price limit to enforce for acceptance into the pool",
@@ -222,6 +240,21 @@ var (
 		Usage: "Number of trie node generations to keep in memory",
 		Value: int(state.MaxTrieCacheGen),
 	}
+	SnapshotCacheFlag = cli.IntFlag{
+		Name:  "snapshot-cache",
+		Usage: "Megabytes of memory allocated to the flat state snapshot (0 disables snapshots)",
+		Value: config.DefaultConfig.SnapshotCache,
+	}
+	ParallelTxsFlag = cli.IntFlag{
+		Name:  "parallel-txs",
+		Usage: "Number of goroutines executing the transactions of imported blocks in parallel (0 = sequential)",
+		Value: config.DefaultConfig.ParallelTxs,
+	}
+	GCModeFlag = cli.StringFlag{
+		Name:  "gcmode",
+		Usage: `Blockchain garbage collection mode ("full", "archive")`,
+		Value: "full",
+	}
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
 		Name:  "mine",
@@ -251,6 +284,19 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerTxOrderFlag = cli.StringFlag{
+		Name:  "miner.txorder",
+		Usage: `Ordering of the transactions in produced blocks ("price", "fcfs" or "price-arrival")`,
+		Value: config.DefaultMinerConfig.TxOrder,
+	}
+	MinerSenderTxCapFlag = cli.IntFlag{
+		Name:  "miner.sendertxcap",
+		Usage: "Maximum number of transactions of a single sender per produced block (0 = no limit)",
+	}
+	MinerPriorityContractsFlag = cli.StringFlag{
+		Name:  "miner.prioritycontracts",
+		Usage: "Comma separated list of system contracts whose transactions are included first",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -276,6 +322,16 @@ var (
 		Name:  metrics.MetricsEnabledFlag,
 		Usage: "Enable metrics collection and reporting",
 	}
+	MetricsAddrFlag = cli.StringFlag{
+		Name:  "metrics.addr",
+		Usage: "Listening address of the Prometheus metrics exporter",
+		Value: config.DefaultMetricsConfig.ListenAddr,
+	}
+	MetricsNamespaceFlag = cli.StringFlag{
+		Name:  "metrics.namespace",
+		Usage: "Namespace prefixing the exported metric names",
+		Value: config.DefaultMetricsConfig.Namespace,
+	}
 	FakePoWFlag = cli.BoolFlag{
 		Name:  "fakepow",
 		Usage: "Disables proof-of-work verification",
@@ -314,6 +370,10 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	GraphQLEnabledFlag = cli.BoolFlag{
+		Name:  "graphql",
+		Usage: "Enable the GraphQL query service at /graphql on the HTTP-RPC server",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -361,6 +421,21 @@ var (
 		Usage: "Maximum number of network peers (network disabled if set to 0)",
 		Value: 25,
 	}
+	PeerLimitFlag = cli.IntFlag{
+		Name:  "peerlimit",
+		Usage: "Maximum number of admitted peers, trusted and static peers excepted (0 = no limit)",
+		Value: 0,
+	}
+	BWTestRateFlag = cli.Uint64Flag{
+		Name:  "bwtest.rate",
+		Usage: "Bytes per second of the bandwidth test streams served to the peers",
+		Value: config.DefaultNTConfig.BWTestRate,
+	}
+	BWTestDurationFlag = cli.DurationFlag{
+		Name:  "bwtest.duration",
+		Usage: "Length of the bandwidth test streams served to the peers",
+		Value: config.DefaultNTConfig.BWTestDuration,
+	}
 	MaxPendingPeersFlag = cli.IntFlag{
 		Name:  "maxpendpeers",
 		Usage: "Maximum number of pending connection attempts (defaults used if set to 0)",
@@ -395,6 +470,11 @@ var (
 		Usage: "P2P node type(synnode,bootnode)",
 		Value: "",
 	}
+	P2PAuthFlag = cli.StringFlag{
+		Name:  "p2pauth",
+		Usage: "P2P peer authentication mode (boe,key)",
+		Value: config.AuthModeBoe,
+	}
 	NodeKeyHexFlag = cli.StringFlag{
 		Name:  "nodekeyhex",
 		Usage: "P2P node key as hex (for testing)",
@@ -725,6 +805,15 @@ func SetNetWorkConfig(ctx *cli.Context, cfg *config.HpbConfig) {
 	if ctx.GlobalIsSet(MaxPeersFlag.Name) {
 		cfg.Network.MaxPeers = ctx.GlobalInt(MaxPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(PeerLimitFlag.Name) {
+		cfg.Network.PeerLimit = ctx.GlobalInt(PeerLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(BWTestRateFlag.Name) {
+		cfg.Network.BWTestRate = ctx.GlobalUint64(BWTestRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(BWTestDurationFlag.Name) {
+		cfg.Network.BWTestDuration = ctx.GlobalDuration(BWTestDurationFlag.Name)
+	}
 	if ctx.GlobalIsSet(MaxPendingPeersFlag.Name) {
 		cfg.Network.MaxPendingPeers = ctx.GlobalInt(MaxPendingPeersFlag.Name)
 	}
@@ -741,15 +830,23 @@ func SetNetWorkConfig(ctx *cli.Context, cfg *config.HpbConfig) {
 	}
 
 	if ctx.GlobalBool(DevModeFlag.Name) {
-		// --dev mode can't use p2p networking.
-		cfg.Network.MaxPeers = 0
-		cfg.Network.ListenAddr = ":0"
+		// --dev mode can't use p2p networking, neither listen nor discover.
+		cfg.Network.BootstrapNodes = nil
+		cfg.Network.ListenAddr = ""
 		cfg.Network.NoDiscovery = true
 	}
 
 	if nodetype := ctx.GlobalString(NodeTypeFlag.Name); nodetype != "" {
 		cfg.Network.RoleType = nodetype
 	}
+	if ctx.GlobalIsSet(P2PAuthFlag.Name) {
+		cfg.Network.AuthMode = ctx.GlobalString(P2PAuthFlag.Name)
+	}
+	switch cfg.Network.AuthMode {
+	case "", config.AuthModeBoe, config.AuthModeKey:
+	default:
+		Fatalf("Option %q: unknown authentication mode %q", P2PAuthFlag.Name, cfg.Network.AuthMode)
+	}
 	//config IPCPath
 	checkExclusive(ctx, IPCDisabledFlag, IPCPathFlag)
 	switch {
@@ -779,6 +876,9 @@ func SetNetWorkConfig(ctx *cli.Context, cfg *config.HpbConfig) {
 	if ctx.GlobalIsSet(RPCVirtualHostsFlag.Name) {
 		cfg.Network.HTTPVirtualHosts = splitAndTrim(ctx.GlobalString(RPCVirtualHostsFlag.Name))
 	}
+	if ctx.GlobalIsSet(GraphQLEnabledFlag.Name) {
+		cfg.Network.GraphQLEnabled = ctx.GlobalBool(GraphQLEnabledFlag.Name)
+	}
 	if ctx.GlobalBool(WSEnabledFlag.Name) && cfg.Network.WSHost == "" {
 		cfg.Network.WSHost = "127.0.0.1"
 		if ctx.GlobalIsSet(WSListenAddrFlag.Name) {
@@ -805,6 +905,45 @@ func SetConfig(ctx *cli.Context, cfg *config.HpbConfig) {
 
 	SetNodeConfig(ctx, cfg)
 	SetNetWorkConfig(ctx, cfg)
+	SetMetricsConfig(ctx, cfg)
+	SetMinerConfig(ctx, cfg)
+}
+
+// SetMinerConfig applies block production related command line flags to the config.
+func SetMinerConfig(ctx *cli.Context, cfg *config.HpbConfig) {
+	if ctx.GlobalIsSet(MinerTxOrderFlag.Name) {
+		cfg.Miner.TxOrder = ctx.GlobalString(MinerTxOrderFlag.Name)
+	}
+	switch cfg.Miner.TxOrder {
+	case "", config.TxOrderPrice, config.TxOrderArrival, config.TxOrderPriceArrival:
+	default:
+		Fatalf("--%s must be one of %q, %q or %q", MinerTxOrderFlag.Name, config.TxOrderPrice, config.TxOrderArrival, config.TxOrderPriceArrival)
+	}
+	if ctx.GlobalIsSet(MinerSenderTxCapFlag.Name) {
+		cfg.Miner.SenderTxCap = ctx.GlobalInt(MinerSenderTxCapFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerPriorityContractsFlag.Name) {
+		cfg.Miner.PriorityContracts = nil
+		for _, account := range strings.Split(ctx.GlobalString(MinerPriorityContractsFlag.Name), ",") {
+			if account = strings.TrimSpace(account); !common.IsHexAddress(account) {
+				Fatalf("Invalid priority contract address %q", account)
+			}
+			cfg.Miner.PriorityContracts = append(cfg.Miner.PriorityContracts, common.HexToAddress(account))
+		}
+	}
+}
+
+// SetMetricsConfig applies metrics-related command line flags to the config.
+func SetMetricsConfig(ctx *cli.Context, cfg *config.HpbConfig) {
+	if ctx.GlobalBool(MetricsEnabledFlag.Name) {
+		cfg.Metrics.Enabled = true
+	}
+	if ctx.GlobalIsSet(MetricsAddrFlag.Name) {
+		cfg.Metrics.ListenAddr = ctx.GlobalString(MetricsAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsNamespaceFlag.Name) {
+		cfg.Metrics.Namespace = ctx.GlobalString(MetricsNamespaceFlag.Name)
+	}
 }
 
 // SetNodeConfig applies node-related command line flags to the config.
@@ -813,7 +952,14 @@ func SetNodeConfig(ctx *cli.Context, cfg *config.HpbConfig) {
 	case ctx.GlobalIsSet(DataDirFlag.Name):
 		cfg.Node.DataDir = ctx.GlobalString(DataDirFlag.Name)
 	case ctx.GlobalBool(DevModeFlag.Name):
-		cfg.Node.DataDir = filepath.Join(os.TempDir(), "hpb_dev_mode")
+		// Every developer chain starts from its genesis in a fresh directory
+		datadir, err := ioutil.TempDir("", "hpb_dev_mode")
+		if err != nil {
+			Fatalf("Failed to create developer datadir: %v", err)
+		}
+		log.Info("Using ephemeral developer datadir", "path", datadir)
+		cfg.Node.DataDir = datadir
+		cfg.Node.EphemeralDataDir = true
 	case ctx.GlobalBool(TestnetFlag.Name):
 		cfg.Node.DataDir = filepath.Join(config.DefaultDataDir(), "testnet")
 	}
@@ -846,6 +992,16 @@ func SetNodeConfig(ctx *cli.Context, cfg *config.HpbConfig) {
 	if ctx.GlobalIsSet(CacheFlag.Name) {
 		cfg.Node.DatabaseCache = ctx.GlobalInt(CacheFlag.Name)
 	}
+	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
+		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
+	}
+	cfg.Node.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	if ctx.GlobalIsSet(SnapshotCacheFlag.Name) {
+		cfg.Node.SnapshotCache = ctx.GlobalInt(SnapshotCacheFlag.Name)
+	}
+	if ctx.GlobalIsSet(ParallelTxsFlag.Name) {
+		cfg.Node.ParallelTxs = ctx.GlobalInt(ParallelTxsFlag.Name)
+	}
 	cfg.Node.DatabaseHandles = makeDatabaseHandles()
 
 	if ctx.GlobalIsSet(MinerThreadsFlag.Name) {
@@ -871,14 +1027,14 @@ func SetNodeConfig(ctx *cli.Context, cfg *config.HpbConfig) {
 		if nil == res || len(res) < 2 {
 			cfg.Node.TestCodeParam = 1
 		} else {
-			consensus.StageNumberII = uint64(res[0])
-			consensus.StageNumberIII = uint64(res[1])
+			cfg.BlockChain.StageIIBlock = big.NewInt(int64(res[0]))
+			cfg.BlockChain.StageIIIBlock = big.NewInt(int64(res[1]))
 		}
 
 		if nil != res || len(res) == 3 {
-			consensus.StageNumberII = uint64(res[0])
-			consensus.StageNumberIII = uint64(res[1])
-			consensus.StageNumberIV = uint64(res[2])
+			cfg.BlockChain.StageIIBlock = big.NewInt(int64(res[0]))
+			cfg.BlockChain.StageIIIBlock = big.NewInt(int64(res[1]))
+			cfg.BlockChain.StageIVBlock = big.NewInt(int64(res[2]))
 		}
 
 	}
@@ -898,6 +1054,11 @@ func SetNodeConfig(ctx *cli.Context, cfg *config.HpbConfig) {
 		if !ctx.GlobalIsSet(GasPriceFlag.Name) {
 			cfg.Node.GasPrice = new(big.Int)
 		}
+		if !ctx.GlobalIsSet(NetworkIdFlag.Name) {
+			cfg.Node.NetworkId = 1337
+		}
+		cfg.Prometheus.Period = uint64(ctx.GlobalInt(DevPeriodFlag.Name))
+		cfg.BlockChain = *config.DeveloperChainConfig(cfg.Prometheus.Period)
 	}
 
 	if gen := ctx.GlobalInt(TrieCacheGenFlag.Name); gen > 0 {
@@ -946,6 +1107,15 @@ func setGPO(ctx *cli.Context, cfg *config.GpoConfig) {
 }
 
 func SetTxPool(ctx *cli.Context, cfg *config.TxPoolConfiguration) {
+	if ctx.GlobalIsSet(TxPoolNoLocalsFlag.Name) {
+		cfg.NoLocals = ctx.GlobalBool(TxPoolNoLocalsFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolJournalFlag.Name) {
+		cfg.Journal = ctx.GlobalString(TxPoolJournalFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
+		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1008,8 +1178,6 @@ func MakeGenesis(ctx *cli.Context) *bc.Genesis {
 	switch {
 	case ctx.GlobalBool(TestnetFlag.Name):
 		genesis = bc.DefaultTestnetGenesisBlock()
-	case ctx.GlobalBool(DevModeFlag.Name):
-		genesis = bc.DevGenesisBlock()
 	}
 	return genesis
 }
@@ -1027,7 +1195,13 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *bc.BlockChain, chainD
 
 	engine = prometheus.New(cfg.Prometheus, chainDb)
 
-	chain, err = bc.NewBlockChainWithEngine(chainDb, cfg, engine)
+	cache := &bc.CacheConfig{
+		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
+		TrieNodeLimit: config.DefaultConfig.TrieCache,
+		TrieTimeLimit: config.DefaultConfig.TrieTimeout,
+		SnapshotLimit: ctx.GlobalInt(SnapshotCacheFlag.Name),
+	}
+	chain, err = bc.NewBlockChainWithEngine(chainDb, cache, cfg, engine)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
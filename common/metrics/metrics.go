@@ -18,18 +18,18 @@
 package metrics
 
 import (
+	"net"
+	"net/http"
 	"os"
 	"runtime"
 	"strings"
 	"time"
 
-	"github.com/deathowl/go-metrics-prometheus"
 	"github.com/hpb-project/go-hpb/common/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rcrowley/go-metrics"
 	"github.com/rcrowley/go-metrics/exp"
-	"net/http"
 )
 
 // MetricsEnabledFlag is the CLI flag name to use to enable metrics collections.
@@ -38,29 +38,33 @@ const MetricsEnabledFlag = "metrics"
 // Enabled is the flag specifying if metrics are enable or not.
 var Enabled = true
 
-// Init enables or disables the metrics system. Since we need this to run before
-// any other code gets to create meters and timers, we'll actually do an ugly hack
-// and peek into the command line args for the metrics flag.
-func Start() {
-	for _, arg := range os.Args {
-		if strings.TrimLeft(arg, "-") == MetricsEnabledFlag {
-			log.Info("Enabling metrics collection")
-			Enabled = true
-		}
+// Start serves the metrics registry to Prometheus on the given address, the
+// names of all the exported metrics are prefixed with namespace. The process
+// and Go runtime collectors are exported too.
+func Start(addr string, namespace string) error {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(prometheus.NewProcessCollector(os.Getpid(), namespace)); err != nil {
+		return err
+	}
+	if err := registry.Register(prometheus.NewGoCollector()); err != nil {
+		return err
 	}
-	prometheusRegistry := prometheus.NewRegistry()
-	prometheusRegistry.MustRegister(prometheus.NewProcessCollector(os.Getpid(), ""))
-	prometheusRegistry.MustRegister(prometheus.NewGoCollector())
-	metricsRegistry := metrics.DefaultRegistry
-	pClient := prometheusmetrics.NewPrometheusProvider(metricsRegistry, "go_hpb", "", prometheusRegistry, 1*time.Second)
-	go pClient.UpdatePrometheusMetrics()
-	exp.Exp(metricsRegistry)
-	go func() {
-		http.Handle("/metrics", promhttp.InstrumentMetricHandler(
-			prometheusRegistry, promhttp.HandlerFor(prometheusRegistry, promhttp.HandlerOpts{}),
-		))
-		http.ListenAndServe("0.0.0.0:8080", nil)
-	}()
+	if err := registry.Register(newCollector(metrics.DefaultRegistry, namespace)); err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		registry, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+	))
+	mux.Handle("/debug/metrics", exp.ExpHandler(metrics.DefaultRegistry))
+
+	log.Info("Starting metrics exporter", "addr", listener.Addr(), "namespace", namespace)
+	go http.Serve(listener, mux)
+	return nil
 }
 
 // NewCounter create a new metrics Counter, either a real one of a NOP stub depending
@@ -90,6 +94,24 @@ func NewTimer(name string) metrics.Timer {
 	return metrics.GetOrRegisterTimer(strings.NewReplacer("/", "_").Replace(name), metrics.DefaultRegistry)
 }
 
+// NewGauge create a new metrics Gauge, either a real one of a NOP stub depending
+// on the metrics flag.
+func NewGauge(name string) metrics.Gauge {
+	if !Enabled {
+		return new(metrics.NilGauge)
+	}
+	return metrics.GetOrRegisterGauge(strings.NewReplacer("/", "_").Replace(name), metrics.DefaultRegistry)
+}
+
+// NewHistogram create a new metrics Histogram over an exponentially decaying
+// sample, either a real one of a NOP stub depending on the metrics flag.
+func NewHistogram(name string) metrics.Histogram {
+	if !Enabled {
+		return new(metrics.NilHistogram)
+	}
+	return metrics.GetOrRegisterHistogram(strings.NewReplacer("/", "_").Replace(name), metrics.DefaultRegistry, metrics.NewExpDecaySample(1028, 0.015))
+}
+
 // CollectProcessMetrics periodically collects various metrics about the running
 // process.
 func CollectProcessMetrics(refresh time.Duration) {
@@ -0,0 +1,107 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rcrowley/go-metrics"
+)
+
+// quantiles are the percentiles reported for timers and histograms.
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99}
+
+// collector exports the content of a go-metrics registry to Prometheus. Unlike
+// a periodic copy into gauges, the registry is walked on every scrape and the
+// timers and histograms keep their distribution.
+type collector struct {
+	registry  metrics.Registry
+	namespace string
+	count     *prometheus.Desc // Number of metrics in the registry
+}
+
+func newCollector(registry metrics.Registry, namespace string) *collector {
+	return &collector{
+		registry:  registry,
+		namespace: namespace,
+		count:     prometheus.NewDesc(prometheus.BuildFQName(namespace, "metrics", "registered"), "Number of exported metrics", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector. The registry content changes over
+// time, so only the static metric is described.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.count
+}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	count := 0
+	c.registry.Each(func(name string, i interface{}) {
+		count++
+		switch metric := i.(type) {
+		case metrics.Counter:
+			c.value(ch, name, prometheus.GaugeValue, float64(metric.Count()))
+		case metrics.Gauge:
+			c.value(ch, name, prometheus.GaugeValue, float64(metric.Value()))
+		case metrics.GaugeFloat64:
+			c.value(ch, name, prometheus.GaugeValue, metric.Value())
+		case metrics.Meter:
+			c.value(ch, name, prometheus.CounterValue, float64(metric.Count()))
+		case metrics.Timer:
+			snap := metric.Snapshot()
+			c.summary(ch, name+"_seconds", snap.Count(), float64(snap.Sum())/float64(time.Second), snap.Percentiles(quantiles), float64(time.Second))
+		case metrics.Histogram:
+			snap := metric.Snapshot()
+			c.summary(ch, name, snap.Count(), float64(snap.Sum()), snap.Percentiles(quantiles), 1)
+		default:
+			count--
+		}
+	})
+	ch <- prometheus.MustNewConstMetric(c.count, prometheus.GaugeValue, float64(count))
+}
+
+// value exports a single valued metric.
+func (c *collector) value(ch chan<- prometheus.Metric, name string, kind prometheus.ValueType, value float64) {
+	desc := prometheus.NewDesc(c.name(name), name, nil, nil)
+	ch <- prometheus.MustNewConstMetric(desc, kind, value)
+}
+
+// summary exports a distribution, the percentiles are divided by scale.
+func (c *collector) summary(ch chan<- prometheus.Metric, name string, count int64, sum float64, percentiles []float64, scale float64) {
+	values := make(map[float64]float64, len(quantiles))
+	for i, q := range quantiles {
+		values[q] = percentiles[i] / scale
+	}
+	desc := prometheus.NewDesc(c.name(name), name, nil, nil)
+	ch <- prometheus.MustNewConstSummary(desc, uint64(count), sum, values)
+}
+
+// name converts a registry name into a valid Prometheus metric name within the
+// namespace of the collector.
+func (c *collector) name(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	return prometheus.BuildFQName(c.namespace, "", name)
+}
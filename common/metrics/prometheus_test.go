@@ -0,0 +1,75 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rcrowley/go-metrics"
+)
+
+// Tests that the collector exports every metric kind of a go-metrics registry
+// under the configured namespace.
+func TestCollectorExport(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("p2p_peers", registry).Inc(3)
+	metrics.GetOrRegisterMeter("chain_reorg", registry).Mark(2)
+	metrics.GetOrRegisterGauge("txpool/pending", registry).Update(7)
+	timer := metrics.GetOrRegisterTimer("chain_inserts", registry)
+	timer.Update(time.Second)
+	timer.Update(3 * time.Second)
+
+	prom := prometheus.NewRegistry()
+	if err := prom.Register(newCollector(registry, "hpb")); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+	families, err := prom.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	exported := make(map[string]*dto.Metric)
+	for _, family := range families {
+		exported[family.GetName()] = family.GetMetric()[0]
+	}
+	if len(exported) != 5 {
+		t.Errorf("exported metric count mismatch: have %d, want %d", len(exported), 5)
+	}
+	if have := exported["hpb_p2p_peers"].GetGauge().GetValue(); have != 3 {
+		t.Errorf("counter mismatch: have %v, want %v", have, 3)
+	}
+	if have := exported["hpb_chain_reorg"].GetCounter().GetValue(); have != 2 {
+		t.Errorf("meter mismatch: have %v, want %v", have, 2)
+	}
+	if have := exported["hpb_txpool_pending"].GetGauge().GetValue(); have != 7 {
+		t.Errorf("gauge mismatch: have %v, want %v", have, 7)
+	}
+	if have := exported["hpb_metrics_registered"].GetGauge().GetValue(); have != 4 {
+		t.Errorf("registered count mismatch: have %v, want %v", have, 4)
+	}
+	summary := exported["hpb_chain_inserts_seconds"].GetSummary()
+	if summary.GetSampleCount() != 2 || summary.GetSampleSum() != 4 {
+		t.Errorf("timer mismatch: have count %d sum %v, want count 2 sum 4", summary.GetSampleCount(), summary.GetSampleSum())
+	}
+	for _, q := range summary.GetQuantile() {
+		if v := q.GetValue(); v < 1 || v > 3 {
+			t.Errorf("quantile %v out of range: %v", q.GetQuantile(), v)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"sync"
+	"time"
+
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/log"
+)
+
+// LeafCallback is a callback type invoked when a trie node containing a leaf
+// value is inserted into the node database. It is used by the state layer to
+// reference the storage trie and code of an account from its leaf.
+type LeafCallback func(leaf []byte, parent common.Hash) error
+
+// NodeDatabase is an intermediate write layer between the trie data structures
+// and the disk database. Nodes committed by tries are held in memory with their
+// reference counts, so whole tries can be garbage collected by dereferencing
+// their roots, and only the tries which are explicitly committed are flushed
+// to disk.
+//
+// NodeDatabase implements Database, so it can be used as the backing store of
+// any trie. Reads fall back to the disk database for nodes not held in memory.
+type NodeDatabase struct {
+	diskdb hpbdb.Database // Persistent storage for matured trie nodes
+	onleaf LeafCallback   // Callback to reference data held in the leaves
+
+	nodes     map[common.Hash]*cachedNode // Data and references relationships of trie nodes
+	preimages map[string][]byte           // Preimages of secure trie keys
+
+	gctime  time.Duration      // Time spent on garbage collection since last commit
+	gcnodes uint64             // Nodes garbage collected since last commit
+	gcsize  common.StorageSize // Data storage garbage collected since last commit
+
+	nodesSize     common.StorageSize // Storage size of the nodes cache
+	preimagesSize common.StorageSize // Storage size of the preimages cache
+
+	lock sync.RWMutex
+}
+
+// cachedNode is all the information we know about a single cached trie node
+// in the memory database write layer.
+type cachedNode struct {
+	blob     []byte              // Cached data block of the trie node
+	parents  int                 // Number of live nodes referencing this one
+	children map[common.Hash]int // Children referenced by this node
+}
+
+// NewNodeDatabase creates a new trie node database to store ephemeral trie
+// content before it's written out to disk or garbage collected.
+func NewNodeDatabase(diskdb hpbdb.Database, onleaf LeafCallback) *NodeDatabase {
+	return &NodeDatabase{
+		diskdb: diskdb,
+		onleaf: onleaf,
+		nodes: map[common.Hash]*cachedNode{
+			{}: {children: make(map[common.Hash]int)},
+		},
+		preimages: make(map[string][]byte),
+	}
+}
+
+// DiskDB retrieves the persistent storage backing the trie node database.
+func (db *NodeDatabase) DiskDB() hpbdb.Database {
+	return db.diskdb
+}
+
+// Put stores a blob without trie node semantics (e.g. contract code or secure
+// key preimages) in the memory layer.
+func (db *NodeDatabase) Put(key, value []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if len(key) != common.HashLength {
+		if _, ok := db.preimages[string(key)]; !ok {
+			db.preimages[string(key)] = common.CopyBytes(value)
+			db.preimagesSize += common.StorageSize(len(key) + len(value))
+		}
+		return nil
+	}
+	db.insert(common.BytesToHash(key), common.CopyBytes(value), nil)
+	return nil
+}
+
+// insertNode stores a collapsed trie node in the memory layer, referencing all
+// of its children which are cached too.
+func (db *NodeDatabase) insertNode(hash common.Hash, blob []byte, n node) {
+	var leaves [][]byte
+
+	db.lock.Lock()
+	if _, ok := db.nodes[hash]; ok {
+		db.lock.Unlock()
+		return
+	}
+	db.insert(hash, common.CopyBytes(blob), n)
+	if db.onleaf != nil {
+		forGatherLeaves(n, func(leaf []byte) { leaves = append(leaves, leaf) })
+	}
+	db.lock.Unlock()
+
+	// The callback may reference other nodes, run it without holding the lock.
+	for _, leaf := range leaves {
+		db.onleaf(leaf, hash)
+	}
+}
+
+// insert inserts a blob into the memory layer. The lock must be held.
+func (db *NodeDatabase) insert(hash common.Hash, blob []byte, n node) {
+	if _, ok := db.nodes[hash]; ok {
+		return
+	}
+	entry := &cachedNode{
+		blob:     blob,
+		children: make(map[common.Hash]int),
+	}
+	forGatherChildren(n, func(child common.Hash) {
+		if c := db.nodes[child]; c != nil {
+			c.parents++
+			entry.children[child]++
+		}
+	})
+	db.nodes[hash] = entry
+	db.nodesSize += common.StorageSize(common.HashLength + len(blob))
+}
+
+// forGatherChildren traverses the node hierarchy of a collapsed storage node and
+// invokes the callback for all the hashnode children.
+func forGatherChildren(n node, onChild func(hash common.Hash)) {
+	switch n := n.(type) {
+	case *shortNode:
+		forGatherChildren(n.Val, onChild)
+	case *fullNode:
+		for i := 0; i < 16; i++ {
+			forGatherChildren(n.Children[i], onChild)
+		}
+	case hashNode:
+		onChild(common.BytesToHash(n))
+	}
+}
+
+// forGatherLeaves traverses the node hierarchy of a collapsed storage node and
+// invokes the callback for all the embedded leaf values.
+func forGatherLeaves(n node, onLeaf func(leaf []byte)) {
+	switch n := n.(type) {
+	case *shortNode:
+		forGatherLeaves(n.Val, onLeaf)
+	case *fullNode:
+		for i := 0; i < 17; i++ {
+			forGatherLeaves(n.Children[i], onLeaf)
+		}
+	case valueNode:
+		if len(n) > 0 {
+			onLeaf(n)
+		}
+	}
+}
+
+// Get retrieves a cached trie node or blob from memory, or from the disk
+// database if it's not cached.
+func (db *NodeDatabase) Get(key []byte) ([]byte, error) {
+	db.lock.RLock()
+	if len(key) == common.HashLength {
+		if node := db.nodes[common.BytesToHash(key)]; node != nil && node.blob != nil {
+			db.lock.RUnlock()
+			return node.blob, nil
+		}
+	} else if preimage, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return preimage, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Get(key)
+}
+
+// Has reports whether a trie node or blob is held in memory or on disk.
+func (db *NodeDatabase) Has(key []byte) (bool, error) {
+	db.lock.RLock()
+	if len(key) == common.HashLength {
+		if node := db.nodes[common.BytesToHash(key)]; node != nil && node.blob != nil {
+			db.lock.RUnlock()
+			return true, nil
+		}
+	} else if _, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return true, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Has(key)
+}
+
+// Nodes retrieves the hashes of all the nodes cached within the memory database.
+func (db *NodeDatabase) Nodes() []common.Hash {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var hashes = make([]common.Hash, 0, len(db.nodes))
+	for hash := range db.nodes {
+		if hash != (common.Hash{}) { // Special case for "root" references/nodes
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// Reference adds a new reference from a parent node to a child node. The empty
+// parent hash references a root which has to be kept alive on its own.
+func (db *NodeDatabase) Reference(child common.Hash, parent common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.reference(child, parent)
+}
+
+// reference is the private locked version of Reference.
+func (db *NodeDatabase) reference(child common.Hash, parent common.Hash) {
+	// If the node does not exist, it's a node pulled from disk, skip
+	node, ok := db.nodes[child]
+	if !ok {
+		return
+	}
+	owner, ok := db.nodes[parent]
+	if !ok {
+		return
+	}
+	// If the reference already exists, only duplicate for roots
+	if _, ok = owner.children[child]; ok && parent != (common.Hash{}) {
+		return
+	}
+	node.parents++
+	owner.children[child]++
+}
+
+// Dereference removes an existing reference from a root node, garbage collecting
+// every node which is not referenced anymore.
+func (db *NodeDatabase) Dereference(root common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	nodes, storage, start := len(db.nodes), db.nodesSize, time.Now()
+	db.dereference(root, common.Hash{})
+
+	db.gcnodes += uint64(nodes - len(db.nodes))
+	db.gcsize += storage - db.nodesSize
+	db.gctime += time.Since(start)
+
+	log.Debug("Dereferenced trie from memory database", "nodes", nodes-len(db.nodes), "size", storage-db.nodesSize, "time", time.Since(start),
+		"gcnodes", db.gcnodes, "gcsize", db.gcsize, "gctime", db.gctime, "livenodes", len(db.nodes), "livesize", db.nodesSize)
+}
+
+// dereference is the private locked version of Dereference.
+func (db *NodeDatabase) dereference(child common.Hash, parent common.Hash) {
+	// Dereference the parent-child
+	owner := db.nodes[parent]
+	if owner != nil && owner.children[child] > 0 {
+		owner.children[child]--
+		if owner.children[child] == 0 {
+			delete(owner.children, child)
+		}
+	}
+	// If the child does not exist, it's a previously committed node.
+	node, ok := db.nodes[child]
+	if !ok {
+		return
+	}
+	// If there are no more references to the child, delete it and cascade
+	if node.parents > 0 {
+		node.parents--
+	}
+	if node.parents == 0 {
+		for hash, count := range node.children {
+			for i := 0; i < count; i++ {
+				db.dereference(hash, child)
+			}
+		}
+		delete(db.nodes, child)
+		db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
+	}
+}
+
+// Commit iterates over all the children of a particular node, writes them out
+// to disk, forcefully tearing down all references in both directions.
+//
+// As a side effect, all pre-images accumulated up to this point are also written.
+func (db *NodeDatabase) Commit(node common.Hash, report bool) error {
+	// Create a database batch to flush persistent data out. It is important that
+	// outside code doesn't see an inconsistent state (referenced data removed from
+	// memory cache during commit but not yet in persistent storage). This is ensured
+	// by only uncaching existing data when the database write finalizes.
+	db.lock.RLock()
+
+	start := time.Now()
+	batch := db.diskdb.NewBatch()
+	put := func(key, value []byte) error {
+		if err := batch.Put(key, value); err != nil {
+			return err
+		}
+		if batch.ValueSize() > hpbdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch = db.diskdb.NewBatch()
+		}
+		return nil
+	}
+	// Move all of the accumulated preimages into a write batch
+	for key, preimage := range db.preimages {
+		if err := put([]byte(key), preimage); err != nil {
+			log.Error("Failed to commit preimage from trie database", "err", err)
+			db.lock.RUnlock()
+			return err
+		}
+	}
+	// Move the trie itself into the batch, flushing if enough data is accumulated
+	nodes, storage := len(db.nodes), db.nodesSize+db.preimagesSize
+	if err := db.commit(node, put, make(map[common.Hash]struct{})); err != nil {
+		log.Error("Failed to commit trie from trie database", "err", err)
+		db.lock.RUnlock()
+		return err
+	}
+	// Write batch ready, unlock for readers during persistence
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to write trie to disk", "err", err)
+		db.lock.RUnlock()
+		return err
+	}
+	db.lock.RUnlock()
+
+	// Write successful, clear out the flushed data
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.preimages = make(map[string][]byte)
+	db.preimagesSize = 0
+
+	db.uncache(node)
+
+	logger := log.Info
+	if !report {
+		logger = log.Debug
+	}
+	logger("Persisted trie from memory database", "nodes", nodes-len(db.nodes), "size", storage-db.nodesSize, "time", time.Since(start),
+		"gcnodes", db.gcnodes, "gcsize", db.gcsize, "gctime", db.gctime, "livenodes", len(db.nodes), "livesize", db.nodesSize)
+
+	// Reset the garbage collection statistics
+	db.gcnodes, db.gcsize, db.gctime = 0, 0, 0
+
+	return nil
+}
+
+// commit is the private locked version of Commit.
+func (db *NodeDatabase) commit(hash common.Hash, put func(key, value []byte) error, done map[common.Hash]struct{}) error {
+	// If the node does not exist, it's a previously committed node
+	node, ok := db.nodes[hash]
+	if !ok {
+		return nil
+	}
+	if _, ok := done[hash]; ok {
+		return nil
+	}
+	for child := range node.children {
+		if err := db.commit(child, put, done); err != nil {
+			return err
+		}
+	}
+	done[hash] = struct{}{}
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	return put(hash[:], node.blob)
+}
+
+// uncache is the post-processing step of a commit operation where the already
+// persisted trie is removed from the cache. The reason behind the two-phase
+// commit is to ensure consistent data availability while moving from memory
+// to disk.
+func (db *NodeDatabase) uncache(hash common.Hash) {
+	// If the node does not exist, we're done on this path
+	node, ok := db.nodes[hash]
+	if !ok {
+		return
+	}
+	if hash == (common.Hash{}) {
+		for child := range node.children {
+			db.uncache(child)
+		}
+		node.children = make(map[common.Hash]int)
+		return
+	}
+	// Otherwise uncache the node's subtries and remove the node itself too
+	for child := range node.children {
+		db.uncache(child)
+	}
+	delete(db.nodes, hash)
+	db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
+}
+
+// Size returns the current storage size of the memory cache in front of the
+// persistent database layer.
+func (db *NodeDatabase) Size() common.StorageSize {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.nodesSize + db.preimagesSize
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+	"testing"
+
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+)
+
+// Tests that dereferencing a root garbage collects all of its nodes without
+// touching the disk.
+func TestNodeDatabaseDereference(t *testing.T) {
+	diskdb, _ := hpbdb.NewMemDatabase()
+	triedb := NewNodeDatabase(diskdb, nil)
+
+	trie, _ := New(common.Hash{}, triedb)
+	for i := 0; i < 100; i++ {
+		updateString(trie, fmt.Sprintf("key-%d", i), fmt.Sprintf("a long enough value to be stored as node %d", i))
+	}
+	root, err := trie.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if len(diskdb.Keys()) != 0 {
+		t.Fatalf("nodes leaked to disk before flush: %d", len(diskdb.Keys()))
+	}
+	triedb.Reference(root, common.Hash{})
+	if _, err := New(root, triedb); err != nil {
+		t.Fatalf("failed to reopen trie from memory: %v", err)
+	}
+	triedb.Dereference(root)
+	if size := triedb.Size(); size != 0 {
+		t.Fatalf("dangling nodes after dereference: %v", size)
+	}
+	if nodes := triedb.Nodes(); len(nodes) != 0 {
+		t.Fatalf("dangling node count mismatch: have %d, want 0", len(nodes))
+	}
+}
+
+// Tests that nodes shared between two tries are kept alive as long as one of
+// the tries is referenced, and that committing flushes the trie to disk.
+func TestNodeDatabaseSharedNodes(t *testing.T) {
+	diskdb, _ := hpbdb.NewMemDatabase()
+	triedb := NewNodeDatabase(diskdb, nil)
+
+	trie, _ := New(common.Hash{}, triedb)
+	for i := 0; i < 100; i++ {
+		updateString(trie, fmt.Sprintf("key-%d", i), fmt.Sprintf("a long enough value to be stored as node %d", i))
+	}
+	first, _ := trie.Commit()
+	triedb.Reference(first, common.Hash{})
+
+	updateString(trie, "key-0", "an updated value which is long enough to be a node")
+	second, _ := trie.Commit()
+	triedb.Reference(second, common.Hash{})
+
+	triedb.Dereference(first)
+	if _, err := New(second, triedb); err != nil {
+		t.Fatalf("second trie lost after dereferencing the first: %v", err)
+	}
+	checkNodeDatabaseTrie(t, triedb, second)
+
+	if err := triedb.Commit(second, false); err != nil {
+		t.Fatalf("failed to flush trie: %v", err)
+	}
+	if size := triedb.Size(); size != 0 {
+		t.Fatalf("dangling nodes after commit: %v", size)
+	}
+	checkNodeDatabaseTrie(t, diskdb, second)
+}
+
+func checkNodeDatabaseTrie(t *testing.T, db Database, root common.Hash) {
+	trie, err := New(root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", root, err)
+	}
+	it := NewIterator(trie.NodeIterator(nil))
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if it.Err != nil {
+		t.Fatalf("trie %x iteration failed: %v", root, it.Err)
+	}
+	if count != 100 {
+		t.Fatalf("trie %x item count mismatch: have %d, want 100", root, count)
+	}
+}
@@ -167,6 +167,11 @@ func (h *hasher) store(n node, db DatabaseWriter, force bool) (node, error) {
 		hash = hashNode(h.sha.Sum(nil))
 	}
 	if db != nil {
+		// Node databases track the references of the node to its children.
+		if ndb, ok := db.(*NodeDatabase); ok {
+			ndb.insertNode(common.BytesToHash(hash), h.tmp.Bytes(), n)
+			return hash, nil
+		}
 		return hash, db.Put(hash, h.tmp.Bytes())
 	}
 	return hash, nil
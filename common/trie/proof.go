@@ -124,6 +124,421 @@ func VerifyProof(rootHash common.Hash, key []byte, proof []rlp.RawValue) (value
 	return nil, errors.New("unexpected end of proof")
 }
 
+// ProveRange constructs the merkle proof of a contiguous range of the trie
+// bounded by the keys first and last. The result contains the nodes of the
+// proofs of both edge keys, each node listed only once. Either edge may be a
+// key that is not present in the trie.
+func (t *Trie) ProveRange(first, last []byte) []rlp.RawValue {
+	proof := t.Prove(first)
+	if proof == nil {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(proof))
+	for _, n := range proof {
+		seen[string(n)] = struct{}{}
+	}
+	edge := t.Prove(last)
+	if edge == nil {
+		return nil
+	}
+	for _, n := range edge {
+		if _, ok := seen[string(n)]; !ok {
+			seen[string(n)] = struct{}{}
+			proof = append(proof, n)
+		}
+	}
+	return proof
+}
+
+// VerifyRangeProof checks whether the given leaves are exactly the contents of
+// the trie with the given root hash between firstKey and lastKey, using the
+// merkle proofs of both edge keys. The leaves must be sorted, the edge keys may
+// point to keys not present in the trie. The returned flag reports whether
+// the trie holds further leaves beyond the last one.
+//
+// Special cases:
+//   - an empty proof requires the leaves to make up the entire trie
+//   - no leaves require the proof to show that nothing exists after firstKey
+func VerifyRangeProof(rootHash common.Hash, firstKey []byte, lastKey []byte, keys [][]byte, values [][]byte, proof []rlp.RawValue) (bool, error) {
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("inconsistent proof data, keys: %d, values: %d", len(keys), len(values))
+	}
+	// Ensure the received batch is monotonically increasing and has no deletions
+	for i := 0; i < len(keys)-1; i++ {
+		if bytes.Compare(keys[i], keys[i+1]) >= 0 {
+			return false, errors.New("range is not monotonically increasing")
+		}
+	}
+	for _, value := range values {
+		if len(value) == 0 {
+			return false, errors.New("range contains deletion")
+		}
+	}
+	// Without an edge proof the leaves must reconstruct the whole trie
+	if len(proof) == 0 {
+		tr := &Trie{db: emptyDatabase{}}
+		for i, key := range keys {
+			if err := tr.TryUpdate(key, values[i]); err != nil {
+				return false, err
+			}
+		}
+		if have := tr.Hash(); have != rootHash {
+			return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+		}
+		return false, nil
+	}
+	nodes, err := newProofNodes(proof)
+	if err != nil {
+		return false, err
+	}
+	// With no leaves, the proof must show there is nothing after firstKey
+	if len(keys) == 0 {
+		root, val, err := proofToPath(rootHash, nil, firstKey, nodes, true)
+		if err != nil {
+			return false, err
+		}
+		if val != nil || hasRightElement(root, firstKey) {
+			return false, errors.New("more entries available")
+		}
+		return false, nil
+	}
+	// A single leaf proven by identical edge keys can't have two edge paths
+	if len(keys) == 1 && bytes.Equal(firstKey, lastKey) {
+		root, val, err := proofToPath(rootHash, nil, firstKey, nodes, false)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(firstKey, keys[0]) {
+			return false, errors.New("correct proof but invalid key")
+		}
+		if !bytes.Equal(val, values[0]) {
+			return false, errors.New("correct proof but invalid data")
+		}
+		return hasRightElement(root, firstKey), nil
+	}
+	// All other cases require two distinct edge paths of the same length
+	if bytes.Compare(firstKey, lastKey) >= 0 {
+		return false, errors.New("invalid edge keys")
+	}
+	if len(firstKey) != len(lastKey) {
+		return false, errors.New("inconsistent edge keys")
+	}
+	if bytes.Compare(keys[0], firstKey) < 0 || bytes.Compare(keys[len(keys)-1], lastKey) > 0 {
+		return false, errors.New("range exceeds edge keys")
+	}
+	// Resolve both edge paths into a partial trie with the shape of the original
+	root, _, err := proofToPath(rootHash, nil, firstKey, nodes, true)
+	if err != nil {
+		return false, err
+	}
+	root, _, err = proofToPath(rootHash, root, lastKey, nodes, true)
+	if err != nil {
+		return false, err
+	}
+	// Drop everything between the edges and refill it from the leaves, the
+	// result must hash to the original root.
+	empty, err := unsetInternal(root, firstKey, lastKey)
+	if err != nil {
+		return false, err
+	}
+	tr := &Trie{root: root, db: emptyDatabase{}}
+	if empty {
+		tr.root = nil
+	}
+	for i, key := range keys {
+		if err := tr.TryUpdate(key, values[i]); err != nil {
+			return false, err
+		}
+	}
+	if have := tr.Hash(); have != rootHash {
+		return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+	}
+	return hasRightElement(tr.root, keys[len(keys)-1]), nil
+}
+
+// proofNodes indexes the nodes of a merkle proof by their hash.
+type proofNodes map[common.Hash][]byte
+
+func newProofNodes(proof []rlp.RawValue) (proofNodes, error) {
+	sha := sha3.NewKeccak256()
+	nodes := make(proofNodes, len(proof))
+	for _, buf := range proof {
+		if len(buf) == 0 {
+			return nil, errors.New("empty proof node")
+		}
+		var hash common.Hash
+		sha.Reset()
+		sha.Write(buf)
+		sha.Sum(hash[:0])
+		nodes[hash] = buf
+	}
+	return nodes, nil
+}
+
+// proofToPath resolves the path to key from the proof nodes into the partial
+// trie rooted at root, creating the root if nil. Nodes off the path are left
+// as hash nodes. Unless allowNonExistent is set, the key must be in the trie.
+func proofToPath(rootHash common.Hash, root node, key []byte, nodes proofNodes, allowNonExistent bool) (node, []byte, error) {
+	resolve := func(hash common.Hash) (node, error) {
+		buf, ok := nodes[hash]
+		if !ok {
+			return nil, fmt.Errorf("proof node (hash %064x) missing", hash)
+		}
+		n, err := decodeNode(hash[:], buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node %v", err)
+		}
+		return n, nil
+	}
+	if root == nil {
+		n, err := resolve(rootHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		root = n
+	}
+	key = keybytesToHex(key)
+	parent := root
+	for {
+		// Step one node down the path
+		var (
+			keyrest []byte
+			child   node
+		)
+		switch n := parent.(type) {
+		case *shortNode:
+			if len(key) < len(n.Key) || !bytes.Equal(n.Key, key[:len(n.Key)]) {
+				child = nil
+			} else {
+				keyrest, child = key[len(n.Key):], n.Val
+			}
+		case *fullNode:
+			keyrest, child = key[1:], n.Children[key[0]]
+		default:
+			return nil, nil, fmt.Errorf("%T: unexpected node in proof path", parent)
+		}
+		var value valueNode
+		switch cld := child.(type) {
+		case nil:
+			// The trie doesn't contain the key, the resolved nodes are still
+			// enough to prove the range.
+			if allowNonExistent {
+				return root, nil, nil
+			}
+			return nil, nil, errors.New("the node is not contained in trie")
+		case *shortNode, *fullNode:
+			key, parent = keyrest, child // Already resolved
+			continue
+		case hashNode:
+			resolved, err := resolve(common.BytesToHash(cld))
+			if err != nil {
+				return nil, nil, err
+			}
+			child = resolved
+		case valueNode:
+			value = cld
+		}
+		// Link the resolved child into its parent
+		switch pnode := parent.(type) {
+		case *shortNode:
+			pnode.Val = child
+		case *fullNode:
+			pnode.Children[key[0]] = child
+		}
+		if value != nil {
+			return root, value, nil // The whole path is resolved
+		}
+		key, parent = keyrest, child
+	}
+}
+
+// unsetInternal removes all the nodes between the edge paths of left and right
+// from the partial trie, to be refilled with the leaves of the range. Nodes on
+// the edge paths are marked dirty as their content changes. It reports whether
+// the entire trie falls into the range.
+func unsetInternal(n node, left []byte, right []byte) (bool, error) {
+	left, right = keybytesToHex(left), keybytesToHex(right)
+
+	// Step down to the fork point of the two edge paths, which is either a
+	// short node not matched by one of the paths or a full node where they
+	// diverge.
+	var (
+		pos    = 0
+		parent node
+
+		// fork indicators, -1 means the path is less, 1 means it is greater
+		shortForkLeft, shortForkRight int
+	)
+findFork:
+	for {
+		switch rn := n.(type) {
+		case *shortNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			if len(left)-pos < len(rn.Key) {
+				shortForkLeft = bytes.Compare(left[pos:], rn.Key)
+			} else {
+				shortForkLeft = bytes.Compare(left[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if len(right)-pos < len(rn.Key) {
+				shortForkRight = bytes.Compare(right[pos:], rn.Key)
+			} else {
+				shortForkRight = bytes.Compare(right[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if shortForkLeft != 0 || shortForkRight != 0 {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Val, pos+len(rn.Key)
+		case *fullNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			leftnode, rightnode := rn.Children[left[pos]], rn.Children[right[pos]]
+			if leftnode == nil || rightnode == nil || leftnode != rightnode {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Children[left[pos]], pos+1
+		default:
+			return false, fmt.Errorf("%T: unexpected node in range proof", n)
+		}
+	}
+	switch rn := n.(type) {
+	case *shortNode:
+		// Both paths on the same side of the short node leave an empty range
+		if shortForkLeft == -1 && shortForkRight == -1 {
+			return false, errors.New("empty range")
+		}
+		if shortForkLeft == 1 && shortForkRight == 1 {
+			return false, errors.New("empty range")
+		}
+		// The short node lies entirely within the range, drop it
+		if shortForkLeft != 0 && shortForkRight != 0 {
+			if parent == nil {
+				return true, nil
+			}
+			parent.(*fullNode).Children[left[pos-1]] = nil
+			return false, nil
+		}
+		// Only one of the paths runs through the short node
+		if shortForkRight != 0 {
+			if _, ok := rn.Val.(valueNode); ok {
+				if parent == nil {
+					return true, nil
+				}
+				parent.(*fullNode).Children[left[pos-1]] = nil
+				return false, nil
+			}
+			return false, unset(rn, rn.Val, left[pos:], len(rn.Key), false)
+		}
+		if shortForkLeft != 0 {
+			if _, ok := rn.Val.(valueNode); ok {
+				if parent == nil {
+					return true, nil
+				}
+				parent.(*fullNode).Children[right[pos-1]] = nil
+				return false, nil
+			}
+			return false, unset(rn, rn.Val, right[pos:], len(rn.Key), true)
+		}
+		return false, nil
+	case *fullNode:
+		// Drop the children strictly between the paths, then trim both edges
+		for i := left[pos] + 1; i < right[pos]; i++ {
+			rn.Children[i] = nil
+		}
+		if err := unset(rn, rn.Children[left[pos]], left[pos:], 1, false); err != nil {
+			return false, err
+		}
+		if err := unset(rn, rn.Children[right[pos]], right[pos:], 1, true); err != nil {
+			return false, err
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("%T: unexpected node in range proof", n)
+	}
+}
+
+// unset removes all the nodes on one side of the path key below child, the
+// right side for the left edge and the left side for the right edge, cutting
+// the path itself where it leaves the trie inside the range.
+func unset(parent node, child node, key []byte, pos int, removeLeft bool) error {
+	switch cld := child.(type) {
+	case *fullNode:
+		if removeLeft {
+			for i := 0; i < int(key[pos]); i++ {
+				cld.Children[i] = nil
+			}
+		} else {
+			for i := key[pos] + 1; i < 16; i++ {
+				cld.Children[i] = nil
+			}
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Children[key[pos]], key, pos+1, removeLeft)
+	case *shortNode:
+		if len(key[pos:]) < len(cld.Key) || !bytes.Equal(cld.Key, key[pos:pos+len(cld.Key)]) {
+			// The path ends at this short node, drop it if it lies in the range
+			if removeLeft {
+				if bytes.Compare(cld.Key, key[pos:]) < 0 {
+					parent.(*fullNode).Children[key[pos-1]] = nil
+				}
+			} else {
+				if bytes.Compare(cld.Key, key[pos:]) > 0 {
+					parent.(*fullNode).Children[key[pos-1]] = nil
+				}
+			}
+			return nil
+		}
+		if _, ok := cld.Val.(valueNode); ok {
+			parent.(*fullNode).Children[key[pos-1]] = nil
+			return nil
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Val, key, pos+len(cld.Key), removeLeft)
+	case nil:
+		// The path ends in an empty slot of the fork point
+		return nil
+	default:
+		return fmt.Errorf("%T: unexpected node in range proof", child)
+	}
+}
+
+// hasRightElement reports whether the resolved partial trie holds any leaves
+// after key, which may or may not be present itself.
+func hasRightElement(n node, key []byte) bool {
+	pos, key := 0, keybytesToHex(key)
+	for n != nil {
+		switch rn := n.(type) {
+		case *fullNode:
+			for i := key[pos] + 1; i < 16; i++ {
+				if rn.Children[i] != nil {
+					return true
+				}
+			}
+			n, pos = rn.Children[key[pos]], pos+1
+		case *shortNode:
+			if len(key)-pos < len(rn.Key) || !bytes.Equal(rn.Key, key[pos:pos+len(rn.Key)]) {
+				return bytes.Compare(rn.Key, key[pos:]) > 0
+			}
+			n, pos = rn.Val, pos+len(rn.Key)
+		case valueNode:
+			return false // The whole path is resolved
+		default:
+			return true // Unresolved part of the trie, assume it's not empty
+		}
+	}
+	return false
+}
+
+// emptyDatabase is a trie database without any content, used for tries that
+// must be fully held in memory.
+type emptyDatabase struct{}
+
+func (emptyDatabase) Get(key []byte) ([]byte, error) { return nil, errors.New("not found") }
+func (emptyDatabase) Has(key []byte) (bool, error)   { return false, nil }
+func (emptyDatabase) Put(key, value []byte) error    { return errors.New("read only") }
+
 func get(tn node, key []byte) ([]byte, node) {
 	for {
 		switch n := tn.(type) {
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -27,8 +28,12 @@ import (
 	"github.com/hpb-project/go-hpb/common/rlp"
 )
 
+// randSeed seeds the math/rand source of the tests, the range proof tests log
+// it along with their failures.
+var randSeed = time.Now().UnixNano()
+
 func init() {
-	mrand.Seed(time.Now().Unix())
+	mrand.Seed(randSeed)
 }
 
 func TestProof(t *testing.T) {
@@ -83,6 +88,140 @@ func TestVerifyBadProof(t *testing.T) {
 	}
 }
 
+// Tests that ranges of leaves with edge proofs, on existing as well as missing
+// edge keys, are accepted.
+func TestRangeProof(t *testing.T) {
+	t.Logf("random seed %d", randSeed)
+
+	trie, entries := sortedRandomTrie(2048)
+	root := trie.Hash()
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + 1 + mrand.Intn(len(entries)-start)
+
+		first, last := entries[start].k, entries[end-1].k
+		if mrand.Intn(2) == 0 {
+			// The all-zero key of randomTrie has no predecessor, decreasing it
+			// wraps around to the largest key
+			prev := decreaseKey(first)
+			if bytes.Compare(prev, first) < 0 && (start == 0 || bytes.Compare(prev, entries[start-1].k) > 0) {
+				first = prev
+			}
+		}
+		keys, vals := splitEntries(entries[start:end])
+		more, err := VerifyRangeProof(root, first, last, keys, vals, trie.ProveRange(first, last))
+		if err != nil {
+			t.Fatalf("range %d-%d: failed to verify: %v", start, end, err)
+		}
+		if more != (end < len(entries)) {
+			t.Fatalf("range %d-%d: more flag mismatch: have %v", start, end, more)
+		}
+	}
+}
+
+// Tests that ranges with missing, altered or extra leaves are rejected.
+func TestBadRangeProof(t *testing.T) {
+	t.Logf("random seed %d", randSeed)
+
+	trie, entries := sortedRandomTrie(2048)
+	root := trie.Hash()
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries) - 3)
+		end := start + 3 + mrand.Intn(len(entries)-start-2)
+
+		keys, vals := splitEntries(entries[start:end])
+		first, last := keys[0], keys[len(keys)-1]
+		switch mrand.Intn(3) {
+		case 0:
+			index := 1 + mrand.Intn(len(keys)-2)
+			keys = append(keys[:index:index], keys[index+1:]...)
+			vals = append(vals[:index:index], vals[index+1:]...)
+		case 1:
+			index := mrand.Intn(len(vals))
+			vals[index] = randBytes(20)
+		case 2:
+			index := mrand.Intn(len(keys))
+			keys[index] = common.CopyBytes(keys[index])
+			keys[index][31]++
+		}
+		if _, err := VerifyRangeProof(root, first, last, keys, vals, trie.ProveRange(first, last)); err == nil {
+			t.Fatalf("range %d-%d: expected verification failure", start, end)
+		}
+	}
+}
+
+// Tests the special ranges: the entire trie without a proof, a single leaf and
+// the empty range past the last leaf.
+func TestSpecialRangeProof(t *testing.T) {
+	trie, entries := sortedRandomTrie(512)
+	root := trie.Hash()
+
+	keys, vals := splitEntries(entries)
+	if more, err := VerifyRangeProof(root, keys[0], keys[len(keys)-1], keys, vals, nil); err != nil || more {
+		t.Fatalf("entire trie: more %v, err %v", more, err)
+	}
+	if _, err := VerifyRangeProof(root, keys[0], keys[len(keys)-1], keys[1:], vals[1:], nil); err == nil {
+		t.Fatalf("partial trie without proof accepted")
+	}
+	for i, key := range keys {
+		more, err := VerifyRangeProof(root, key, key, keys[i:i+1], vals[i:i+1], trie.Prove(key))
+		if err != nil {
+			t.Fatalf("leaf %d: failed to verify: %v", i, err)
+		}
+		if more != (i < len(keys)-1) {
+			t.Fatalf("leaf %d: more flag mismatch: have %v", i, more)
+		}
+	}
+	past := increaseKey(keys[len(keys)-1])
+	if more, err := VerifyRangeProof(root, past, past, nil, nil, trie.Prove(past)); err != nil || more {
+		t.Fatalf("empty range: more %v, err %v", more, err)
+	}
+	before := decreaseKey(keys[len(keys)-1])
+	if _, err := VerifyRangeProof(root, before, before, nil, nil, trie.Prove(before)); err == nil {
+		t.Fatalf("empty range hiding leaves accepted")
+	}
+}
+
+func sortedRandomTrie(n int) (*Trie, []*kv) {
+	trie, vals := randomTrie(n)
+	entries := make([]*kv, 0, len(vals))
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].k, entries[j].k) < 0 })
+	return trie, entries
+}
+
+func splitEntries(entries []*kv) ([][]byte, [][]byte) {
+	keys, vals := make([][]byte, len(entries)), make([][]byte, len(entries))
+	for i, kv := range entries {
+		keys[i], vals[i] = kv.k, kv.v
+	}
+	return keys, vals
+}
+
+func increaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x00 {
+			break
+		}
+	}
+	return key
+}
+
+func decreaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]--
+		if key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
 // mutateByte changes one byte in b.
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {
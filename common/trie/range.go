@@ -0,0 +1,253 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/hpb-project/go-hpb/common"
+)
+
+var (
+	errRangeOrder    = errors.New("range keys not ascending")
+	errRangeBounds   = errors.New("range key out of bounds")
+	errRangeFinished = errors.New("range already finished")
+)
+
+// RangeTrie incrementally builds the part of a trie holding the leaves of a
+// contiguous key range, as retrieved during a range based state sync. Leaves
+// must be added in ascending order. Every subtree that can no longer change,
+// because all keys it may contain were already delivered, is written out to
+// the database and collapsed into its hash, so only the path to the last leaf
+// is held in memory.
+//
+// The nodes on the edges of the range depend on the leaves of the neighbouring
+// ranges, so they are not written. Once all ranges covering the key space are
+// finished, StitchRanges assembles their edges into the complete trie.
+type RangeTrie struct {
+	trie   *Trie
+	origin []byte // First key of the range, hex encoded without terminator
+	limit  []byte // Last key of the range, hex encoded without terminator
+	last   []byte // Last key added, hex encoded without terminator
+	done   bool   // Whether all the leaves of the range were added
+}
+
+// NewRangeTrie creates an empty builder for the leaves between origin and
+// limit, both inclusive. All keys must be of the same length as the bounds.
+func NewRangeTrie(origin, limit []byte) *RangeTrie {
+	return &RangeTrie{
+		trie:   &Trie{db: emptyDatabase{}},
+		origin: hexKey(origin),
+		limit:  hexKey(limit),
+	}
+}
+
+// hexKey converts a key to its nibbles without the terminator flag.
+func hexKey(key []byte) []byte {
+	hex := keybytesToHex(key)
+	return hex[:len(hex)-1]
+}
+
+// Update adds the next leaf of the range.
+func (r *RangeTrie) Update(key, value []byte) error {
+	if r.done {
+		return errRangeFinished
+	}
+	hex := hexKey(key)
+	if len(hex) != len(r.origin) || bytes.Compare(hex, r.origin) < 0 || bytes.Compare(hex, r.limit) > 0 {
+		return errRangeBounds
+	}
+	if r.last != nil && bytes.Compare(hex, r.last) <= 0 {
+		return errRangeOrder
+	}
+	if len(value) == 0 {
+		return fmt.Errorf("empty value for key %x", key)
+	}
+	if err := r.trie.TryUpdate(key, value); err != nil {
+		return err
+	}
+	r.last = hex
+	return nil
+}
+
+// Commit writes all the subtrees completed by the leaves added so far to db.
+func (r *RangeTrie) Commit(db DatabaseWriter) error {
+	if r.last == nil || r.done {
+		return nil
+	}
+	return r.seal(db, r.last)
+}
+
+// Finish marks the range complete, no more leaves exist up to its limit, and
+// writes all the subtrees lying entirely within the range to db.
+func (r *RangeTrie) Finish(db DatabaseWriter) error {
+	if r.done {
+		return nil
+	}
+	if err := r.seal(db, r.limit); err != nil {
+		return err
+	}
+	r.done = true
+	return nil
+}
+
+// seal collapses all the subtrees whose key space lies between the origin of
+// the range and bound.
+func (r *RangeTrie) seal(db DatabaseWriter, bound []byte) error {
+	if r.trie.root == nil {
+		return nil
+	}
+	h := newHasher(0, 0)
+	defer returnHasherToPool(h)
+
+	var walk func(n node, prefix []byte) (node, error)
+	walk = func(n node, prefix []byte) (node, error) {
+		switch n := n.(type) {
+		case *shortNode:
+			if _, ok := n.Val.(valueNode); ok {
+				return n, nil
+			}
+			val, err := walk(n.Val, concat(prefix, n.Key...))
+			if err != nil {
+				return n, err
+			}
+			n.Val = val
+			return n, nil
+
+		case *fullNode:
+			for i := 0; i < 16; i++ {
+				child := n.Children[i]
+				if child == nil {
+					continue
+				}
+				if _, ok := child.(hashNode); ok {
+					continue
+				}
+				path := concat(prefix, byte(i))
+				if !r.covers(path, bound) {
+					sealed, err := walk(child, path)
+					if err != nil {
+						return n, err
+					}
+					n.Children[i] = sealed
+					continue
+				}
+				hashed, cached, err := h.hash(child, db, false)
+				if err != nil {
+					return n, err
+				}
+				if _, ok := hashed.(hashNode); ok {
+					n.Children[i] = hashed
+				} else {
+					n.Children[i] = cached // Small subtree embedded in its parent
+				}
+			}
+			return n, nil
+
+		default:
+			return n, nil
+		}
+	}
+	root, err := walk(r.trie.root, nil)
+	r.trie.root = root
+	return err
+}
+
+// covers reports whether every key starting with prefix lies between the
+// origin of the range and bound.
+func (r *RangeTrie) covers(prefix []byte, bound []byte) bool {
+	n := len(prefix)
+	if c := bytes.Compare(prefix, r.origin[:n]); c < 0 || (c == 0 && !allNibbles(r.origin[n:], 0)) {
+		return false
+	}
+	if c := bytes.Compare(prefix, bound[:n]); c > 0 || (c == 0 && !allNibbles(bound[n:], 15)) {
+		return false
+	}
+	return true
+}
+
+func allNibbles(hex []byte, nibble byte) bool {
+	for _, b := range hex {
+		if b != nibble {
+			return false
+		}
+	}
+	return true
+}
+
+// fragment is a subtree of a finished range trie at the given path, that is
+// identical in the complete trie.
+type fragment struct {
+	path []byte
+	node node
+}
+
+// fragments collects the largest subtrees of a finished range trie that lie
+// entirely within its range.
+func (r *RangeTrie) fragments() []fragment {
+	var (
+		frags []fragment
+		walk  func(n node, prefix []byte)
+	)
+	walk = func(n node, prefix []byte) {
+		if r.covers(prefix, r.limit) {
+			frags = append(frags, fragment{common.CopyBytes(prefix), n})
+			return
+		}
+		switch n := n.(type) {
+		case *shortNode:
+			if _, ok := n.Val.(valueNode); ok {
+				frags = append(frags, fragment{concat(prefix, n.Key...), n.Val})
+				return
+			}
+			walk(n.Val, concat(prefix, n.Key...))
+		case *fullNode:
+			for i := 0; i < 16; i++ {
+				if n.Children[i] != nil {
+					walk(n.Children[i], concat(prefix, byte(i)))
+				}
+			}
+		}
+	}
+	if r.trie.root != nil {
+		walk(r.trie.root, nil)
+	}
+	return frags
+}
+
+// StitchRanges assembles the edges of finished range tries into the complete
+// trie, writes the nodes not yet stored to db and returns the root hash. The
+// ranges must cover the entire key space without overlapping, which the
+// caller verifies by comparing the returned hash with the expected root.
+func StitchRanges(db DatabaseWriter, ranges []*RangeTrie) (common.Hash, error) {
+	t := &Trie{db: emptyDatabase{}}
+	for _, r := range ranges {
+		if !r.done {
+			return common.Hash{}, errors.New("range not finished")
+		}
+		for _, frag := range r.fragments() {
+			_, root, err := t.insert(t.root, nil, frag.path, frag.node)
+			if err != nil {
+				return common.Hash{}, err
+			}
+			t.root = root
+		}
+	}
+	return t.CommitTo(db)
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+)
+
+// Tests that a trie split into ranges, filled leaf by leaf with interleaved
+// commits, is stitched back into the original trie.
+func TestRangeTrieStitch(t *testing.T) {
+	for _, chunks := range []int{1, 2, 3, 16, 100} {
+		trie, entries := sortedRandomTrie(2048)
+		diskdb, _ := hpbdb.NewMemDatabase()
+
+		var (
+			ranges []*RangeTrie
+			next   = 0
+		)
+		for i := 0; i < chunks; i++ {
+			origin := make([]byte, 32)
+			origin[0] = byte(i * 256 / chunks)
+			limit := bytes.Repeat([]byte{0xff}, 32)
+			if i < chunks-1 {
+				limit = make([]byte, 32)
+				limit[0] = byte((i + 1) * 256 / chunks)
+				limit = decreaseKey(limit)
+			}
+			r := NewRangeTrie(origin, limit)
+			for ; next < len(entries) && bytes.Compare(entries[next].k, limit) <= 0; next++ {
+				if err := r.Update(entries[next].k, entries[next].v); err != nil {
+					t.Fatalf("chunks %d: failed to add leaf %d: %v", chunks, next, err)
+				}
+				if next%97 == 0 {
+					if err := r.Commit(diskdb); err != nil {
+						t.Fatalf("chunks %d: failed to commit: %v", chunks, err)
+					}
+				}
+			}
+			if err := r.Finish(diskdb); err != nil {
+				t.Fatalf("chunks %d: failed to finish range: %v", chunks, err)
+			}
+			ranges = append(ranges, r)
+		}
+		root, err := StitchRanges(diskdb, ranges)
+		if err != nil {
+			t.Fatalf("chunks %d: failed to stitch: %v", chunks, err)
+		}
+		if want := trie.Hash(); root != want {
+			t.Fatalf("chunks %d: root mismatch: have %x, want %x", chunks, root, want)
+		}
+		// Every leaf must be reachable from the written nodes
+		stitched, err := New(root, diskdb)
+		if err != nil {
+			t.Fatalf("chunks %d: failed to open stitched trie: %v", chunks, err)
+		}
+		for _, kv := range entries {
+			if val, err := stitched.TryGet(kv.k); err != nil || !bytes.Equal(val, kv.v) {
+				t.Fatalf("chunks %d: leaf %x mismatch: have %x, want %x, err %v", chunks, kv.k, val, kv.v, err)
+			}
+		}
+	}
+}
+
+// Tests that leaves out of order or outside of the range are rejected.
+func TestRangeTrieOrder(t *testing.T) {
+	origin, limit := make([]byte, 32), bytes.Repeat([]byte{0x7f}, 32)
+	r := NewRangeTrie(origin, limit)
+	if err := r.Update(bytes.Repeat([]byte{0x10}, 32), []byte{1}); err != nil {
+		t.Fatalf("failed to add leaf: %v", err)
+	}
+	if err := r.Update(bytes.Repeat([]byte{0x01}, 32), []byte{1}); err != errRangeOrder {
+		t.Fatalf("descending leaf error mismatch: have %v, want %v", err, errRangeOrder)
+	}
+	if err := r.Update(bytes.Repeat([]byte{0x80}, 32), []byte{1}); err != errRangeBounds {
+		t.Fatalf("out of range leaf error mismatch: have %v, want %v", err, errRangeBounds)
+	}
+}
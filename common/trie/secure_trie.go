@@ -21,6 +21,7 @@ import (
 
 	"github.com/hpb-project/go-hpb/common"
 	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/common/rlp"
 )
 
 var secureKeyPrefix = []byte("secure-key-")
@@ -67,6 +68,12 @@ func NewSecure(root common.Hash, db Database, cachelimit uint16) (*SecureTrie, e
 	return &SecureTrie{trie: *trie}, nil
 }
 
+// Prove constructs a merkle proof for key, hashing it first the same way as
+// all other operations on the secure trie do.
+func (t *SecureTrie) Prove(key []byte) []rlp.RawValue {
+	return t.trie.Prove(t.hashKey(key))
+}
+
 // Get returns the value for key stored in the trie.
 // The value bytes must not be modified by the caller.
 func (t *SecureTrie) Get(key []byte) []byte {
@@ -17,6 +17,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
@@ -24,6 +25,10 @@ import (
 	"github.com/hpb-project/go-hpb/common"
 )
 
+// ErrSoftRandomNotDev is returned for chain configs selecting the software
+// random source outside of a developer chain.
+var ErrSoftRandomNotDev = errors.New("software random source is only allowed on developer chains")
+
 var (
 	MainnetGenesisHash = common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3") // Mainnet genesis hash to enforce below configs on
 	TestnetGenesisHash = common.HexToHash("0x41941023680923e0fe4d74a34bdac8141f2540e3ae90623718e47d66d1ca4a2d") // Testnet genesis hash to enforce below configs on
@@ -32,6 +37,17 @@ var (
 // MainnetChainConfig is the chain parameters to run a node on the main network.
 var MainnetChainConfig = &ChainConfig{
 	ChainId: big.NewInt(269),
+
+	StageIIBlock:     big.NewInt(260000),
+	StageIIIBlock:    big.NewInt(1200000),
+	StageIVBlock:     big.NewInt(2560000),
+	StageVBlock:      big.NewInt(999999000000), // unused forever
+	StageVIBlock:     big.NewInt(2561790),
+	StageVIIBlock:    big.NewInt(2896000),
+	NewContractBlock: big.NewInt(3788000),
+	RealRandomBlock:  big.NewInt(5159000),
+	UpgradedEVMBlock: big.NewInt(8685000),
+
 	Prometheus: &PrometheusConfig{
 		Period: 3,
 		Epoch:  30000,
@@ -89,9 +105,54 @@ var (
 	CompatibleChainId = big.NewInt(1)
 )
 
+// ChainConfig is the core config which determines the blockchain settings.
+//
+// The fork heights are optional, a nil value falls back to the mainnet height
+// so that chain configs stored before the heights were configurable keep the
+// same consensus rules.
 type ChainConfig struct {
-	ChainId    *big.Int          `json:"chainId"` // Chain id identifies the current chain and is used for replay protection
-	Prometheus *PrometheusConfig `json:"prometheus"`
+	ChainId *big.Int `json:"chainId"` // Chain id identifies the current chain and is used for replay protection
+
+	StageIIBlock     *big.Int `json:"stageIIBlock,omitempty"`     // Prometheus stage II switch block (hpb node election by checkpoint)
+	StageIIIBlock    *big.Int `json:"stageIIIBlock,omitempty"`    // Prometheus stage III switch block (vote based candidate ranking)
+	StageIVBlock     *big.Int `json:"stageIVBlock,omitempty"`     // Prometheus stage IV switch block (reward rules)
+	StageVBlock      *big.Int `json:"stageVBlock,omitempty"`      // Prometheus stage V switch block (reserved)
+	StageVIBlock     *big.Int `json:"stageVIBlock,omitempty"`     // Prometheus stage VI switch block (vote reward)
+	StageVIIBlock    *big.Int `json:"stageVIIBlock,omitempty"`    // Prometheus stage VII switch block (end of vote reward fix)
+	NewContractBlock *big.Int `json:"newContractBlock,omitempty"` // Switch block to the new node/vote contracts
+	RealRandomBlock  *big.Int `json:"realRandomBlock,omitempty"`  // Switch block to real random and boe hash v2
+	UpgradedEVMBlock *big.Int `json:"upgradedEVMBlock,omitempty"` // Switch block to the upgraded EVM instruction set
+
+	Prometheus   *PrometheusConfig   `json:"prometheus"`
+	RandomSource *RandomSourceConfig `json:"randomSource,omitempty"` // Source of the header random chain, nil means the BOE hardware
+	Dev          bool                `json:"dev,omitempty"`          // Single node developer chain, the genesis hpb nodes are never re-elected
+}
+
+// DeveloperChainConfig returns the chain parameters of a single node developer
+// chain sealing a block every period seconds, or on every transaction if the
+// period is zero. All Prometheus stages are active from the genesis block and
+// the header random chain is generated in software.
+func DeveloperChainConfig(period uint64) *ChainConfig {
+	return &ChainConfig{
+		ChainId: big.NewInt(1337),
+
+		StageIIBlock:     big.NewInt(0),
+		StageIIIBlock:    big.NewInt(0),
+		StageIVBlock:     big.NewInt(0),
+		StageVBlock:      new(big.Int).Set(MainnetChainConfig.StageVBlock),
+		StageVIBlock:     big.NewInt(0),
+		StageVIIBlock:    big.NewInt(0),
+		NewContractBlock: big.NewInt(0),
+		RealRandomBlock:  big.NewInt(0),
+		UpgradedEVMBlock: big.NewInt(0),
+
+		Prometheus: &PrometheusConfig{
+			Period: period,
+			Epoch:  DefaultPrometheusConfig.Epoch,
+		},
+		RandomSource: &RandomSourceConfig{Type: RandomSourceSoft},
+		Dev:          true,
+	}
 }
 
 var DefaultBlockChainConfig = ChainConfig{
@@ -130,9 +191,172 @@ func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *Confi
 }
 
 func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+	stored, next := c.forks(), newcfg.forks()
+	for i := range stored {
+		if isForkIncompatible(stored[i].block, next[i].block, head) {
+			return newCompatError(stored[i].name, stored[i].block, next[i].block)
+		}
+	}
 	return nil
 }
 
+type fork struct {
+	name  string
+	block *big.Int
+}
+
+// forks returns the effective fork heights of the config in activation order.
+func (c *ChainConfig) forks() []fork {
+	return []fork{
+		{"StageII fork block", forkBlock(c.StageIIBlock, MainnetChainConfig.StageIIBlock)},
+		{"StageIII fork block", forkBlock(c.StageIIIBlock, MainnetChainConfig.StageIIIBlock)},
+		{"StageIV fork block", forkBlock(c.StageIVBlock, MainnetChainConfig.StageIVBlock)},
+		{"StageVI fork block", forkBlock(c.StageVIBlock, MainnetChainConfig.StageVIBlock)},
+		{"StageVII fork block", forkBlock(c.StageVIIBlock, MainnetChainConfig.StageVIIBlock)},
+		{"NewContract fork block", forkBlock(c.NewContractBlock, MainnetChainConfig.NewContractBlock)},
+		{"RealRandom fork block", forkBlock(c.RealRandomBlock, MainnetChainConfig.RealRandomBlock)},
+		{"UpgradedEVM fork block", forkBlock(c.UpgradedEVMBlock, MainnetChainConfig.UpgradedEVMBlock)},
+		{"StageV fork block", forkBlock(c.StageVBlock, MainnetChainConfig.StageVBlock)},
+	}
+}
+
+func forkBlock(configured, mainnet *big.Int) *big.Int {
+	if configured != nil {
+		return configured
+	}
+	return mainnet
+}
+
+// StageNumberII returns the block number at which Prometheus stage II starts.
+func (c *ChainConfig) StageNumberII() uint64 {
+	return forkBlock(c.StageIIBlock, MainnetChainConfig.StageIIBlock).Uint64()
+}
+
+// StageNumberIII returns the block number at which Prometheus stage III starts.
+func (c *ChainConfig) StageNumberIII() uint64 {
+	return forkBlock(c.StageIIIBlock, MainnetChainConfig.StageIIIBlock).Uint64()
+}
+
+// StageNumberIV returns the block number at which Prometheus stage IV starts.
+func (c *ChainConfig) StageNumberIV() uint64 {
+	return forkBlock(c.StageIVBlock, MainnetChainConfig.StageIVBlock).Uint64()
+}
+
+// StageNumberV returns the block number at which Prometheus stage V starts.
+func (c *ChainConfig) StageNumberV() uint64 {
+	return forkBlock(c.StageVBlock, MainnetChainConfig.StageVBlock).Uint64()
+}
+
+// StageNumberVI returns the block number at which Prometheus stage VI starts.
+func (c *ChainConfig) StageNumberVI() uint64 {
+	return forkBlock(c.StageVIBlock, MainnetChainConfig.StageVIBlock).Uint64()
+}
+
+// StageNumberVII returns the block number at which Prometheus stage VII starts.
+func (c *ChainConfig) StageNumberVII() uint64 {
+	return forkBlock(c.StageVIIBlock, MainnetChainConfig.StageVIIBlock).Uint64()
+}
+
+// NewContractVersion returns the block number after which the new node and
+// vote contracts are used.
+func (c *ChainConfig) NewContractVersion() uint64 {
+	return forkBlock(c.NewContractBlock, MainnetChainConfig.NewContractBlock).Uint64()
+}
+
+// StageNumberRealRandom returns the block number at which real random is enabled.
+func (c *ChainConfig) StageNumberRealRandom() uint64 {
+	return forkBlock(c.RealRandomBlock, MainnetChainConfig.RealRandomBlock).Uint64()
+}
+
+// StateNumberNewHash returns the block number at which boe hash v2 is used and
+// continuous block generation is limited. It is the same as the real random block.
+func (c *ChainConfig) StateNumberNewHash() uint64 {
+	return c.StageNumberRealRandom()
+}
+
+// StageNumberUpgradedEVM returns the block number after which the upgraded EVM
+// instruction set is used.
+func (c *ChainConfig) StageNumberUpgradedEVM() uint64 {
+	return forkBlock(c.UpgradedEVMBlock, MainnetChainConfig.UpgradedEVMBlock).Uint64()
+}
+
+// MergeForks fills the fork heights and the random source which are not set in
+// c with the ones of other, typically the config stored along with the genesis
+// block. A developer chain stays one whatever the local flags.
+func (c *ChainConfig) MergeForks(other *ChainConfig) {
+	if other == nil {
+		return
+	}
+	merge := func(dst **big.Int, src *big.Int) {
+		if *dst == nil && src != nil {
+			*dst = new(big.Int).Set(src)
+		}
+	}
+	merge(&c.StageIIBlock, other.StageIIBlock)
+	merge(&c.StageIIIBlock, other.StageIIIBlock)
+	merge(&c.StageIVBlock, other.StageIVBlock)
+	merge(&c.StageVBlock, other.StageVBlock)
+	merge(&c.StageVIBlock, other.StageVIBlock)
+	merge(&c.StageVIIBlock, other.StageVIIBlock)
+	merge(&c.NewContractBlock, other.NewContractBlock)
+	merge(&c.RealRandomBlock, other.RealRandomBlock)
+	merge(&c.UpgradedEVMBlock, other.UpgradedEVMBlock)
+
+	if c.RandomSource == nil && other.RandomSource != nil {
+		source := *other.RandomSource
+		c.RandomSource = &source
+	}
+	c.Dev = c.Dev || other.Dev
+}
+
+// SoftRandom reports whether the header random chain is generated in software
+// instead of by the BOE hardware. The software chain is predictable by anyone
+// knowing the genesis config, so it is only ever used by developer chains.
+func (c *ChainConfig) SoftRandom() bool {
+	return c.Dev && c.RandomSource != nil && c.RandomSource.Type == RandomSourceSoft
+}
+
+// CheckRandomSource rejects the software random source on chains that are not
+// developer chains.
+func (c *ChainConfig) CheckRandomSource() error {
+	if !c.Dev && c.RandomSource != nil && c.RandomSource.Type == RandomSourceSoft {
+		return ErrSoftRandomNotDev
+	}
+	return nil
+}
+
+// SetTestParam moves the early Prometheus stages to the first blocks, used by
+// test chains running with the test code param.
+func (c *ChainConfig) SetTestParam() {
+	c.StageIIBlock = big.NewInt(1)
+	c.StageIIIBlock = big.NewInt(0)
+	c.StageIVBlock = big.NewInt(1)
+}
+
+// isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
+// block s2 because head is already past the fork.
+func isForkIncompatible(s1, s2, head *big.Int) bool {
+	return (isForked(s1, head) || isForked(s2, head)) && !configNumEqual(s1, s2)
+}
+
+// isForked returns whether a fork scheduled at block s is active at the given head block.
+func isForked(s, head *big.Int) bool {
+	if s == nil || head == nil {
+		return false
+	}
+	return s.Cmp(head) <= 0
+}
+
+func configNumEqual(x, y *big.Int) bool {
+	if x == nil {
+		return y == nil
+	}
+	if y == nil {
+		return x == nil
+	}
+	return x.Cmp(y) == 0
+}
+
 // ConfigCompatError is raised if the locally-stored blockchain is initialised with a
 // ChainConfig that would alter the past.
 type ConfigCompatError struct {
@@ -158,6 +382,23 @@ func (c *ChainConfig) String() string {
 	)
 }
 
+func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatError {
+	var rew *big.Int
+	switch {
+	case storedblock == nil:
+		rew = newblock
+	case newblock == nil || storedblock.Cmp(newblock) < 0:
+		rew = storedblock
+	default:
+		rew = newblock
+	}
+	err := &ConfigCompatError{what, storedblock, newblock, 0}
+	if rew != nil && rew.Sign() > 0 {
+		err.RewindTo = rew.Uint64() - 1
+	}
+	return err
+}
+
 func (err *ConfigCompatError) Error() string {
 	return fmt.Sprintf("mismatching %s in database (have %d, want %d, rewindto %d)", err.What, err.StoredConfig, err.NewConfig, err.RewindTo)
 }
@@ -0,0 +1,109 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestForkDefaults(t *testing.T) {
+	empty := &ChainConfig{}
+	if empty.StageNumberII() != 260000 || empty.NewContractVersion() != 3788000 || empty.StageNumberUpgradedEVM() != 8685000 {
+		t.Fatalf("unset fork heights do not fall back to mainnet")
+	}
+	if empty.StateNumberNewHash() != empty.StageNumberRealRandom() {
+		t.Fatalf("new hash height differs from real random height")
+	}
+	custom := &ChainConfig{StageIIBlock: big.NewInt(10)}
+	if custom.StageNumberII() != 10 {
+		t.Fatalf("stage II height mismatch: have %d, want 10", custom.StageNumberII())
+	}
+}
+
+func TestCheckCompatible(t *testing.T) {
+	type test struct {
+		stored, new *ChainConfig
+		head        uint64
+		wantErr     *ConfigCompatError
+	}
+	tests := []test{
+		{stored: MainnetChainConfig, new: MainnetChainConfig, head: 0, wantErr: nil},
+		{stored: MainnetChainConfig, new: MainnetChainConfig, head: 100000000, wantErr: nil},
+		{stored: &ChainConfig{}, new: MainnetChainConfig, head: 100000000, wantErr: nil},
+		{
+			stored:  &ChainConfig{StageIIBlock: big.NewInt(10)},
+			new:     &ChainConfig{StageIIBlock: big.NewInt(20)},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{StageIIBlock: big.NewInt(10)},
+			new:    &ChainConfig{StageIIBlock: big.NewInt(20)},
+			head:   25,
+			wantErr: &ConfigCompatError{
+				What:         "StageII fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(20),
+				RewindTo:     9,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		err := test.stored.CheckCompatible(test.new, test.head)
+		if !reflect.DeepEqual(err, test.wantErr) {
+			t.Errorf("error mismatch:\nstored: %v\nnew: %v\nhead: %v\nerr: %v\nwant: %v", test.stored, test.new, test.head, err, test.wantErr)
+		}
+	}
+}
+
+func TestMergeForks(t *testing.T) {
+	local := &ChainConfig{StageIIBlock: big.NewInt(1)}
+	local.MergeForks(&ChainConfig{StageIIBlock: big.NewInt(5), StageIIIBlock: big.NewInt(6)})
+
+	if local.StageNumberII() != 1 {
+		t.Errorf("merge overrode a local fork height: have %d, want 1", local.StageNumberII())
+	}
+	if local.StageNumberIII() != 6 {
+		t.Errorf("merge skipped a stored fork height: have %d, want 6", local.StageNumberIII())
+	}
+	if local.SoftRandom() {
+		t.Errorf("software random source enabled without config")
+	}
+	local.MergeForks(&ChainConfig{RandomSource: &RandomSourceConfig{Type: RandomSourceSoft}})
+	if local.RandomSource == nil || local.RandomSource.Type != RandomSourceSoft {
+		t.Errorf("merge skipped the stored random source")
+	}
+	if local.SoftRandom() {
+		t.Errorf("software random source enabled outside of a developer chain")
+	}
+	if err := local.CheckRandomSource(); err != ErrSoftRandomNotDev {
+		t.Errorf("random source check mismatch: have %v, want %v", err, ErrSoftRandomNotDev)
+	}
+	local.MergeForks(DeveloperChainConfig(0))
+	if !local.Dev {
+		t.Errorf("merge dropped the developer chain flag")
+	}
+	if !local.SoftRandom() {
+		t.Errorf("software random source disabled on a developer chain")
+	}
+	if err := local.CheckRandomSource(); err != nil {
+		t.Errorf("random source rejected on a developer chain: %v", err)
+	}
+}
@@ -32,11 +32,12 @@ import (
 var HpbConfigIns *HpbConfig
 
 const (
-	DatadirPrivateKey      = "nodekey"            // Path within the datadir to the node's private key
-	DatadirDefaultKeyStore = "keystore"           // Path within the datadir to the keystore
-	DatadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
-	DatadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
-	DatadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	DatadirPrivateKey        = "nodekey"            // Path within the datadir to the node's private key
+	DatadirDefaultKeyStore   = "keystore"           // Path within the datadir to the keystore
+	DatadirDefaultHDKeyStore = "hd"                 // Path within the keystore to the HD wallet seeds
+	DatadirStaticNodes       = "static-nodes.json"  // Path within the datadir to the static node list
+	DatadirTrustedNodes      = "trusted-nodes.json" // Path within the datadir to the trusted node list
+	DatadirNodeDatabase      = "nodes"              // Path within the datadir to store the node infos
 )
 
 const (
@@ -135,6 +136,12 @@ type HpbConfig struct {
 	Gas GasConfig
 
 	HpbStats hpbStatsConfig
+
+	//configuration of the metrics exporter
+	Metrics MetricsConfig
+
+	//configuration of block production
+	Miner MinerConfig
 }
 
 // These settings ensure that TOML keys use the same names as Go struct fields.
@@ -188,6 +195,10 @@ func New() *HpbConfig {
 			Prometheus: DefaultPrometheusConfig,
 
 			Gas: DefaultGasConfig,
+
+			Metrics: DefaultMetricsConfig,
+
+			Miner: DefaultMinerConfig,
 		}
 		log.Info("Create New HpbConfig object")
 		INSTANCE.Store(HpbConfigIns)
@@ -217,6 +228,8 @@ func GetHpbConfigInstance() *HpbConfig {
 		Prometheus: DefaultPrometheusConfig,
 
 		Gas: DefaultGasConfig,
+
+		Miner: DefaultMinerConfig,
 	}
 	log.Info("Create New HpbConfig object")
 	INSTANCE.Store(HpbConfigIns)
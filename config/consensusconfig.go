@@ -16,6 +16,14 @@
 
 package config
 
+import "github.com/hpb-project/go-hpb/common"
+
+// Random sources of the header hardware random chain.
+const (
+	RandomSourceBoe  = "boe"  // BOE hardware board, the default
+	RandomSourceSoft = "soft" // Deterministic software hash chain keyed by a network seed, developer chains only
+)
+
 var DefaultPrometheusConfig = PrometheusConfig{
 	//for test,change from 3 to 6 seconds
 	Period: 6,
@@ -27,6 +35,15 @@ type PrometheusConfig struct {
 	Epoch  uint64 `json:"epoch"`  // Epoch length to reset votes and checkpoint
 }
 
+// RandomSourceConfig selects where Prometheus takes the header hardware random
+// chain from. Developer chains, which have no BOE boards, use the software
+// source, all nodes of such a network must share the same seed. The software
+// chain is predictable from the genesis config, other chains reject it.
+type RandomSourceConfig struct {
+	Type string      `json:"type"`           // One of RandomSourceBoe or RandomSourceSoft
+	Seed common.Hash `json:"seed,omitempty"` // Key of the software hash chain
+}
+
 // PrometheusConfig is the consensus engine configs for proof-of-authority based sealing.
 // String implements the stringer interface, returning the consensus engine details.
 func (c *PrometheusConfig) String() string {
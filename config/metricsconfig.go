@@ -0,0 +1,35 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+// MetricsConfig holds the settings of the Prometheus metrics exporter.
+type MetricsConfig struct {
+	// Enabled starts the exporter, the metrics are collected regardless.
+	Enabled bool `toml:",omitempty"`
+
+	// ListenAddr is the TCP address the exporter serves /metrics on.
+	ListenAddr string
+
+	// Namespace prefixes the names of all the exported metrics.
+	Namespace string
+}
+
+// DefaultMetricsConfig contains the default settings of the metrics exporter.
+var DefaultMetricsConfig = MetricsConfig{
+	ListenAddr: "127.0.0.1:8080",
+	Namespace:  "go_hpb",
+}
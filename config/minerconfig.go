@@ -0,0 +1,44 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import "github.com/hpb-project/go-hpb/common"
+
+// Transaction ordering policies of the produced blocks.
+const (
+	TxOrderPrice        = "price"         // Highest gas price first
+	TxOrderArrival      = "fcfs"          // First come first served
+	TxOrderPriceArrival = "price-arrival" // Highest gas price first, earliest arrival among equal prices
+)
+
+// MinerConfig holds the settings of block production.
+type MinerConfig struct {
+	// TxOrder is the ordering policy of the transactions included in blocks.
+	TxOrder string
+
+	// SenderTxCap limits the transactions of a single sender per block, 0 for no limit.
+	SenderTxCap int `toml:",omitempty"`
+
+	// PriorityContracts are system contracts whose transactions are included
+	// ahead of all the others, ordered among themselves by TxOrder.
+	PriorityContracts []common.Address `toml:",omitempty"`
+}
+
+// DefaultMinerConfig contains the default settings of block production.
+var DefaultMinerConfig = MinerConfig{
+	TxOrder: TxOrderPrice,
+}
@@ -38,6 +38,11 @@ const (
 const (
 	clientIdentifier = "ghpb" // Client identifier to advertise over the network
 )
+
+const (
+	AuthModeBoe = "boe" // Handshake nonces are signed by the BOE board
+	AuthModeKey = "key" // Handshake nonces are signed by the coinbase key, for private deployments
+)
 const (
 	// BloomBitsBlocks is the number of blocks a single bloom bit section vector
 	// contains.
@@ -54,6 +59,8 @@ var DefaultNTConfig = NetworkConfig{
 	HTTPTimeouts:     DefaultHTTPTimeouts,
 	ListenAddr:       ":30303",
 	MaxPeers:         50,
+	BWTestRate:       220 * 1024 * 1024,
+	BWTestDuration:   time.Second,
 	NAT:              nat.Any(),
 	IpcEndpoint:      DefaultIPCEndpoint(clientIdentifier),
 	HttpEndpoint:     DefaultHTTPEndpoint(),
@@ -105,6 +112,10 @@ type NetworkConfig struct {
 	// interface.
 	HTTPTimeouts HTTPTimeouts
 
+	// GraphQLEnabled mounts the GraphQL query service on the HTTP RPC server at
+	// the /graphql path. It has no effect if the HTTP endpoint is disabled.
+	GraphQLEnabled bool `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string `toml:",omitempty"`
@@ -135,6 +146,18 @@ type NetworkConfig struct {
 	// connected. It must be greater than zero.
 	MaxPeers int
 
+	// PeerLimit is an opt-in limit on the number of connected peers, enforced
+	// on inbound handshakes and dynamic dials. Static and trusted peers are
+	// admitted beyond it. Zero (the default) keeps admission unlimited.
+	PeerLimit int `toml:",omitempty"`
+
+	// BWTestRate is the rate in bytes per second of the bandwidth test streams
+	// served to the peers, BWTestDuration their length. The consensus clamps
+	// the measurements at 200 MiB per second, the default paces the streams
+	// a little above it and faster streams only waste traffic.
+	BWTestRate     uint64        `toml:",omitempty"`
+	BWTestDuration time.Duration `toml:",omitempty"`
+
 	// MaxPendingPeers is the maximum number of peers that can be pending in the
 	// handshake phase, counted separately for inbound and outbound connections.
 	// Zero defaults to preset values.
@@ -155,6 +178,10 @@ type NetworkConfig struct {
 	// One of hpnode,prenode,access,light.
 	RoleType string
 
+	// AuthMode selects how peers prove their identity in the protocol handshake,
+	// one of AuthModeBoe (default) or AuthModeKey.
+	AuthMode string `toml:",omitempty"`
+
 	// Connectivity can be restricted to certain IP networks.
 	// If this option is set to a non-nil value, only hosts which match one of the
 	// IP networks contained in the list are considered.
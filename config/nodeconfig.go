@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/hpb-project/go-hpb/common"
 	"github.com/hpb-project/go-hpb/common/crypto"
@@ -76,6 +77,9 @@ var DefaultConfig = Nodeconfig{
 	NetworkId:       1,
 	LightPeers:      20,
 	DatabaseCache:   128,
+	TrieCache:       256,
+	TrieTimeout:     5 * time.Minute,
+	SnapshotCache:   256,
 	GasPrice:        big.NewInt(18 * Shannon),
 	IPCPath:         "ghpb.ipc",
 	MaxTrieCacheGen: uint16(120),
@@ -107,6 +111,10 @@ type Nodeconfig struct {
 	// in memory.
 	DataDir string
 
+	// EphemeralDataDir marks a DataDir created for a single run, like the one of
+	// the developer mode, that is removed when the node is stopped.
+	EphemeralDataDir bool `toml:"-"`
+
 	// The genesis block, which is inserted if the database is empty.
 	// If nil, the Hpb main net block is used.
 	//Genesis *bc.Genesis `toml:",omitempty"`
@@ -124,6 +132,17 @@ type Nodeconfig struct {
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
 
+	// Trie pruning options
+	NoPruning   bool          // Whether to disable pruning and flush every state to disk (archive mode)
+	TrieCache   int           // Memory allowance (MB) for in-memory trie nodes before flushing to disk
+	TrieTimeout time.Duration // Cumulative block processing time after which the in-memory trie is flushed
+
+	// Snapshot options
+	SnapshotCache int // Memory allowance (MB) for caching flat state snapshot entries, 0 disables snapshots
+
+	// Block processing options
+	ParallelTxs int `toml:",omitempty"` // Number of goroutines executing block transactions in parallel, 0 executes them sequentially
+
 	// Mining-related options
 	Hpberbase    common.Address `toml:",omitempty"`
 	MinerThreads int            `toml:",omitempty"`
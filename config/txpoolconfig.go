@@ -20,6 +20,10 @@ import "time"
 
 // TxPoolConfiguration txpool config.
 type TxPoolConfiguration struct {
+	NoLocals  bool          // Whether local transaction handling should be disabled
+	Journal   string        // Journal of local transactions to survive node restarts
+	Rejournal time.Duration // Time interval to regenerate the local transaction journal
+
 	PriceLimit   uint64        // Minimum gas price to enforce for acceptance into the pool
 	PriceBump    uint64        // Minimum price bump percentage to replace an already existing transaction (nonce)
 	AccountSlots uint64        // Minimum number of executable transaction slots guaranteed per account
@@ -31,6 +35,9 @@ type TxPoolConfiguration struct {
 
 // DefaultTxPoolConfig default txpool config.
 var DefaultTxPoolConfig = TxPoolConfiguration{
+	Journal:   "transactions.rlp",
+	Rejournal: time.Hour,
+
 	PriceLimit:   1,
 	PriceBump:    10,
 	AccountSlots: 60000,
@@ -64,21 +64,11 @@ var (
 	NumberPrehp   = 20    //nodes num from 151 nodes select
 	IgnoreRetErr  = false //ignore finalize return err
 
-	StageNumberII  uint64 = 260000
-	StageNumberIII uint64 = 1200000
-	StageNumberIV  uint64 = 2560000
-	StageNumberV   uint64 = 999999000000 // unused forever
-	StageNumberVI  uint64 = 2561790
-	StageNumberVII uint64 = 2896000
-
-	StageNumberRealRandom uint64 = 5159000               // used to enable real random.
-	StateNumberNewHash           = StageNumberRealRandom // used to enable fpga hashV2 and limit continue gen block.
-
-	StageNumberUpgradedEVM uint64 = 8685000 // used to upgraded EVM .
+	// The fork heights (stages, real random, new contract and upgraded EVM)
+	// live in config.ChainConfig.
 
 	ContinuousGenBlkLimit uint64 = 2
 
-	NewContractVersion        uint64 = 3788000
 	CadNodeCheckpointInterval uint64 = 200
 )
 
@@ -203,12 +193,6 @@ func SigHash(header *types.Header) (hash common.Hash) {
 	return hash
 }
 
-func SetTestParam() {
-	StageNumberII = 1
-	StageNumberIII = 0
-	StageNumberIV = 1
-}
-
 func Gen32BRandom() [32]byte {
 
 	var Res [32]byte
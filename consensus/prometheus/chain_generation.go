@@ -120,11 +120,20 @@ func InstancePrometheus() *Prometheus {
 
 type SignerFn func(accounts.Account, []byte) ([]byte, error)
 
-func (c *Prometheus) GetNextRand(lastrand []byte, number uint64) ([]byte, error) {
-	if number < consensus.StateNumberNewHash {
-		return c.hboe.GetNextHash(lastrand)
+// randomSource returns the source of the header random chain configured for
+// the network, the BOE hardware unless the chain config selects the software one.
+func (c *Prometheus) randomSource(chain consensus.ChainReader) boe.RandomSource {
+	if cfg := chain.Config(); cfg.SoftRandom() {
+		return boe.NewSoftRandom(cfg.RandomSource.Seed.Bytes())
+	}
+	return c.hboe
+}
+
+func (c *Prometheus) GetNextRand(chain consensus.ChainReader, lastrand []byte, number uint64) ([]byte, error) {
+	if number < chain.Config().StateNumberNewHash() {
+		return c.randomSource(chain).GetNextHash(lastrand)
 	} else {
-		return c.hboe.GetNextHash_v2(lastrand)
+		return c.randomSource(chain).GetNextHash_v2(lastrand)
 	}
 
 }
@@ -153,25 +162,27 @@ func (c *Prometheus) PrepareBlockHeader(chain consensus.ChainReader, header *typ
 		log.Error("PrepareBlockHeader", "Parentheader bytesToExtraDetail error", err)
 	}
 
-	if config.GetHpbConfigInstance().Node.TestMode == 1 || config.GetHpbConfigInstance().Network.RoleType == "synnode" {
+	// The software random source is verifiable, so it is used even in test mode
+	testMode := config.GetHpbConfigInstance().Node.TestMode == 1 && !chain.Config().SoftRandom()
+	if testMode || config.GetHpbConfigInstance().Network.RoleType == "synnode" {
 		log.Debug("TestMode, using the gensis.json hardwarerandom")
 		header.HardwareRandom = make([]byte, len(parentheader.HardwareRandom))
 		copy(header.HardwareRandom, crypto.Keccak256(parentheader.HardwareRandom))
 		//header.HardwareRandom[len(header.HardwareRandom)-1] = header.HardwareRandom[len(header.HardwareRandom)-1] + 1
 		//set header hareware real random
-		if header.Number.Uint64() >= consensus.StageNumberRealRandom {
+		if header.Number.Uint64() >= chain.Config().StageNumberRealRandom() {
 			HWRealRand := consensus.Gen32BRandom()
 			extra.SetRealRND(HWRealRand[:])
 		}
 
 	} else {
-		if c.hboe.HWCheck() {
+		if source := c.randomSource(chain); source.HWCheck() {
 			if parentheader.HardwareRandom == nil || len(parentheader.HardwareRandom) != 32 {
 				log.Debug("parentheader.HardwareRandom is nil or length is not 32")
 			}
 
 			for {
-				if boehwrand, err := c.GetNextRand(parentheader.HardwareRandom, number); err != nil {
+				if boehwrand, err := c.GetNextRand(chain, parentheader.HardwareRandom, number); err != nil {
 					if err == boe.ErrHashTimeLimited {
 						time.Sleep(time.Millisecond * 500)
 						continue
@@ -190,8 +201,8 @@ func (c *Prometheus) PrepareBlockHeader(chain consensus.ChainReader, header *typ
 			}
 
 			//set header real random getting from boe
-			if header.Number.Uint64() >= consensus.StageNumberRealRandom {
-				HWRealRand, err := c.hboe.GetRandom()
+			if header.Number.Uint64() >= chain.Config().StageNumberRealRandom() {
+				HWRealRand, err := source.GetRandom()
 				if err != nil {
 					log.Error("PrepareBlockHeader boe gen real random fail", "error", err)
 					return err
@@ -205,7 +216,7 @@ func (c *Prometheus) PrepareBlockHeader(chain consensus.ChainReader, header *typ
 	}
 
 	//block 0 has no HWRealRnd, so from block 1 beginning set SignLastHWRealRnd
-	if config.GetHpbConfigInstance().Network.RoleType != "synnode" && number > consensus.StageNumberRealRandom {
+	if config.GetHpbConfigInstance().Network.RoleType != "synnode" && number > chain.Config().StageNumberRealRandom() {
 		//set last number header hardware real random signature
 		signer, signFn := c.signer, c.signFn
 		if signFn == nil {
@@ -248,7 +259,10 @@ func (c *Prometheus) PrepareBlockHeader(chain consensus.ChainReader, header *typ
 		return errors.New("prepare header get hpbnodesnap success, but snap`s singers is 0")
 	}
 	header.Difficulty = diffNoTurn
-	if number < consensus.StateNumberNewHash {
+	if chain.Config().Dev {
+		// The developer account is the only hpb node, always in turn
+		header.Difficulty = diffInTurn
+	} else if number < chain.Config().StateNumberNewHash() {
 		if _, inturn := snap.CalculateCurrentMinerorigin(new(big.Int).SetBytes(header.HardwareRandom).Uint64(), c.GetSinger()); inturn {
 			header.Difficulty = diffInTurn
 		}
@@ -338,7 +352,17 @@ func (c *Prometheus) PrepareBlockHeader(chain consensus.ChainReader, header *typ
 		if nil == nonce {
 			copy(header.Nonce[:], consensus.NonceDropVote)
 		} else {
-			if number > consensus.StageNumberIII {
+			if number > chain.Config().StageNumberIII() {
+				if len(nonce) == 2 {
+					// Winners that couldn't be measured, like the peers still on
+					// the iperf test, keep their last bandwidth of the chain
+					if nonce[0] == 0 {
+						nonce[0] = c.lastBandwith(chain, header.CandAddress, number)
+					}
+					if nonce[1] == 0 {
+						nonce[1] = c.lastBandwith(chain, header.ComdAddress, number)
+					}
+				}
 				copy(header.Nonce[len(header.Nonce)-len(nonce):], nonce)
 			} else {
 				copy(header.Nonce[:], consensus.NonceDropVote)
@@ -447,6 +471,11 @@ func (c *Prometheus) GenBlockWithSig(chain consensus.ChainReader, block *types.B
 	extra.SetSeal(sighash)
 	header.Extra = common.CopyBytes(extra.ToBytes())
 
+	if header.Difficulty.Cmp(diffInTurn) == 0 {
+		sealInTurnMeter.Mark(1)
+	} else {
+		sealOutTurnMeter.Mark(1)
+	}
 	return block.WithSeal(header), nil
 }
 
@@ -523,7 +552,11 @@ func (c *Prometheus) Finalize(chain consensus.ChainReader, header *types.Header,
 }
 
 func (c *Prometheus) CalculateRewards(chain consensus.ChainReader, state *state.StateDB, header *types.Header, uncles []*types.Header) error {
-	if header.Number.Uint64()%consensus.HpbNodeCheckpointInterval != 0 && header.Number.Uint64() > consensus.StageNumberIV {
+	// Developer chains have no reward contracts, the developer account is prefunded
+	if chain.Config().Dev {
+		return nil
+	}
+	if header.Number.Uint64()%consensus.HpbNodeCheckpointInterval != 0 && header.Number.Uint64() > chain.Config().StageNumberIV() {
 		log.Debug("CalculateRewards number is not 200 mulitple, do not reward", "number", header.Number)
 		return nil
 	}
@@ -542,7 +575,7 @@ func (c *Prometheus) CalculateRewards(chain consensus.ChainReader, state *state.
 	bigIntblocksoneyearfloat.SetInt(bigIntblocksoneyear)      //from big.Int to big.Float
 	A := bigrewards.Quo(bigrewards, bigIntblocksoneyearfloat) //calc reward mining one block
 
-	if header.Number.Uint64() >= consensus.StageNumberIII {
+	if header.Number.Uint64() >= chain.Config().StageNumberIII() {
 		seconds := big.NewInt(0)
 		tempheader := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
 		fromtime := tempheader.Time
@@ -554,7 +587,7 @@ func (c *Prometheus) CalculateRewards(chain consensus.ChainReader, state *state.
 		seconds.Sub(fromtime, tempheader.Time)
 		secondsfloat := big.NewFloat(0)
 		secondsfloat.SetInt(seconds)
-		if header.Number.Uint64() <= consensus.StageNumberIV {
+		if header.Number.Uint64() <= chain.Config().StageNumberIV() {
 			secondsfloat.Quo(secondsfloat, big.NewFloat(200))
 		}
 
@@ -572,7 +605,7 @@ func (c *Prometheus) CalculateRewards(chain consensus.ChainReader, state *state.
 	var bigA13 = new(big.Float) //1/3 one block reward
 	bigA23.Set(A)
 	bigA13.Set(A)
-	if consensus.StageNumberVI < header.Number.Uint64() {
+	if chain.Config().StageNumberVI() < header.Number.Uint64() {
 		bigA13.Quo(bigA13, big.NewFloat(200.0))
 	}
 
@@ -592,7 +625,7 @@ func (c *Prometheus) CalculateRewards(chain consensus.ChainReader, state *state.
 
 	var hpsnap *snapshots.HpbNodeSnap
 	var err error
-	if number < consensus.StageNumberII {
+	if number < chain.Config().StageNumberII() {
 		finalhpbrewards := new(big.Int)
 		bighobBlockRewardwei.Int(finalhpbrewards) //from big.Float to big.Int
 		state.AddBalance(header.Coinbase, finalhpbrewards)
@@ -612,7 +645,7 @@ func (c *Prometheus) CalculateRewards(chain consensus.ChainReader, state *state.
 
 	// fix bug : in full sync mode, process the block after StageNumberIII will occur a bad block,
 	// because there is no snap in promethus.recents , so need call voting.GetCadNodeSnap by manual.
-	if number == (consensus.StageNumberIII + 1) {
+	if number == (chain.Config().StageNumberIII() + 1) {
 		chain := bc.InstanceBlockChain()
 		parentH := chain.GetHeaderByNumber(number - 1)
 		voting.GetCadNodeSnap(c.db, c.recents, chain, parentH.Number.Uint64(), parentH.ParentHash)
@@ -620,7 +653,7 @@ func (c *Prometheus) CalculateRewards(chain consensus.ChainReader, state *state.
 
 	if csnap, err := voting.GetCadNodeSnap(c.db, c.recents, chain, number, header.ParentHash); err == nil {
 		if csnap != nil {
-			if number < consensus.StageNumberII {
+			if number < chain.Config().StageNumberII() {
 				bigA23.Mul(bigA23, big.NewFloat(0.65))
 				canBlockReward := bigA23.Quo(bigA23, big.NewFloat(float64(len(csnap.VotePercents)))) //calc average reward coin part about cadidate nodes
 
@@ -651,7 +684,7 @@ func (c *Prometheus) CalculateRewards(chain consensus.ChainReader, state *state.
 				}
 			}
 
-			if number%consensus.HpbNodeCheckpointInterval == 0 && number <= consensus.NewContractVersion && number >= consensus.StageNumberII {
+			if number%consensus.HpbNodeCheckpointInterval == 0 && number <= chain.Config().NewContractVersion() && number >= chain.Config().StageNumberII() {
 				var errreward error
 				loopcount := 3
 			GETCONTRACTLOOP:
@@ -664,7 +697,7 @@ func (c *Prometheus) CalculateRewards(chain consensus.ChainReader, state *state.
 				}
 				return errreward
 			}
-			if number%consensus.HpbNodeCheckpointInterval == 0 && number > consensus.NewContractVersion {
+			if number%consensus.HpbNodeCheckpointInterval == 0 && number > chain.Config().NewContractVersion() {
 				var errreward error
 				loopcount := 3
 				for i := 0; i < loopcount; i++ {
@@ -742,7 +775,7 @@ func (c *Prometheus) rewardvotepercentcad(chain consensus.ChainReader, header *t
 
 	//use read contract addr and funstr get vote result
 	var realaddr common.Address
-	if (consensus.StageNumberVI < header.Number.Uint64()) && (header.Number.Uint64() < consensus.StageNumberVII) {
+	if (chain.Config().StageNumberVI() < header.Number.Uint64()) && (header.Number.Uint64() < chain.Config().StageNumberVII()) {
 		realaddr = common.HexToAddress("0x2072f300c98539760be185b05b738f9e94d2e48a")
 	} else {
 		realaddr = common.BytesToAddress(resultaddr)
@@ -886,7 +919,7 @@ func (c *Prometheus) GetVoteRes(chain consensus.ChainReader, header *types.Heade
 
 	//use read contract addr and funstr get vote result
 	var realaddr common.Address
-	if (consensus.StageNumberVI < header.Number.Uint64()) && (header.Number.Uint64() < consensus.StageNumberVII) {
+	if (chain.Config().StageNumberVI() < header.Number.Uint64()) && (header.Number.Uint64() < chain.Config().StageNumberVII()) {
 		realaddr = common.HexToAddress("0x2072f300c98539760be185b05b738f9e94d2e48a")
 	} else {
 		realaddr = common.BytesToAddress(resultaddr)
@@ -953,6 +986,27 @@ func (c *Prometheus) GetVoteRes(chain consensus.ChainReader, header *types.Heade
 	return nil, votecounts, voteres
 }
 
+// lastBandwith returns the last non zero bandwidth recorded for the address
+// within the statistic window of GetBandwithRes, zero if there is none.
+func (c *Prometheus) lastBandwith(chain consensus.ChainReader, addr common.Address, number uint64) byte {
+	for i := uint64(1); i <= consensus.NumberBackBandwith && i < number; i++ {
+		header := chain.GetHeaderByNumber(number - i)
+		if header == nil {
+			break
+		}
+		if bytes.Equal(header.Nonce[:], consensus.NonceAuthVote) {
+			continue
+		}
+		if header.CandAddress == addr && header.Nonce[6] != 0 {
+			return header.Nonce[6]
+		}
+		if header.ComdAddress == addr && header.Nonce[7] != 0 {
+			return header.Nonce[7]
+		}
+	}
+	return 0
+}
+
 //input number, return key is commonAddress, order is value
 func (c *Prometheus) GetBandwithRes(addrlist []common.Address, chain consensus.ChainReader, number uint64) (map[common.Address]int, error) {
 
@@ -961,7 +1015,7 @@ func (c *Prometheus) GetBandwithRes(addrlist []common.Address, chain consensus.C
 	}
 
 	var bCalcZero = true
-	if number < consensus.StageNumberIV {
+	if number < chain.Config().StageNumberIV() {
 		bCalcZero = false
 	}
 
@@ -153,7 +153,7 @@ func (c *Prometheus) verifyCascadingFields(chain consensus.ChainReader, header *
 		return consensus.ErrInvalidTimestamp
 	}
 
-	if number > consensus.StageNumberIII && mode == config.FullSync {
+	if number > chain.Config().StageNumberIII() && mode == config.FullSync && !chain.Config().Dev {
 
 		lastheader := chain.GetHeader(header.ParentHash, number-1)
 		state, _ := chain.StateAt(lastheader.Root)
@@ -219,7 +219,7 @@ func (c *Prometheus) verifySeal(chain consensus.ChainReader, header *types.Heade
 		return err
 	}
 
-	if number > consensus.StageNumberRealRandom && mode == config.FullSync {
+	if number > chain.Config().StageNumberRealRandom() && mode == config.FullSync {
 		var realrandom = make([]byte, 0)
 		var checkRandom = true
 		if number%200 == 0 {
@@ -269,11 +269,11 @@ func (c *Prometheus) verifySeal(chain consensus.ChainReader, header *types.Heade
 		}
 	}
 
-	if config.GetHpbConfigInstance().Network.RoleType != "synnode" && config.GetHpbConfigInstance().Network.RoleType != "bootnode" && number >= consensus.StageNumberII {
+	if config.GetHpbConfigInstance().Network.RoleType != "synnode" && config.GetHpbConfigInstance().Network.RoleType != "bootnode" && number >= chain.Config().StageNumberII() {
 		// Retrieve the getHpbNodeSnap needed to verify this header and cache it
 
-		if config.GetHpbConfigInstance().Node.TestMode != 1 {
-			if !c.hboe.HWCheck() {
+		if source := c.randomSource(chain); config.GetHpbConfigInstance().Node.TestMode != 1 || chain.Config().SoftRandom() {
+			if !source.HWCheck() {
 				return consensus.Errboehwcheck
 			}
 			if parentheader == nil {
@@ -284,13 +284,13 @@ func (c *Prometheus) verifySeal(chain consensus.ChainReader, header *types.Heade
 				log.Error("verifySeal GetHeaderByNumber", "fail", "HardwareRandom is nil")
 				return consensus.ErrInvalidblockbutnodrop
 			}
-			if number >= consensus.StateNumberNewHash {
-				if err = c.hboe.HashVerify(parentheader.HardwareRandom, header.HardwareRandom); err != nil {
+			if number >= chain.Config().StateNumberNewHash() {
+				if err = source.HashVerify(parentheader.HardwareRandom, header.HardwareRandom); err != nil {
 					log.Error("verify fail HashVerify", "error", err)
 					return consensus.Errrandcheck
 				}
 			} else {
-				newrand, err := c.GetNextRand(parentheader.HardwareRandom, number)
+				newrand, err := c.GetNextRand(chain, parentheader.HardwareRandom, number)
 				if err != nil {
 					log.Error("verifySeal GetNextHash", "fail", err)
 					return consensus.ErrInvalidblockbutnodrop
@@ -304,7 +304,9 @@ func (c *Prometheus) verifySeal(chain consensus.ChainReader, header *types.Heade
 
 		if mode == config.FullSync {
 			var inturn bool
-			if number < consensus.StateNumberNewHash {
+			if chain.Config().Dev {
+				inturn = true
+			} else if number < chain.Config().StateNumberNewHash() {
 				_, inturn = snap.CalculateCurrentMinerorigin(new(big.Int).SetBytes(header.HardwareRandom).Uint64(), signer)
 			} else {
 				//statistics the miners` addresses donnot care repeat address
@@ -366,10 +368,14 @@ func (c *Prometheus) GetSelectPrehp(state *state.StateDB, chain consensus.ChainR
 	if state == nil {
 		return nil, nil, errors.New("chain stateAt return nil")
 	}
+	// Developer chains have no candidate nodes to elect
+	if chain.Config().Dev {
+		return nil, nil, nil
+	}
 	var err error
 	var bootnodeinfp []p2p.HwPair
-	log.Debug("GetSelectPrehp", "number", header.Number.Uint64(), "consensus.NewContractVersion", consensus.NewContractVersion)
-	if header.Number.Uint64() > consensus.NewContractVersion {
+	log.Debug("GetSelectPrehp", "number", header.Number.Uint64(), "NewContractVersion", chain.Config().NewContractVersion())
+	if header.Number.Uint64() > chain.Config().NewContractVersion() {
 		err, bootnodeinfp = c.GetNodeinfoFromNewContract(chain, header, state)
 	} else {
 		err, bootnodeinfp = c.GetNodeinfoFromContract(chain, header, state)
@@ -422,7 +428,7 @@ func (c *Prometheus) GetSelectPrehp(state *state.StateDB, chain consensus.ChainR
 
 	//get all votes
 	var voteres map[common.Address]big.Int
-	if header.Number.Uint64() > consensus.NewContractVersion {
+	if header.Number.Uint64() > chain.Config().NewContractVersion() {
 		err, voteres = c.GetVoteResFromNewContract(chain, header, state)
 	} else {
 		err, _, voteres = c.GetVoteRes(chain, header, state)
@@ -440,7 +446,7 @@ func (c *Prometheus) GetSelectPrehp(state *state.StateDB, chain consensus.ChainR
 	bandrank, errbandwith := c.GetBandwithRes(addrlist, chain, number-1)
 	//get all balances
 	var allbalances map[common.Address]big.Int
-	if header.Number.Uint64() > consensus.NewContractVersion {
+	if header.Number.Uint64() > chain.Config().NewContractVersion() {
 		errs, hpblist, coinaddresslist := c.GetCoinAddressFromNewContract(chain, header, state)
 		if errs != nil || coinaddresslist == nil || len(coinaddresslist) == 0 || hpblist == nil || len(hpblist) == 0 {
 			log.Error("CoinAddress ERR", "errs", errs)
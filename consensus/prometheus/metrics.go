@@ -0,0 +1,28 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the prometheus consensus.
+
+package prometheus
+
+import (
+	"github.com/hpb-project/go-hpb/common/metrics"
+)
+
+var (
+	sealInTurnMeter  = metrics.NewMeter("consensus/prometheus/seal/inturn")  // Blocks sealed in turn
+	sealOutTurnMeter = metrics.NewMeter("consensus/prometheus/seal/outturn") // Blocks sealed out of turn, after the wiggle delay
+)
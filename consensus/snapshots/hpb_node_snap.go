@@ -283,7 +283,7 @@ func CalculateHpbSnap(index uint64, signatures *lru.ARCCache, config *config.Pro
 	for i := 0; i < len(snap.Tally); i++ {
 		for j := 0; j < len(snap.Tally)-i-1; j++ {
 			var switchcondition bool
-			if number >= consensus.StageNumberIII {
+			if number >= chain.Config().StageNumberIII() {
 				switchcondition = tallytemp[j].VotePercent.Cmp(tallytemp[j+1].VotePercent) < 0
 			} else {
 				switchcondition = tallytemp[j].VotePercent.Cmp(tallytemp[j+1].VotePercent) > 0
@@ -89,7 +89,7 @@ func GetAllCadNodeSnap(db hpbdb.Database, recents *lru.ARCCache, chain consensus
 	)
 
 	gobacknum := 200
-	if number > consensus.StageNumberIII {
+	if number > chain.Config().StageNumberIII() {
 		if number <= consensus.CadNodeCheckpointInterval+200 {
 			return nil, nil
 		}
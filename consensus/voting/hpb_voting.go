@@ -37,8 +37,8 @@ func GetHpbNodeSnap(db hpbdb.Database, recents *lru.ARCCache, signatures *lru.AR
 		}
 	}
 
-	// no voting in the first ten blocks
-	if number < consensus.HpbNodeCheckpointInterval {
+	// no voting in the first ten blocks, nor ever on a developer chain
+	if number < consensus.HpbNodeCheckpointInterval || chain.Config().Dev {
 		genesis := chain.GetHeaderByNumber(0)
 		hash := genesis.Hash()
 
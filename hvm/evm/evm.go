@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"math/big"
 	"sync/atomic"
+	"time"
 
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
@@ -184,6 +185,25 @@ func (evm *EVM) Cancel() {
 	atomic.StoreInt32(&evm.abort, 1)
 }
 
+// captureBegin notifies the tracer about a new call frame. The outermost frame
+// is reported through CaptureStart, all nested ones through CaptureEnter.
+func (evm *EVM) captureBegin(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if evm.depth == 0 {
+		evm.vmConfig.Tracer.CaptureStart(evm, from, to, typ == CREATE || typ == CREATE2, input, gas, value)
+	} else {
+		evm.vmConfig.Tracer.CaptureEnter(typ, from, to, input, gas, value)
+	}
+}
+
+// captureEnd notifies the tracer that the current call frame finished.
+func (evm *EVM) captureEnd(output []byte, gasUsed uint64, start time.Time, err error) {
+	if evm.depth == 0 {
+		evm.vmConfig.Tracer.CaptureEnd(output, gasUsed, time.Since(start), err)
+	} else {
+		evm.vmConfig.Tracer.CaptureExit(output, gasUsed, err)
+	}
+}
+
 // Call executes the contract associated with the addr with the given input as
 // parameters. It also handles any necessary value transfer required and takes
 // the necessary steps to create accounts and reverses the state in case of an
@@ -201,6 +221,10 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	if !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
 		return nil, gas, ErrInsufficientBalance
 	}
+	if evm.vmConfig.Debug {
+		evm.captureBegin(CALL, caller.Address(), addr, input, gas, value)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 
 	var (
 		to       = AccountRef(addr)
@@ -301,6 +325,10 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	if !evm.CanTransfer(evm.StateDB, caller.Address(), value) {
 		return nil, gas, ErrInsufficientBalance
 	}
+	if evm.vmConfig.Debug {
+		evm.captureBegin(CALLCODE, caller.Address(), addr, input, gas, value)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 
 	var (
 		snapshot = evm.StateDB.Snapshot()
@@ -335,6 +363,10 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	if evm.depth > int(config.CallCreateDepth) {
 		return nil, gas, ErrDepth
 	}
+	if evm.vmConfig.Debug {
+		evm.captureBegin(DELEGATECALL, caller.Address(), addr, input, gas, nil)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 
 	var (
 		snapshot = evm.StateDB.Snapshot()
@@ -374,6 +406,10 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 		evm.interpreter.readOnly = true
 		defer func() { evm.interpreter.readOnly = false }()
 	}
+	if evm.vmConfig.Debug {
+		evm.captureBegin(STATICCALL, caller.Address(), addr, input, gas, new(big.Int))
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 
 	var (
 		to       = AccountRef(addr)
@@ -414,6 +450,10 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	evm.StateDB.SetNonce(caller.Address(), nonce+1)
 
 	contractAddr = crypto.CreateAddress(caller.Address(), nonce)
+	if evm.vmConfig.Debug {
+		evm.captureBegin(CREATE, caller.Address(), contractAddr, code, gas, value)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 	contractHash := evm.StateDB.GetCodeHash(contractAddr)
 	if evm.StateDB.GetNonce(contractAddr) != 0 || (contractHash != (common.Hash{}) && contractHash != emptyCodeHash) {
 		return nil, common.Address{}, 0, ErrContractAddressCollision
@@ -478,6 +518,10 @@ func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, value *big.
 	evm.StateDB.SetNonce(caller.Address(), nonce+1)
 	codeHash := crypto.Keccak256Hash(code)
 	contractAddr = common.BytesToAddress(crypto.Keccak256([]byte{0xff}, caller.Address().Bytes(), common.BigToHash(salt).Bytes(), codeHash.Bytes())[12:])
+	if evm.vmConfig.Debug {
+		evm.captureBegin(CREATE2, caller.Address(), contractAddr, code, gas, value)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 	contractHash := evm.StateDB.GetCodeHash(contractAddr)
 	if evm.StateDB.GetNonce(contractAddr) != 0 || (contractHash != (common.Hash{}) && contractHash != emptyCodeHash) {
 		return nil, common.Address{}, 0, ErrContractAddressCollision
@@ -27,6 +27,7 @@ import (
 	accounts "github.com/hpb-project/go-hpb/account"
 	"github.com/hpb-project/go-hpb/account/keystore"
 	bc "github.com/hpb-project/go-hpb/blockchain"
+	"github.com/hpb-project/go-hpb/blockchain/state"
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
 	"github.com/hpb-project/go-hpb/common/crypto"
@@ -269,6 +270,139 @@ func (s *PrivateAccountAPI) OpenWallet(url string, passphrase *string) error {
 	return wallet.Open(pass)
 }
 
+// DeriveAccount requests a HD wallet to derive a new account, optionally pinning
+// it for later reuse.
+func (s *PrivateAccountAPI) DeriveAccount(url string, path string, pin *bool) (accounts.Account, error) {
+	wallet, err := s.am.Wallet(url)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	derivPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	if pin == nil {
+		pin = new(bool)
+	}
+	return wallet.Derive(derivPath, *pin)
+}
+
+// DiscoverAccounts requests a HD wallet to pin all its accounts with on-chain
+// activity, scanning the derivation indexes from the given base path, or from
+// the default one if none is given. Discovery runs in the background as soon
+// as the wallet is open.
+func (s *PrivateAccountAPI) DiscoverAccounts(url string, base *string) error {
+	wallet, err := s.am.Wallet(url)
+	if err != nil {
+		return err
+	}
+	derivPath := accounts.DefaultBaseDerivationPath
+	if base != nil {
+		if derivPath, err = accounts.ParseDerivationPath(*base); err != nil {
+			return err
+		}
+	}
+	wallet.SelfDerive(derivPath, &chainStateReader{s.b})
+	return nil
+}
+
+// chainStateReader exposes the state of the local chain to the account discovery
+// of HD wallets.
+type chainStateReader struct {
+	b Backend
+}
+
+// state retrieves the state at the given block, or at the chain head if nil.
+func (r *chainStateReader) state(ctx context.Context, number *big.Int) (*state.StateDB, error) {
+	blockNr := rpc.LatestBlockNumber
+	if number != nil {
+		blockNr = rpc.BlockNumber(number.Int64())
+	}
+	statedb, _, err := r.b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, fmt.Errorf("state of block %v unavailable: %v", blockNr, err)
+	}
+	return statedb, nil
+}
+
+func (r *chainStateReader) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	statedb, err := r.state(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetBalance(account), statedb.Error()
+}
+
+func (r *chainStateReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, number *big.Int) ([]byte, error) {
+	statedb, err := r.state(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	res := statedb.GetState(account, key)
+	return res[:], statedb.Error()
+}
+
+func (r *chainStateReader) CodeAt(ctx context.Context, account common.Address, number *big.Int) ([]byte, error) {
+	statedb, err := r.state(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetCode(account), statedb.Error()
+}
+
+func (r *chainStateReader) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	statedb, err := r.state(ctx, number)
+	if err != nil {
+		return 0, err
+	}
+	return statedb.GetNonce(account), statedb.Error()
+}
+
+// hdWalletResult is the result of creating a new HD wallet, holding the mnemonic
+// to back up, which is not stored anywhere.
+type hdWalletResult struct {
+	URL      string         `json:"url"`
+	Address  common.Address `json:"address"`
+	Mnemonic string         `json:"mnemonic"`
+}
+
+// NewHDWallet creates a new HD wallet from a random mnemonic, storing its seed
+// encrypted with the password.
+func (s *PrivateAccountAPI) NewHDWallet(password string) (*hdWalletResult, error) {
+	hs, err := fetchHDKeyStore(s.am)
+	if err != nil {
+		return nil, err
+	}
+	mnemonic, wallet, err := hs.NewWallet(password)
+	if err != nil {
+		return nil, err
+	}
+	return &hdWalletResult{
+		URL:      wallet.URL().String(),
+		Address:  wallet.Accounts()[0].Address,
+		Mnemonic: mnemonic,
+	}, nil
+}
+
+// ImportMnemonic stores the seed of the given BIP-39 mnemonic, derived along
+// with the optional mnemonic passphrase, encrypting it with the password. The
+// URL of the new HD wallet is returned.
+func (s *PrivateAccountAPI) ImportMnemonic(mnemonic string, password string, mnemonicPassphrase *string) (string, error) {
+	hs, err := fetchHDKeyStore(s.am)
+	if err != nil {
+		return "", err
+	}
+	pass := ""
+	if mnemonicPassphrase != nil {
+		pass = *mnemonicPassphrase
+	}
+	wallet, err := hs.ImportMnemonic(mnemonic, pass, password)
+	if err != nil {
+		return "", err
+	}
+	return wallet.URL().String(), nil
+}
+
 // NewAccount will create a new account and returns the address for the new account.
 func (s *PrivateAccountAPI) NewAccount(password string) (common.Address, error) {
 
@@ -284,6 +418,15 @@ func fetchKeystore(am *accounts.Manager) *keystore.KeyStore {
 	return am.KeyStore().(*keystore.KeyStore)
 }
 
+// fetchHDKeyStore retrives the HD wallet keystore from the account manager.
+func fetchHDKeyStore(am *accounts.Manager) (*keystore.HDKeyStore, error) {
+	backends := am.Backends(keystore.HDKeyStoreType)
+	if len(backends) == 0 {
+		return nil, errors.New("hd keystore not available")
+	}
+	return backends[0].(*keystore.HDKeyStore), nil
+}
+
 // ImportRawKey stores the given hex encoded ECDSA key into the key directory,
 // encrypting it with the passphrase.
 func (s *PrivateAccountAPI) ImportRawKey(privkey string, password string) (common.Address, error) {
@@ -556,6 +699,77 @@ func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.A
 	return res[:], state.Error()
 }
 
+// AccountResult is the result of a GetProof operation: the account fields and
+// their Merkle proof against the state root, along with the requested storage
+// slots proven against the storage root of the account.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the value and Merkle proof of a single storage slot.
+type StorageResult struct {
+	Key   string          `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// GetProof returns the account and storage values of the specified account
+// including the Merkle proofs, so they can be verified against the state root
+// of the block header without trusting the node.
+func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	storageHash := types.EmptyRootHash
+	if storageTrie := state.StorageTrie(address); storageTrie != nil {
+		storageHash = storageTrie.Hash()
+	}
+	codeHash := state.GetCodeHash(address)
+	if codeHash == (common.Hash{}) {
+		// Non-existent accounts are proven absent, report the empty code hash
+		codeHash = crypto.Keccak256Hash(nil)
+	}
+	storageProof := make([]StorageResult, len(storageKeys))
+	for i, key := range storageKeys {
+		slot := common.HexToHash(key)
+		proof, err := state.GetStorageProof(address, slot)
+		if err != nil {
+			return nil, err
+		}
+		value := state.GetState(address, slot).Big()
+		storageProof[i] = StorageResult{key, (*hexutil.Big)(value), toHexSlice(proof)}
+	}
+	accountProof, err := state.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountResult{
+		Address:      address,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(state.GetBalance(address)),
+		CodeHash:     codeHash,
+		Nonce:        hexutil.Uint64(state.GetNonce(address)),
+		StorageHash:  storageHash,
+		StorageProof: storageProof,
+	}, state.Error()
+}
+
+// toHexSlice converts a list of RLP encoded trie nodes to hex bytes.
+func toHexSlice(nodes []rlp.RawValue) []hexutil.Bytes {
+	res := make([]hexutil.Bytes, len(nodes))
+	for i, node := range nodes {
+		res[i] = hexutil.Bytes(node)
+	}
+	return res
+}
+
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
 	From     common.Address  `json:"from"`
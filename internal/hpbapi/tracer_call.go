@@ -0,0 +1,170 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package hpbapi
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/hexutil"
+	"github.com/hpb-project/go-hpb/hvm/evm"
+)
+
+// errNoCallFrame is returned by the callTracer if the traced message never
+// reached the EVM.
+var errNoCallFrame = errors.New("no call was traced")
+
+// callFrame is a single call of the call tree reconstructed by the callTracer.
+type callFrame struct {
+	Type    string         `json:"type"`
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Value   *hexutil.Big   `json:"value,omitempty"`
+	Gas     hexutil.Uint64 `json:"gas"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Input   hexutil.Bytes  `json:"input"`
+	Output  hexutil.Bytes  `json:"output,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Calls   []*callFrame   `json:"calls,omitempty"`
+}
+
+// callTracerConfig are the options accepted by the callTracer.
+type callTracerConfig struct {
+	OnlyTopCall bool `json:"onlyTopCall"` // Skip all the nested calls
+}
+
+// callTracer reconstructs the tree of calls made during a transaction, with the
+// value, gas, input, output and error of each one of them.
+type callTracer struct {
+	nativeStop
+	config callTracerConfig
+	stack  []*callFrame // Frames of the calls currently in progress, outermost first
+	root   *callFrame
+}
+
+// newCallTracer creates a call tree tracer.
+func newCallTracer(cfg json.RawMessage) (ResultTracer, error) {
+	t := new(callTracer)
+	if err := parseTracerConfig(cfg, &t.config); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// newCallFrame assembles the frame of a call being entered.
+func newCallFrame(typ evm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) *callFrame {
+	frame := &callFrame{
+		Type:  typ.String(),
+		From:  from,
+		To:    to,
+		Gas:   hexutil.Uint64(gas),
+		Input: common.CopyBytes(input),
+	}
+	if value != nil {
+		frame.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+	return frame
+}
+
+// finish fills in the outcome of a call leaving.
+func (f *callFrame) finish(output []byte, gasUsed uint64, err error) {
+	f.GasUsed = hexutil.Uint64(gasUsed)
+	f.Output = common.CopyBytes(output)
+	if err != nil {
+		f.Error = err.Error()
+		if f.Type == evm.CREATE.String() || f.Type == evm.CREATE2.String() {
+			f.To = common.Address{}
+		}
+	}
+}
+
+// CaptureStart implements the Tracer interface, opening the root frame.
+func (t *callTracer) CaptureStart(env *evm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.attach(env)
+
+	typ := evm.CALL
+	if create {
+		typ = evm.CREATE
+	}
+	t.root = newCallFrame(typ, from, to, input, gas, value)
+	t.stack = []*callFrame{t.root}
+	return nil
+}
+
+// CaptureState implements the Tracer interface. Self destructs don't open a new
+// call frame in the EVM, they are recorded as leaf calls here.
+func (t *callTracer) CaptureState(env *evm.EVM, pc uint64, op evm.OpCode, gas, cost uint64, memory *evm.Memory, stack *evm.Stack, contract *evm.Contract, depth int, err error) error {
+	if op != evm.SELFDESTRUCT || err != nil || t.config.OnlyTopCall || len(t.stack) == 0 {
+		return nil
+	}
+	if len(stack.Data()) < 1 {
+		return nil
+	}
+	var (
+		from   = contract.Address()
+		to     = common.BigToAddress(stack.Back(0))
+		parent = t.stack[len(t.stack)-1]
+	)
+	parent.Calls = append(parent.Calls, newCallFrame(op, from, to, nil, 0, env.StateDB.GetBalance(from)))
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface, opening a nested frame.
+func (t *callTracer) CaptureEnter(typ evm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	if t.config.OnlyTopCall || len(t.stack) == 0 {
+		return nil
+	}
+	frame := newCallFrame(typ, from, to, input, gas, value)
+
+	parent := t.stack[len(t.stack)-1]
+	parent.Calls = append(parent.Calls, frame)
+	t.stack = append(t.stack, frame)
+	return nil
+}
+
+// CaptureExit implements the Tracer interface, closing a nested frame.
+func (t *callTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	if t.config.OnlyTopCall || len(t.stack) < 2 {
+		return nil
+	}
+	t.stack[len(t.stack)-1].finish(output, gasUsed, err)
+	t.stack = t.stack[:len(t.stack)-1]
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface, closing the root frame.
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	if t.root != nil {
+		t.root.finish(output, gasUsed, err)
+	}
+	t.stack = nil
+	return nil
+}
+
+// GetResult returns the root of the call tree.
+func (t *callTracer) GetResult() (interface{}, error) {
+	if err := t.stopped(); err != nil {
+		return nil, err
+	}
+	if t.root == nil {
+		return nil, errNoCallFrame
+	}
+	return t.root, nil
+}
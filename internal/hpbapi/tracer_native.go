@@ -0,0 +1,174 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package hpbapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/hvm/evm"
+)
+
+// ResultTracer is an EVM tracer accumulating a JSON serialisable result, which
+// can be interrupted from a different goroutine.
+type ResultTracer interface {
+	evm.Tracer
+
+	// GetResult returns the result collected during the execution.
+	GetResult() (interface{}, error)
+
+	// Stop aborts the tracing, GetResult will return err afterwards.
+	Stop(err error)
+}
+
+// nativeTracers contains the constructors of the built-in Go tracers, indexed
+// by the name they can be requested with through the trace RPC calls.
+var nativeTracers = map[string]func(cfg json.RawMessage) (ResultTracer, error){
+	"callTracer":     newCallTracer,
+	"prestateTracer": newPrestateTracer,
+	"4byteTracer":    newFourByteTracer,
+}
+
+// NewTracer creates the tracer identified by code, which is either the name of
+// a built-in native tracer or a Javascript snippet. The optional cfg is passed
+// to native tracers only.
+func NewTracer(code string, cfg json.RawMessage) (ResultTracer, error) {
+	if ctor, ok := nativeTracers[code]; ok {
+		return ctor(cfg)
+	}
+	return NewJavascriptTracer(code)
+}
+
+// parseTracerConfig decodes the user supplied config of a native tracer, an
+// empty config leaves the defaults in place.
+func parseTracerConfig(cfg json.RawMessage, v interface{}) error {
+	if len(cfg) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(cfg, v); err != nil {
+		return fmt.Errorf("invalid tracer config: %v", err)
+	}
+	return nil
+}
+
+// nativeStop implements the interruption of native tracers. Aborting cancels
+// the EVM the tracer is attached to, so that the execution ends as soon as
+// possible.
+type nativeStop struct {
+	lock   sync.Mutex
+	env    *evm.EVM
+	reason error
+}
+
+// attach records the EVM to cancel upon interruption.
+func (s *nativeStop) attach(env *evm.EVM) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.env = env
+	if s.reason != nil {
+		env.Cancel()
+	}
+}
+
+// Stop aborts the tracing with the given reason.
+func (s *nativeStop) Stop(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.reason = err
+	if s.env != nil {
+		s.env.Cancel()
+	}
+}
+
+// stopped returns the reason the tracing was aborted with, if any.
+func (s *nativeStop) stopped() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.reason
+}
+
+// fourByteTracer collects the 4 byte function selectors of all the calls made
+// during a transaction together with the size of their call data, so that the
+// contract methods can be reverse looked up from their signatures.
+type fourByteTracer struct {
+	nativeStop
+	ids map[string]int // Number of calls per "selector-datasize" pair
+}
+
+// newFourByteTracer creates a 4 byte selector histogram tracer.
+func newFourByteTracer(cfg json.RawMessage) (ResultTracer, error) {
+	return &fourByteTracer{ids: make(map[string]int)}, nil
+}
+
+// record counts a call with the given input, ignoring calls without a complete
+// selector and calls into precompiled contracts.
+func (t *fourByteTracer) record(to common.Address, input []byte) {
+	if len(input) < 4 {
+		return
+	}
+	if _, ok := evm.PrecompiledContractsByzantium[to]; ok {
+		return
+	}
+	t.ids[fmt.Sprintf("0x%x-%d", input[:4], len(input)-4)]++
+}
+
+// CaptureStart implements the Tracer interface.
+func (t *fourByteTracer) CaptureStart(env *evm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.attach(env)
+	if !create {
+		t.record(to, input)
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface.
+func (t *fourByteTracer) CaptureState(env *evm.EVM, pc uint64, op evm.OpCode, gas, cost uint64, memory *evm.Memory, stack *evm.Stack, contract *evm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface.
+func (t *fourByteTracer) CaptureEnter(typ evm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	if typ != evm.CREATE && typ != evm.CREATE2 {
+		t.record(to, input)
+	}
+	return nil
+}
+
+// CaptureExit implements the Tracer interface.
+func (t *fourByteTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface.
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the number of calls made per selector and call data size.
+func (t *fourByteTracer) GetResult() (interface{}, error) {
+	if err := t.stopped(); err != nil {
+		return nil, err
+	}
+	return t.ids, nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package hpbapi
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/hexutil"
+	config "github.com/hpb-project/go-hpb/config"
+	vm "github.com/hpb-project/go-hpb/hvm/evm"
+)
+
+var (
+	traceSender = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	traceCaller = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	traceCallee = common.HexToAddress("0x3000000000000000000000000000000000000003")
+)
+
+// runNativeTrace deploys a contract calling into a second one with the selector
+// 0xdeadbeef, the second contract storing 0x2a into slot 0, and executes a call
+// with the given input into the first contract.
+func runNativeTrace(t *testing.T, tracer ResultTracer, input []byte) (interface{}, error) {
+	db, _ := hpbdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	statedb.SetNonce(traceSender, 1)
+	statedb.AddBalance(traceSender, big.NewInt(1000))
+
+	caller := []byte{
+		byte(vm.PUSH4), 0xde, 0xad, 0xbe, 0xef, byte(vm.PUSH1), 0x00, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x04, byte(vm.PUSH1), 0x1c, byte(vm.PUSH1), 0x00,
+		byte(vm.PUSH20),
+	}
+	caller = append(caller, traceCallee.Bytes()...)
+	caller = append(caller, byte(vm.PUSH2), 0xff, 0xff, byte(vm.CALL), byte(vm.POP), byte(vm.STOP))
+	statedb.SetCode(traceCaller, caller)
+	statedb.SetCode(traceCallee, []byte{byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.STOP)})
+
+	ctx := vm.Context{
+		CanTransfer: vm.CanTransfer,
+		Transfer:    vm.Transfer,
+		BlockNumber: big.NewInt(0),
+		GasPrice:    big.NewInt(0),
+	}
+	env := vm.NewEVM(ctx, statedb, config.MainnetChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	if _, _, err := env.Call(vm.AccountRef(traceSender), traceCaller, input, 100000, big.NewInt(10)); err != nil {
+		t.Fatalf("failed to execute call: %v", err)
+	}
+	return tracer.GetResult()
+}
+
+func TestCallTracer(t *testing.T) {
+	tracer, err := NewTracer("callTracer", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := runNativeTrace(t, tracer, []byte{0x12, 0x34, 0x56, 0x78})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := ret.(*callFrame)
+	if root.Type != "CALL" || root.From != traceSender || root.To != traceCaller {
+		t.Fatalf("root frame mismatch: have %s %x -> %x", root.Type, root.From, root.To)
+	}
+	if (*big.Int)(root.Value).Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("root value mismatch: have %v, want 10", root.Value)
+	}
+	if root.GasUsed == 0 || root.Error != "" {
+		t.Errorf("root outcome mismatch: gas used %d, error %q", root.GasUsed, root.Error)
+	}
+	if len(root.Calls) != 1 {
+		t.Fatalf("nested call count mismatch: have %d, want 1", len(root.Calls))
+	}
+	inner := root.Calls[0]
+	if inner.Type != "CALL" || inner.From != traceCaller || inner.To != traceCallee {
+		t.Errorf("nested frame mismatch: have %s %x -> %x", inner.Type, inner.From, inner.To)
+	}
+	if want := (hexutil.Bytes{0xde, 0xad, 0xbe, 0xef}); string(inner.Input) != string(want) {
+		t.Errorf("nested input mismatch: have %x, want %x", inner.Input, want)
+	}
+	if inner.GasUsed == 0 || inner.GasUsed >= root.GasUsed {
+		t.Errorf("nested gas used %d out of bounds (root %d)", inner.GasUsed, root.GasUsed)
+	}
+}
+
+func TestCallTracerOnlyTopCall(t *testing.T) {
+	tracer, err := NewTracer("callTracer", json.RawMessage(`{"onlyTopCall": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := runNativeTrace(t, tracer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := ret.(*callFrame).Calls; len(calls) != 0 {
+		t.Errorf("nested calls reported: %d", len(calls))
+	}
+}
+
+func TestPrestateTracer(t *testing.T) {
+	tracer, err := NewTracer("prestateTracer", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := runNativeTrace(t, tracer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pre := ret.(map[common.Address]*prestateAccount)
+	for _, addr := range []common.Address{traceSender, traceCaller, traceCallee} {
+		if _, ok := pre[addr]; !ok {
+			t.Errorf("account %x missing from prestate", addr)
+		}
+	}
+	if balance := (*big.Int)(pre[traceSender].Balance); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want 1000", balance)
+	}
+	if nonce := pre[traceSender].Nonce; nonce != 0 {
+		t.Errorf("sender nonce mismatch: have %d, want 0", nonce)
+	}
+	if slot, ok := pre[traceCallee].Storage[common.Hash{}]; !ok || slot != (common.Hash{}) {
+		t.Errorf("callee slot mismatch: have %x (%v), want empty", slot, ok)
+	}
+}
+
+func TestPrestateTracerDiff(t *testing.T) {
+	tracer, err := NewTracer("prestateTracer", json.RawMessage(`{"diffMode": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := runNativeTrace(t, tracer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := ret.(*prestateDiff)
+
+	post, ok := diff.Post[traceCallee]
+	if !ok {
+		t.Fatalf("callee missing from poststate")
+	}
+	if slot := post.Storage[common.Hash{}]; slot != common.BigToHash(big.NewInt(0x2a)) {
+		t.Errorf("callee slot mismatch: have %x, want 0x2a", slot)
+	}
+	if balance := (*big.Int)(diff.Post[traceCaller].Balance); balance.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("caller balance mismatch: have %v, want 10", balance)
+	}
+	if balance := (*big.Int)(diff.Pre[traceCaller].Balance); balance.Sign() != 0 {
+		t.Errorf("caller prestate balance mismatch: have %v, want 0", balance)
+	}
+}
+
+func TestFourByteTracer(t *testing.T) {
+	tracer, err := NewTracer("4byteTracer", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := runNativeTrace(t, tracer, []byte{0x12, 0x34, 0x56, 0x78, 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := ret.(map[string]int)
+	want := map[string]int{"0x12345678-2": 1, "0xdeadbeef-0": 1}
+	if len(ids) != len(want) {
+		t.Fatalf("selector count mismatch: have %v, want %v", ids, want)
+	}
+	for id, count := range want {
+		if ids[id] != count {
+			t.Errorf("selector %s count mismatch: have %d, want %d", id, ids[id], count)
+		}
+	}
+}
+
+func TestNativeTracerStop(t *testing.T) {
+	tracer, err := NewTracer("callTracer", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("stahp")
+	tracer.Stop(stop)
+	if _, err := runNativeTrace(t, tracer, nil); err != stop {
+		t.Errorf("stop error mismatch: have %v, want %v", err, stop)
+	}
+}
+
+func TestNativeTracerConfig(t *testing.T) {
+	if _, err := NewTracer("callTracer", json.RawMessage(`{"onlyTopCall": 1}`)); err == nil {
+		t.Errorf("invalid tracer config accepted")
+	}
+}
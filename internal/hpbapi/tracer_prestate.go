@@ -0,0 +1,224 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package hpbapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/common/hexutil"
+	"github.com/hpb-project/go-hpb/hvm/evm"
+)
+
+// prestateAccount is the state of a single account reported by the
+// prestateTracer. Only the storage slots accessed by the transaction are
+// included.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// empty returns whether the account did not exist before the transaction.
+func (a *prestateAccount) empty() bool {
+	return a.Nonce == 0 && (*big.Int)(a.Balance).Sign() == 0 && len(a.Code) == 0
+}
+
+// prestateDiff is the result of the prestateTracer in diff mode.
+type prestateDiff struct {
+	Pre  map[common.Address]*prestateAccount `json:"pre"`
+	Post map[common.Address]*prestateAccount `json:"post"`
+}
+
+// prestateTracerConfig are the options accepted by the prestateTracer.
+type prestateTracerConfig struct {
+	DiffMode bool `json:"diffMode"` // Report the modified accounts before and after the transaction
+}
+
+// prestateTracer collects the state of every account and storage slot touched
+// by a transaction as it was before the transaction executed, which is enough
+// to replay the transaction statelessly. In diff mode only the modified parts
+// are reported, together with their values after the transaction.
+type prestateTracer struct {
+	nativeStop
+	config prestateTracerConfig
+	db     evm.StateDB
+	pre    map[common.Address]*prestateAccount
+}
+
+// newPrestateTracer creates a prestate tracer.
+func newPrestateTracer(cfg json.RawMessage) (ResultTracer, error) {
+	t := &prestateTracer{pre: make(map[common.Address]*prestateAccount)}
+	if err := parseTracerConfig(cfg, &t.config); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// lookupAccount records the current state of an account, unless it was seen
+// already.
+func (t *prestateTracer) lookupAccount(addr common.Address) *prestateAccount {
+	if account, ok := t.pre[addr]; ok {
+		return account
+	}
+	account := &prestateAccount{
+		Balance: (*hexutil.Big)(new(big.Int).Set(t.db.GetBalance(addr))),
+		Nonce:   t.db.GetNonce(addr),
+		Code:    common.CopyBytes(t.db.GetCode(addr)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	t.pre[addr] = account
+	return account
+}
+
+// lookupStorage records the current value of a storage slot, unless it was
+// seen already.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	account := t.lookupAccount(addr)
+	if _, ok := account.Storage[key]; !ok {
+		account.Storage[key] = t.db.GetState(addr, key)
+	}
+}
+
+// CaptureStart implements the Tracer interface. By the time the EVM is entered
+// the sender already paid for the gas and bumped its nonce, both are rewound
+// to report the state before the transaction.
+func (t *prestateTracer) CaptureStart(env *evm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.attach(env)
+	t.db = env.StateDB
+
+	t.lookupAccount(from)
+	t.lookupAccount(to)
+	t.lookupAccount(env.Coinbase)
+
+	sender := t.pre[from]
+	bought := new(big.Int).Add(types.IntrinsicGas(input, create), new(big.Int).SetUint64(gas))
+	sender.Balance = (*hexutil.Big)(new(big.Int).Add((*big.Int)(sender.Balance), bought.Mul(bought, env.GasPrice)))
+	if sender.Nonce > 0 {
+		sender.Nonce--
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface, recording the accounts and
+// storage slots accessed by the instruction about to execute.
+func (t *prestateTracer) CaptureState(env *evm.EVM, pc uint64, op evm.OpCode, gas, cost uint64, memory *evm.Memory, stack *evm.Stack, contract *evm.Contract, depth int, err error) error {
+	if err != nil || t.db == nil {
+		return nil
+	}
+	size := len(stack.Data())
+	switch {
+	case (op == evm.SLOAD || op == evm.SSTORE) && size >= 1:
+		t.lookupStorage(contract.Address(), common.BigToHash(stack.Back(0)))
+
+	case (op == evm.BALANCE || op == evm.EXTCODESIZE || op == evm.EXTCODECOPY || op == evm.EXTCODEHASH || op == evm.SELFDESTRUCT) && size >= 1:
+		t.lookupAccount(common.BigToAddress(stack.Back(0)))
+
+	case (op == evm.CALL || op == evm.CALLCODE || op == evm.DELEGATECALL || op == evm.STATICCALL) && size >= 2:
+		t.lookupAccount(common.BigToAddress(stack.Back(1)))
+
+	case op == evm.CREATE:
+		t.lookupAccount(crypto.CreateAddress(contract.Address(), t.db.GetNonce(contract.Address())))
+
+	case op == evm.CREATE2 && size >= 4:
+		offset, length := stack.Back(1), stack.Back(2)
+		if !offset.IsUint64() || !length.IsUint64() || offset.Uint64()+length.Uint64() > uint64(memory.Len()) {
+			return nil
+		}
+		code := memory.Data()[offset.Uint64() : offset.Uint64()+length.Uint64()]
+		salt := common.BigToHash(stack.Back(3))
+		addr := common.BytesToAddress(crypto.Keccak256([]byte{0xff}, contract.Address().Bytes(), salt.Bytes(), crypto.Keccak256(code))[12:])
+		t.lookupAccount(addr)
+	}
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface.
+func (t *prestateTracer) CaptureEnter(typ evm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureExit implements the Tracer interface.
+func (t *prestateTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the state of the touched accounts. In diff mode the state
+// database is compared against the recorded prestate, so the result must be
+// requested only after the whole message was applied, including the gas refund
+// and the fee payment.
+func (t *prestateTracer) GetResult() (interface{}, error) {
+	if err := t.stopped(); err != nil {
+		return nil, err
+	}
+	if !t.config.DiffMode {
+		return t.pre, nil
+	}
+	diff := &prestateDiff{
+		Pre:  make(map[common.Address]*prestateAccount),
+		Post: make(map[common.Address]*prestateAccount),
+	}
+	if t.db == nil {
+		return diff, nil
+	}
+	for addr, prev := range t.pre {
+		// Self destructed accounts are reported in the prestate only
+		if t.db.HasSuicided(addr) {
+			diff.Pre[addr] = prev
+			continue
+		}
+		var (
+			pre      = &prestateAccount{Balance: prev.Balance, Nonce: prev.Nonce, Code: prev.Code, Storage: make(map[common.Hash]common.Hash)}
+			post     = &prestateAccount{Storage: make(map[common.Hash]common.Hash)}
+			modified bool
+		)
+		if balance := t.db.GetBalance(addr); balance.Cmp((*big.Int)(prev.Balance)) != 0 {
+			post.Balance, modified = (*hexutil.Big)(new(big.Int).Set(balance)), true
+		}
+		if nonce := t.db.GetNonce(addr); nonce != prev.Nonce {
+			post.Nonce, modified = nonce, true
+		}
+		if code := t.db.GetCode(addr); !bytes.Equal(code, prev.Code) {
+			post.Code, modified = common.CopyBytes(code), true
+		}
+		for key, val := range prev.Storage {
+			if current := t.db.GetState(addr, key); current != val {
+				pre.Storage[key], post.Storage[key] = val, current
+				modified = true
+			}
+		}
+		if !modified {
+			continue
+		}
+		if !prev.empty() {
+			diff.Pre[addr] = pre
+		}
+		diff.Post[addr] = post
+	}
+	return diff, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"strings"
+
+	"github.com/hpb-project/go-hpb/boe"
+	"github.com/hpb-project/go-hpb/common/crypto"
+)
+
+// authPrefix is mixed into the nonce before it is signed with a coinbase key,
+// so that a remote peer choosing the nonce can never obtain a signature over
+// a block or transaction hash.
+var authPrefix = []byte("hpb p2p authentication:")
+
+// Authenticator proves the identity of the local node to remote peers during
+// the protocol handshake and verifies the proofs sent by remote peers.
+type Authenticator interface {
+	// Sign signs the random nonce chosen by the remote peer.
+	Sign(nonce []byte) ([]byte, error)
+
+	// Verify checks the signature of a remote peer over the local nonce against
+	// the hardware table entry of its coinbase.
+	Verify(nonce []byte, hw HwPair, sign []byte) bool
+}
+
+// boeAuthenticator authenticates peers with the BOE board, the signature is
+// verified against the hardware and chip ids bound to the remote coinbase.
+type boeAuthenticator struct{}
+
+func (boeAuthenticator) Sign(nonce []byte) ([]byte, error) {
+	return boe.BoeGetInstance().HW_Auth_Sign(nonce)
+}
+
+func (boeAuthenticator) Verify(nonce []byte, hw HwPair, sign []byte) bool {
+	return boe.BoeGetInstance().HW_Auth_Verify(nonce, hw.Hid, hw.Cid, sign)
+}
+
+// SignHashFn signs a hash with the coinbase key of the local node.
+type SignHashFn func(hash []byte) ([]byte, error)
+
+// keyAuthenticator authenticates peers with their coinbase key, nodes without
+// a BOE board are admitted as long as their coinbase is in the hardware table.
+type keyAuthenticator struct {
+	signFn SignHashFn
+}
+
+// NewKeyAuthenticator creates an authenticator signing the handshake nonces
+// with the coinbase key behind signFn.
+func NewKeyAuthenticator(signFn SignHashFn) Authenticator {
+	return &keyAuthenticator{signFn: signFn}
+}
+
+func (a *keyAuthenticator) Sign(nonce []byte) ([]byte, error) {
+	return a.signFn(authHash(nonce))
+}
+
+func (a *keyAuthenticator) Verify(nonce []byte, hw HwPair, sign []byte) bool {
+	if len(sign) != 65 {
+		return false
+	}
+	pubkey, err := crypto.SigToPub(authHash(nonce), sign)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(crypto.PubkeyToAddress(*pubkey).String(), hw.Adr)
+}
+
+// authHash returns the hash signed by the coinbase key for a handshake nonce.
+func authHash(nonce []byte) []byte {
+	return crypto.Keccak256(authPrefix, nonce)
+}
@@ -0,0 +1,57 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/common/crypto"
+)
+
+func TestKeyAuthenticator(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+
+	auth := NewKeyAuthenticator(func(hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	})
+	nonce := bytes.Repeat([]byte{0x42}, RandNonceSize)
+	sign, err := auth.Sign(nonce)
+	if err != nil {
+		t.Fatalf("failed to sign nonce: %v", err)
+	}
+	// The nonce must never be signed as is, it is chosen by the remote peer
+	if plain, _ := crypto.Sign(nonce, key); bytes.Equal(sign, plain) {
+		t.Fatalf("nonce signed without the authentication prefix")
+	}
+	self := HwPair{Adr: strings.ToLower(crypto.PubkeyToAddress(key.PublicKey).String())}
+	if !auth.Verify(nonce, self, sign) {
+		t.Fatalf("valid signature rejected")
+	}
+	if auth.Verify(bytes.Repeat([]byte{0x43}, RandNonceSize), self, sign) {
+		t.Fatalf("signature over another nonce accepted")
+	}
+	stranger := HwPair{Adr: strings.ToLower(crypto.PubkeyToAddress(other.PublicKey).String())}
+	if auth.Verify(nonce, stranger, sign) {
+		t.Fatalf("signature accepted for another coinbase")
+	}
+	if auth.Verify(nonce, self, sign[:64]) {
+		t.Fatalf("truncated signature accepted")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/hpb-project/go-hpb/common/rlp"
+	"github.com/hpb-project/go-hpb/consensus"
+)
+
+const (
+	bwTestVersion    = 0x02             // Native bandwidth test, version 0x01 ran iperf3
+	bwTestDuration   = time.Second      // Default length of the stream sent for a bandwidth test
+	bwTestChunk      = 64 * 1024        // Payload size of a single bandwidth test message
+	bwTestMaxStreams = 2                // Maximum number of test streams served at the same time
+	bwTestCooldown   = 10 * time.Minute // Minimum interval between two tests served to the same peer
+
+	// Default bytes per second of a test stream. The consensus records the
+	// bandwidth in MiB per second and clamps it at consensus.BandwithLimit,
+	// the pacing leaves some headroom so that a fast link reaches the limit.
+	bwTestMaxRate = (consensus.BandwithLimit + 20) * 1024 * 1024
+)
+
+var errBWTestStream = errors.New("unexpected bandwidth test data")
+
+// bwTestReq is a bandwidth test request. Version 0x01 nodes send an empty
+// request and can't receive the test stream.
+type bwTestReq struct {
+	Version uint64
+}
+
+// decodeBWTestReq decodes a bandwidth test request, tolerating the empty
+// requests of version 0x01 nodes.
+func decodeBWTestReq(msg Msg) (*bwTestReq, error) {
+	var fields []rlp.RawValue
+	if err := msg.Decode(&fields); err != nil {
+		return nil, err
+	}
+	req := &bwTestReq{Version: 0x01}
+	if len(fields) > 0 {
+		if err := rlp.DecodeBytes(fields[0], &req.Version); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// bwTestRes is the reply to a bandwidth test request, the test stream follows
+// it right away if the test is allowed.
+type bwTestRes struct {
+	Version uint64
+	Port    uint16 // Iperf3 port of version 0x01 tests, unused
+	Allowed uint16
+	Expir   uint64
+}
+
+// bwTestData is a single message of a bandwidth test stream.
+type bwTestData struct {
+	Seq  uint64 // Index of the message within the stream
+	Last bool   // Whether the message terminates the stream
+	Data []byte // Random filler
+}
+
+// bwMeter measures the rate of an incoming bandwidth test stream.
+type bwMeter struct {
+	start time.Time
+	seq   uint64
+	bytes uint64
+}
+
+func newBWMeter() *bwMeter {
+	return &bwMeter{start: time.Now()}
+}
+
+// add accounts a message of the stream. Once the last message is received the
+// measured rate is returned in bits per second, zero otherwise.
+func (m *bwMeter) add(data *bwTestData) (float64, error) {
+	if data.Seq != m.seq {
+		return 0, errBWTestStream
+	}
+	m.seq++
+	m.bytes += uint64(len(data.Data))
+
+	if !data.Last {
+		return 0, nil
+	}
+	elapsed := time.Since(m.start)
+	if elapsed <= 0 {
+		return 0, nil
+	}
+	return float64(m.bytes*8) / elapsed.Seconds(), nil
+}
+
+// streamBWTest sends a bandwidth test stream lasting duration, paced to stay
+// below rate bytes per second so that the test doesn't starve the other
+// traffic of the link.
+func streamBWTest(w MsgWriter, duration time.Duration, rate uint64) error {
+	var (
+		chunk = make([]byte, bwTestChunk)
+		start = time.Now()
+		sent  uint64
+	)
+	rand.Read(chunk)
+
+	for seq := uint64(0); ; seq++ {
+		last := time.Since(start) >= duration
+		if err := send(w, BWTestDataMsg, &bwTestData{Seq: seq, Last: last, Data: chunk}); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		sent += uint64(len(chunk))
+		if ahead := time.Duration(sent*uint64(time.Second)/rate) - time.Since(start); ahead > 0 {
+			time.Sleep(ahead)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/common/rlp"
+	"github.com/hpb-project/go-hpb/consensus"
+	"github.com/hpb-project/go-hpb/network/p2p/discover"
+)
+
+// meterWriter feeds the messages written into it to a bandwidth meter.
+type meterWriter struct {
+	meter  *bwMeter
+	result float64
+	err    error
+}
+
+func (w *meterWriter) WriteMsg(msg Msg) error {
+	if msg.Code != BWTestDataMsg {
+		return errBWTestStream
+	}
+	// Buffer the payload like the rlpx transport does
+	blob, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	var data bwTestData
+	if err := rlp.DecodeBytes(blob, &data); err != nil {
+		return err
+	}
+	w.result, w.err = w.meter.add(&data)
+	return w.err
+}
+
+func TestBandwidthTestStream(t *testing.T) {
+	const rate = 4 * 1024 * 1024
+
+	w := &meterWriter{meter: newBWMeter()}
+	if err := streamBWTest(w, 500*time.Millisecond, rate); err != nil {
+		t.Fatalf("failed to stream test data: %v", err)
+	}
+	if w.result == 0 {
+		t.Fatalf("stream terminated without a result")
+	}
+	// The measured rate must respect the cap, allowing for the final chunk
+	if limit := float64(rate*8) * 1.2; w.result > limit {
+		t.Fatalf("stream exceeded rate limit: have %v bps, want <= %v", w.result, limit)
+	}
+	if w.result < float64(rate*8)/4 {
+		t.Fatalf("stream rate too low: have %v bps", w.result)
+	}
+}
+
+// Tests that a stream paced at the default rate is measured at the bandwidth
+// limit of the consensus, which records it in MiB per second.
+func TestBandwidthTestDefaultRate(t *testing.T) {
+	w := &meterWriter{meter: newBWMeter()}
+	if err := streamBWTest(w, bwTestDuration, bwTestMaxRate); err != nil {
+		t.Fatalf("failed to stream test data: %v", err)
+	}
+	if have := uint64(w.result) / (8 << 20); have < consensus.BandwithLimit {
+		t.Fatalf("default stream below the consensus limit: have %d, want >= %d", have, consensus.BandwithLimit)
+	}
+}
+
+func TestBandwidthMeterSequence(t *testing.T) {
+	meter := newBWMeter()
+	if _, err := meter.add(&bwTestData{Seq: 0, Data: make([]byte, 16)}); err != nil {
+		t.Fatalf("failed to account first message: %v", err)
+	}
+	if _, err := meter.add(&bwTestData{Seq: 2, Last: true}); err != errBWTestStream {
+		t.Fatalf("gap error mismatch: have %v, want %v", err, errBWTestStream)
+	}
+}
+
+func TestBandwidthTestRequestDecoding(t *testing.T) {
+	tests := []struct {
+		payload interface{}
+		version uint64
+	}{
+		{struct{}{}, 0x01}, // Version 0x01 nodes send an empty request
+		{&bwTestReq{Version: bwTestVersion}, bwTestVersion},
+		{[]uint64{bwTestVersion + 1, 42}, bwTestVersion + 1},
+	}
+	for i, tt := range tests {
+		blob, err := rlp.EncodeToBytes(tt.payload)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode request: %v", i, err)
+		}
+		msg := Msg{Code: ReqBWTestMsg, Size: uint32(len(blob)), Payload: bytes.NewReader(blob)}
+		req, err := decodeBWTestReq(msg)
+		if err != nil {
+			t.Fatalf("test %d: failed to decode request: %v", i, err)
+		}
+		if req.Version != tt.version {
+			t.Errorf("test %d: version mismatch: have %d, want %d", i, req.Version, tt.version)
+		}
+	}
+}
+
+// Tests that the bandwidth measured of a peer survives its reconnection, so
+// that peers which can't be tested again keep their last measurement.
+func TestBandwidthKeptAcrossReconnect(t *testing.T) {
+	prm := NewPeerManager()
+	newPeer := func() *Peer {
+		return &Peer{PeerBase: &PeerBase{log: log.New(), remoteType: discover.PreNode}, id: "peer"}
+	}
+	p := newPeer()
+	if err := prm.Register(p); err != nil {
+		t.Fatalf("failed to register peer: %v", err)
+	}
+	p.bwmeter = newBWMeter()
+	blob, _ := rlp.EncodeToBytes(&bwTestData{Seq: 0, Last: true, Data: make([]byte, bwTestChunk)})
+	msg := Msg{Code: BWTestDataMsg, Size: uint32(len(blob)), Payload: bytes.NewReader(blob)}
+	if err := prm.HandleBWTestDataMsg(p, msg); err != nil {
+		t.Fatalf("failed to handle test data: %v", err)
+	}
+	measured := p.Bandwidth()
+	if measured == 0 {
+		t.Fatalf("stream terminated without a result")
+	}
+	prm.unregister(p.id)
+
+	p = newPeer()
+	if err := prm.Register(p); err != nil {
+		t.Fatalf("failed to register reconnected peer: %v", err)
+	}
+	if have := p.Bandwidth(); have != measured {
+		t.Fatalf("bandwidth mismatch after reconnect: have %v, want %v", have, measured)
+	}
+}
@@ -64,6 +64,7 @@ type dialstate struct {
 
 	start     time.Time        // time when the dialer was first used
 	bootnodes []*discover.Node // default dials when there are no peers
+	peerLimit int              // opt-in peer limit for dynamic dials, zero means no limit
 }
 
 type discoverTable interface {
@@ -102,7 +103,7 @@ type waitExpireTask struct {
 	time.Duration
 }
 
-func newDialState(static []*discover.Node, bootnodes []*discover.Node, ntab discoverTable, netrestrict *netutil.Netlist) *dialstate {
+func newDialState(static []*discover.Node, bootnodes []*discover.Node, ntab discoverTable, netrestrict *netutil.Netlist, peerLimit int) *dialstate {
 	s := &dialstate{
 		ntab:        ntab,
 		netrestrict: netrestrict,
@@ -110,6 +111,7 @@ func newDialState(static []*discover.Node, bootnodes []*discover.Node, ntab disc
 		dialing:     make(map[discover.NodeID]connFlag),
 		bootnodes:   make([]*discover.Node, len(bootnodes)),
 		hist:        new(dialHistory),
+		peerLimit:   peerLimit,
 	}
 
 	copy(s.bootnodes, bootnodes)
@@ -163,7 +165,11 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*PeerBase,
 		}
 	}
 
-	nodes := s.ntab.FindNodes()
+	// Don't dial new nodes dynamically once the peer limit is reached.
+	var nodes []*discover.Node
+	if s.peerLimit == 0 || len(peers)+nRunning < s.peerLimit {
+		nodes = s.ntab.FindNodes()
+	}
 	for _, n := range nodes {
 		if addDial(dynDialedConn, n) {
 			log.Trace("Add node to dial task.", "id", n.ID)
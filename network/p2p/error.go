@@ -73,6 +73,7 @@ const (
 	DiscUnknownNode
 	DiscUnexpectedConnected
 	DiscHwSignError
+	DiscTooManyPeers
 	DiscSubprotocolError = 0x10
 )
 
@@ -92,6 +93,7 @@ var discReasonToString = [...]string{
 	DiscUnknownNode:         "unknown node type",
 	DiscUnexpectedConnected: "unexpected connected",
 	DiscHwSignError:         "hardware sign error or synnode",
+	DiscTooManyPeers:        "too many peers",
 	DiscSubprotocolError:    "subprotocol error",
 }
 
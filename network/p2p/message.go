@@ -49,6 +49,7 @@ const (
 	ResNodesMsg       uint64 = 0x1021
 	ReqBWTestMsg      uint64 = 0x1030
 	ResBWTestMsg      uint64 = 0x1031
+	BWTestDataMsg     uint64 = 0x1032
 	ReqRemoteStateMsg uint64 = 0x1040
 	ResRemoteStateMsg uint64 = 0x1041
 
@@ -65,6 +66,17 @@ const (
 	ReceiptsMsg        uint64 = 0x201c
 
 	NewHashBlockMsg uint64 = 0x2020
+
+	GetAccountRangeMsg  uint64 = 0x2030
+	AccountRangeMsg     uint64 = 0x2031
+	GetStorageRangesMsg uint64 = 0x2032
+	StorageRangesMsg    uint64 = 0x2033
+	GetByteCodesMsg     uint64 = 0x2034
+	ByteCodesMsg        uint64 = 0x2035
+
+	NewTxHashesMsg  uint64 = 0x2040
+	GetPooledTxsMsg uint64 = 0x2041
+	PooledTxsMsg    uint64 = 0x2042
 )
 
 // Msg defines the structure of a p2p message.
@@ -19,8 +19,11 @@
 package p2p
 
 import (
-	"github.com/hpb-project/go-hpb/common/metrics"
+	"fmt"
 	"net"
+
+	"github.com/hpb-project/go-hpb/common/metrics"
+	gometrics "github.com/rcrowley/go-metrics"
 )
 
 var (
@@ -97,6 +100,41 @@ var (
 	miscOutTrafficMeter       = metrics.NewMeter("hpb/misc/out/traffic")
 )
 
+// msgMeters are the packet and traffic meters of a single message code.
+type msgMeters struct {
+	packets gometrics.Meter
+	traffic gometrics.Meter
+}
+
+// meteredMsgCodes lists the message codes tracked individually, any other code
+// is only accounted for in its traffic category to keep the metric set bounded.
+var meteredMsgCodes = []uint64{
+	handshakeMsg, discMsg, pingMsg, pongMsg, hardwareMsg,
+	StatusMsg, ExchangeMsg, ReqNodesMsg, ResNodesMsg, ReqBWTestMsg, ResBWTestMsg, BWTestDataMsg,
+	ReqRemoteStateMsg, ResRemoteStateMsg, NewBlockHashesMsg, TxMsg, GetBlockHeadersMsg, BlockHeadersMsg,
+	GetBlockBodiesMsg, BlockBodiesMsg, NewBlockMsg, GetNodeDataMsg, NodeDataMsg, GetReceiptsMsg,
+	ReceiptsMsg, NewHashBlockMsg, GetAccountRangeMsg, AccountRangeMsg, GetStorageRangesMsg, StorageRangesMsg,
+	GetByteCodesMsg, ByteCodesMsg, NewTxHashesMsg, GetPooledTxsMsg, PooledTxsMsg,
+}
+
+var (
+	msgInMeters  = make(map[uint64]*msgMeters)
+	msgOutMeters = make(map[uint64]*msgMeters)
+)
+
+func init() {
+	for _, code := range meteredMsgCodes {
+		msgInMeters[code] = &msgMeters{
+			packets: metrics.NewMeter(fmt.Sprintf("p2p/msg/0x%04x/in/packets", code)),
+			traffic: metrics.NewMeter(fmt.Sprintf("p2p/msg/0x%04x/in/traffic", code)),
+		}
+		msgOutMeters[code] = &msgMeters{
+			packets: metrics.NewMeter(fmt.Sprintf("p2p/msg/0x%04x/out/packets", code)),
+			traffic: metrics.NewMeter(fmt.Sprintf("p2p/msg/0x%04x/out/traffic", code)),
+		}
+	}
+}
+
 type meteredMsgReadWriter struct {
 	MsgReadWriter
 	version uint
@@ -127,7 +165,7 @@ func (rw *meteredMsgReadWriter) ReadMsg() (Msg, error) {
 	case msg.Code == BlockBodiesMsg:
 		packets, traffic = reqBodyInPacketsMeter, reqBodyInTrafficMeter
 
-	case msg.Code == NodeDataMsg:
+	case msg.Code == NodeDataMsg, msg.Code == AccountRangeMsg, msg.Code == StorageRangesMsg, msg.Code == ByteCodesMsg:
 		packets, traffic = reqStateInPacketsMeter, reqStateInTrafficMeter
 	case msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptInPacketsMeter, reqReceiptInTrafficMeter
@@ -136,12 +174,15 @@ func (rw *meteredMsgReadWriter) ReadMsg() (Msg, error) {
 		packets, traffic = propHashInPacketsMeter, propHashInTrafficMeter
 	case msg.Code == NewBlockMsg:
 		packets, traffic = propBlockInPacketsMeter, propBlockInTrafficMeter
-	case msg.Code == TxMsg:
+	case msg.Code == TxMsg, msg.Code == NewTxHashesMsg, msg.Code == PooledTxsMsg:
 		packets, traffic = propTxnInPacketsMeter, propTxnInTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
-
+	if meters, ok := msgInMeters[msg.Code]; ok {
+		meters.packets.Mark(1)
+		meters.traffic.Mark(int64(msg.Size))
+	}
 	return msg, err
 }
 
@@ -154,7 +195,7 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg Msg) error {
 	case msg.Code == BlockBodiesMsg:
 		packets, traffic = reqBodyOutPacketsMeter, reqBodyOutTrafficMeter
 
-	case msg.Code == NodeDataMsg:
+	case msg.Code == NodeDataMsg, msg.Code == AccountRangeMsg, msg.Code == StorageRangesMsg, msg.Code == ByteCodesMsg:
 		packets, traffic = reqStateOutPacketsMeter, reqStateOutTrafficMeter
 	case msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptOutPacketsMeter, reqReceiptOutTrafficMeter
@@ -163,11 +204,14 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg Msg) error {
 		packets, traffic = propHashOutPacketsMeter, propHashOutTrafficMeter
 	case msg.Code == NewBlockMsg:
 		packets, traffic = propBlockOutPacketsMeter, propBlockOutTrafficMeter
-	case msg.Code == TxMsg:
+	case msg.Code == TxMsg, msg.Code == NewTxHashesMsg, msg.Code == PooledTxsMsg:
 		packets, traffic = propTxnOutPacketsMeter, propTxnOutTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
-
+	if meters, ok := msgOutMeters[msg.Code]; ok {
+		meters.packets.Mark(1)
+		meters.traffic.Mark(int64(msg.Size))
+	}
 	return rw.MsgReadWriter.WriteMsg(msg)
 }
@@ -123,6 +123,8 @@ type Peer struct {
 	version   uint
 	txsRate   float64
 	bandwidth float64
+	bwmeter   *bwMeter  // Bandwidth test stream being measured, if any
+	bwserved  time.Time // Last time a bandwidth test stream was served to the peer
 
 	head common.Hash
 	td   *big.Int
@@ -180,6 +182,16 @@ func (p *PeerBase) Caps() []Cap {
 	return p.rw.their.Caps
 }
 
+// HasCap reports whether the remote peer advertised the given capability.
+func (p *PeerBase) HasCap(cap Cap) bool {
+	for _, have := range p.Caps() {
+		if have == cap {
+			return true
+		}
+	}
+	return false
+}
+
 // RemoteAddr returns the remote address of the network connection.
 func (p *PeerBase) RemoteAddr() net.Addr {
 	return p.rw.fd.RemoteAddr()
@@ -192,10 +204,6 @@ func (p *PeerBase) RemoteListenPort() int {
 	return int(p.rw.their.End.TCP)
 }
 
-func (p *PeerBase) RemoteIperfPort() int {
-	return int(p.rw.their.End.TCP + 100)
-}
-
 // LocalAddr returns the local address of the network connection.
 func (p *PeerBase) LocalAddr() net.Addr {
 	return p.rw.fd.LocalAddr()
@@ -435,7 +443,7 @@ func (p *PeerBase) startProtocols(writeStart <-chan struct{}, writeErr chan<- er
 	proto.closed = p.closed
 	proto.wstart = writeStart
 	proto.werr = writeErr
-	var rw MsgReadWriter = proto
+	var rw MsgReadWriter = newMeteredMsgWriter(proto)
 	if p.events != nil {
 		rw = newMsgEventer(rw, p.events, p.ID(), proto.Name)
 	}
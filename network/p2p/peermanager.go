@@ -19,14 +19,10 @@ package p2p
 import (
 	"bytes"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
-	"net"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -38,7 +34,6 @@ import (
 	"github.com/hpb-project/go-hpb/common/log"
 	"github.com/hpb-project/go-hpb/config"
 	"github.com/hpb-project/go-hpb/network/p2p/discover"
-	"github.com/hpb-project/go-hpb/network/p2p/nat"
 )
 
 var (
@@ -58,36 +53,52 @@ type PeerManager struct {
 	lock   sync.RWMutex
 	closed bool
 
-	server *Server   // pointer to server of p2p
-	hpbpro *HpbProto // pointer to hpb protocol
+	server *Server       // pointer to server of p2p
+	hpbpro *HpbProto     // pointer to hpb protocol
+	auth   Authenticator // peer authenticator, nil for the boe hardware
 
-	ilock   sync.Mutex
-	iport   int       //iperf test port
-	isrvcmd *exec.Cmd // for bandwidth test
-	isrvout *os.File  // for bandwidth test
+	bwslots    chan struct{}      // limits the bandwidth test streams sent at the same time
+	bwrate     uint64             // bytes per second of the bandwidth test streams
+	bwduration time.Duration      // length of the bandwidth test streams
+	bwresults  map[string]float64 // last bandwidth measured of the peers, kept across reconnections
 }
 
 var INSTANCE = atomic.Value{}
 
 func PeerMgrInst() *PeerManager {
 	if INSTANCE.Load() == nil {
-		pm := &PeerManager{
-			peers:  make(map[string]*Peer),
-			boots:  make(map[string]*Peer),
-			server: &Server{},
-			hpbpro: NewProtos(),
-		}
-		INSTANCE.Store(pm)
+		INSTANCE.Store(NewPeerManager())
 	}
 
 	return INSTANCE.Load().(*PeerManager)
 }
 
+// NewPeerManager creates a peer manager with its own p2p server and hpb
+// protocol. The node uses the process one returned by PeerMgrInst, others
+// serve nodes sharing the process, like the network simulations.
+func NewPeerManager() *PeerManager {
+	pm := &PeerManager{
+		peers:  make(map[string]*Peer),
+		boots:  make(map[string]*Peer),
+		server: &Server{},
+		hpbpro: NewProtos(),
+
+		bwslots:    make(chan struct{}, bwTestMaxStreams),
+		bwrate:     bwTestMaxRate,
+		bwduration: bwTestDuration,
+		bwresults:  make(map[string]float64),
+	}
+	pm.server.mgr = pm
+	pm.hpbpro.mgr = pm
+
+	return pm
+}
+
 func (prm *PeerManager) Start(coinbase common.Address) error {
 
 	config := config.GetHpbConfigInstance()
 
-	prm.server.Config = Config{
+	cfg := Config{
 		NAT:        config.Network.NAT,
 		Name:       config.Network.Name,
 		TestMode:   config.Node.TestMode == 1,
@@ -99,28 +110,25 @@ func (prm *PeerManager) Start(coinbase common.Address) error {
 		NodeDatabase:    config.Network.NodeDatabase,
 		BootstrapNodes:  config.Network.BootstrapNodes,
 		EnableMsgEvents: config.Network.EnableMsgEvents,
+		PeerLimit:       config.Network.PeerLimit,
+		NoDiscovery:     config.Network.NoDiscovery,
 
-		Protocols: prm.hpbpro.Protocols(),
+		Authenticator: prm.auth,
 	}
 
-	prm.server.Config.CoinBase = coinbase
+	cfg.CoinBase = coinbase
 	log.Info("Set coinbase address by start", "address", coinbase.String(), "roletype", config.Network.RoleType)
 	if config.Network.RoleType != "synnode" {
 		if coinbase.String() == "0x0000000000000000000000000000000000000000" {
 			panic("coinbase address is nil.")
 		}
 	}
-	prm.hpbpro.networkId = prm.server.NetworkId
-	prm.hpbpro.regMsgProcess(ReqNodesMsg, HandleReqNodesMsg)
-	prm.hpbpro.regMsgProcess(ResNodesMsg, HandleResNodesMsg)
-
-	prm.hpbpro.regMsgProcess(ReqBWTestMsg, prm.HandleReqBWTestMsg)
-	prm.hpbpro.regMsgProcess(ResBWTestMsg, prm.HandleResBWTestMsg)
-
-	prm.hpbpro.regMsgProcess(ReqRemoteStateMsg, HandleReqRemoteStateMsg)
-	prm.hpbpro.regMsgProcess(ResRemoteStateMsg, HandleResRemoteStateMsg)
-
-	copy(prm.server.Protocols, prm.hpbpro.Protocols())
+	if config.Network.BWTestRate > 0 {
+		prm.bwrate = config.Network.BWTestRate
+	}
+	if config.Network.BWTestDuration > 0 {
+		prm.bwduration = config.Network.BWTestDuration
+	}
 
 	localType := discover.PreNode
 	if config.Network.RoleType == "bootnode" {
@@ -128,11 +136,7 @@ func (prm *PeerManager) Start(coinbase common.Address) error {
 	} else if config.Network.RoleType == "synnode" {
 		localType = discover.SynNode
 	}
-	prm.SetLocalType(localType)
-	log.Info("Set Init Local Type by p2p", "type", localType.ToString())
-
-	if err := prm.server.Start(); err != nil {
-		log.Error("Hpb protocol", "error", err)
+	if err := prm.StartServer(cfg, localType); err != nil {
 		return err
 	}
 	////////////////////////////////////////////////////////////////////////////////////////
@@ -145,24 +149,8 @@ func (prm *PeerManager) Start(coinbase common.Address) error {
 	}
 
 	/////////////////////////////////////////////////////////////////////////////////////////
-	add, _ := net.ResolveUDPAddr("udp", prm.server.ListenAddr)
-	prm.iport = add.Port + 100
-	log.Debug("Iperf server start", "port", prm.iport)
-	if err := prm.startServerBW(strconv.Itoa(prm.iport)); err == nil {
-		srvaddr := prm.server.listener.Addr().(*net.TCPAddr)
-		if !srvaddr.IP.IsLoopback() && prm.server.NAT != nil {
-			prm.server.loopWG.Add(1)
-			go func(port int) {
-				log.Debug("goto add iperf port nat Map")
-				nat.Map(prm.server.NAT, prm.server.quit, "tcp", port, port, "hpb iperf")
-				prm.server.loopWG.Done()
-			}(prm.iport)
-		} else {
-			log.Debug("not use NAT on iperf")
-		}
-	}
-
-	if prm.server.localType != discover.BootNode && prm.server.localType != discover.SynNode {
+	// Developer chains have no peers to measure the bandwidth of
+	if !config.BlockChain.Dev && prm.server.localType != discover.BootNode && prm.server.localType != discover.SynNode {
 		go prm.startClientBW()
 	}
 
@@ -177,16 +165,47 @@ func (prm *PeerManager) Start(coinbase common.Address) error {
 	return nil
 }
 
+// StartServer starts the p2p server of the manager as a node of the given
+// type, serving the hpb protocol of the manager unless the config sets its
+// own protocols.
+func (prm *PeerManager) StartServer(cfg Config, localType discover.NodeType) error {
+	if cfg.Protocols == nil {
+		cfg.Protocols = prm.hpbpro.Protocols()
+	}
+	prm.server.Config = cfg
+	prm.hpbpro.networkId = cfg.NetworkId
+
+	prm.hpbpro.regMsgProcess(ReqNodesMsg, HandleReqNodesMsg)
+	prm.hpbpro.regMsgProcess(ResNodesMsg, prm.HandleResNodesMsg)
+
+	prm.hpbpro.regMsgProcess(ReqBWTestMsg, prm.HandleReqBWTestMsg)
+	prm.hpbpro.regMsgProcess(ResBWTestMsg, prm.HandleResBWTestMsg)
+	prm.hpbpro.regMsgProcess(BWTestDataMsg, prm.HandleBWTestDataMsg)
+
+	prm.hpbpro.regMsgProcess(ReqRemoteStateMsg, prm.HandleReqRemoteStateMsg)
+	prm.hpbpro.regMsgProcess(ResRemoteStateMsg, HandleResRemoteStateMsg)
+
+	prm.SetLocalType(localType)
+	log.Info("Set Init Local Type by p2p", "type", localType.ToString())
+
+	if err := prm.server.Start(); err != nil {
+		log.Error("Hpb protocol", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Protocols returns the hpb protocol served by the manager, for servers
+// wrapping it with their own transport.
+func (prm *PeerManager) Protocols() []Protocol {
+	return prm.hpbpro.Protocols()
+}
+
 func (prm *PeerManager) Stop() {
 	prm.server.Stop()
 	prm.server = nil
 
 	prm.close()
-
-	if prm.isrvcmd != nil && prm.isrvcmd.Process != nil {
-		prm.isrvout.Close()
-		prm.isrvcmd.Process.Kill()
-	}
 }
 
 func (prm *PeerManager) P2pSvr() *Server {
@@ -214,6 +233,12 @@ func (prm *PeerManager) Register(p *Peer) error {
 		return DiscAlreadyConnected
 	}
 	prm.peers[p.id] = p
+
+	// Peers that can't be measured again, like the ones still running the
+	// iperf test, keep the bandwidth measured before they reconnected.
+	p.lock.Lock()
+	p.bandwidth = prm.bwresults[p.id]
+	p.lock.Unlock()
 	return nil
 }
 
@@ -234,6 +259,12 @@ func (prm *PeerManager) unregister(id string) error {
 	return nil
 }
 
+// SetAuthenticator replaces the boe hardware authentication of the peers, it
+// must be called before Start.
+func (prm *PeerManager) SetAuthenticator(auth Authenticator) {
+	prm.auth = auth
+}
+
 // Peer retrieves the registered peer with the given id.
 func (prm *PeerManager) Peer(id string) *Peer {
 	prm.lock.RLock()
@@ -384,10 +415,11 @@ type PeerInfo struct {
 		Local  string `json:"local"`  // Local endpoint of the TCP data connection
 		Remote string `json:"remote"` // Remote endpoint of the TCP data connection
 	} `json:"network"`
-	Start  string      `json:"start"`  //
-	Beat   string      `json:"beat"`   //
-	Mining string      `json:"mining"` //
-	HPB    interface{} `json:"hpb"`    // Sub-protocol specific metadata fields
+	Start     string      `json:"start"`     //
+	Beat      string      `json:"beat"`      //
+	Mining    string      `json:"mining"`    //
+	Bandwidth float64     `json:"bandwidth"` // Last measured bandwidth of the peer in bits per second
+	HPB       interface{} `json:"hpb"`       // Sub-protocol specific metadata fields
 }
 
 type HpbInfo struct {
@@ -431,14 +463,15 @@ func (prm *PeerManager) PeersInfo() []*PeerInfo {
 	for _, p := range prm.peers {
 		hash, td := p.Head()
 		info := &PeerInfo{
-			ID:      p.ID().TerminalString(),
-			Name:    p.Name(),
-			Version: p.Version(),
-			Remote:  p.remoteType.ToString(),
-			Cap:     p.Caps()[0].String(),
-			Start:   p.beatStart.String(),
-			Beat:    strconv.FormatUint(p.count, 10),
-			Mining:  p.statMining,
+			ID:        p.ID().TerminalString(),
+			Name:      p.Name(),
+			Version:   p.Version(),
+			Remote:    p.remoteType.ToString(),
+			Cap:       p.Caps()[0].String(),
+			Start:     p.beatStart.String(),
+			Beat:      strconv.FormatUint(p.count, 10),
+			Mining:    p.statMining,
+			Bandwidth: p.Bandwidth(),
 			HPB: &HpbInfo{
 				TD:   td,
 				Head: hash.Hex(),
@@ -626,79 +659,6 @@ func (prm *PeerManager) ParseAndMonitorBinding(filename string) {
 }
 
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////////
-func (prm *PeerManager) startServerBW(port string) error {
-	/////////////////////////////////////
-	//for iperf test
-	hpbbin, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	ipfbin := filepath.Join(hpbbin, "iperf3")
-
-	if flag, err := exists(ipfbin); err != nil || flag == false {
-		log.Error("Iperf3 should exist in correct dir.", "Path", ipfbin)
-		panic("Iperf3 should exist in correct dir.")
-	}
-
-	//server
-	var err error
-	logName := "iperf_server_" + port + ".log"
-	logName = filepath.Join(hpbbin, logName)
-	prm.isrvout, err = os.OpenFile(logName, os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		log.Error("Open iperf log file", "file", logName, "err", err)
-		panic("Can not open iperf log file")
-		return err
-	}
-	log.Info("Start server of bandwidth test.", "port", port)
-	cmd := ipfbin + " -s -p " + port
-	go func(cmdline string, logout *os.File, isrvcmd **exec.Cmd) {
-		var firsttime = true
-		for {
-			c := exec.Command("/bin/bash", "-c", cmd)
-			*isrvcmd = c
-			c.Stdout = logout
-			log.Debug("start iperf server and daemon")
-			if err := c.Start(); err != nil {
-				if firsttime {
-					log.Error("Start iperf server", "err", err)
-					panic("start iperf server failed")
-				}
-			}
-			firsttime = false
-			if err := c.Wait(); err != nil {
-				log.Debug("iperf server exit", "err", err)
-			}
-			log.Debug("iperf server restart")
-			time.Sleep(time.Second) // add some delay
-		}
-	}(cmd, prm.isrvout, &prm.isrvcmd)
-	return nil
-}
-
-func (prm *PeerManager) startTest(host string, port string) float64 {
-	hpbbin, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	ipfbin := filepath.Join(hpbbin, "iperf3")
-
-	cmd := ipfbin + " -J -c " + host + " -p " + port + " -t 5"
-	result, _ := exec_shell(cmd)
-
-	if !strings.Contains(result, "bits_per_second") {
-		log.Warn("Test string in not right.", "host", host, "port", port)
-		return 0
-	}
-
-	var dat map[string]interface{}
-	json.Unmarshal([]byte(result), &dat)
-
-	sum := dat["end"].(map[string]interface{})
-
-	sum_sent := sum["sum_sent"].(map[string]interface{})
-	sum_received := sum["sum_received"].(map[string]interface{})
-
-	send := sum_sent["bits_per_second"].(float64)
-	recv := sum_received["bits_per_second"].(float64)
-	log.Debug("iperf test result", "sendrate", send, "recvrate", recv, "avg", (send+recv)/2)
-	return (send + recv) / 2
-}
-
 func (prm *PeerManager) startClientBW() {
 	/////////////////////////////////////
 	//client
@@ -764,45 +724,70 @@ func (prm *PeerManager) startClientBW() {
 	}
 }
 
-type bwTestRes struct {
-	Version uint64
-	Port    uint16
-	Allowed uint16
-	Expir   uint64
-}
-
 func (prm *PeerManager) sendReqBWTestMsg(p *Peer) {
-	if err := SendData(p, ReqBWTestMsg, struct{}{}); err != nil {
+	if err := SendData(p, ReqBWTestMsg, &bwTestReq{Version: bwTestVersion}); err != nil {
 		log.Error("Send req bandwidth test msg.", "error", err)
 	}
 
 	return
 }
 
+// HandleReqBWTestMsg answers a bandwidth test request and streams the test data
+// to the remote peer. Requests of version 0x01 nodes are ignored, as are the
+// requests arriving while bwTestMaxStreams streams are being sent or within
+// bwTestCooldown of the last test served to the peer. Peers that can't be
+// measured keep their last bandwidth, see Register.
 func (prm *PeerManager) HandleReqBWTestMsg(p *Peer, msg Msg) error {
+	request, err := decodeBWTestReq(msg)
+	if err != nil {
+		return ErrResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	if request.Version < bwTestVersion {
+		p.log.Debug("Ignore bandwidth test request of old version.", "version", request.Version)
+		return nil
+	}
+	resp := bwTestRes{
+		Version: bwTestVersion,
+		Expir:   uint64(time.Now().Add(time.Second * 5).Unix()),
+	}
+
+	p.lock.Lock()
+	recent := time.Since(p.bwserved) < bwTestCooldown
+	if !recent {
+		p.bwserved = time.Now()
+	}
+	p.lock.Unlock()
+
+	if recent {
+		p.log.Debug("Refuse bandwidth test, peer tested recently.")
+		return SendData(p, ResBWTestMsg, &resp)
+	}
+	select {
+	case prm.bwslots <- struct{}{}:
+	default:
+		p.log.Debug("Refuse bandwidth test, too many tests running.")
+		return SendData(p, ResBWTestMsg, &resp)
+	}
 	go func() {
-		prm.ilock.Lock()
-		defer prm.ilock.Unlock()
-
-		p.log.Debug("Lock of iperf server.")
-		resp := bwTestRes{
-			Version: 0x01,
-			Port:    uint16(prm.iport),
-			Allowed: 0xff,
-			Expir:   uint64(time.Now().Add(time.Second * 5).Unix()),
-		}
+		defer func() { <-prm.bwslots }()
+
+		resp.Allowed = 0xff
 		if err := SendData(p, ResBWTestMsg, &resp); err != nil {
 			p.log.Warn("Send ResBWTestMsg msg error.", "error", err)
 			return
 		}
-
-		time.Sleep(time.Second * 15)
-		p.log.Debug("Release lock of iperf server.")
+		if err := streamBWTest(p.rw, prm.bwduration, prm.bwrate); err != nil {
+			p.log.Warn("Send bandwidth test stream error.", "error", err)
+			return
+		}
+		p.log.Debug("Bandwidth test stream sent.")
 	}()
 
 	return nil
 }
 
+// HandleResBWTestMsg starts measuring the test stream announced by the remote
+// peer.
 func (prm *PeerManager) HandleResBWTestMsg(p *Peer, msg Msg) error {
 	var request bwTestRes
 	if err := msg.Decode(&request); err != nil {
@@ -811,50 +796,56 @@ func (prm *PeerManager) HandleResBWTestMsg(p *Peer, msg Msg) error {
 	}
 	log.Trace("Received bandwidth test msg from remote", "request", request)
 
+	if request.Version < bwTestVersion {
+		p.log.Debug("Remote node only supports iperf bandwidth test.", "version", request.Version)
+		return nil
+	}
 	if request.Allowed == 0 {
-		log.Error("Remote node do not allowed to bw test.")
-		return errors.New("remote node do not allowed to bw test")
+		p.log.Debug("Remote node refused the bandwidth test.")
+		return nil
 	}
 	if time.Unix(int64(request.Expir), 0).Before(time.Now()) {
 		log.Error("Test bandwidth msg timeout.")
 		return errors.New("test bandwidth msg timeout")
 	}
+	p.log.Debug("Test bandwidth start", "addr", p.RemoteAddr())
 
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				p.log.Error("Test bandwidth panic.", "ip", p.RemoteIP(), "port", p.RemoteIperfPort())
-				p.log.Error("Test bandwidth panic.", "r", r)
-			}
-		}()
-
-		p.log.Debug("Test bandwidth start", "ip", p.RemoteIP(), "port", p.RemoteIperfPort())
-
-		result := prm.startTest(p.RemoteIP(), strconv.Itoa(p.RemoteIperfPort()))
-		p.lock.Lock()
-		defer p.lock.Unlock()
-		p.bandwidth = result
-		p.log.Info("Test bandwidth ok", "result", result)
-	}()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.bwmeter = newBWMeter()
 
 	return nil
 }
 
-func exec_shell(s string) (string, error) {
-	var out bytes.Buffer
-	cmd := exec.Command("/bin/bash", "-c", s)
+// HandleBWTestDataMsg accounts a message of the bandwidth test stream and
+// records the bandwidth of the peer once the stream is over.
+func (prm *PeerManager) HandleBWTestDataMsg(p *Peer, msg Msg) error {
+	var data bwTestData
+	if err := msg.Decode(&data); err != nil {
+		return ErrResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	p.lock.Lock()
+	if p.bwmeter == nil {
+		p.lock.Unlock()
+		return errBWTestStream
+	}
+	result, err := p.bwmeter.add(&data)
+	if err != nil || data.Last {
+		p.bwmeter = nil
+	}
+	if err == nil && data.Last {
+		p.bandwidth = result
+	}
+	p.lock.Unlock()
 
-	cmd.Stdout = &out
-	err := cmd.Run()
-	return out.String(), err
-}
-func exists(path string) (bool, error) {
-	_, err := os.Stat(path)
-	if err == nil {
-		return true, nil
+	if err != nil {
+		return err
 	}
-	if os.IsNotExist(err) {
-		return false, nil
+	if data.Last {
+		prm.lock.Lock()
+		prm.bwresults[p.id] = result
+		prm.lock.Unlock()
+		p.log.Info("Test bandwidth ok", "result", result)
 	}
-	return true, err
+	return nil
 }
@@ -0,0 +1,53 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+
+	"github.com/hpb-project/go-hpb/network/p2p/discover"
+)
+
+// staticTable replaces the discovery table of a server running without
+// discovery, it only knows the local node.
+type staticTable struct {
+	self *discover.Node
+}
+
+func (t *staticTable) Self() *discover.Node               { return t.self }
+func (t *staticTable) Close()                             {}
+func (t *staticTable) FindNodes() []*discover.Node        { return nil }
+func (t *staticTable) Bondall(nodes []*discover.Node) int { return 0 }
+func (t *staticTable) RemoveNode(nid discover.NodeID)     {}
+
+// AddConn runs the handshakes on a connection established outside of the
+// server, such as one end of a net.Pipe, and adds it as a peer. The dest node
+// is the remote end of outbound connections and nil for inbound ones. It
+// returns when the peer has been added or the handshakes have failed.
+func (srv *Server) AddConn(fd net.Conn, dest *discover.Node) {
+	flags := inboundConn
+	if dest != nil {
+		flags = staticDialedConn
+	}
+	srv.SetupConn(fd, flags, dest)
+}
+
+// SetHwTable replaces the hardware table the remote peers are authenticated
+// against.
+func (srv *Server) SetHwTable(pairs []HwPair) {
+	srv.updateHdtab(pairs, false)
+}
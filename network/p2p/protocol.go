@@ -59,6 +59,8 @@ type HpbProto struct {
 	onDropPeer OnDropPeerCB
 
 	statMining StatMining
+
+	mgr *PeerManager // manager the peers of the protocol are registered with
 }
 
 const ProtoName = "hpb"
@@ -67,6 +69,14 @@ var ProtocolVersions = []uint{ProtoVersion100}
 
 const ProtoVersion100 uint = 100
 
+// StateRangeCap is advertised in the handshake by nodes able to serve account
+// and storage ranges of their state, used by range based state sync.
+var StateRangeCap = Cap{"range", 1}
+
+// TxAnnounceCap is advertised in the handshake by nodes accepting transaction
+// hash announcements and serving pooled transactions by hash.
+var TxAnnounceCap = Cap{"txann", 1}
+
 type MsgProcessCB func(p *Peer, msg Msg) error
 type ChanStatusCB func() (td *big.Int, currentBlock common.Hash, genesisBlock common.Hash)
 
@@ -147,7 +157,7 @@ func (hp *HpbProto) handle(p *Peer) error {
 	}
 
 	// Register the peer locally
-	if err := PeerMgrInst().Register(p); err != nil {
+	if err := hp.mgr.Register(p); err != nil {
 		p.log.Debug("Hpb peer registration failed", "err", err)
 		return err
 	}
@@ -225,14 +235,15 @@ func (hp *HpbProto) handleMsg(p *Peer) error {
 		}
 		return nil
 
-	case ReqBWTestMsg, ResBWTestMsg:
+	case ReqBWTestMsg, ResBWTestMsg, BWTestDataMsg:
 		if cb := hp.msgProcess[msg.Code]; cb != nil {
 			err := cb(p, msg)
 			p.log.Trace("Handle bandwidth test message.", "msg", msg, "err", err)
 		}
 		return nil
 
-	case GetBlockHeadersMsg, GetBlockBodiesMsg, GetNodeDataMsg, GetReceiptsMsg:
+	case GetBlockHeadersMsg, GetBlockBodiesMsg, GetNodeDataMsg, GetReceiptsMsg,
+		GetAccountRangeMsg, GetStorageRangesMsg, GetByteCodesMsg, GetPooledTxsMsg:
 		if cb := hp.msgProcess[msg.Code]; cb != nil {
 			err := cb(p, msg)
 			p.log.Trace("Process syn get msg", "msg", msg, "err", err)
@@ -242,14 +253,15 @@ func (hp *HpbProto) handleMsg(p *Peer) error {
 		}
 		return nil
 
-	case BlockHeadersMsg, BlockBodiesMsg, NodeDataMsg, ReceiptsMsg:
+	case BlockHeadersMsg, BlockBodiesMsg, NodeDataMsg, ReceiptsMsg,
+		AccountRangeMsg, StorageRangesMsg, ByteCodesMsg, PooledTxsMsg:
 		if cb := hp.msgProcess[msg.Code]; cb != nil {
 			err := cb(p, msg)
 			p.log.Trace("Process syn msg", "msg", msg, "err", err)
 		}
 		return nil
 
-	case NewBlockHashesMsg, NewBlockMsg, NewHashBlockMsg, TxMsg:
+	case NewBlockHashesMsg, NewBlockMsg, NewHashBlockMsg, TxMsg, NewTxHashesMsg:
 		if cb := hp.msgProcess[msg.Code]; cb != nil {
 			err := cb(p, msg)
 			p.log.Trace("Process syn new msg", "msg", msg, "err", err)
@@ -299,7 +311,7 @@ func HandleReqNodesMsg(p *Peer, msg Msg) error {
 	return nil
 }
 
-func HandleResNodesMsg(p *Peer, msg Msg) error {
+func (prm *PeerManager) HandleResNodesMsg(p *Peer, msg Msg) error {
 	var response nodeRes
 	if err := msg.Decode(&response); err != nil {
 		log.Error("Received nodes from remote", "msg", msg, "error", err)
@@ -332,7 +344,7 @@ func HandleResNodesMsg(p *Peer, msg Msg) error {
 				continue
 			}
 		}
-		if PeerMgrInst().Peer(pid) == nil {
+		if prm.Peer(pid) == nil {
 			toBondNode = append(toBondNode, n)
 			p.chbond <- n
 		}
@@ -396,11 +408,11 @@ type statusRes struct {
 	Status  []StatDetail
 }
 
-func HandleReqRemoteStateMsg(p *Peer, msg Msg) error {
+func (prm *PeerManager) HandleReqRemoteStateMsg(p *Peer, msg Msg) error {
 	resp := statusRes{Version: 0x01}
 
 	mining := "false"
-	if PeerMgrInst().hpbpro.statMining() {
+	if prm.hpbpro.statMining != nil && prm.hpbpro.statMining() {
 		mining = "true"
 	}
 	resp.Status = append(resp.Status, StatDetail{0x00, mining})
@@ -76,6 +76,20 @@ type Config struct {
 	NetworkId       uint64
 	CoinBase        common.Address
 
+	// NoDiscovery disables the UDP discovery, peers are only added by dialing
+	// static nodes or through AddConn. Without discovery an empty ListenAddr
+	// disables the TCP listener too.
+	NoDiscovery bool
+
+	// PeerLimit is an opt-in maximum number of peers that can be connected,
+	// static and trusted peers are admitted beyond it. Zero (the default) keeps
+	// admission unlimited.
+	PeerLimit int
+
+	// Authenticator signs and verifies the handshake nonces, nil selects the
+	// BOE hardware authentication.
+	Authenticator Authenticator `toml:"-"`
+
 	TestMode bool
 }
 
@@ -105,6 +119,8 @@ type Server struct {
 	quit          chan struct{}
 	addstatic     chan *discover.Node //channel for add static node
 	removestatic  chan *discover.Node //channel for remove static node
+	addtrusted    chan *discover.Node //channel for add trusted node
+	removetrusted chan *discover.Node //channel for remove trusted node
 	posthandshake chan *conn
 	addpeer       chan *conn     // channel for add peer
 	delpeer       chan peerDrop  // channel for del peer
@@ -125,6 +141,16 @@ type Server struct {
 	hdtab  []HwPair // hardware table of "ADDR CID HID"
 
 	setupLock sync.Mutex
+
+	mgr *PeerManager // manager registering the peers, the process one if nil
+}
+
+// peerMgr returns the manager the peers of the server are registered with.
+func (srv *Server) peerMgr() *PeerManager {
+	if srv.mgr != nil {
+		return srv.mgr
+	}
+	return PeerMgrInst()
 }
 
 type peerOpFunc func(map[discover.NodeID]*PeerBase)
@@ -141,6 +167,7 @@ const (
 	dynDialedConn connFlag = 1 << iota
 	staticDialedConn
 	inboundConn
+	trustedConn
 )
 const RandNonceSize = 32
 
@@ -194,6 +221,9 @@ func (f connFlag) String() string {
 	if f&inboundConn != 0 {
 		s += "-inbound"
 	}
+	if f&trustedConn != 0 {
+		s += "-trusted"
+	}
 	if s != "" {
 		s = s[1:]
 	}
@@ -251,11 +281,40 @@ func (srv *Server) RemovePeer(node *discover.Node) {
 	}
 }
 
+// AddTrustedPeer adds the given node to a reserved whitelist which allows the
+// node to always connect, even if the peer limit is reached.
+func (srv *Server) AddTrustedPeer(node *discover.Node) {
+	select {
+	case srv.addtrusted <- node:
+	case <-srv.quit:
+	}
+}
+
+// RemoveTrustedPeer removes the given node from the trusted peer set, it is
+// subject to the peer limit again on the next connection.
+func (srv *Server) RemoveTrustedPeer(node *discover.Node) {
+	select {
+	case srv.removetrusted <- node:
+	case <-srv.quit:
+	}
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(et event.EventType) event.Subscriber {
 	return srv.peerEvent.Subscribe(et)
 }
 
+// UnsubscribeEvents cancels a subscription created by SubscribeEvents. Events
+// racing with the cancellation are discarded, so the notifier never blocks on
+// a subscriber which stopped reading.
+func (srv *Server) UnsubscribeEvents(et event.EventType, sub event.Subscriber) {
+	go func() {
+		for range sub {
+		}
+	}()
+	srv.peerEvent.UnSubscribe(et, sub)
+}
+
 // Self returns the local node's endpoint information.
 func (srv *Server) Self() *discover.Node {
 	srv.lock.Lock()
@@ -330,6 +389,8 @@ func (srv *Server) Start() (err error) {
 	srv.posthandshake = make(chan *conn)
 	srv.addstatic = make(chan *discover.Node)
 	srv.removestatic = make(chan *discover.Node)
+	srv.addtrusted = make(chan *discover.Node)
+	srv.removetrusted = make(chan *discover.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 	srv.peerEvent = event.NewEvent()
@@ -338,14 +399,19 @@ func (srv *Server) Start() (err error) {
 	srv.dialer = TCPDialer{&net.Dialer{Timeout: defaultDialTimeout}}
 
 	// node table
-	ntab, ourend, err := discover.ListenUDP(srv.PrivateKey, srv.localType, srv.ListenAddr, srv.NAT, srv.NodeDatabase, srv.NetRestrict)
-	if err != nil {
-		return err
-	}
-	if err := ntab.SetFallbackNodes(srv.BootstrapNodes); err != nil {
-		return err
+	ourend := &discover.EndPoint{IP: net.IPv4(127, 0, 0, 1)}
+	if srv.NoDiscovery {
+		srv.ntab = &staticTable{self: discover.NewNode(discover.PubkeyID(&srv.PrivateKey.PublicKey), ourend.IP, 0, 0)}
+	} else {
+		ntab, end, err := discover.ListenUDP(srv.PrivateKey, srv.localType, srv.ListenAddr, srv.NAT, srv.NodeDatabase, srv.NetRestrict)
+		if err != nil {
+			return err
+		}
+		if err := ntab.SetFallbackNodes(srv.BootstrapNodes); err != nil {
+			return err
+		}
+		srv.ntab, ourend = ntab, end
 	}
-	srv.ntab = ntab
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: config.VersionID, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey), End: ourend}
@@ -360,13 +426,18 @@ func (srv *Server) Start() (err error) {
 		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, Cap{"N.A", 0})
 		log.Error("p2p get boe version", "error", err)
 	}
+	srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, StateRangeCap, TxAnnounceCap)
 	srv.ourHandshake.CoinBase = srv.CoinBase
 
-	if srv.ListenAddr == "" {
-		log.Error("P2P server start, listen address is nil")
-	}
-	if err := srv.startListening(); err != nil {
-		return err
+	if srv.ListenAddr == "" && srv.NoDiscovery {
+		log.Info("P2P server start without listener")
+	} else {
+		if srv.ListenAddr == "" {
+			log.Error("P2P server start, listen address is nil")
+		}
+		if err := srv.startListening(); err != nil {
+			return err
+		}
 	}
 
 	//////////////////////////////////////////////////////////////////////////////////////////////
@@ -377,7 +448,7 @@ func (srv *Server) Start() (err error) {
 
 	log.Info("Server start with type.", "NodeType", srv.localType.ToString())
 
-	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, srv.NetRestrict)
+	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, srv.NetRestrict, srv.PeerLimit)
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	srv.running = true
@@ -417,7 +488,7 @@ type dialer interface {
 func (srv *Server) checkHeartBeatStoped(lasttime time.Time, peers map[discover.NodeID]*PeerBase) time.Time {
 	now := time.Now()
 	if now.After(lasttime.Add(time.Minute * 5)) {
-		mgr := PeerMgrInst()
+		mgr := srv.peerMgr()
 		pmrpeers := mgr.PeersAllWithBoots()
 		for _, peer := range pmrpeers {
 			if peer.lastpingpong.Before(lasttime) && peer.bremove {
@@ -439,6 +510,7 @@ func (srv *Server) run(dialstate dialer) {
 	defer srv.loopWG.Done()
 	var (
 		peers        = make(map[discover.NodeID]*PeerBase)
+		trusted      = make(map[discover.NodeID]bool)
 		taskdone     = make(chan task, maxActiveDialTasks)
 		runningTasks []task
 		queuedTasks  []task // tasks that can't run yet
@@ -508,6 +580,16 @@ running:
 			if p, ok := peers[n.ID]; ok {
 				p.Disconnect(DiscRequested)
 			}
+		case n := <-srv.addtrusted:
+			// This channel is used by AddTrustedPeer to add a node
+			// to the trusted set, which bypasses the peer limit.
+			log.Debug("Adding trusted node", "node", n)
+			trusted[n.ID] = true
+		case n := <-srv.removetrusted:
+			// This channel is used by RemoveTrustedPeer to remove a
+			// node from the trusted set. An existing connection is kept.
+			log.Debug("Removing trusted node", "node", n)
+			delete(trusted, n.ID)
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
@@ -522,13 +604,17 @@ running:
 			// A connection has passed the encryption handshake so
 			// the remote identity is known (but hasn't been verified yet).
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
+			if trusted[c.id] {
+				// Ensure that the trusted flag is set before checking against PeerLimit.
+				c.flags |= trustedConn
+			}
 			err := srv.encHandshakeChecks(peers, c)
 			if err == DiscAlreadyConnected {
 				log.Debug("discAlreadyConnected", "nid", c.id[0:8])
 				nid := c.id
 				delete(peers, nid)
 				shortid := fmt.Sprintf("%x", nid[0:8])
-				if err := PeerMgrInst().unregister(shortid); err != nil {
+				if err := srv.peerMgr().unregister(shortid); err != nil {
 					log.Debug("Peer removal failed", "peer", shortid, "err", err)
 				}
 				srv.ntab.RemoveNode(nid)
@@ -575,7 +661,7 @@ running:
 			delete(peers, nid)
 
 			shortid := fmt.Sprintf("%x", nid[0:8])
-			if err := PeerMgrInst().unregister(shortid); err != nil {
+			if err := srv.peerMgr().unregister(shortid); err != nil {
 				log.Debug("Peer removal failed", "peer", shortid, "err", err)
 			}
 
@@ -627,6 +713,8 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*PeerBase, c *co
 		return DiscAlreadyConnected
 	case c.id == srv.Self().ID:
 		return DiscSelf
+	case srv.PeerLimit > 0 && len(peers) >= srv.PeerLimit && !c.is(trustedConn|staticDialedConn) && srv.localType != discover.BootNode:
+		return DiscTooManyPeers
 	default:
 		return nil
 	}
@@ -734,7 +822,8 @@ func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 	c.our = *srv.ourHandshake
 	c.our.RandNonce = ourRand
 
-	if c.our.Sign, err = boe.BoeGetInstance().HW_Auth_Sign(theirRand); err != nil {
+	auth := srv.authenticator()
+	if c.our.Sign, err = auth.Sign(theirRand); err != nil {
 		clog.Debug("Do hardware sign  error.", "err", err)
 	}
 	clog.Debug("Hardware has signed remote rand.", "rand", hex.EncodeToString(theirRand), "sign", hex.EncodeToString(c.our.Sign))
@@ -777,7 +866,7 @@ func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 		for _, hw := range hdtab {
 			if hw.Adr == remoteCoinbase {
 				clog.Trace("Input to boe paras", "rand", hex.EncodeToString(c.our.RandNonce), "hid", hex.EncodeToString(hw.Hid), "cid", hex.EncodeToString(hw.Cid), "sign", hex.EncodeToString(c.their.Sign))
-				c.isboe = boe.BoeGetInstance().HW_Auth_Verify(c.our.RandNonce, hw.Hid, hw.Cid, c.their.Sign)
+				c.isboe = auth.Verify(c.our.RandNonce, hw, c.their.Sign)
 				clog.Info("Boe verify the remote.", "id", c.id.TerminalString(), "result", c.isboe)
 			}
 		}
@@ -815,6 +904,15 @@ func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 	}
 }
 
+// authenticator returns the configured peer authenticator, defaulting to the
+// BOE hardware.
+func (srv *Server) authenticator() Authenticator {
+	if srv.Authenticator == nil {
+		return boeAuthenticator{}
+	}
+	return srv.Authenticator
+}
+
 func (srv *Server) updateHdtab(pairs []HwPair, boot bool) error {
 
 	log.Trace("hw pairs from prometheus", "boot", boot, "pairs", pairs)
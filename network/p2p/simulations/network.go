@@ -0,0 +1,451 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+// Package simulations runs in-process networks of hpb nodes connected over
+// net.Pipe transports, so that the synchronisation of the chains can be
+// exercised against scripted topologies, latency and message loss without
+// touching the real network.
+//
+// Every node runs its own chain, transaction pool, peer manager and SynCtrl
+// serving the hpb protocol, peers are authenticated with throwaway coinbase
+// keys instead of the BOE hardware.
+package simulations
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	bc "github.com/hpb-project/go-hpb/blockchain"
+	"github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/config"
+	"github.com/hpb-project/go-hpb/consensus"
+	"github.com/hpb-project/go-hpb/network/p2p"
+	"github.com/hpb-project/go-hpb/network/p2p/discover"
+	"github.com/hpb-project/go-hpb/synctrl"
+	"github.com/hpb-project/go-hpb/txpool"
+)
+
+// peerTimeout is the time allowed for both ends of a link to register or
+// drop each other.
+const peerTimeout = 5 * time.Second
+
+var (
+	errPartitioned = errors.New("nodes are partitioned")
+	errSameNode    = errors.New("cannot connect a node to itself")
+)
+
+// Node is a single simulated hpb node.
+type Node struct {
+	ID       discover.NodeID
+	Coinbase common.Address
+	Server   *p2p.Server
+
+	Chain   *bc.BlockChain   // Local chain of the node
+	TxPool  *txpool.TxPool   // Transactions pending on the local chain
+	Peers   *p2p.PeerManager // Peers of the node, owning the server
+	SynCtrl *synctrl.SynCtrl // Synchronisation of the chain with the peers
+
+	coinbaseKey *ecdsa.PrivateKey
+}
+
+// InsertChain imports the given blocks into the chain of the node one by one,
+// routing each of them to the peers as if the node had mined it.
+func (n *Node) InsertChain(blocks types.Blocks) error {
+	for _, block := range blocks {
+		if _, err := n.Chain.InsertChain(types.Blocks{block}); err != nil {
+			return err
+		}
+		if err := n.SynCtrl.NewBlockMux().Post(bc.NewMinedBlockEvent{Block: block}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stop shuts down the services of the node, the network first.
+func (n *Node) stop() {
+	n.SynCtrl.Stop()
+	n.Peers.Stop()
+	n.TxPool.Stop()
+	n.Chain.Stop()
+}
+
+// String implements fmt.Stringer.
+func (n *Node) String() string {
+	return n.ID.TerminalString()
+}
+
+// connected reports whether the server of n has the given node as a peer.
+func (n *Node) connected(id discover.NodeID) bool {
+	return n.peer(id) != nil
+}
+
+func (n *Node) peer(id discover.NodeID) *p2p.PeerBase {
+	for _, p := range n.Server.Peers() {
+		if p.ID() == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// linkKey identifies the link between two nodes regardless of direction.
+type linkKey struct {
+	a, b discover.NodeID
+}
+
+func newLinkKey(a, b *Node) linkKey {
+	if strings.Compare(a.ID.String(), b.ID.String()) > 0 {
+		a, b = b, a
+	}
+	return linkKey{a.ID, b.ID}
+}
+
+// link holds the conditions applied to the messages exchanged by two nodes.
+type link struct {
+	latency time.Duration // Delay added to every message
+	loss    float64       // Probability of a message being dropped
+	cut     bool          // Whether the link is severed by a partition
+}
+
+// Network is a set of simulated nodes and the links between them.
+type Network struct {
+	genesis *bc.Genesis
+	engine  consensus.Engine
+
+	nodes []*Node
+	links map[linkKey]*link
+	lock  sync.RWMutex
+}
+
+// NewNetwork creates an empty network, every node added to it starts a chain
+// from the given genesis, verified and finalized by engine.
+func NewNetwork(genesis *bc.Genesis, engine consensus.Engine) *Network {
+	return &Network{
+		genesis: genesis,
+		engine:  engine,
+		links:   make(map[linkKey]*link),
+	}
+}
+
+// Nodes returns the nodes of the network in the order they were added.
+func (nw *Network) Nodes() []*Node {
+	nw.lock.RLock()
+	defer nw.lock.RUnlock()
+
+	return append([]*Node(nil), nw.nodes...)
+}
+
+// AddNode creates and starts a new node on an empty chain. The hardware tables
+// of all nodes are refreshed so that the new node is accepted by the existing
+// ones.
+func (nw *Network) AddNode() (*Node, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	coinbaseKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{
+		ID:          discover.PubkeyID(&key.PublicKey),
+		Coinbase:    crypto.PubkeyToAddress(coinbaseKey.PublicKey),
+		coinbaseKey: coinbaseKey,
+	}
+	if err := nw.startServices(node); err != nil {
+		return nil, err
+	}
+	cfg := p2p.Config{
+		PrivateKey:  key,
+		Name:        fmt.Sprintf("sim-%s", node),
+		Protocols:   nw.wrap(node, node.Peers.Protocols()),
+		CoinBase:    node.Coinbase,
+		NoDiscovery: true,
+		Authenticator: p2p.NewKeyAuthenticator(func(hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, coinbaseKey)
+		}),
+	}
+
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	if err := node.Peers.StartServer(cfg, discover.PreNode); err != nil {
+		node.SynCtrl.Stop()
+		node.TxPool.Stop()
+		node.Chain.Stop()
+		return nil, err
+	}
+	node.Server = node.Peers.P2pSvr()
+	nw.nodes = append(nw.nodes, node)
+
+	pairs := make([]p2p.HwPair, 0, len(nw.nodes))
+	for _, n := range nw.nodes {
+		pairs = append(pairs, p2p.HwPair{Adr: strings.ToLower(n.Coinbase.String())})
+	}
+	for _, n := range nw.nodes {
+		n.Server.SetHwTable(pairs)
+	}
+	return node, nil
+}
+
+// startServices creates the chain of a node from the genesis of the network,
+// along with the transaction pool and SynCtrl registered on its own peers.
+func (nw *Network) startServices(node *Node) error {
+	db, err := hpbdb.NewMemDatabase()
+	if err != nil {
+		return err
+	}
+	if _, err := nw.genesis.Commit(db); err != nil {
+		return err
+	}
+	node.Chain, err = bc.NewBlockChainWithEngine(db, nil, nw.genesis.Config, nw.engine)
+	if err != nil {
+		return err
+	}
+	poolConfig := config.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+	node.TxPool = txpool.New(poolConfig, nw.genesis.Config, node.Chain)
+	node.TxPool.Start()
+
+	node.Peers = p2p.NewPeerManager()
+	node.Peers.RegChanStatus(node.Chain.Status)
+	node.SynCtrl, err = synctrl.NewSynCtrl(nw.genesis.Config, config.FullSync, node.TxPool, nw.engine, node.Chain, db, node.Peers)
+	if err != nil {
+		node.TxPool.Stop()
+		node.Chain.Stop()
+		return err
+	}
+	node.SynCtrl.Start()
+	return nil
+}
+
+// Shutdown stops all the nodes of the network.
+func (nw *Network) Shutdown() {
+	for _, n := range nw.Nodes() {
+		n.stop()
+	}
+}
+
+// Connect links two nodes over a fresh net.Pipe, returning once both of them
+// have registered the other as a peer.
+func (nw *Network) Connect(a, b *Node) error {
+	if a == b {
+		return errSameNode
+	}
+	nw.lock.RLock()
+	l := nw.links[newLinkKey(a, b)]
+	cut := l != nil && l.cut
+	nw.lock.RUnlock()
+
+	if cut {
+		return errPartitioned
+	}
+	if a.connected(b.ID) {
+		return nil
+	}
+	c1, c2 := net.Pipe()
+	go b.Server.AddConn(c2, nil)
+	a.Server.AddConn(c1, b.Server.Self())
+
+	return waitLink(a, b, true)
+}
+
+// Disconnect drops the link between two nodes, returning once both of them
+// have removed the other from their peers.
+func (nw *Network) Disconnect(a, b *Node) error {
+	if p := a.peer(b.ID); p != nil {
+		p.Disconnect(p2p.DiscRequested)
+	}
+	return waitLink(a, b, false)
+}
+
+// Connected reports whether two nodes are peered with each other.
+func (nw *Network) Connected(a, b *Node) bool {
+	return a.connected(b.ID) && b.connected(a.ID)
+}
+
+// ConnectAll links every pair of nodes in the network.
+func (nw *Network) ConnectAll() error {
+	nodes := nw.Nodes()
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			if err := nw.Connect(nodes[i], nodes[j]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Partition splits the given nodes from the rest of the network. Links crossing
+// the partition are disconnected and refuse new connections until Heal.
+func (nw *Network) Partition(group ...*Node) error {
+	inside := make(map[discover.NodeID]bool)
+	for _, n := range group {
+		inside[n.ID] = true
+	}
+	for _, a := range group {
+		for _, b := range nw.Nodes() {
+			if inside[b.ID] {
+				continue
+			}
+			nw.lock.Lock()
+			nw.link(a, b).cut = true
+			nw.lock.Unlock()
+
+			if err := nw.Disconnect(a, b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Heal removes all partitions, leaving it to the caller to reconnect the nodes.
+func (nw *Network) Heal() {
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	for _, l := range nw.links {
+		l.cut = false
+	}
+}
+
+// SetLatency delays every message exchanged between two nodes by d.
+func (nw *Network) SetLatency(a, b *Node, d time.Duration) {
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	nw.link(a, b).latency = d
+}
+
+// SetLoss makes the link between two nodes drop messages with the given
+// probability, in the range [0, 1].
+func (nw *Network) SetLoss(a, b *Node, rate float64) {
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	nw.link(a, b).loss = rate
+}
+
+// link returns the link between two nodes, creating it if needed. The caller
+// must hold the network lock.
+func (nw *Network) link(a, b *Node) *link {
+	key := newLinkKey(a, b)
+	l := nw.links[key]
+	if l == nil {
+		l = new(link)
+		nw.links[key] = l
+	}
+	return l
+}
+
+// conditions returns the latency and loss rate of the link between two nodes.
+func (nw *Network) conditions(a, b discover.NodeID) (time.Duration, float64) {
+	nw.lock.RLock()
+	defer nw.lock.RUnlock()
+
+	key := linkKey{a, b}
+	if strings.Compare(a.String(), b.String()) > 0 {
+		key = linkKey{b, a}
+	}
+	if l := nw.links[key]; l != nil {
+		return l.latency, l.loss
+	}
+	return 0, 0
+}
+
+// WaitConverged blocks until head reports the same hash for every node of the
+// network, or the context is cancelled. Nodes catch up with the best chain of
+// their peers every forced sync cycle of SynCtrl, ten seconds.
+func (nw *Network) WaitConverged(ctx context.Context, head func(*Node) common.Hash) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		heads := make(map[common.Hash][]*Node)
+		for _, n := range nw.Nodes() {
+			h := head(n)
+			heads[h] = append(heads[h], n)
+		}
+		if len(heads) <= 1 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%v: %d distinct heads", ctx.Err(), len(heads))
+		}
+	}
+}
+
+// wrap returns the protocols of the given node with the link conditions applied
+// to the messages it sends.
+func (nw *Network) wrap(self *Node, protos []p2p.Protocol) []p2p.Protocol {
+	wrapped := make([]p2p.Protocol, 0, len(protos))
+	for _, proto := range protos {
+		run := proto.Run
+		proto.Run = func(p *p2p.PeerBase, rw p2p.MsgReadWriter) error {
+			return run(p, &linkRW{MsgReadWriter: rw, net: nw, local: self.ID, remote: p.ID()})
+		}
+		wrapped = append(wrapped, proto)
+	}
+	return wrapped
+}
+
+// linkRW applies the conditions of a link to the outgoing messages of a peer.
+type linkRW struct {
+	p2p.MsgReadWriter
+	net           *Network
+	local, remote discover.NodeID
+}
+
+func (rw *linkRW) WriteMsg(msg p2p.Msg) error {
+	latency, loss := rw.net.conditions(rw.local, rw.remote)
+	if loss > 0 && rand.Float64() < loss {
+		return msg.Discard()
+	}
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	return rw.MsgReadWriter.WriteMsg(msg)
+}
+
+// waitLink polls both ends of a link until they agree on its state.
+func waitLink(a, b *Node, up bool) error {
+	deadline := time.Now().Add(peerTimeout)
+	for time.Now().Before(deadline) {
+		if a.connected(b.ID) == up && b.connected(a.ID) == up {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if up {
+		return fmt.Errorf("timeout connecting %v to %v", a, b)
+	}
+	return fmt.Errorf("timeout disconnecting %v from %v", a, b)
+}
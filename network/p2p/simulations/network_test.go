@@ -0,0 +1,189 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bc "github.com/hpb-project/go-hpb/blockchain"
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	"github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/config"
+	"github.com/hpb-project/go-hpb/consensus"
+	"github.com/hpb-project/go-hpb/network/rpc"
+)
+
+// syncTimeout leaves room for a few forced sync cycles of SynCtrl.
+const syncTimeout = 45 * time.Second
+
+// testEngine accepts every header without any seal or election checks and pays
+// no rewards, matching the blocks of bc.GenerateChain.
+type testEngine struct{}
+
+func (testEngine) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
+
+func (testEngine) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool, mode config.SyncMode) error {
+	return nil
+}
+
+func (testEngine) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool, mode config.SyncMode) (chan<- struct{}, <-chan error) {
+	abort, results := make(chan struct{}), make(chan error, len(headers))
+	for range headers {
+		results <- nil
+	}
+	return abort, results
+}
+
+func (testEngine) SetNetTopology(chain consensus.ChainReader, headers []*types.Header) {}
+
+func (testEngine) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	return nil
+}
+
+func (testEngine) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	return nil
+}
+
+func (testEngine) PrepareBlockHeader(chain consensus.ChainReader, header *types.Header, state *state.StateDB) error {
+	return nil
+}
+
+func (testEngine) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
+	uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	header.Root = state.IntermediateRoot(true)
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+func (testEngine) GenBlockWithSig(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	return block, nil
+}
+
+func (testEngine) APIs(chain consensus.ChainReader) []rpc.API {
+	return nil
+}
+
+func newTestNetwork(t *testing.T, size int) *Network {
+	net := NewNetwork(bc.DeveloperGenesisBlock(0, common.Address{}), testEngine{})
+	for i := 0; i < size; i++ {
+		if _, err := net.AddNode(); err != nil {
+			net.Shutdown()
+			t.Fatalf("failed to add node %d: %v", i, err)
+		}
+	}
+	return net
+}
+
+// makeBlocks generates n empty blocks on top of the genesis of the network, the
+// seed keeps the chains of different calls apart.
+func makeBlocks(net *Network, n int, seed byte) []*types.Block {
+	db, _ := hpbdb.NewMemDatabase()
+	genesis := net.genesis.MustCommit(db)
+	blocks, _ := bc.GenerateChain(net.genesis.Config, genesis, db, n, func(i int, b *bc.BlockGen) {
+		b.SetCoinbase(common.Address{0: seed, 19: byte(i)})
+	})
+	return blocks
+}
+
+func insertBlocks(t *testing.T, n *Node, blocks []*types.Block) {
+	if err := n.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks into %v: %v", n, err)
+	}
+}
+
+func chainHead(n *Node) common.Hash {
+	return n.Chain.CurrentBlock().Hash()
+}
+
+func waitConverged(t *testing.T, net *Network) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+
+	if err := net.WaitConverged(ctx, chainHead); err != nil {
+		t.Fatalf("network did not converge: %v", err)
+	}
+}
+
+func TestNetworkSync(t *testing.T) {
+	net := newTestNetwork(t, 4)
+	defer net.Shutdown()
+
+	nodes := net.Nodes()
+	for i := 1; i < len(nodes); i++ {
+		if err := net.Connect(nodes[i-1], nodes[i]); err != nil {
+			t.Fatalf("failed to connect nodes: %v", err)
+		}
+	}
+	net.SetLatency(nodes[1], nodes[2], 20*time.Millisecond)
+
+	blocks := makeBlocks(net, 32, 1)
+	insertBlocks(t, nodes[0], blocks)
+
+	waitConverged(t, net)
+	if head := chainHead(nodes[3]); head != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, blocks[len(blocks)-1].Hash())
+	}
+}
+
+func TestNetworkPartition(t *testing.T) {
+	net := newTestNetwork(t, 4)
+	defer net.Shutdown()
+
+	if err := net.ConnectAll(); err != nil {
+		t.Fatalf("failed to connect nodes: %v", err)
+	}
+	nodes := net.Nodes()
+	if err := net.Partition(nodes[0], nodes[1]); err != nil {
+		t.Fatalf("failed to partition network: %v", err)
+	}
+	if net.Connected(nodes[0], nodes[2]) || !net.Connected(nodes[0], nodes[1]) {
+		t.Fatalf("partition did not cut the right links")
+	}
+	if err := net.Connect(nodes[1], nodes[3]); err != errPartitioned {
+		t.Fatalf("connect across partition error mismatch: have %v, want %v", err, errPartitioned)
+	}
+	// Mine a chain on both sides and ensure each side only syncs its own
+	long, short := makeBlocks(net, 8, 1), makeBlocks(net, 4, 2)
+	insertBlocks(t, nodes[0], long)
+	insertBlocks(t, nodes[2], short)
+
+	deadline := time.Now().Add(syncTimeout)
+	for chainHead(nodes[1]) != chainHead(nodes[0]) || chainHead(nodes[3]) != chainHead(nodes[2]) {
+		if time.Now().After(deadline) {
+			t.Fatalf("partitions did not sync their own chains")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if chainHead(nodes[1]) == chainHead(nodes[3]) {
+		t.Fatalf("partitioned network converged")
+	}
+	// Heal the partition and reconnect over a slow link, the longer chain wins
+	net.Heal()
+	net.SetLatency(nodes[1], nodes[2], 20*time.Millisecond)
+	if err := net.ConnectAll(); err != nil {
+		t.Fatalf("failed to reconnect nodes: %v", err)
+	}
+	waitConverged(t, net)
+	if head := chainHead(nodes[3]); head != long[len(long)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, long[len(long)-1].Hash())
+	}
+}
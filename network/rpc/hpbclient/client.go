@@ -346,6 +346,19 @@ func (ec *Client) NonceAt(ctx context.Context, account common.Address, blockNumb
 	return uint64(result), err
 }
 
+// GetProof returns the account and the given storage slots of an account along
+// with their Merkle proofs. The block number can be nil, in which case the proof
+// is taken from the latest known block. The result should be checked with
+// AccountResult.Verify against the state root of a trusted header.
+func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []common.Hash, blockNumber *big.Int) (*AccountResult, error) {
+	var result AccountResult
+	err := ec.c.CallContext(ctx, &result, "hpb_getProof", account, keys, toBlockNumArg(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 // Filters
 
 // FilterLogs executes a filter query.
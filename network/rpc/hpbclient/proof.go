@@ -0,0 +1,115 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package hpbclient
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/common/hexutil"
+	"github.com/hpb-project/go-hpb/common/rlp"
+	"github.com/hpb-project/go-hpb/common/trie"
+)
+
+// emptyCodeHash is the code hash of accounts without contract code.
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// AccountResult is an account together with its Merkle proof against the state
+// root of a block, as returned by hpb_getProof.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is a storage slot together with its Merkle proof against the
+// storage root of the account.
+type StorageResult struct {
+	Key   common.Hash     `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// Verify checks the account and all storage slots of the result against the
+// given state root, which should be taken from a trusted block header.
+func (res *AccountResult) Verify(root common.Hash) error {
+	blob, err := verifyProof(root, res.Address.Bytes(), res.AccountProof)
+	if err != nil {
+		return fmt.Errorf("invalid account proof: %v", err)
+	}
+	account := state.Account{Balance: new(big.Int), Root: types.EmptyRootHash, CodeHash: emptyCodeHash.Bytes()}
+	if blob != nil {
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			return fmt.Errorf("invalid account proof: %v", err)
+		}
+	}
+	switch {
+	case account.Nonce != uint64(res.Nonce):
+		return fmt.Errorf("nonce mismatch: have %d, proven %d", res.Nonce, account.Nonce)
+	case res.Balance == nil || account.Balance.Cmp(res.Balance.ToInt()) != 0:
+		return fmt.Errorf("balance mismatch: have %v, proven %v", res.Balance, account.Balance)
+	case account.Root != res.StorageHash:
+		return fmt.Errorf("storage hash mismatch: have %x, proven %x", res.StorageHash, account.Root)
+	case common.BytesToHash(account.CodeHash) != res.CodeHash:
+		return fmt.Errorf("code hash mismatch: have %x, proven %x", res.CodeHash, account.CodeHash)
+	}
+	for _, slot := range res.StorageProof {
+		if err := slot.Verify(res.StorageHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify checks the storage slot against the given storage root of an account.
+func (res *StorageResult) Verify(root common.Hash) error {
+	blob, err := verifyProof(root, res.Key.Bytes(), res.Proof)
+	if err != nil {
+		return fmt.Errorf("invalid proof for slot %x: %v", res.Key, err)
+	}
+	var content []byte
+	if blob != nil {
+		if err := rlp.DecodeBytes(blob, &content); err != nil {
+			return fmt.Errorf("invalid proof for slot %x: %v", res.Key, err)
+		}
+	}
+	if value := new(big.Int).SetBytes(content); res.Value == nil || value.Cmp(res.Value.ToInt()) != 0 {
+		return fmt.Errorf("slot %x value mismatch: have %v, proven %v", res.Key, res.Value, value)
+	}
+	return nil
+}
+
+// verifyProof checks a Merkle proof of a key in a secure trie, returning the
+// proven value or nil if the proof shows the key is absent.
+func verifyProof(root common.Hash, key []byte, proof []hexutil.Bytes) ([]byte, error) {
+	if root == types.EmptyRootHash && len(proof) == 0 {
+		return nil, nil
+	}
+	nodes := make([]rlp.RawValue, len(proof))
+	for i, node := range proof {
+		nodes[i] = rlp.RawValue(node)
+	}
+	return trie.VerifyProof(root, crypto.Keccak256(key), nodes)
+}
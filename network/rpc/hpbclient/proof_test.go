@@ -0,0 +1,124 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package hpbclient
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/hexutil"
+	"github.com/hpb-project/go-hpb/common/rlp"
+)
+
+// proveAccount assembles the hpb_getProof result of an account the same way the
+// API does.
+func proveAccount(t *testing.T, statedb *state.StateDB, addr common.Address, keys ...common.Hash) *AccountResult {
+	accountProof, err := statedb.GetProof(addr)
+	if err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+	res := &AccountResult{
+		Address:      addr,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(statedb.GetBalance(addr)),
+		CodeHash:     emptyCodeHash,
+		Nonce:        hexutil.Uint64(statedb.GetNonce(addr)),
+		StorageHash:  types.EmptyRootHash,
+	}
+	if hash := statedb.GetCodeHash(addr); hash != (common.Hash{}) {
+		res.CodeHash = hash
+	}
+	if tr := statedb.StorageTrie(addr); tr != nil {
+		res.StorageHash = tr.Hash()
+	}
+	for _, key := range keys {
+		proof, err := statedb.GetStorageProof(addr, key)
+		if err != nil {
+			t.Fatalf("failed to prove slot %x: %v", key, err)
+		}
+		res.StorageProof = append(res.StorageProof, StorageResult{
+			Key:   key,
+			Value: (*hexutil.Big)(statedb.GetState(addr, key).Big()),
+			Proof: toHexSlice(proof),
+		})
+	}
+	return res
+}
+
+func toHexSlice(nodes []rlp.RawValue) []hexutil.Bytes {
+	res := make([]hexutil.Bytes, len(nodes))
+	for i, node := range nodes {
+		res[i] = hexutil.Bytes(node)
+	}
+	return res
+}
+
+func TestProofVerification(t *testing.T) {
+	db, _ := hpbdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	var (
+		contract = common.BytesToAddress([]byte{0x01})
+		plain    = common.BytesToAddress([]byte{0x02})
+		missing  = common.BytesToAddress([]byte{0x03})
+	)
+	for i := byte(0); i < 16; i++ {
+		statedb.AddBalance(common.BytesToAddress([]byte{0x10, i}), big.NewInt(int64(i)+1))
+		statedb.SetState(contract, common.BytesToHash([]byte{i}), common.BytesToHash([]byte{0xff, i}))
+	}
+	statedb.SetCode(contract, []byte{0x60, 0x00})
+	statedb.SetNonce(contract, 3)
+	statedb.AddBalance(plain, big.NewInt(42))
+	root, err := statedb.CommitTo(db, false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	statedb, _ = state.New(root, state.NewDatabase(db))
+
+	// Existing and missing slots of a contract must verify
+	res := proveAccount(t, statedb, contract, common.BytesToHash([]byte{5}), common.BytesToHash([]byte{0x80}))
+	if err := res.Verify(root); err != nil {
+		t.Fatalf("contract proof failed: %v", err)
+	}
+	if res.StorageProof[1].Value.ToInt().Sign() != 0 {
+		t.Fatalf("missing slot has value %v", res.StorageProof[1].Value)
+	}
+	// Accounts without storage and absent accounts must verify too
+	for _, addr := range []common.Address{plain, missing} {
+		if err := proveAccount(t, statedb, addr, common.Hash{}).Verify(root); err != nil {
+			t.Fatalf("proof of %x failed: %v", addr, err)
+		}
+	}
+	// Any tampering with the proven values must be detected
+	res.Balance = (*hexutil.Big)(big.NewInt(1))
+	if err := res.Verify(root); err == nil {
+		t.Fatalf("forged balance verified")
+	}
+	res = proveAccount(t, statedb, contract, common.BytesToHash([]byte{5}))
+	res.StorageProof[0].Value = (*hexutil.Big)(big.NewInt(7))
+	if err := res.Verify(root); err == nil {
+		t.Fatalf("forged storage value verified")
+	}
+	res = proveAccount(t, statedb, plain)
+	if err := res.Verify(common.Hash{0x01}); err == nil {
+		t.Fatalf("proof verified against wrong root")
+	}
+}
@@ -3,6 +3,7 @@ package rpc
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"sync"
 
 	"github.com/hpb-project/go-hpb/common/log"
@@ -13,7 +14,8 @@ import (
 
 ////////////////////////////////////////////////////////////////////////////
 type RpcManager struct {
-	rpcmgr *RpcMgr
+	rpcmgr   *RpcMgr
+	handlers map[string]http.Handler // Additional handlers served on the HTTP endpoint
 }
 
 var INSTANCE = atomic.Value{}
@@ -49,6 +51,8 @@ func (prm *RpcManager) Start(apis []API) error {
 		wsOrigins:   config.Network.WSOrigins,
 		wsModules:   config.Network.WSModules,
 		wsExposeAll: config.Network.WSExposeAll,
+
+		httpHandlers: prm.handlers,
 	}
 	if err := prm.rpcmgr.startRPC(apis); err != nil {
 		log.Error("start rpc error", "reason", err)
@@ -57,6 +61,16 @@ func (prm *RpcManager) Start(apis []API) error {
 	return nil
 }
 
+// RegisterHTTPHandler mounts an additional handler on the HTTP RPC endpoint at
+// the given path, next to the JSON-RPC API served at the root. It must be called
+// before Start.
+func (prm *RpcManager) RegisterHTTPHandler(path string, handler http.Handler) {
+	if prm.handlers == nil {
+		prm.handlers = make(map[string]http.Handler)
+	}
+	prm.handlers[path] = handler
+}
+
 func (prm *RpcManager) Stop() {
 	prm.rpcmgr.stopRPC()
 }
@@ -91,6 +105,7 @@ type RpcMgr struct {
 	httpModules      []string
 	httpVirtualHosts []string
 	httpTimeouts     config.HTTPTimeouts
+	httpHandlers     map[string]http.Handler
 
 	wsOrigins   []string
 	wsModules   []string
@@ -112,7 +127,7 @@ func (n *RpcMgr) startRPC(apis []API) error {
 		n.stopInProc()
 		return err
 	}
-	if err := n.startHTTP(n.httpEndpoint, apis, n.httpModules, n.httpCors, n.httpVirtualHosts, n.httpTimeouts); err != nil {
+	if err := n.startHTTP(n.httpEndpoint, apis, n.httpModules, n.httpCors, n.httpVirtualHosts, n.httpTimeouts, n.httpHandlers); err != nil {
 		n.stopIPC()
 		n.stopInProc()
 		return err
@@ -186,16 +201,19 @@ func (n *RpcMgr) stopIPC() {
 }
 
 // startHTTP initializes and starts the HTTP RPC endpoint.
-func (n *RpcMgr) startHTTP(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts config.HTTPTimeouts) error {
+func (n *RpcMgr) startHTTP(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts config.HTTPTimeouts, handlers map[string]http.Handler) error {
 	// Short circuit if the HTTP endpoint isn't being exposed
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts)
+	listener, handler, err := StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts, handlers)
 	if err != nil {
 		return err
 	}
 	log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
+	for path := range handlers {
+		log.Info("HTTP handler mounted", "url", fmt.Sprintf("http://%s%s", endpoint, path))
+	}
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
 	n.httpListener = listener
@@ -253,8 +271,9 @@ func (n *RpcMgr) stopWS() {
 
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules
-func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts config.HTTPTimeouts) (net.Listener, *Server, error) {
+// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules.
+// The optional handlers are served next to the JSON-RPC API at their own paths.
+func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts config.HTTPTimeouts, handlers map[string]http.Handler) (net.Listener, *Server, error) {
 	// Generate the whitelist based on the allowed modules
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
@@ -278,7 +297,7 @@ func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []str
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return nil, nil, err
 	}
-	go NewHTTPServer(cors, vhosts, timeouts, handler).Serve(listener)
+	go NewHTTPServer(cors, vhosts, timeouts, newHTTPMux(handler, handlers)).Serve(listener)
 	return listener, handler, err
 }
 
@@ -40,9 +40,12 @@ import (
 	"github.com/hpb-project/go-hpb/common/rlp"
 	"github.com/hpb-project/go-hpb/common/trie"
 	"github.com/hpb-project/go-hpb/config"
+	"github.com/hpb-project/go-hpb/event"
+	"github.com/hpb-project/go-hpb/hvm"
 	"github.com/hpb-project/go-hpb/hvm/evm"
 	"github.com/hpb-project/go-hpb/internal/hpbapi"
 	"github.com/hpb-project/go-hpb/network/p2p"
+	"github.com/hpb-project/go-hpb/network/p2p/discover"
 	"github.com/hpb-project/go-hpb/network/rpc"
 )
 
@@ -429,6 +432,115 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// AddPeer requests connecting to a remote node, and also maintaining the new
+// connection at all times, even reconnecting if it is lost.
+func (api *PrivateAdminAPI) AddPeer(url string) (bool, error) {
+	server, node, err := api.peerTarget(url)
+	if err != nil {
+		return false, err
+	}
+	server.AddPeer(node)
+	return true, nil
+}
+
+// RemovePeer disconnects from a remote node if the connection exists.
+func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
+	server, node, err := api.peerTarget(url)
+	if err != nil {
+		return false, err
+	}
+	server.RemovePeer(node)
+	return true, nil
+}
+
+// AddTrustedPeer allows a remote node to always connect, even if slots are full.
+func (api *PrivateAdminAPI) AddTrustedPeer(url string) (bool, error) {
+	server, node, err := api.peerTarget(url)
+	if err != nil {
+		return false, err
+	}
+	server.AddTrustedPeer(node)
+	return true, nil
+}
+
+// RemoveTrustedPeer removes a remote node from the trusted peer set, but it
+// does not disconnect it automatically.
+func (api *PrivateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
+	server, node, err := api.peerTarget(url)
+	if err != nil {
+		return false, err
+	}
+	server.RemoveTrustedPeer(node)
+	return true, nil
+}
+
+// peerTarget returns the running p2p server and the node parsed from url.
+func (api *PrivateAdminAPI) peerTarget(url string) (*p2p.Server, *discover.Node, error) {
+	pm := api.hpb.Hpbpeermanager
+	if pm == nil {
+		return nil, nil, ErrNodeStopped
+	}
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid enode: %v", err)
+	}
+	return pm.P2pSvr(), node, nil
+}
+
+// PeerEvents creates an RPC subscription which receives peer events from the
+// node's p2p server. Message events are only emitted if the server is started
+// with EnableMsgEvents.
+func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
+	pm := api.hpb.Hpbpeermanager
+	if pm == nil {
+		return nil, ErrNodeStopped
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		var (
+			server  = pm.P2pSvr()
+			kinds   = []event.EventType{p2p.PeerEventAdd, p2p.PeerEventDrop, p2p.PeerEventMsgSend, p2p.PeerEventMsgRecv}
+			events  = make(chan *p2p.PeerEvent)
+			release = make(chan struct{})
+		)
+		// Funnel the per type subscriptions into a single channel
+		for _, et := range kinds {
+			sub := server.SubscribeEvents(et)
+			defer server.UnsubscribeEvents(et, sub)
+
+			go func(sub event.Subscriber) {
+				for ev := range sub {
+					if ev, ok := ev.(*p2p.PeerEvent); ok {
+						select {
+						case events <- ev:
+						case <-release:
+						}
+					}
+				}
+			}(sub)
+		}
+		defer close(release)
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, ev)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // PublicDebugAPI is the collection of Hpb full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -493,8 +605,9 @@ type BlockTraceResult struct {
 // TraceArgs holds extra parameters to trace functions
 type TraceArgs struct {
 	*evm.LogConfig
-	Tracer  *string
-	Timeout *string
+	Tracer       *string
+	TracerConfig json.RawMessage
+	Timeout      *string
 }
 
 // TraceBlock processes the given block'api RLP but does not import the block in to
@@ -566,6 +679,36 @@ func (api *PrivateDebugAPI) TraceBlockByHash(hash common.Hash, config *evm.LogCo
 	}
 }
 
+// TraceBlockTxsByNumber replays the block by canonical block number and
+// returns the trace of each of its transactions, built by the tracer of the
+// config.
+func (api *PrivateDebugAPI) TraceBlockTxsByNumber(ctx context.Context, blockNr rpc.BlockNumber, config *TraceArgs) ([]*TxTraceResult, error) {
+	var block *types.Block
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		// Pending block is only known by the miner
+		block = api.hpb.miner.PendingBlock()
+	case rpc.LatestBlockNumber:
+		block = api.hpb.Hpbbc.CurrentBlock()
+	default:
+		block = api.hpb.Hpbbc.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	return api.traceBlockTxs(ctx, block, config), nil
+}
+
+// TraceBlockTxsByHash replays the block by hash and returns the trace of each
+// of its transactions, built by the tracer of the config.
+func (api *PrivateDebugAPI) TraceBlockTxsByHash(ctx context.Context, hash common.Hash, config *TraceArgs) ([]*TxTraceResult, error) {
+	block := api.hpb.BlockChain().GetBlockByHash(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block #%x not found", hash)
+	}
+	return api.traceBlockTxs(ctx, block, config), nil
+}
+
 // traceBlock processes the given block but does not save the state.
 func (api *PrivateDebugAPI) traceBlock(block *types.Block, logConfig *evm.LogConfig) (bool, []evm.StructLog, error) {
 	// Validate and reprocess the block
@@ -610,9 +753,26 @@ func (t *timeoutError) Error() string {
 	return "Execution time exceeded"
 }
 
-/*// TraceTransaction returns the structured logs created during the execution of EVM
-// and returns them as a JSON object.
+// TraceTransaction returns the structured logs created during the execution of EVM
+// and returns them as a JSON object. If a tracer is given, it is either the name
+// of a built-in native tracer or a Javascript snippet and its result is returned
+// instead.
 func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.Hash, config *TraceArgs) (interface{}, error) {
+	// Retrieve the tx from the chain and the containing block
+	tx, blockHash, _, txIndex := bc.GetTransaction(api.hpb.ChainDb(), txHash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", txHash)
+	}
+	msg, vmctx, statedb, err := api.computeTxEnv(blockHash, int(txIndex))
+	if err != nil {
+		return nil, err
+	}
+	return api.traceTx(ctx, msg, vmctx, statedb, new(bc.GasPool).AddGas(msg.Gas()), config)
+}
+
+// traceTx applies the given message on statedb with tracing enabled and returns
+// the result of the requested tracer.
+func (api *PrivateDebugAPI) traceTx(ctx context.Context, msg hvm.Message, vmctx evm.Context, statedb *state.StateDB, gp *bc.GasPool, config *TraceArgs) (interface{}, error) {
 	var tracer evm.Tracer
 	if config != nil && config.Tracer != nil {
 		timeout := defaultTraceTimeout
@@ -622,40 +782,28 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 				return nil, err
 			}
 		}
-
-		var err error
-		if tracer, err = hpbapi.NewJavascriptTracer(*config.Tracer); err != nil {
+		custom, err := hpbapi.NewTracer(*config.Tracer, config.TracerConfig)
+		if err != nil {
 			return nil, err
 		}
-
 		// Handle timeouts and RPC cancellations
 		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		go func() {
 			<-deadlineCtx.Done()
-			tracer.(*hpbapi.JavascriptTracer).Stop(&timeoutError{})
+			custom.Stop(&timeoutError{})
 		}()
 		defer cancel()
+
+		tracer = custom
 	} else if config == nil {
 		tracer = evm.NewStructLogger(nil)
 	} else {
 		tracer = evm.NewStructLogger(config.LogConfig)
 	}
 
-	// Retrieve the tx from the chain and the containing block
-	tx, blockHash, _, txIndex := bc.GetTransaction(api.hpb.ChainDb(), txHash)
-	if tx == nil {
-		return nil, fmt.Errorf("transaction %x not found", txHash)
-	}
-	msg, context, statedb, err := api.computeTxEnv(blockHash, int(txIndex))
-	if err != nil {
-		return nil, err
-	}
-
 	// Run the transaction with tracing enabled.
-	vmenv := evm.NewEVM(context, statedb, api.config, evm.Config{Debug: true, Tracer: tracer})
-
-	block := bc.InstanceBlockChain()
-	ret, gas, failed, err := bc.ApplyMessage(block, nil, nil, nil, msg , nil)
+	vmenv := evm.NewEVM(vmctx, statedb, api.config, evm.Config{Debug: true, Tracer: tracer})
+	ret, gas, failed, err := bc.ApplyMessage(vmenv, msg, gp)
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %v", err)
 	}
@@ -667,51 +815,84 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 			ReturnValue: fmt.Sprintf("%x", ret),
 			StructLogs:  hpbapi.FormatLogs(tracer.StructLogs()),
 		}, nil
-	case *hpbapi.JavascriptTracer:
+	case hpbapi.ResultTracer:
 		return tracer.GetResult()
 	default:
 		panic(fmt.Sprintf("bad tracer type %T", tracer))
 	}
-}*/
-/*
-// computeTxEnv returns the execution environment of a certain transaction.
-func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (bc.Message, evm.Context, *state.StateDB, error) {
+}
+
+// parentState returns the state the given block is applied on, together with
+// the address collecting the fees of the block.
+func (api *PrivateDebugAPI) parentState(block *types.Block) (*state.StateDB, common.Address, error) {
+	blockchain := api.hpb.BlockChain()
+	parent := blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, common.Address{}, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	author, err := api.hpb.Hpbengine.Author(block.Header())
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return statedb, author, nil
+}
+
+// nativeTx reports whether the state processor applies the transaction as a
+// plain transfer, without running it through the EVM.
+func (api *PrivateDebugAPI) nativeTx(statedb *state.StateDB, block *types.Block, tx *types.Transaction) bool {
+	if block.NumberU64() > api.config.NewContractVersion() {
+		return !((tx.To() == nil && len(tx.Data()) > 0) || (tx.To() != nil && len(statedb.GetCode(*tx.To())) > 0))
+	}
+	return len(tx.Data()) == 0
+}
+
+// computeTxEnv returns the execution environment of a certain transaction. The
+// preceding transactions of the block are replayed the same way the state
+// processor applies them.
+func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (hvm.Message, evm.Context, *state.StateDB, error) {
 	// Create the parent state.
-	block := api.hpb.BlockChain().GetBlockByHash(blockHash)
+	blockchain := api.hpb.BlockChain()
+	block := blockchain.GetBlockByHash(blockHash)
 	if block == nil {
 		return nil, evm.Context{}, nil, fmt.Errorf("block %x not found", blockHash)
 	}
-	parent := api.hpb.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
-	if parent == nil {
-		return nil, evm.Context{}, nil, fmt.Errorf("block parent %x not found", block.ParentHash())
-	}
-	statedb, err := api.hpb.BlockChain().StateAt(parent.Root())
+	statedb, author, err := api.parentState(block)
 	if err != nil {
 		return nil, evm.Context{}, nil, err
 	}
-	txs := block.Transactions()
-
+	var (
+		header  = block.Header()
+		signer  = types.MakeSigner(api.config)
+		gp      = new(bc.GasPool).AddGas(block.GasLimit())
+		usedGas = new(big.Int)
+	)
 	// Recompute transactions up to the target index.
-	signer := types.MakeSigner(api.config, block.Number())
-	for idx, tx := range txs {
-		// Assemble the transaction call message
-		msg, _ := tx.AsMessage(signer)
-		context := hvm.NewEVMContext(msg, block.Header(), api.hpb.BlockChain(), nil)
+	for idx, tx := range block.Transactions() {
+		statedb.Prepare(tx.Hash(), block.Hash(), idx)
 		if idx == txIndex {
-			return msg, context, statedb, nil
+			// Assemble the transaction call message
+			msg, err := tx.AsMessage(signer)
+			if err != nil {
+				return nil, evm.Context{}, nil, err
+			}
+			return msg, hvm.NewEVMContext(msg, header, blockchain, &author), statedb, nil
+		}
+		if api.nativeTx(statedb, block, tx) {
+			_, _, err = bc.ApplyTransactionNonContractNonFinallize(api.config, blockchain, &author, gp, statedb, header, tx, usedGas)
+		} else {
+			_, _, err = bc.ApplyTransactionNonFinallize(api.config, blockchain, &author, gp, statedb, header, tx, usedGas)
 		}
-
-		vmenv := evm.NewEVM(context, statedb, api.config, evm.Config{})
-		gp := new(bc.GasPool).AddGas(tx.Gas())
-		_, _, _, err := bc.ApplyMessage(vmenv, msg, gp)
 		if err != nil {
 			return nil, evm.Context{}, nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
 		}
-		statedb.DeleteSuicides()
 	}
 	return nil, evm.Context{}, nil, fmt.Errorf("tx index %d out of range for block %x", txIndex, blockHash)
 }
-*/
+
 // Preimage is a debug API function that returns the preimage for a sha3 hash, if known.
 func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
 	db := bc.PreimageTable(api.hpb.ChainDb())
@@ -737,7 +918,6 @@ type storageEntry struct {
 	Value common.Hash  `json:"value"`
 }
 
-/*
 // StorageRangeAt returns the storage at the given block height and transaction index.
 func (api *PrivateDebugAPI) StorageRangeAt(ctx context.Context, blockHash common.Hash, txIndex int, contractAddress common.Address, keyStart hexutil.Bytes, maxResult int) (StorageRangeResult, error) {
 	_, _, statedb, err := api.computeTxEnv(blockHash, txIndex)
@@ -750,7 +930,7 @@ func (api *PrivateDebugAPI) StorageRangeAt(ctx context.Context, blockHash common
 	}
 	return storageRangeAt(st, keyStart, maxResult), nil
 }
-*/
+
 func storageRangeAt(st state.Trie, start []byte, maxResult int) StorageRangeResult {
 	it := trie.NewIterator(st.NodeIterator(start))
 	result := StorageRangeResult{Storage: storageMap{}}
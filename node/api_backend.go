@@ -142,7 +142,7 @@ func (b *HpbApiBackend) SubscribeLogsEvent(ch chan<- []*types.Log) sub.Subscript
 }
 
 func (b *HpbApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
-	return b.hpb.TxPool().AddTx(signedTx)
+	return b.hpb.TxPool().AddLocal(signedTx)
 }
 
 func (b *HpbApiBackend) GetPoolTransactions() (types.Transactions, error) {
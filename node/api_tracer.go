@@ -0,0 +1,290 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"runtime"
+
+	bc "github.com/hpb-project/go-hpb/blockchain"
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/hexutil"
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/hvm"
+	"github.com/hpb-project/go-hpb/internal/hpbapi"
+	"github.com/hpb-project/go-hpb/network/rpc"
+)
+
+// OverrideAccount specifies the state of an account to be replaced before a
+// call is traced. Only the given fields are overridden, storage slots which are
+// not listed keep their current value.
+type OverrideAccount struct {
+	Nonce   *hexutil.Uint64             `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Balance *hexutil.Big                `json:"balance"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// StateOverride is the collection of overridden accounts.
+type StateOverride map[common.Address]OverrideAccount
+
+// Apply overrides the fields of the specified accounts in statedb.
+func (diff StateOverride) Apply(statedb *state.StateDB) {
+	for addr, account := range diff {
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, uint64(*account.Nonce))
+		}
+		if account.Code != nil {
+			statedb.SetCode(addr, *account.Code)
+		}
+		if account.Balance != nil {
+			statedb.SetBalance(addr, (*big.Int)(account.Balance))
+		}
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+}
+
+// TraceCallConfig holds the parameters of a traced call.
+type TraceCallConfig struct {
+	TraceArgs
+	StateOverrides StateOverride
+}
+
+// TraceCall traces the execution of a call on top of the state of the given
+// block, as if it was a transaction included right after it. The state can be
+// altered beforehand through the state overrides of the config. The gas limit
+// of the block is used if the call specifies no gas.
+func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args hpbapi.CallArgs, blockNr rpc.BlockNumber, config *TraceCallConfig) (interface{}, error) {
+	statedb, header, err := api.hpb.ApiBackend.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || header == nil || err != nil {
+		if err == nil {
+			err = fmt.Errorf("block #%d not found", blockNr)
+		}
+		return nil, err
+	}
+	var traceArgs *TraceArgs
+	if config != nil {
+		config.StateOverrides.Apply(statedb)
+		traceArgs = &config.TraceArgs
+	}
+	gas := args.Gas.ToInt()
+	if gas.Sign() == 0 {
+		gas = new(big.Int).Set(header.GasLimit)
+	}
+	msg := types.NewMessage(args.From, args.To, 0, args.Value.ToInt(), gas, args.GasPrice.ToInt(), args.Data, args.ExData, false)
+	vmctx := hvm.NewEVMContext(msg, header, api.hpb.BlockChain(), &header.Coinbase)
+
+	return api.traceTx(ctx, msg, vmctx, statedb, new(bc.GasPool).AddGas(gas), traceArgs)
+}
+
+// TxTraceResult is the trace of a single transaction streamed by TraceChain.
+type TxTraceResult struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	TxIndex     hexutil.Uint   `json:"transactionIndex"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	Result      interface{}    `json:"result,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// blockTraceTask is a block traced by one of the TraceChain workers.
+type blockTraceTask struct {
+	block   *types.Block
+	results []*TxTraceResult
+}
+
+// TraceChain creates a subscription streaming the traces of every transaction
+// in the blocks start to end, both inclusive. The blocks are replayed on a pool
+// of worker goroutines, the traces are nonetheless delivered in chain order.
+func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceArgs) (*rpc.Subscription, error) {
+	from, err := api.resolveBlockNumber(start)
+	if err != nil {
+		return nil, err
+	}
+	to, err := api.resolveBlockNumber(end)
+	if err != nil {
+		return nil, err
+	}
+	if from == 0 || from > to {
+		return nil, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		var (
+			threads = runtime.NumCPU()
+			tasks   = make(chan *blockTraceTask)
+			done    = make(chan *blockTraceTask)
+			quit    = make(chan struct{})
+		)
+		defer close(quit)
+
+		if blocks := int(to - from + 1); threads > blocks {
+			threads = blocks
+		}
+		// The traces are not tied to the RPC request, interrupt them only once
+		// the subscription is dropped
+		traceCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		for i := 0; i < threads; i++ {
+			go func() {
+				for task := range tasks {
+					task.results = api.traceBlockTxs(traceCtx, task.block, config)
+					select {
+					case done <- task:
+					case <-quit:
+						return
+					}
+				}
+			}()
+		}
+		// Feed the blocks to the workers in the background
+		go func() {
+			defer close(tasks)
+			for number := from; number <= to; number++ {
+				block := api.hpb.BlockChain().GetBlockByNumber(number)
+				if block == nil {
+					log.Warn("Chain tracing stopped at missing block", "number", number)
+					return
+				}
+				select {
+				case tasks <- &blockTraceTask{block: block}:
+				case <-quit:
+					return
+				}
+			}
+		}()
+		// Deliver the traces in order, buffering the ones finished ahead of time
+		var (
+			next    = from
+			pending = make(map[uint64]*blockTraceTask)
+		)
+		for next <= to {
+			select {
+			case task := <-done:
+				pending[task.block.NumberU64()] = task
+				for {
+					ready, ok := pending[next]
+					if !ok {
+						break
+					}
+					for _, result := range ready.results {
+						notifier.Notify(rpcSub.ID, result)
+					}
+					delete(pending, next)
+					next++
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// resolveBlockNumber maps an RPC block number to a canonical block number.
+func (api *PrivateDebugAPI) resolveBlockNumber(number rpc.BlockNumber) (uint64, error) {
+	switch number {
+	case rpc.PendingBlockNumber:
+		return 0, fmt.Errorf("pending block not supported")
+	case rpc.LatestBlockNumber:
+		return api.hpb.BlockChain().CurrentBlock().NumberU64(), nil
+	default:
+		return uint64(number), nil
+	}
+}
+
+// traceBlockTxs replays all the transactions of a block on its parent state and
+// returns the trace of each one of them. Plain transfers are traced through the
+// EVM on a snapshot which is reverted afterwards, so that the replayed state
+// matches the one produced by the state processor exactly. The gas of the
+// traced contract transactions is charged to the block like the processor does.
+func (api *PrivateDebugAPI) traceBlockTxs(ctx context.Context, block *types.Block, config *TraceArgs) []*TxTraceResult {
+	var (
+		txs     = block.Transactions()
+		results = make([]*TxTraceResult, len(txs))
+	)
+	for i, tx := range txs {
+		results[i] = &TxTraceResult{
+			BlockNumber: hexutil.Uint64(block.NumberU64()),
+			BlockHash:   block.Hash(),
+			TxIndex:     hexutil.Uint(i),
+			TxHash:      tx.Hash(),
+		}
+	}
+	fail := func(from int, err error) []*TxTraceResult {
+		for _, result := range results[from:] {
+			result.Error = err.Error()
+		}
+		return results
+	}
+	statedb, author, err := api.parentState(block)
+	if err != nil {
+		return fail(0, err)
+	}
+	var (
+		blockchain = api.hpb.BlockChain()
+		header     = block.Header()
+		signer     = types.MakeSigner(api.config)
+		gp         = new(bc.GasPool).AddGas(block.GasLimit())
+		usedGas    = new(big.Int)
+	)
+	for i, tx := range txs {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			return fail(i, err)
+		}
+		vmctx := hvm.NewEVMContext(msg, header, blockchain, &author)
+
+		if api.nativeTx(statedb, block, tx) {
+			snapshot := statedb.Snapshot()
+			results[i].Result, err = api.traceTx(ctx, msg, vmctx, statedb, new(bc.GasPool).AddGas(msg.Gas()), config)
+			statedb.RevertToSnapshot(snapshot)
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+			if _, _, err = bc.ApplyTransactionNonContractNonFinallize(api.config, blockchain, &author, gp, statedb, header, tx, usedGas); err != nil {
+				return fail(i+1, fmt.Errorf("tx %x failed: %v", tx.Hash(), err))
+			}
+			continue
+		}
+		available := new(big.Int).Set((*big.Int)(gp))
+		if results[i].Result, err = api.traceTx(ctx, msg, vmctx, statedb, gp, config); err != nil {
+			// The remaining transactions depend on the state of the failed one
+			return fail(i, err)
+		}
+		usedGas.Add(usedGas, available.Sub(available, (*big.Int)(gp)))
+		statedb.ClearRefund()
+	}
+	return results
+}
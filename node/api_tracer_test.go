@@ -0,0 +1,68 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+)
+
+func TestStateOverrideApply(t *testing.T) {
+	db, _ := hpbdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	addr := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	statedb.SetNonce(addr, 5)
+	statedb.SetBalance(addr, big.NewInt(100))
+	statedb.SetState(addr, common.Hash{1}, common.Hash{1})
+	statedb.SetState(addr, common.Hash{2}, common.Hash{2})
+
+	var config TraceCallConfig
+	blob := `{"tracer": "callTracer", "stateOverrides": {"0x1000000000000000000000000000000000000001": {
+		"balance": "0x2a",
+		"code": "0x6000",
+		"storage": {"0x0200000000000000000000000000000000000000000000000000000000000000": "0x0300000000000000000000000000000000000000000000000000000000000000"}
+	}}}`
+	if err := json.Unmarshal([]byte(blob), &config); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if config.Tracer == nil || *config.Tracer != "callTracer" {
+		t.Fatalf("tracer mismatch: have %v", config.Tracer)
+	}
+	config.StateOverrides.Apply(statedb)
+
+	if nonce := statedb.GetNonce(addr); nonce != 5 {
+		t.Errorf("nonce mismatch: have %d, want 5", nonce)
+	}
+	if balance := statedb.GetBalance(addr); balance.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 42", balance)
+	}
+	if code := statedb.GetCode(addr); len(code) != 2 {
+		t.Errorf("code mismatch: have %x, want 6000", code)
+	}
+	if value := statedb.GetState(addr, common.Hash{1}); value != (common.Hash{1}) {
+		t.Errorf("untouched slot mismatch: have %x", value)
+	}
+	if value := statedb.GetState(addr, common.Hash{2}); value != (common.Hash{3}) {
+		t.Errorf("overridden slot mismatch: have %x", value)
+	}
+}
@@ -60,6 +60,13 @@ func OpenDatabase(name string, cache int, handles int) (hpbdb.Database, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Attach the flat file store holding the finalized chain segments
+	freezer, err := hpbdb.OpenFreezer(db.Path())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	db.SetFreezer(freezer)
 	DBINSTANCE.Store(db)
 
 	return db, nil
@@ -0,0 +1,248 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	bc "github.com/hpb-project/go-hpb/blockchain"
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/config"
+	"github.com/hpb-project/go-hpb/network/rpc"
+)
+
+var (
+	testAccount  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testNode     = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	testContract = common.HexToAddress("0x3000000000000000000000000000000000000003")
+	testTopic    = common.HexToHash("0x0400000000000000000000000000000000000000000000000000000000000004")
+)
+
+// testBackend serves a generated chain from an in-memory database, the methods
+// the GraphQL service doesn't need are left to the nil embedded interface.
+type testBackend struct {
+	Backend
+	db    hpbdb.Database
+	chain []*types.Block
+}
+
+func newTestBackend(t *testing.T) *testBackend {
+	db, _ := hpbdb.NewMemDatabase()
+
+	extra, _ := types.NewExtraDetail(types.ExtraVersion)
+	extra.SetNodes(common.Addresses{testNode})
+	extra.SetRealRND(bytes.Repeat([]byte{0x01}, types.ExtraRealRNDLength))
+
+	genesis := (&bc.Genesis{
+		ExtraData: extra.ToBytes(),
+		Alloc:     bc.GenesisAlloc{testAccount: {Balance: big.NewInt(1000)}},
+	}).MustCommit(db)
+
+	blocks, receipts := bc.GenerateChain(&config.ChainConfig{}, genesis, db, 3, func(i int, gen *bc.BlockGen) {
+		if i == 1 {
+			receipt := types.NewReceipt(nil, false, new(big.Int))
+			receipt.Logs = []*types.Log{{Address: testContract, Topics: []common.Hash{testTopic}, Data: []byte{0x42}}}
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	td := genesis.Difficulty()
+	for i, block := range blocks {
+		td = new(big.Int).Add(td, block.Difficulty())
+		if err := bc.WriteTd(db, block.Hash(), block.NumberU64(), td); err != nil {
+			t.Fatalf("failed to write total difficulty: %v", err)
+		}
+		if err := bc.WriteBlock(db, block); err != nil {
+			t.Fatalf("failed to write block: %v", err)
+		}
+		if err := bc.WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+			t.Fatalf("failed to write canonical hash: %v", err)
+		}
+		if err := bc.WriteHeadBlockHash(db, block.Hash()); err != nil {
+			t.Fatalf("failed to write head hash: %v", err)
+		}
+		if err := bc.WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts[i]); err != nil {
+			t.Fatalf("failed to write receipts: %v", err)
+		}
+	}
+	return &testBackend{db: db, chain: append([]*types.Block{genesis}, blocks...)}
+}
+
+func (b *testBackend) ChainDb() hpbdb.Database { return b.db }
+
+func (b *testBackend) CurrentBlock() *types.Block { return b.chain[len(b.chain)-1] }
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.CurrentBlock(), nil
+	}
+	if number < 0 || int(number) >= len(b.chain) {
+		return nil, nil
+	}
+	return b.chain[number], nil
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	block, _ := b.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, nil
+	}
+	return block.Header(), nil
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return bc.GetBlock(b.db, hash, bc.GetBlockNumber(b.db, hash)), nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return bc.GetBlockReceipts(b.db, hash, bc.GetBlockNumber(b.db, hash)), nil
+}
+
+func (b *testBackend) GetTd(hash common.Hash) *big.Int {
+	return bc.GetTd(b.db, hash, bc.GetBlockNumber(b.db, hash))
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, _ := b.HeaderByNumber(ctx, number)
+	if header == nil {
+		return nil, nil, nil
+	}
+	statedb, err := state.New(header.Root, state.NewDatabase(b.db))
+	return statedb, header, err
+}
+
+func (b *testBackend) GetPoolTransactions() (types.Transactions, error) { return nil, nil }
+
+func (b *testBackend) GetPoolTransaction(hash common.Hash) *types.Transaction { return nil }
+
+func (b *testBackend) BloomStatus() (uint64, uint64) { return config.BloomBitsBlocks, 0 }
+
+// query posts a GraphQL query to the handler and returns the response code and
+// the decoded response.
+func query(t *testing.T, handler http.Handler, q string) (int, interface{}) {
+	body, _ := json.Marshal(map[string]string{"query": q})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+
+	var result interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("query %q: invalid response %q: %v", q, rec.Body.String(), err)
+	}
+	return rec.Code, result
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	backend := newTestBackend(t)
+	handler, err := NewHandler(backend)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	genesis := backend.chain[0]
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: `{ block(number: 0) { number hash extraDetail { version nodesAddr realRND } } }`,
+			want: fmt.Sprintf(`{"data":{"block":{"number":0,"hash":"%s","extraDetail":{"version":1,"nodesAddr":["%s"],"realRND":"0x%x"}}}}`,
+				genesis.Hash().Hex(), hexAddress(testNode), bytes.Repeat([]byte{0x01}, types.ExtraRealRNDLength)),
+		},
+		{
+			query: `{ block { number parent { hash } } }`,
+			want:  fmt.Sprintf(`{"data":{"block":{"number":3,"parent":{"hash":"%s"}}}}`, backend.chain[2].Hash().Hex()),
+		},
+		{
+			query: fmt.Sprintf(`{ block(hash: "%s") { number totalDifficulty } }`, backend.chain[1].Hash().Hex()),
+			want:  `{"data":{"block":{"number":1,"totalDifficulty":"0x` + backend.GetTd(backend.chain[1].Hash()).Text(16) + `"}}}`,
+		},
+		{
+			query: `{ block(number: 9) { number } }`,
+			want:  `{"data":{"block":null}}`,
+		},
+		{
+			query: `{ blocks(from: 1) { number transactionCount } }`,
+			want:  `{"data":{"blocks":[{"number":1,"transactionCount":0},{"number":2,"transactionCount":0},{"number":3,"transactionCount":0}]}}`,
+		},
+		{
+			query: fmt.Sprintf(`{ block(number: 0) { account(address: "%s") { balance transactionCount } } }`, hexAddress(testAccount)),
+			want:  `{"data":{"block":{"account":{"balance":"0x3e8","transactionCount":0}}}}`,
+		},
+		{
+			query: `{ block(number: 2) { logs(filter: {}) { index topics data account { address } } } }`,
+			want: fmt.Sprintf(`{"data":{"block":{"logs":[{"index":0,"topics":["%s"],"data":"0x42","account":{"address":"%s"}}]}}}`,
+				testTopic.Hex(), hexAddress(testContract)),
+		},
+		{
+			query: fmt.Sprintf(`{ logs(filter: {fromBlock: 0, addresses: ["%s"]}) { topics } }`, hexAddress(testContract)),
+			want:  fmt.Sprintf(`{"data":{"logs":[{"topics":["%s"]}]}}`, testTopic.Hex()),
+		},
+		{
+			query: `{ pending { transactionCount } }`,
+			want:  `{"data":{"pending":{"transactionCount":0}}}`,
+		},
+	}
+	for i, tt := range tests {
+		code, have := query(t, handler, tt.query)
+		if code != http.StatusOK {
+			t.Errorf("test %d: status mismatch: have %d, want %d (%v)", i, code, http.StatusOK, have)
+			continue
+		}
+		var want interface{}
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatalf("test %d: invalid expected result: %v", i, err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("test %d: result mismatch:\nhave %v\nwant %v", i, have, want)
+		}
+	}
+}
+
+func TestGraphQLRequestErrors(t *testing.T) {
+	handler, err := NewHandler(newTestBackend(t))
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	if code, _ := query(t, handler, `{ block { unknownField } }`); code != http.StatusBadRequest {
+		t.Errorf("invalid query status mismatch: have %d, want %d", code, http.StatusBadRequest)
+	}
+	if code, _ := query(t, handler, `{ blocks(from: 0, to: 5000) { number } }`); code != http.StatusBadRequest {
+		t.Errorf("oversized range status mismatch: have %d, want %d", code, http.StatusBadRequest)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphql", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status mismatch: have %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+// hexAddress returns the lower case hex encoding of an address, as marshalled
+// by the GraphQL service.
+func hexAddress(addr common.Address) string {
+	return fmt.Sprintf("0x%x", addr[:])
+}
@@ -36,6 +36,7 @@ import (
 	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/common/metrics"
 	"github.com/hpb-project/go-hpb/common/rlp"
 	"github.com/hpb-project/go-hpb/network/p2p"
 	"github.com/hpb-project/go-hpb/network/rpc"
@@ -55,6 +56,7 @@ import (
 	"github.com/hpb-project/go-hpb/internal/hpbapi"
 	"github.com/hpb-project/go-hpb/node/db"
 	"github.com/hpb-project/go-hpb/node/gasprice"
+	"github.com/hpb-project/go-hpb/node/graphql"
 	"github.com/hpb-project/go-hpb/worker"
 )
 
@@ -154,6 +156,16 @@ func New(conf *config.HpbConfig) (*Node, error) {
 	log.Info("Initialising Hpb node", "network", conf.Node.NetworkId)
 
 	hpbdatabase, _ := db.CreateDB(&conf.Node, "chaindata")
+	// Pick up the fork heights written along with the genesis block, flags
+	// and config file settings take precedence.
+	if stored := bc.GetCanonicalHash(hpbdatabase, 0); stored != (common.Hash{}) {
+		if storedcfg, err := bc.GetChainConfig(hpbdatabase, stored); err == nil {
+			conf.BlockChain.MergeForks(storedcfg)
+		}
+	}
+	if err := conf.BlockChain.CheckRandomSource(); err != nil {
+		return nil, err
+	}
 	// Ensure that the AccountManager method works before the node has started.
 	// We rely on this in cmd/geth.
 	am, _, err := makeAccountManager(&conf.Node)
@@ -163,8 +175,11 @@ func New(conf *config.HpbConfig) (*Node, error) {
 	hpbnode.accman = am
 
 	hpbnode.Hpbboe = boe.BoeGetInstance()
-	err = hpbnode.Hpbboe.Init()
-	if err != nil {
+	if conf.BlockChain.Dev {
+		// Developer chains generate the header random chain in software
+		log.Info("Developer chain, skip boe init")
+		hpbnode.Boeflag = 0
+	} else if err = hpbnode.Hpbboe.Init(); err != nil {
 		log.Warn("Boe init fail.")
 		hpbnode.Boeflag = 0
 	} else {
@@ -201,6 +216,9 @@ func New(conf *config.HpbConfig) (*Node, error) {
 
 	peermanager.RegChanStatus(hpbnode.Hpbbc.Status)
 
+	if conf.TxPool.Journal != "" {
+		conf.TxPool.Journal = conf.Node.ResolvePath(conf.TxPool.Journal)
+	}
 	txpool.NewTxPool(conf.TxPool, &conf.BlockChain, hpbnode.Hpbbc)
 	hpbtxpool := txpool.GetTxPool()
 
@@ -221,7 +239,7 @@ func (hpbnode *Node) WorkerInit(conf *config.HpbConfig) error {
 	if stored != (common.Hash{}) {
 		if !conf.Node.SkipBcVersionCheck {
 			bcVersion := bc.GetBlockChainVersion(hpbnode.HpbDb)
-			if bcVersion != bc.BlockChainVersion && bcVersion != 0 {
+			if bcVersion != bc.BlockChainVersion && bcVersion != bc.LegacyBlockChainVersion && bcVersion != 0 {
 				return fmt.Errorf("Blockchain DB version mismatch (%d / %d). Run geth upgradedb.\n", bcVersion, bc.BlockChainVersion)
 			}
 			bc.WriteBlockChainVersion(hpbnode.HpbDb, bc.BlockChainVersion)
@@ -234,6 +252,9 @@ func (hpbnode *Node) WorkerInit(conf *config.HpbConfig) error {
 			log.Error("add engine to blockchain error")
 			return err
 		}
+		if conf.Node.ParallelTxs > 1 {
+			hpbnode.Hpbbc.SetProcessor(bc.NewParallelStateProcessor(hpbnode.Hpbbc.Config(), hpbnode.Hpbbc, engine, conf.Node.ParallelTxs))
+		}
 		hpbnode.Hpbsyncctr = synctrl.InstanceSynCtrl()
 		hpbnode.newBlockMux = hpbnode.Hpbsyncctr.NewBlockMux()
 
@@ -298,7 +319,7 @@ func (hpbnode *Node) Start(conf *config.HpbConfig) error {
 	}
 
 	if config.GetHpbConfigInstance().Node.TestCodeParam == 1 {
-		consensus.SetTestParam()
+		conf.BlockChain.SetTestParam()
 	}
 
 	log.Info("consensus.HpbNodenumber", "value", consensus.HpbNodenumber)
@@ -309,6 +330,12 @@ func (hpbnode *Node) Start(conf *config.HpbConfig) error {
 		log.Info("config.MainnetBootnodes", "value", v)
 	}
 
+	if conf.Metrics.Enabled {
+		if err := metrics.Start(conf.Metrics.ListenAddr, conf.Metrics.Namespace); err != nil {
+			log.Error("Failed to start metrics exporter", "err", err)
+			return err
+		}
+	}
 	hpbnode.startBloomHandlers()
 
 	err := hpbnode.WorkerInit(conf)
@@ -321,6 +348,10 @@ func (hpbnode *Node) Start(conf *config.HpbConfig) error {
 		return errors.New("synctrl is nil")
 	}
 	hpbnode.Hpbsyncctr.Start()
+	if conf.Network.AuthMode == config.AuthModeKey {
+		log.Info("Authenticate peers with the coinbase key", "coinbase", hpbnode.hpberbase)
+		hpbnode.Hpbpeermanager.SetAuthenticator(p2p.NewKeyAuthenticator(hpbnode.signCoinbaseHash))
+	}
 	retval := hpbnode.Hpbpeermanager.Start(hpbnode.hpberbase)
 	if retval != nil {
 		log.Error("Start hpbpeermanager error")
@@ -329,6 +360,14 @@ func (hpbnode *Node) Start(conf *config.HpbConfig) error {
 	hpbnode.Hpbpeermanager.RegStatMining(hpbnode.miner.Mining)
 
 	hpbnode.SetNodeAPI()
+	if conf.Network.GraphQLEnabled {
+		handler, err := graphql.NewHandler(hpbnode.ApiBackend)
+		if err != nil {
+			log.Error("Failed to create GraphQL service", "err", err)
+			return err
+		}
+		hpbnode.Hpbrpcmanager.RegisterHTTPHandler("/graphql", handler)
+	}
 	hpbnode.Hpbrpcmanager.Start(hpbnode.RpcAPIs)
 	hpbnode.Hpbtxpool.Start()
 
@@ -371,7 +410,12 @@ func makeAccountManager(conf *config.Nodeconfig) (*accounts.Manager, string, err
 	if err := os.MkdirAll(keydir, 0700); err != nil {
 		return nil, "", err
 	}
-	return accounts.NewManager(keystore.NewKeyStore(keydir, scryptN, scryptP)), ephemeral, nil
+	// HD wallet seeds live in a subdirectory, hidden from the plain keystore
+	backends := []accounts.Backend{
+		keystore.NewKeyStore(keydir, scryptN, scryptP),
+		keystore.NewHDKeyStore(filepath.Join(keydir, config.DatadirDefaultHDKeyStore), scryptN, scryptP),
+	}
+	return accounts.NewManager(backends...), ephemeral, nil
 }
 
 func (n *Node) openDataDir() error {
@@ -405,6 +449,7 @@ func (n *Node) Stop() error {
 	n.Hpbtxpool.Stop()
 	n.miner.Stop()
 	n.Hpbpeermanager.Stop()
+	n.Hpbbc.Stop()
 
 	n.Hpbrpcmanager.Stop()
 	n.HpbDb.Close()
@@ -416,6 +461,13 @@ func (n *Node) Stop() error {
 		}
 		n.instanceDirLock = nil
 	}
+	// Remove the datadir if it was created for this run only, before n.Wait
+	// lets the process exit.
+	if n.Hpbconfig.Node.EphemeralDataDir {
+		if err := os.RemoveAll(n.Hpbconfig.Node.DataDir); err != nil {
+			log.Error("Can't remove ephemeral datadir", "err", err)
+		}
+	}
 
 	// unblock n.Wait
 	close(n.stop)
@@ -578,6 +630,17 @@ func (self *Node) SetHpberbase(hpberbase common.Address) {
 	self.lock.Unlock()
 }
 
+// signCoinbaseHash signs a hash with the coinbase account for the key based
+// peer authentication, the account must be unlocked.
+func (s *Node) signCoinbaseHash(hash []byte) ([]byte, error) {
+	account := accounts.Account{Address: s.hpberbase}
+	wallet, err := s.accman.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	return wallet.SignHash(account, hash)
+}
+
 func (s *Node) StartMining(local bool) error {
 	//read coinbase from node
 	eb := s.hpberbase
@@ -22,7 +22,9 @@ import (
 	"time"
 
 	bc "github.com/hpb-project/go-hpb/blockchain"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
 	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
 	"github.com/hpb-project/go-hpb/common/log"
 	"github.com/hpb-project/go-hpb/config"
 	"github.com/hpb-project/go-hpb/consensus"
@@ -32,6 +34,7 @@ import (
 	"github.com/hpb-project/go-hpb/network/p2p/discover"
 	"github.com/hpb-project/go-hpb/node/db"
 	"github.com/hpb-project/go-hpb/txpool"
+	"gopkg.in/fatih/set.v0"
 )
 
 const (
@@ -43,6 +46,8 @@ const (
 	// This is the target size for the packs of transactions sent by txsyncLoop.
 	// A pack can get larger than this if a single transactions exceeds this size.
 	txsyncPackSize = 100 * 1024
+	// This is the maximum number of transactions routed together by txRoutingLoop.
+	txRouteBatch = 1024
 )
 
 var (
@@ -62,8 +67,16 @@ type SynCtrl struct {
 	chainconfig *config.ChainConfig
 	maxPeers    int
 
-	syner  *Syncer
-	puller *Puller
+	chain   *bc.BlockChain   // Local chain served to and synced from the peers
+	chaindb hpbdb.Database   // Database of the local chain
+	peers   *p2p.PeerManager // Peers of the node
+
+	handleKnownBlocks *set.Set                // Propagated blocks already scheduled for import
+	poolTxsCh         chan *types.Transaction // Transactions received from the peers, batched into the pool
+
+	syner     *Syncer
+	puller    *Puller
+	txFetcher *TxFetcher
 
 	SubProtocols []p2p.Protocol
 
@@ -86,7 +99,8 @@ type SynCtrl struct {
 // InstanceSynCtrl returns the singleton of SynCtrl.
 func InstanceSynCtrl() *SynCtrl {
 	once.Do(func() {
-		i, err := newSynCtrl(&config.GetHpbConfigInstance().BlockChain, config.GetHpbConfigInstance().Node.SyncMode, txpool.GetTxPool(), prometheus.InstancePrometheus())
+		i, err := NewSynCtrl(&config.GetHpbConfigInstance().BlockChain, config.GetHpbConfigInstance().Node.SyncMode, txpool.GetTxPool(), prometheus.InstancePrometheus(),
+			bc.InstanceBlockChain(), db.GetHpbDbInstance(), p2p.PeerMgrInst())
 		if err != nil {
 			log.Error("Failed to instance SynCtrl", "err", err)
 		}
@@ -95,20 +109,27 @@ func InstanceSynCtrl() *SynCtrl {
 	return syncInstance
 }
 
-// NewSynCtrl returns a new block synchronization controller.
-func newSynCtrl(cfg *config.ChainConfig, mode config.SyncMode, txpoolins *txpool.TxPool,
-	engine consensus.Engine) (*SynCtrl, error) {
+// NewSynCtrl returns a new block synchronization controller of the given chain,
+// registering its message handlers on the peer manager.
+func NewSynCtrl(cfg *config.ChainConfig, mode config.SyncMode, txpoolins *txpool.TxPool,
+	engine consensus.Engine, chain *bc.BlockChain, chaindb hpbdb.Database, peers *p2p.PeerManager) (*SynCtrl, error) {
 	synctrl := &SynCtrl{
 		newBlockMux: new(sub.TypeMux),
 		txpool:      txpoolins,
 		chainconfig: cfg,
+		chain:       chain,
+		chaindb:     chaindb,
+		peers:       peers,
 		newPeerCh:   make(chan *p2p.Peer),
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
+
+		handleKnownBlocks: set.New(),
+		poolTxsCh:         make(chan *types.Transaction, 2000),
 	}
 
-	if mode == config.FastSync && bc.InstanceBlockChain().CurrentBlock().NumberU64() > 0 {
+	if mode == config.FastSync && chain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = config.FullSync
 	}
@@ -116,13 +137,13 @@ func newSynCtrl(cfg *config.ChainConfig, mode config.SyncMode, txpoolins *txpool
 		synctrl.fastSync = uint32(1)
 	}
 	// Construct the different synchronisation mechanisms
-	synctrl.syner = NewSyncer(mode, db.GetHpbDbInstance(), synctrl.newBlockMux, nil, synctrl.removePeer)
+	synctrl.syner = NewSyncer(mode, chaindb, synctrl.newBlockMux, chain, synctrl.removePeer)
 
 	validator := func(header *types.Header) error {
-		return engine.VerifyHeader(bc.InstanceBlockChain(), header, true, mode)
+		return engine.VerifyHeader(chain, header, true, mode)
 	}
 	heighter := func() uint64 {
-		return bc.InstanceBlockChain().CurrentBlock().NumberU64()
+		return chain.CurrentBlock().NumberU64()
 	}
 	inserter := func(blocks types.Blocks) (int, error) {
 		// If fast sync is running, deny importing weird blocks
@@ -131,28 +152,49 @@ func newSynCtrl(cfg *config.ChainConfig, mode config.SyncMode, txpoolins *txpool
 			return 0, nil
 		}
 		atomic.StoreUint32(&synctrl.AcceptTxs, 1) // Mark initial sync done on any fetcher import
-		return bc.InstanceBlockChain().InsertChain(blocks)
+		return chain.InsertChain(blocks)
+	}
+	synctrl.puller = NewPuller(chain.GetBlockByHash, peers.Peer, validator, synctrl.routBlock, heighter, inserter, synctrl.removePeer)
+
+	hasTx := func(hash common.Hash) bool {
+		return txpoolins.GetTxByHash(hash) != nil
+	}
+	fetchTxs := func(id string, hashes []common.Hash) error {
+		peer := peers.Peer(id)
+		if peer == nil {
+			return errUnknownPeer
+		}
+		return requestPooledTxs(peer, hashes)
 	}
-	synctrl.puller = NewPuller(bc.InstanceBlockChain().GetBlockByHash, validator, routBlock, heighter, inserter, synctrl.removePeer)
-
-	p2p.PeerMgrInst().RegMsgProcess(p2p.GetBlockHeadersMsg, HandleGetBlockHeadersMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.GetBlockBodiesMsg, HandleGetBlockBodiesMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.BlockHeadersMsg, HandleBlockHeadersMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.BlockBodiesMsg, HandleBlockBodiesMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.GetNodeDataMsg, HandleGetNodeDataMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.NodeDataMsg, HandleNodeDataMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.GetReceiptsMsg, HandleGetReceiptsMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.ReceiptsMsg, HandleReceiptsMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.NewBlockHashesMsg, HandleNewBlockHashesMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.NewBlockMsg, HandleNewBlockMsg)
-	p2p.PeerMgrInst().RegMsgProcess(p2p.NewHashBlockMsg, HandleNewHashBlockMsg)
-
-	p2p.PeerMgrInst().RegMsgProcess(p2p.TxMsg, HandleTxMsg)
-
-	p2p.PeerMgrInst().RegOnAddPeer(synctrl.RegisterNetPeer)
-	p2p.PeerMgrInst().RegOnDropPeer(synctrl.UnregisterNetPeer)
-
-	go TxsPoolLoop()
+	synctrl.txFetcher = NewTxFetcher(hasTx, fetchTxs)
+
+	peers.RegMsgProcess(p2p.GetBlockHeadersMsg, synctrl.HandleGetBlockHeadersMsg)
+	peers.RegMsgProcess(p2p.GetBlockBodiesMsg, synctrl.HandleGetBlockBodiesMsg)
+	peers.RegMsgProcess(p2p.BlockHeadersMsg, synctrl.HandleBlockHeadersMsg)
+	peers.RegMsgProcess(p2p.BlockBodiesMsg, synctrl.HandleBlockBodiesMsg)
+	peers.RegMsgProcess(p2p.GetNodeDataMsg, synctrl.HandleGetNodeDataMsg)
+	peers.RegMsgProcess(p2p.NodeDataMsg, synctrl.HandleNodeDataMsg)
+	peers.RegMsgProcess(p2p.GetAccountRangeMsg, synctrl.HandleGetAccountRangeMsg)
+	peers.RegMsgProcess(p2p.AccountRangeMsg, synctrl.HandleAccountRangeMsg)
+	peers.RegMsgProcess(p2p.GetStorageRangesMsg, synctrl.HandleGetStorageRangesMsg)
+	peers.RegMsgProcess(p2p.StorageRangesMsg, synctrl.HandleStorageRangesMsg)
+	peers.RegMsgProcess(p2p.GetByteCodesMsg, synctrl.HandleGetByteCodesMsg)
+	peers.RegMsgProcess(p2p.ByteCodesMsg, synctrl.HandleByteCodesMsg)
+	peers.RegMsgProcess(p2p.GetReceiptsMsg, synctrl.HandleGetReceiptsMsg)
+	peers.RegMsgProcess(p2p.ReceiptsMsg, synctrl.HandleReceiptsMsg)
+	peers.RegMsgProcess(p2p.NewBlockHashesMsg, synctrl.HandleNewBlockHashesMsg)
+	peers.RegMsgProcess(p2p.NewBlockMsg, synctrl.HandleNewBlockMsg)
+	peers.RegMsgProcess(p2p.NewHashBlockMsg, synctrl.HandleNewHashBlockMsg)
+
+	peers.RegMsgProcess(p2p.TxMsg, synctrl.HandleTxMsg)
+	peers.RegMsgProcess(p2p.NewTxHashesMsg, synctrl.HandleNewTxHashesMsg)
+	peers.RegMsgProcess(p2p.GetPooledTxsMsg, synctrl.HandleGetPooledTxsMsg)
+	peers.RegMsgProcess(p2p.PooledTxsMsg, synctrl.HandlePooledTxsMsg)
+
+	peers.RegOnAddPeer(synctrl.RegisterNetPeer)
+	peers.RegOnDropPeer(synctrl.UnregisterNetPeer)
+
+	go synctrl.TxsPoolLoop()
 	return synctrl, nil
 }
 
@@ -166,6 +208,7 @@ func (this *SynCtrl) Start() {
 	this.txSub = this.txpool.SubscribeTxPreEvent(this.txCh)
 
 	go this.txRoutingLoop()
+	this.txFetcher.start()
 
 	// broadcast mined blocks
 	this.minedBlockSub = this.newBlockMux.Subscribe(bc.NewMinedBlockEvent{})
@@ -194,6 +237,7 @@ func (this *SynCtrl) RegisterNetPeer(peer *p2p.Peer) error {
 
 func (this *SynCtrl) UnregisterNetPeer(peer *p2p.Peer) error {
 	log.Debug("unregister net peer", "pid", peer.GetID())
+	this.txFetcher.Drop(peer.GetID())
 	return this.syner.UnregisterPeer(peer.GetID())
 }
 
@@ -203,8 +247,8 @@ func (this *SynCtrl) minedRoutingLoop() {
 	for obj := range this.minedBlockSub.Chan() {
 		switch ev := obj.Data.(type) {
 		case bc.NewMinedBlockEvent:
-			go routBlock(ev.Block, true) // First propagate block to peers
-			routBlock(ev.Block, false)   // Only then announce to the rest
+			go this.routBlock(ev.Block, true) // First propagate block to peers
+			this.routBlock(ev.Block, false)   // Only then announce to the rest
 		}
 	}
 }
@@ -226,7 +270,7 @@ func (this *SynCtrl) sync() {
 		case <-this.newPeerCh:
 		case <-forceSync.C:
 			// Force a sync even if not enough peers are present
-			go this.synchronise(p2p.PeerMgrInst().BestPeer())
+			go this.synchronise(this.peers.BestPeer())
 
 		case <-this.noMorePeers:
 			return
@@ -241,8 +285,8 @@ func (this *SynCtrl) synchronise(peer *p2p.Peer) {
 		return
 	}
 	// Make sure the peer's TD is higher than our own
-	currentBlock := bc.InstanceBlockChain().CurrentBlock()
-	td := bc.InstanceBlockChain().GetTd(currentBlock.Hash(), currentBlock.NumberU64())
+	currentBlock := this.chain.CurrentBlock()
+	td := this.chain.GetTd(currentBlock.Hash(), currentBlock.NumberU64())
 
 	pHead, pTd := peer.Head()
 
@@ -254,7 +298,7 @@ func (this *SynCtrl) synchronise(peer *p2p.Peer) {
 	if atomic.LoadUint32(&this.fastSync) == 1 {
 		// Fast sync was explicitly requested, and explicitly granted
 		mode = config.FastSync
-	} else if currentBlock.NumberU64() == 0 && bc.InstanceBlockChain().CurrentFastBlock().NumberU64() > 0 {
+	} else if currentBlock.NumberU64() == 0 && this.chain.CurrentFastBlock().NumberU64() > 0 {
 		// The database seems empty as the current block is the genesis. Yet the fast
 		// block is ahead, so fast sync was enabled for this node at a certain point.
 		// The only scenario where this can happen is if the user manually (or via a
@@ -268,7 +312,7 @@ func (this *SynCtrl) synchronise(peer *p2p.Peer) {
 
 	if atomic.LoadUint32(&this.fastSync) == 1 {
 		// Disable fast sync if we indeed have something in our chain
-		if bc.InstanceBlockChain().CurrentBlock().NumberU64() > 0 {
+		if this.chain.CurrentBlock().NumberU64() > 0 {
 			atomic.StoreUint32(&this.fastSync, 0)
 		}
 	}
@@ -276,14 +320,14 @@ func (this *SynCtrl) synchronise(peer *p2p.Peer) {
 		return
 	}
 	atomic.StoreUint32(&this.AcceptTxs, 1) // Mark initial sync done
-	if head := bc.InstanceBlockChain().CurrentBlock(); head.NumberU64() > 0 {
+	if head := this.chain.CurrentBlock(); head.NumberU64() > 0 {
 		// We've completed a sync cycle, notify all peers of new state. This path is
 		// essential in star-topology networks where a gateway node needs to notify
 		// all its out-of-date peers of the availability of a new block. This failure
 		// scenario will most often crop up in private and hackathon networks with
 		// degenerate connectivity, but it should be healthy for the mainnet too to
 		// more reliably update peers or the local TD state.
-		go routBlock(head, false)
+		go this.routBlock(head, false)
 	}
 }
 
@@ -296,6 +340,7 @@ func (this *SynCtrl) Stop() {
 
 	this.txSub.Unsubscribe()         // quits txRoutingLoop
 	this.minedBlockSub.Unsubscribe() // quits minedRoutingLoop
+	this.txFetcher.stop()
 
 	// Quit the sync loop.
 	// After this send has completed, no new peers will be accepted.
@@ -312,7 +357,7 @@ func (this *SynCtrl) Stop() {
 
 func (this *SynCtrl) removePeer(id string) {
 	// Short circuit if the peer was already removed
-	peer := p2p.PeerMgrInst().Peer(id)
+	peer := this.peers.Peer(id)
 	if peer == nil {
 		return
 	}
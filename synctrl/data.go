@@ -106,6 +106,68 @@ type blockBody struct {
 // blockBodiesData is the network packet for block content distribution.
 type blockBodiesData []*blockBody
 
+// getAccountRangeData is the network packet requesting a contiguous range of
+// accounts from the state trie with the given root.
+type getAccountRangeData struct {
+	ID     uint64      // Request ID to match up the response with
+	Root   common.Hash // Root of the state trie to serve the accounts from
+	Origin common.Hash // Hash of the first account to retrieve
+	Limit  common.Hash // Hash of the last account to retrieve
+	Bytes  uint64      // Soft limit at which to stop returning data
+}
+
+// accountData is a single account of a state range, encoded as in the trie.
+type accountData struct {
+	Hash common.Hash // Hash of the account address
+	Body []byte      // RLP encoded account
+}
+
+// accountRangeData is the network packet returning a range of accounts along
+// with the merkle proofs of its edges.
+type accountRangeData struct {
+	ID       uint64
+	Accounts []*accountData
+	Proof    []rlp.RawValue
+}
+
+// getStorageRangesData is the network packet requesting the storage slots of
+// a batch of accounts.
+type getStorageRangesData struct {
+	ID       uint64        // Request ID to match up the response with
+	Root     common.Hash   // Root of the state trie holding the accounts
+	Accounts []common.Hash // Hashes of the accounts to retrieve the storage of
+	Origin   common.Hash   // Hash of the first slot to retrieve of the first account
+	Bytes    uint64        // Soft limit at which to stop returning data
+}
+
+// storageData is a single storage slot of a state range, encoded as in the trie.
+type storageData struct {
+	Hash common.Hash // Hash of the slot key
+	Body []byte      // RLP encoded slot value
+}
+
+// storageRangesData is the network packet returning the storage slots of a
+// batch of accounts. Only the storage of the last account may be incomplete,
+// in which case the merkle proofs of its edges are attached.
+type storageRangesData struct {
+	ID    uint64
+	Slots [][]*storageData
+	Proof []rlp.RawValue
+}
+
+// getByteCodesData is the network packet requesting contract codes by hash.
+type getByteCodesData struct {
+	ID     uint64        // Request ID to match up the response with
+	Hashes []common.Hash // Code hashes to retrieve
+	Bytes  uint64        // Soft limit at which to stop returning data
+}
+
+// byteCodesData is the network packet returning contract codes.
+type byteCodesData struct {
+	ID    uint64
+	Codes [][]byte
+}
+
 func sendNewBlock(peer *p2p.Peer, block *types.Block, td *big.Int) error {
 	peer.KnownBlockAdd(block.Hash())
 	return p2p.SendData(peer, p2p.NewBlockMsg, []interface{}{block, td})
@@ -151,6 +213,21 @@ func sendNodeData(peer *p2p.Peer, data [][]byte) error {
 	return p2p.SendData(peer, p2p.NodeDataMsg, data)
 }
 
+// sendAccountRange sends a range of accounts of the state trie.
+func sendAccountRange(peer *p2p.Peer, data *accountRangeData) error {
+	return p2p.SendData(peer, p2p.AccountRangeMsg, data)
+}
+
+// sendStorageRanges sends the storage slots of a batch of accounts.
+func sendStorageRanges(peer *p2p.Peer, data *storageRangesData) error {
+	return p2p.SendData(peer, p2p.StorageRangesMsg, data)
+}
+
+// sendByteCodes sends a batch of contract codes.
+func sendByteCodes(peer *p2p.Peer, data *byteCodesData) error {
+	return p2p.SendData(peer, p2p.ByteCodesMsg, data)
+}
+
 // sendReceiptsRLP sends a batch of transaction receipts, corresponding to the
 // ones requested from an already RLP encoded format.
 func sendReceiptsRLP(peer *p2p.Peer, receipts []rlp.RawValue) error {
@@ -23,18 +23,13 @@ import (
 	"github.com/hpb-project/go-hpb/common/log"
 	"github.com/hpb-project/go-hpb/common/rlp"
 	"github.com/hpb-project/go-hpb/network/p2p"
-	"github.com/hpb-project/go-hpb/node/db"
-	"github.com/hpb-project/go-hpb/txpool"
-	"gopkg.in/fatih/set.v0"
 	"math/big"
 	"sync/atomic"
 	"time"
 )
 
-var handleKnownBlocks = set.New()
-
 // HandleGetBlockHeadersMsg deal received GetBlockHeadersMsg
-func HandleGetBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleGetBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// Decode the complex header query
 	var query getBlockHeadersData
 	if err := msg.Decode(&query); err != nil {
@@ -52,9 +47,9 @@ func HandleGetBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
 		// Retrieve the next header satisfying the query
 		var origin *types.Header
 		if hashMode {
-			origin = bc.InstanceBlockChain().GetHeaderByHash(query.Origin.Hash)
+			origin = this.chain.GetHeaderByHash(query.Origin.Hash)
 		} else {
-			origin = bc.InstanceBlockChain().GetHeaderByNumber(query.Origin.Number)
+			origin = this.chain.GetHeaderByNumber(query.Origin.Number)
 		}
 		if origin == nil {
 			break
@@ -68,7 +63,7 @@ func HandleGetBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
 		case query.Origin.Hash != (common.Hash{}) && query.Reverse:
 			// Hash based traversal towards the genesis block
 			for i := 0; i < int(query.Skip)+1; i++ {
-				if header := bc.InstanceBlockChain().GetHeader(query.Origin.Hash, number); header != nil {
+				if header := this.chain.GetHeader(query.Origin.Hash, number); header != nil {
 					query.Origin.Hash = header.ParentHash
 					number--
 				} else {
@@ -86,8 +81,8 @@ func HandleGetBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
 				log.Warn("GetBlockHeaders skip overflow attack", "current", current, "skip", query.Skip, "next", next, "attacker", p.ID())
 				unknown = true
 			} else {
-				if header := bc.InstanceBlockChain().GetHeaderByNumber(next); header != nil {
-					if bc.InstanceBlockChain().GetBlockHashesFromHash(header.Hash(), query.Skip+1)[query.Skip] == query.Origin.Hash {
+				if header := this.chain.GetHeaderByNumber(next); header != nil {
+					if this.chain.GetBlockHashesFromHash(header.Hash(), query.Skip+1)[query.Skip] == query.Origin.Hash {
 						query.Origin.Hash = header.Hash()
 					} else {
 						unknown = true
@@ -113,7 +108,7 @@ func HandleGetBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
 }
 
 // HandleBlockHeadersMsg deal received BlockHeadersMsg
-func HandleBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// A batch of headers arrived to one of our previous requests
 	var headers []*types.Header
 	if err := msg.Decode(&headers); err != nil {
@@ -124,10 +119,10 @@ func HandleBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
 	filter := len(headers) == 1
 	if filter {
 		// Irrelevant of the fork checks, send the header to the fetcher just in case
-		headers = InstanceSynCtrl().puller.FilterHeaders(p.GetID(), headers, time.Now())
+		headers = this.puller.FilterHeaders(p.GetID(), headers, time.Now())
 	}
 	if len(headers) > 0 || !filter {
-		err := InstanceSynCtrl().syner.DeliverHeaders(p.GetID(), headers)
+		err := this.syner.DeliverHeaders(p.GetID(), headers)
 		if err != nil {
 			log.Debug("Failed to deliver headers", "err", err)
 		}
@@ -136,7 +131,7 @@ func HandleBlockHeadersMsg(p *p2p.Peer, msg p2p.Msg) error {
 }
 
 // HandleGetBlockBodiesMsg deal received GetBlockBodiesMsg
-func HandleGetBlockBodiesMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleGetBlockBodiesMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// Decode the retrieval message
 	msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
 	if _, err := msgStream.List(); err != nil {
@@ -156,7 +151,7 @@ func HandleGetBlockBodiesMsg(p *p2p.Peer, msg p2p.Msg) error {
 			return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
 		}
 		// Retrieve the requested block body, stopping if enough was found
-		if data := bc.InstanceBlockChain().GetBodyRLP(hash); len(data) != 0 {
+		if data := this.chain.GetBodyRLP(hash); len(data) != 0 {
 			bodies = append(bodies, data)
 			bytes += len(data)
 		}
@@ -165,7 +160,7 @@ func HandleGetBlockBodiesMsg(p *p2p.Peer, msg p2p.Msg) error {
 }
 
 // HandleBlockBodiesMsg deal received BlockBodiesMsg
-func HandleBlockBodiesMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleBlockBodiesMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// A batch of block bodies arrived to one of our previous requests
 	var request blockBodiesData
 	if err := msg.Decode(&request); err != nil {
@@ -182,10 +177,10 @@ func HandleBlockBodiesMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// Filter out any explicitly requested bodies, deliver the rest to the downloader
 	filter := len(trasactions) > 0 || len(uncles) > 0
 	if filter {
-		trasactions, uncles = InstanceSynCtrl().puller.FilterBodies(p.GetID(), trasactions, uncles, time.Now())
+		trasactions, uncles = this.puller.FilterBodies(p.GetID(), trasactions, uncles, time.Now())
 	}
 	if len(trasactions) > 0 || len(uncles) > 0 || !filter {
-		err := InstanceSynCtrl().syner.DeliverBodies(p.GetID(), trasactions, uncles)
+		err := this.syner.DeliverBodies(p.GetID(), trasactions, uncles)
 		if err != nil {
 			log.Debug("Failed to deliver bodies", "err", err)
 		}
@@ -194,7 +189,7 @@ func HandleBlockBodiesMsg(p *p2p.Peer, msg p2p.Msg) error {
 }
 
 // HandleGetNodeDataMsg deal received GetNodeDataMsg
-func HandleGetNodeDataMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleGetNodeDataMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// Decode the retrieval message
 	msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
 	if _, err := msgStream.List(); err != nil {
@@ -214,7 +209,7 @@ func HandleGetNodeDataMsg(p *p2p.Peer, msg p2p.Msg) error {
 			return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
 		}
 		// Retrieve the requested state entry, stopping if enough was found
-		if entry, err := db.GetHpbDbInstance().Get(hash.Bytes()); err == nil {
+		if entry, err := this.chaindb.Get(hash.Bytes()); err == nil {
 			data = append(data, entry)
 			bytes += len(entry)
 		}
@@ -223,21 +218,84 @@ func HandleGetNodeDataMsg(p *p2p.Peer, msg p2p.Msg) error {
 }
 
 // HandleNodeDataMsg deal received NodeDataMsg
-func HandleNodeDataMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleNodeDataMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// A batch of node state data arrived to one of our previous requests
 	var data [][]byte
 	if err := msg.Decode(&data); err != nil {
 		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
 	}
 	// Deliver all to the downloader
-	if err := InstanceSynCtrl().syner.DeliverNodeData(p.GetID(), data); err != nil {
+	if err := this.syner.DeliverNodeData(p.GetID(), data); err != nil {
 		log.Debug("Failed to deliver node state data", "err", err)
 	}
 	return nil
 }
 
+// HandleGetAccountRangeMsg deal received GetAccountRangeMsg
+func (this *SynCtrl) HandleGetAccountRangeMsg(p *p2p.Peer, msg p2p.Msg) error {
+	var query getAccountRangeData
+	if err := msg.Decode(&query); err != nil {
+		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
+	}
+	return sendAccountRange(p, serveAccountRange(this.chain.StateCache().TrieDB(), &query))
+}
+
+// HandleAccountRangeMsg deal received AccountRangeMsg
+func (this *SynCtrl) HandleAccountRangeMsg(p *p2p.Peer, msg p2p.Msg) error {
+	var data accountRangeData
+	if err := msg.Decode(&data); err != nil {
+		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
+	}
+	if err := this.syner.DeliverStateRange(p.GetID(), &rangePack{peerId: p.GetID(), id: data.ID, accounts: &data}); err != nil {
+		log.Debug("Failed to deliver account range", "err", err)
+	}
+	return nil
+}
+
+// HandleGetStorageRangesMsg deal received GetStorageRangesMsg
+func (this *SynCtrl) HandleGetStorageRangesMsg(p *p2p.Peer, msg p2p.Msg) error {
+	var query getStorageRangesData
+	if err := msg.Decode(&query); err != nil {
+		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
+	}
+	return sendStorageRanges(p, serveStorageRanges(this.chain.StateCache().TrieDB(), &query))
+}
+
+// HandleStorageRangesMsg deal received StorageRangesMsg
+func (this *SynCtrl) HandleStorageRangesMsg(p *p2p.Peer, msg p2p.Msg) error {
+	var data storageRangesData
+	if err := msg.Decode(&data); err != nil {
+		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
+	}
+	if err := this.syner.DeliverStateRange(p.GetID(), &rangePack{peerId: p.GetID(), id: data.ID, storage: &data}); err != nil {
+		log.Debug("Failed to deliver storage ranges", "err", err)
+	}
+	return nil
+}
+
+// HandleGetByteCodesMsg deal received GetByteCodesMsg
+func (this *SynCtrl) HandleGetByteCodesMsg(p *p2p.Peer, msg p2p.Msg) error {
+	var query getByteCodesData
+	if err := msg.Decode(&query); err != nil {
+		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
+	}
+	return sendByteCodes(p, serveByteCodes(this.chain.StateCache().TrieDB(), &query))
+}
+
+// HandleByteCodesMsg deal received ByteCodesMsg
+func (this *SynCtrl) HandleByteCodesMsg(p *p2p.Peer, msg p2p.Msg) error {
+	var data byteCodesData
+	if err := msg.Decode(&data); err != nil {
+		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
+	}
+	if err := this.syner.DeliverStateRange(p.GetID(), &rangePack{peerId: p.GetID(), id: data.ID, codes: &data}); err != nil {
+		log.Debug("Failed to deliver contract codes", "err", err)
+	}
+	return nil
+}
+
 // HandleGetReceiptsMsg deal received GetReceiptsMsg
-func HandleGetReceiptsMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleGetReceiptsMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// Decode the retrieval message
 	msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
 	if _, err := msgStream.List(); err != nil {
@@ -257,9 +315,9 @@ func HandleGetReceiptsMsg(p *p2p.Peer, msg p2p.Msg) error {
 			return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
 		}
 		// Retrieve the requested block's receipts, skipping if unknown to us
-		results := bc.GetBlockReceipts(db.GetHpbDbInstance(), hash, bc.GetBlockNumber(db.GetHpbDbInstance(), hash))
+		results := bc.GetBlockReceipts(this.chaindb, hash, bc.GetBlockNumber(this.chaindb, hash))
 		if results == nil {
-			if header := bc.InstanceBlockChain().GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
+			if header := this.chain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
 				continue
 			}
 		}
@@ -275,21 +333,21 @@ func HandleGetReceiptsMsg(p *p2p.Peer, msg p2p.Msg) error {
 }
 
 // HandleReceiptsMsg deal received ReceiptsMsg
-func HandleReceiptsMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleReceiptsMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// A batch of receipts arrived to one of our previous requests
 	var receipts [][]*types.Receipt
 	if err := msg.Decode(&receipts); err != nil {
 		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
 	}
 	// Deliver all to the downloader
-	if err := InstanceSynCtrl().syner.DeliverReceipts(p.GetID(), receipts); err != nil {
+	if err := this.syner.DeliverReceipts(p.GetID(), receipts); err != nil {
 		log.Debug("Failed to deliver receipts", "err", err)
 	}
 	return nil
 }
 
 // HandleNewBlockHashesMsg deal received NewBlockHashesMsg
-func HandleNewBlockHashesMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleNewBlockHashesMsg(p *p2p.Peer, msg p2p.Msg) error {
 	var announces newBlockHashesData
 	if err := msg.Decode(&announces); err != nil {
 		return p2p.ErrResp(p2p.ErrDecode, "%v: %v", msg, err)
@@ -301,19 +359,19 @@ func HandleNewBlockHashesMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// Schedule all the unknown hashes for retrieval
 	unknown := make(newBlockHashesData, 0, len(announces))
 	for _, block := range announces {
-		if !bc.InstanceBlockChain().HasBlock(block.Hash, block.Number) {
+		if !this.chain.HasBlock(block.Hash, block.Number) {
 			unknown = append(unknown, block)
 		}
 	}
 	for _, block := range unknown {
-		InstanceSynCtrl().puller.Notify(p.GetID(), block.Hash, block.Number, time.Now(), requestOneHeader, requestBodies)
+		this.puller.Notify(p.GetID(), block.Hash, block.Number, time.Now(), requestOneHeader, requestBodies)
 	}
 
 	return nil
 }
 
 // HandleNewBlockMsg deal received NewBlockMsg
-func HandleNewBlockMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleNewBlockMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// Retrieve and decode the propagated block
 	var request newBlockData
 	if err := msg.Decode(&request); err != nil {
@@ -324,13 +382,13 @@ func HandleNewBlockMsg(p *p2p.Peer, msg p2p.Msg) error {
 
 	// Mark the peer as owning the block and schedule it for import
 	p.KnownBlockAdd(request.Block.Hash())
-	if handleKnownBlocks.Has(request.Block.Hash()) {
+	if this.handleKnownBlocks.Has(request.Block.Hash()) {
 		log.Debug("handleKnownBlocks~~~~~~", "msgsize", msg.Size)
 		return nil
 	} else {
-		handleKnownBlocksAdd(request.Block.Hash())
+		this.handleKnownBlocksAdd(request.Block.Hash())
 	}
-	InstanceSynCtrl().puller.Enqueue(p.GetID(), request.Block)
+	this.puller.Enqueue(p.GetID(), request.Block)
 
 	// Assuming the block is importable by the peer, but possibly not yet done so,
 	// calculate the head hash and TD that the peer truly must have.
@@ -345,22 +403,22 @@ func HandleNewBlockMsg(p *p2p.Peer, msg p2p.Msg) error {
 		// Schedule a sync if above ours. Note, this will not fire a sync for a gap of
 		// a singe block (as the true TD is below the propagated block), however this
 		// scenario should easily be covered by the fetcher.
-		currentBlock := bc.InstanceBlockChain().CurrentBlock()
-		if trueTD.Cmp(bc.InstanceBlockChain().GetTd(currentBlock.Hash(), currentBlock.NumberU64())) > 0 {
+		currentBlock := this.chain.CurrentBlock()
+		if trueTD.Cmp(this.chain.GetTd(currentBlock.Hash(), currentBlock.NumberU64())) > 0 {
 		}
 	}
 	return nil
 }
 
-func handleKnownBlocksAdd(hash common.Hash) {
-	if handleKnownBlocks.Size() >= 1000000 {
-		handleKnownBlocks.Clear()
+func (this *SynCtrl) handleKnownBlocksAdd(hash common.Hash) {
+	if this.handleKnownBlocks.Size() >= 1000000 {
+		this.handleKnownBlocks.Clear()
 	}
-	handleKnownBlocks.Add(hash)
+	this.handleKnownBlocks.Add(hash)
 }
 
 // HandleNewBlockMsg deal received NewBlockMsg
-func HandleNewHashBlockMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleNewHashBlockMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// Retrieve and decode the propagated block
 	var request newBlockHashData
 	if err := msg.Decode(&request); err != nil {
@@ -369,7 +427,7 @@ func HandleNewHashBlockMsg(p *p2p.Peer, msg p2p.Msg) error {
 	txs := make([]*types.Transaction, 0, len(request.BlockH.TxsHash))
 	for _, txhs := range request.BlockH.TxsHash {
 		//get tx data from txpool
-		tx := txpool.GetTxPool().GetTxByHash(txhs)
+		tx := this.txpool.GetTxByHash(txhs)
 		txs = append(txs, tx)
 	}
 	newBlock := types.BuildBlock(request.BlockH.Header, txs, request.BlockH.Uncles, request.BlockH.Td)
@@ -381,12 +439,12 @@ func HandleNewHashBlockMsg(p *p2p.Peer, msg p2p.Msg) error {
 	////////////////////////////////////////////////
 	// Mark the peer as owning the block and schedule it for import
 	p.KnownBlockAdd(newBlock.Hash())
-	if handleKnownBlocks.Has(newBlock.Hash()) {
+	if this.handleKnownBlocks.Has(newBlock.Hash()) {
 		return nil
 	} else {
-		handleKnownBlocksAdd(newBlock.Hash())
+		this.handleKnownBlocksAdd(newBlock.Hash())
 	}
-	InstanceSynCtrl().puller.Enqueue(p.GetID(), newBlock)
+	this.puller.Enqueue(p.GetID(), newBlock)
 
 	// Assuming the block is importable by the peer, but possibly not yet done so,
 	// calculate the head hash and TD that the peer truly must have.
@@ -401,37 +459,34 @@ func HandleNewHashBlockMsg(p *p2p.Peer, msg p2p.Msg) error {
 		// Schedule a sync if above ours. Note, this will not fire a sync for a gap of
 		// a singe block (as the true TD is below the propagated block), however this
 		// scenario should easily be covered by the fetcher.
-		currentBlock := bc.InstanceBlockChain().CurrentBlock()
-		if trueTD.Cmp(bc.InstanceBlockChain().GetTd(currentBlock.Hash(), currentBlock.NumberU64())) > 0 {
+		currentBlock := this.chain.CurrentBlock()
+		if trueTD.Cmp(this.chain.GetTd(currentBlock.Hash(), currentBlock.NumberU64())) > 0 {
 		}
 	}
 	return nil
 }
 
-var poolTxsCh chan *types.Transaction
-
-func TxsPoolLoop() {
+func (this *SynCtrl) TxsPoolLoop() {
 	duration := time.Millisecond * 500
 	timer := time.NewTimer(duration)
 
 	txCap := 2000
 	txs := make([]*types.Transaction, 0, txCap)
-	poolTxsCh = make(chan *types.Transaction, 2000)
 
 	for {
 		select {
 		case <-timer.C:
 			if len(txs) > 0 {
-				log.Debug("TxsPoolLoop timeout", "len(txs)", len(txs), "len(poolTxsCh)", len(poolTxsCh))
-				go txpool.GetTxPool().AddTxs(txs)
+				log.Debug("TxsPoolLoop timeout", "len(txs)", len(txs), "len(poolTxsCh)", len(this.poolTxsCh))
+				go this.txpool.AddTxs(txs)
 				txs = make([]*types.Transaction, 0, txCap)
 			}
-		case tx, ok := <-poolTxsCh:
+		case tx, ok := <-this.poolTxsCh:
 			if ok {
 				txs = append(txs, tx)
 				if len(txs) >= txCap {
-					log.Debug("TxsPoolLoop full", "len(txs)", len(txs), "len(poolTxsCh)", len(poolTxsCh))
-					go txpool.GetTxPool().AddTxs(txs)
+					log.Debug("TxsPoolLoop full", "len(txs)", len(txs), "len(poolTxsCh)", len(this.poolTxsCh))
+					go this.txpool.AddTxs(txs)
 					txs = make([]*types.Transaction, 0, txCap)
 				}
 			}
@@ -442,10 +497,10 @@ func TxsPoolLoop() {
 }
 
 // HandleTxMsg deal received TxMsg
-func HandleTxMsg(p *p2p.Peer, msg p2p.Msg) error {
+func (this *SynCtrl) HandleTxMsg(p *p2p.Peer, msg p2p.Msg) error {
 	// Transactions arrived, make sure we have a valid and fresh chain to handle them
 	// Don't change this code if you don't understand it
-	if atomic.LoadUint32(&InstanceSynCtrl().AcceptTxs) == 0 {
+	if atomic.LoadUint32(&this.AcceptTxs) == 0 {
 		return nil
 	}
 
@@ -455,22 +510,76 @@ func HandleTxMsg(p *p2p.Peer, msg p2p.Msg) error {
 		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
 	}
 
-	go txpool.GetTxPool().GoTxsAsynSender(txs)
+	go this.txpool.GoTxsAsynSender(txs)
+	hashes := make([]common.Hash, 0, len(txs))
+	defer func() { this.txFetcher.Deliver(p.GetID(), hashes) }()
+
 	for i, tx := range txs {
 		// Validate and mark the remote transaction
 		if tx == nil {
 			return p2p.ErrResp(p2p.ErrDecode, "transaction %d is nil", i)
 		}
 		p.KnownTxsAdd(tx.Hash())
+		hashes = append(hashes, tx.Hash())
 
-		if nil != txpool.GetTxPool().GetTxByHash(tx.Hash()) {
+		if nil != this.txpool.GetTxByHash(tx.Hash()) {
 			continue
 		} else {
 			go func() {
-				poolTxsCh <- tx
+				this.poolTxsCh <- tx
 			}()
 		}
 	}
 
 	return nil
 }
+
+// HandlePooledTxsMsg deal received PooledTxsMsg
+func (this *SynCtrl) HandlePooledTxsMsg(p *p2p.Peer, msg p2p.Msg) error {
+	// Requested transactions are imported the same way as the pushed ones
+	return this.HandleTxMsg(p, msg)
+}
+
+// HandleNewTxHashesMsg deal received NewTxHashesMsg
+func (this *SynCtrl) HandleNewTxHashesMsg(p *p2p.Peer, msg p2p.Msg) error {
+	if atomic.LoadUint32(&this.AcceptTxs) == 0 {
+		return nil
+	}
+	var hashes []common.Hash
+	if err := msg.Decode(&hashes); err != nil {
+		return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
+	}
+	for _, hash := range hashes {
+		p.KnownTxsAdd(hash)
+	}
+	return this.txFetcher.Notify(p.GetID(), hashes, time.Now())
+}
+
+// HandleGetPooledTxsMsg deal received GetPooledTxsMsg
+func (this *SynCtrl) HandleGetPooledTxsMsg(p *p2p.Peer, msg p2p.Msg) error {
+	// Decode the retrieval message
+	msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+	if _, err := msgStream.List(); err != nil {
+		return err
+	}
+	// Gather transactions until the fetch or network limits is reached
+	var (
+		hash  common.Hash
+		bytes common.StorageSize
+		txs   types.Transactions
+	)
+	for bytes < softResponseLimit && len(txs) < txFetchLimit {
+		// Retrieve the hash of the next transaction
+		if err := msgStream.Decode(&hash); err == rlp.EOL {
+			break
+		} else if err != nil {
+			return p2p.ErrResp(p2p.ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Retrieve the requested transaction, skipping the ones already dropped
+		if tx := this.txpool.GetTxByHash(hash); tx != nil {
+			txs = append(txs, tx)
+			bytes += tx.Size()
+		}
+	}
+	return sendPooledTxs(p, txs)
+}
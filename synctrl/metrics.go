@@ -59,4 +59,11 @@ var (
 	headerFilterOutMeter = metrics.NewMeter("hpb/puller/filter/headers/out")
 	bodyFilterInMeter    = metrics.NewMeter("hpb/puller/filter/bodies/in")
 	bodyFilterOutMeter   = metrics.NewMeter("hpb/puller/filter/bodies/out")
+
+	//Transaction fetcher metrics
+	txAnnounceInMeter   = metrics.NewMeter("hpb/txfetcher/announces/in")
+	txAnnounceDOSMeter  = metrics.NewMeter("hpb/txfetcher/announces/dos")
+	txFetchOutMeter     = metrics.NewMeter("hpb/txfetcher/fetch/out")
+	txFetchInMeter      = metrics.NewMeter("hpb/txfetcher/fetch/in")
+	txFetchTimeoutMeter = metrics.NewMeter("hpb/txfetcher/fetch/timeout")
 )
@@ -98,3 +98,31 @@ type statePack struct {
 func (p *statePack) PeerId() string { return p.peerId }
 func (p *statePack) Items() int     { return len(p.states) }
 func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
+
+// rangePack is a range based state response returned by a peer, holding an
+// account range, storage ranges or contract codes.
+type rangePack struct {
+	peerId   string
+	id       uint64
+	accounts *accountRangeData
+	storage  *storageRangesData
+	codes    *byteCodesData
+}
+
+func (p *rangePack) PeerId() string { return p.peerId }
+func (p *rangePack) Items() int {
+	switch {
+	case p.accounts != nil:
+		return len(p.accounts.Accounts)
+	case p.storage != nil:
+		items := 0
+		for _, slots := range p.storage.Slots {
+			items += len(slots)
+		}
+		return items
+	case p.codes != nil:
+		return len(p.codes.Codes)
+	}
+	return 0
+}
+func (p *rangePack) Stats() string { return fmt.Sprintf("%d", p.Items()) }
@@ -46,6 +46,9 @@ var (
 // blockRetrievalFn is a callback type for retrieving a block from the local chain.
 type blockRetrievalFn func(common.Hash) *types.Block
 
+// peerRetrievalFn is a callback type for retrieving a connected peer by id.
+type peerRetrievalFn func(id string) *p2p.Peer
+
 // headerRequesterFn is a callback type for sending a header retrieval request.
 type headerRequesterFn func(*p2p.Peer, common.Hash) error
 
@@ -129,6 +132,7 @@ type Puller struct {
 
 	// Callbacks
 	getBlock       blockRetrievalFn   // Retrieves a block from the local chain
+	getPeer        peerRetrievalFn    // Retrieves a connected peer
 	verifyHeader   headerVerifierFn   // Checks if a block's headers have a valid proof of work
 	broadcastBlock blockBroadcasterFn // Broadcasts a block to connected peers
 	chainHeight    chainHeightFn      // Retrieves the current chain's height
@@ -143,7 +147,7 @@ type Puller struct {
 	importedHook       func(*types.Block)      // Method to call upon successful block import
 }
 
-func NewPuller(getBlock blockRetrievalFn, verifyHeader headerVerifierFn, broadcastBlock blockBroadcasterFn,
+func NewPuller(getBlock blockRetrievalFn, getPeer peerRetrievalFn, verifyHeader headerVerifierFn, broadcastBlock blockBroadcasterFn,
 	chainHeight chainHeightFn, insertChain chainInsertFn, dropPeer peerDropFn) *Puller {
 	return &Puller{
 		notify:         make(chan *announce),
@@ -162,6 +166,7 @@ func NewPuller(getBlock blockRetrievalFn, verifyHeader headerVerifierFn, broadca
 		queues:         make(map[string]int),
 		queued:         make(map[common.Hash]*inject),
 		getBlock:       getBlock,
+		getPeer:        getPeer,
 		verifyHeader:   verifyHeader,
 		broadcastBlock: broadcastBlock,
 		chainHeight:    chainHeight,
@@ -389,7 +394,7 @@ func (this *Puller) loop() {
 					}
 					for _, hash := range hashes {
 						headerFetchMeter.Mark(1)
-						fetchHeader(p2p.PeerMgrInst().Peer(peer), hash) // Suboptimal, but protocol doesn't allow batch header retrievals
+						fetchHeader(this.getPeer(peer), hash) // Suboptimal, but protocol doesn't allow batch header retrievals
 					}
 				}()
 			}
@@ -420,7 +425,7 @@ func (this *Puller) loop() {
 					this.completingHook(hashes)
 				}
 				bodyFetchMeter.Mark(int64(len(hashes)))
-				go this.completing[hashes[0]].fetchBodies(p2p.PeerMgrInst().Peer(peer), hashes)
+				go this.completing[hashes[0]].fetchBodies(this.getPeer(peer), hashes)
 			}
 			// Schedule the next fetch if blocks are still pending
 			this.rescheduleComplete(completeTimer)
@@ -17,12 +17,12 @@
 package synctrl
 
 import (
-	"github.com/hpb-project/go-hpb/blockchain"
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
 	"github.com/hpb-project/go-hpb/common/log"
 	"github.com/hpb-project/go-hpb/network/p2p"
 	"github.com/hpb-project/go-hpb/network/p2p/discover"
+	"math"
 	"math/big"
 	"time"
 )
@@ -34,16 +34,16 @@ const (
 
 // routingBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
-func routBlock(block *types.Block, propagate bool) {
+func (this *SynCtrl) routBlock(block *types.Block, propagate bool) {
 	hash := block.Hash()
-	peers := p2p.PeerMgrInst().PeersWithoutBlock(hash)
+	peers := this.peers.PeersWithoutBlock(hash)
 
 	// If propagation is requested, send to a subset of the peer
 	if propagate {
 		// Calculate the TD of the block (it's not imported yet, so block.Td is not valid)
 		var td *big.Int
-		if parent := bc.InstanceBlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1); parent != nil {
-			td = new(big.Int).Add(block.Difficulty(), bc.InstanceBlockChain().GetTd(block.ParentHash(), block.NumberU64()-1))
+		if parent := this.chain.GetBlock(block.ParentHash(), block.NumberU64()-1); parent != nil {
+			td = new(big.Int).Add(block.Difficulty(), this.chain.GetTd(block.ParentHash(), block.NumberU64()-1))
 		} else {
 			log.Error("Propagating dangling block", "number", block.Number(), "hash", hash)
 			return
@@ -95,7 +95,7 @@ func routBlock(block *types.Block, propagate bool) {
 		return
 	}
 	// Otherwise if the block is indeed in out own chain, announce it
-	if bc.InstanceBlockChain().HasBlock(hash, block.NumberU64()) {
+	if this.chain.HasBlock(hash, block.NumberU64()) {
 		for _, peer := range peers {
 			switch peer.LocalType() {
 			case discover.PreNode:
@@ -133,108 +133,106 @@ func routBlock(block *types.Block, propagate bool) {
 	}
 }
 
-// routingTx will propagate a transaction to peers by type which are not known to
-// already have the given transaction.
-func routTx(hash common.Hash, tx *types.Transaction) {
-	// Broadcast transaction to a batch of peers not knowing about it
+// routTxs will propagate a batch of transactions to peers by type which are not
+// known to already have them. Each transaction is pushed directly to a square
+// root of its peers, the rest only get its hash announced and fetch it if they
+// still miss it.
+func (this *SynCtrl) routTxs(txs types.Transactions) {
+	var (
+		pushes    = make(map[*p2p.Peer]types.Transactions)
+		announces = make(map[*p2p.Peer][]common.Hash)
+	)
+	for _, tx := range txs {
+		hash := tx.Hash()
 
-	if tx.IsForward() {
-		routForwardTx(hash, tx)
-	} else {
-		tx.SetForward(true)
-		routNativeTx(hash, tx)
+		var peers []*p2p.Peer
+		if tx.IsForward() {
+			peers = this.forwardTxPeers(hash)
+		} else {
+			tx.SetForward(true)
+			peers = this.nativeTxPeers(hash)
+		}
+		direct := int(math.Sqrt(float64(len(peers))))
+		if direct < 1 {
+			direct = 1
+		}
+		for i, peer := range peers {
+			if i < direct || !peer.HasCap(p2p.TxAnnounceCap) {
+				pushes[peer] = append(pushes[peer], tx)
+			} else {
+				announces[peer] = append(announces[peer], hash)
+			}
+		}
 	}
+	for peer, txs := range pushes {
+		sendTransactions(peer, txs)
+	}
+	for peer, hashes := range announces {
+		sendTxHashes(peer, hashes)
+	}
+	log.Trace("Broadcast transactions", "count", len(txs), "pushed", len(pushes), "announced", len(announces))
 }
 
-func routNativeTx(hash common.Hash, tx *types.Transaction) {
-	peers := p2p.PeerMgrInst().PeersWithoutTx(hash)
+// nativeTxPeers returns the peers by type a locally originated transaction is
+// routed to.
+func (this *SynCtrl) nativeTxPeers(hash common.Hash) []*p2p.Peer {
+	peers := this.peers.PeersWithoutTx(hash)
 	if len(peers) == 0 {
-		return
+		return nil
 	}
 
-	switch p2p.PeerMgrInst().GetLocalType() {
+	var transfer []*p2p.Peer
+	switch this.peers.GetLocalType() {
 	case discover.HpNode:
 		for _, peer := range peers {
 			switch peer.RemoteType() {
 			case discover.HpNode:
-				sendTransactions(peer, types.Transactions{tx})
+				transfer = append(transfer, peer)
 				break
 			}
 		}
 		break
-	case discover.PreNode:
+	case discover.PreNode, discover.SynNode:
 		for _, peer := range peers {
 			switch peer.RemoteType() {
 			case discover.HpNode:
-				sendTransactions(peer, types.Transactions{tx})
+				transfer = append(transfer, peer)
 				break
 			}
 		}
 
-		toPreCount := 0
 		for _, peer := range peers {
-			switch peer.RemoteType() {
-			case discover.PreNode:
-				sendTransactions(peer, types.Transactions{tx})
-				toPreCount += 1
-				break
-			}
-			if toPreCount >= 1 {
-				break
-			}
-		}
-
-		break
-	case discover.SynNode:
-		for _, peer := range peers {
-			switch peer.RemoteType() {
-			case discover.HpNode:
-				sendTransactions(peer, types.Transactions{tx})
-				break
-			}
-		}
-
-		toPreCount := 0
-		for _, peer := range peers {
-			switch peer.RemoteType() {
-			case discover.PreNode:
-				sendTransactions(peer, types.Transactions{tx})
-				toPreCount += 1
-				break
-			}
-
-			if toPreCount >= 1 {
+			if peer.RemoteType() == discover.PreNode {
+				transfer = append(transfer, peer)
 				break
 			}
 		}
 		break
 	}
-	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(peers))
+	return transfer
 }
 
-func routForwardTx(hash common.Hash, tx *types.Transaction) {
-	peers := p2p.PeerMgrInst().PeersWithoutTx(hash)
+// forwardTxPeers returns the peers by type a transaction forwarded by another
+// node is routed to.
+func (this *SynCtrl) forwardTxPeers(hash common.Hash) []*p2p.Peer {
+	peers := this.peers.PeersWithoutTx(hash)
 	if len(peers) == 0 {
-		return
+		return nil
 	}
 
-	switch p2p.PeerMgrInst().GetLocalType() {
-	case discover.HpNode:
-		break
+	var transfer []*p2p.Peer
+	switch this.peers.GetLocalType() {
 	case discover.PreNode:
 		for _, peer := range peers {
 			switch peer.RemoteType() {
 			case discover.HpNode:
-				sendTransactions(peer, types.Transactions{tx})
+				transfer = append(transfer, peer)
 				break
 			}
 		}
 		break
-	case discover.SynNode:
-		break
 	}
-
-	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(peers))
+	return transfer
 }
 
 func sendTransactions(peer *p2p.Peer, txs types.Transactions) error {
@@ -243,3 +241,24 @@ func sendTransactions(peer *p2p.Peer, txs types.Transactions) error {
 	}
 	return p2p.SendData(peer, p2p.TxMsg, txs)
 }
+
+// sendTxHashes announces the availability of a batch of transactions by hash.
+func sendTxHashes(peer *p2p.Peer, hashes []common.Hash) error {
+	for _, hash := range hashes {
+		peer.KnownTxsAdd(hash)
+	}
+	return p2p.SendData(peer, p2p.NewTxHashesMsg, hashes)
+}
+
+// requestPooledTxs fetches a batch of announced transactions by hash.
+func requestPooledTxs(peer *p2p.Peer, hashes []common.Hash) error {
+	return p2p.SendData(peer, p2p.GetPooledTxsMsg, hashes)
+}
+
+// sendPooledTxs sends a batch of requested transactions.
+func sendPooledTxs(peer *p2p.Peer, txs types.Transactions) error {
+	for _, tx := range txs {
+		peer.KnownTxsAdd(tx.Hash())
+	}
+	return p2p.SendData(peer, p2p.PooledTxsMsg, txs)
+}
@@ -149,6 +149,7 @@ type syncStrategy interface {
 	deliverBodies(id string, transactions [][]*types.Transaction, uncles [][]*types.Header) (err error)
 	deliverReceipts(id string, receipts [][]*types.Receipt) (err error)
 	deliverNodeData(id string, data [][]byte) (err error)
+	deliverStateRange(id string, pack *rangePack) (err error)
 }
 
 type Syncer struct {
@@ -158,6 +159,7 @@ type Syncer struct {
 	mux        *sub.TypeMux // Event multiplexer to announce sync operation events
 	stateDB    hpbdb.Database
 	lightchain LightChain
+	blockchain BlockChain // Full chain synced by the full and fast strategies, nil for light chains
 
 	peers    *peerSet   // Set of active peers from which sync can proceed
 	dropPeer peerDropFn // Drops a peer for misbehaving
@@ -191,11 +193,13 @@ func NewSyncer(mode config.SyncMode, stateDb hpbdb.Database, mux *sub.TypeMux, l
 	if lightchain == nil {
 		lightchain = bc.InstanceBlockChain()
 	}
+	blockchain, _ := lightchain.(BlockChain)
 	syn := &Syncer{
 		mode:           mode,
 		stateDB:        stateDb,
 		mux:            mux,
 		lightchain:     lightchain,
+		blockchain:     blockchain,
 		peers:          newPeerSet(),
 		dropPeer:       dropPeer,
 		sch:            newScheduler(),
@@ -235,7 +239,7 @@ func (this *Syncer) Progress() hpbinter.SyncProgress {
 	this.syncStatsLock.RLock()
 	defer this.syncStatsLock.RUnlock()
 
-	current := bc.InstanceBlockChain().CurrentBlock().NumberU64()
+	current := this.blockchain.CurrentBlock().NumberU64()
 	return hpbinter.SyncProgress{
 		StartingBlock: this.syncStatsChainOrigin,
 		CurrentBlock:  current,
@@ -368,6 +372,11 @@ func (this *Syncer) DeliverNodeData(id string, data [][]byte) (err error) {
 	return this.strategy.deliverNodeData(id, data)
 }
 
+// DeliverStateRange injects a range based state response received from a remote node.
+func (this *Syncer) DeliverStateRange(id string, pack *rangePack) (err error) {
+	return this.strategy.deliverStateRange(id, pack)
+}
+
 // qosTuner is the quality of service tuning loop that occasionally gathers the
 // peer latency statistics and updates the estimated request round trip time.
 func (this *Syncer) qosTuner() {
@@ -466,13 +475,18 @@ func (this *Syncer) runStateSync(s *stateSync) *stateSync {
 		case pack := <-this.stateCh:
 			// Discard any data not requested (or previsouly timed out)
 			req := active[pack.PeerId()]
-			if req == nil {
+			if req == nil || !req.answeredBy(pack) {
 				log.Debug("Unrequested node data", "peer", pack.PeerId(), "len", pack.Items())
 				continue
 			}
 			// Finalize the request and queue up for processing
 			req.timer.Stop()
-			req.response = pack.(*statePack).states
+			switch pack := pack.(type) {
+			case *statePack:
+				req.response = pack.states
+			case *rangePack:
+				req.ranged.response = pack
+			}
 
 			finished = append(finished, req)
 			delete(active, pack.PeerId())
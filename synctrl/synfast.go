@@ -25,7 +25,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/hpb-project/go-hpb/blockchain"
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
 	"github.com/hpb-project/go-hpb/common/log"
@@ -92,6 +91,11 @@ func (this *fastSync) deliverNodeData(id string, data [][]byte) (err error) {
 	return this.deliver(id, this.syncer.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
+// DeliverStateRange injects a range based state response received from a remote node.
+func (this *fastSync) deliverStateRange(id string, pack *rangePack) (err error) {
+	return this.deliver(id, this.syncer.stateCh, pack, stateInMeter, stateDropMeter)
+}
+
 // Cancel cancels all of the operations and resets the sch. It returns true
 // if the cancel operation was completed.
 func (this *fastSync) cancel() {
@@ -347,7 +351,7 @@ func (this *fastSync) findAncestor(p *peerConnection, height uint64) (uint64, er
 	floor, ceil := int64(-1), this.syncer.lightchain.CurrentHeader().Number.Uint64()
 
 	p.log.Debug("Looking for common ancestor", "local", ceil, "remote", height)
-	ceil = bc.InstanceBlockChain().CurrentFastBlock().NumberU64()
+	ceil = this.syncer.blockchain.CurrentFastBlock().NumberU64()
 	if ceil >= MaxForkAncestry {
 		floor = int64(ceil - MaxForkAncestry)
 	}
@@ -898,12 +902,12 @@ func (this *fastSync) processHeaders(origin uint64, td *big.Int) error {
 			}
 			lastHeader, lastFastBlock, lastBlock :=
 				this.syncer.lightchain.CurrentHeader().Number, common.Big0, common.Big0
-			lastFastBlock = bc.InstanceBlockChain().CurrentFastBlock().Number()
-			lastBlock = bc.InstanceBlockChain().CurrentBlock().Number()
+			lastFastBlock = this.syncer.blockchain.CurrentFastBlock().Number()
+			lastBlock = this.syncer.blockchain.CurrentBlock().Number()
 			this.syncer.lightchain.Rollback(hashes)
 			curFastBlock, curBlock := common.Big0, common.Big0
-			curFastBlock = bc.InstanceBlockChain().CurrentFastBlock().Number()
-			curBlock = bc.InstanceBlockChain().CurrentBlock().Number()
+			curFastBlock = this.syncer.blockchain.CurrentFastBlock().Number()
+			curBlock = this.syncer.blockchain.CurrentBlock().Number()
 			log.Warn("Rolled back headers", "count", len(hashes),
 				"header", fmt.Sprintf("%d->%d", lastHeader, this.syncer.lightchain.CurrentHeader().Number),
 				"fast", fmt.Sprintf("%d->%d", lastFastBlock, curFastBlock),
@@ -954,7 +958,7 @@ func (this *fastSync) processHeaders(origin uint64, td *big.Int) error {
 				// L: Sync begins, and finds common ancestor at 11
 				// L: Request new headers up from 11 (R's TD was higher, it must have something)
 				// R: Nothing to give
-				if !gotHeaders && td.Cmp(bc.InstanceBlockChain().GetTdByHash(bc.InstanceBlockChain().CurrentBlock().Hash())) > 0 {
+				if !gotHeaders && td.Cmp(this.syncer.blockchain.GetTdByHash(this.syncer.blockchain.CurrentBlock().Hash())) > 0 {
 					return errStallingPeer
 				}
 				// If fast or light syncing, ensure promised headers are indeed delivered. This is
@@ -1072,7 +1076,7 @@ func (this *fastSync) importBlockResults(results []*fetchResult) error {
 		for i, result := range results[:items] {
 			blocks[i] = types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
 		}
-		if index, err := bc.InstanceBlockChain().InsertChain(blocks); err != nil {
+		if index, err := this.syncer.blockchain.InsertChain(blocks); err != nil {
 			log.Debug("fast synced item processing failed", "number", results[index].Header.Number, "hash", results[index].Header.Hash(), "err", err)
 			if err == consensus.ErrInvalidblockbutnodrop {
 				return consensus.ErrInvalidblockbutnodrop
@@ -1163,7 +1167,7 @@ func (this *fastSync) commitFastSyncData(results []*fetchResult, stateSync *stat
 			blocks[i] = types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
 			receipts[i] = result.Receipts
 		}
-		if index, err := bc.InstanceBlockChain().InsertReceiptChain(blocks, receipts); err != nil {
+		if index, err := this.syncer.blockchain.InsertReceiptChain(blocks, receipts); err != nil {
 			log.Debug("fast synced item processing failed", "number", results[index].Header.Number, "hash", results[index].Header.Hash(), "err", err)
 			return errInvalidChain
 		}
@@ -1181,10 +1185,10 @@ func (this *fastSync) commitPivotBlock(result *fetchResult) error {
 		return err
 	}
 	log.Debug("Committing fast sync pivot as new head", "number", b.Number(), "hash", b.Hash())
-	if _, err := bc.InstanceBlockChain().InsertReceiptChain([]*types.Block{b}, []types.Receipts{result.Receipts}); err != nil {
+	if _, err := this.syncer.blockchain.InsertReceiptChain([]*types.Block{b}, []types.Receipts{result.Receipts}); err != nil {
 		return err
 	}
-	return bc.InstanceBlockChain().FastSyncCommitHead(b.Hash())
+	return this.syncer.blockchain.FastSyncCommitHead(b.Hash())
 }
 
 // deliver injects a new batch of data received from a remote node.
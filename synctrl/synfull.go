@@ -24,7 +24,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/hpb-project/go-hpb/blockchain"
 	"github.com/hpb-project/go-hpb/blockchain/types"
 	"github.com/hpb-project/go-hpb/common"
 	"github.com/hpb-project/go-hpb/common/log"
@@ -90,6 +89,11 @@ func (this *fullSync) deliverNodeData(id string, data [][]byte) (err error) {
 	return this.deliver(id, this.syncer.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
+// DeliverStateRange injects a range based state response received from a remote node.
+func (this *fullSync) deliverStateRange(id string, pack *rangePack) (err error) {
+	return this.deliver(id, this.syncer.stateCh, pack, stateInMeter, stateDropMeter)
+}
+
 // Cancel cancels all of the operations and resets the sch. It returns true
 // if the cancel operation was completed.
 func (this *fullSync) cancel() {
@@ -319,7 +323,7 @@ func (this *fullSync) findAncestor(p *peerConnection, height uint64) (uint64, er
 	floor, ceil := int64(-1), this.syncer.lightchain.CurrentHeader().Number.Uint64()
 
 	p.log.Debug("Looking for common ancestor", "local", ceil, "remote", height)
-	ceil = bc.InstanceBlockChain().CurrentBlock().NumberU64()
+	ceil = this.syncer.blockchain.CurrentBlock().NumberU64()
 	if ceil >= MaxForkAncestry {
 		floor = int64(ceil - MaxForkAncestry)
 	}
@@ -379,7 +383,7 @@ func (this *fullSync) findAncestor(p *peerConnection, height uint64) (uint64, er
 					continue
 				}
 				// Otherwise check if we already know the header or not
-				if bc.InstanceBlockChain().HasBlockAndState(headers[i].Hash()) {
+				if this.syncer.blockchain.HasBlockAndState(headers[i].Hash()) {
 					number, hash = headers[i].Number.Uint64(), headers[i].Hash()
 
 					// If every header is known, even future ones, the peer straight out lied about its head
@@ -444,7 +448,7 @@ func (this *fullSync) findAncestor(p *peerConnection, height uint64) (uint64, er
 				arrived = true
 
 				// Modify the search interval based on the response
-				if !bc.InstanceBlockChain().HasBlockAndState(headers[0].Hash()) {
+				if !this.syncer.blockchain.HasBlockAndState(headers[0].Hash()) {
 					end = check
 					break
 				}
@@ -868,12 +872,12 @@ func (this *fullSync) processHeaders(origin uint64, td *big.Int) error {
 				hashes[i] = header.Hash()
 			}
 			lastHeader, lastFastBlock, lastBlock := this.syncer.lightchain.CurrentHeader().Number, common.Big0, common.Big0
-			lastFastBlock = bc.InstanceBlockChain().CurrentFastBlock().Number()
-			lastBlock = bc.InstanceBlockChain().CurrentBlock().Number()
+			lastFastBlock = this.syncer.blockchain.CurrentFastBlock().Number()
+			lastBlock = this.syncer.blockchain.CurrentBlock().Number()
 			this.syncer.lightchain.Rollback(hashes)
 			curFastBlock, curBlock := common.Big0, common.Big0
-			curFastBlock = bc.InstanceBlockChain().CurrentFastBlock().Number()
-			curBlock = bc.InstanceBlockChain().CurrentBlock().Number()
+			curFastBlock = this.syncer.blockchain.CurrentFastBlock().Number()
+			curBlock = this.syncer.blockchain.CurrentBlock().Number()
 			log.Warn("Rolled back headers", "count", len(hashes),
 				"header", fmt.Sprintf("%d->%d", lastHeader, this.syncer.lightchain.CurrentHeader().Number),
 				"fast", fmt.Sprintf("%d->%d", lastFastBlock, curFastBlock),
@@ -911,7 +915,7 @@ func (this *fullSync) processHeaders(origin uint64, td *big.Int) error {
 				// L: Sync begins, and finds common ancestor at 11
 				// L: Request new headers up from 11 (R's TD was higher, it must have something)
 				// R: Nothing to give
-				if !gotHeaders && td.Cmp(bc.InstanceBlockChain().GetTdByHash(bc.InstanceBlockChain().CurrentBlock().Hash())) > 0 {
+				if !gotHeaders && td.Cmp(this.syncer.blockchain.GetTdByHash(this.syncer.blockchain.CurrentBlock().Hash())) > 0 {
 					return errStallingPeer
 				}
 				// Disable any rollback and return
@@ -998,7 +1002,7 @@ func (this *fullSync) importBlockResults(results []*fetchResult) error {
 		for i, result := range results[:items] {
 			blocks[i] = types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
 		}
-		if index, err := bc.InstanceBlockChain().InsertChain(blocks); err != nil {
+		if index, err := this.syncer.blockchain.InsertChain(blocks); err != nil {
 			log.Debug("synced item processing failed", "number", results[index].Header.Number, "hash", results[index].Header.Hash(), "err", err)
 			if err == consensus.ErrInvalidblockbutnodrop {
 				return consensus.ErrInvalidblockbutnodrop
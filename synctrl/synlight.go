@@ -88,6 +88,11 @@ func (this *lightSync) deliverNodeData(id string, data [][]byte) (err error) {
 	return this.deliver(id, this.syncer.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
+// DeliverStateRange injects a range based state response received from a remote node.
+func (this *lightSync) deliverStateRange(id string, pack *rangePack) (err error) {
+	return this.deliver(id, this.syncer.stateCh, pack, stateInMeter, stateDropMeter)
+}
+
 // Cancel cancels all of the operations and resets the sch. It returns true
 // if the cancel operation was completed.
 func (this *lightSync) cancel() {
@@ -92,6 +92,15 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// RangePeer encapsulates the methods required to synchronise the state with a
+// remote full peer in contiguous ranges instead of trie node by trie node.
+type RangePeer interface {
+	SupportsStateRanges() bool
+	RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error
+	RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin common.Hash, bytes uint64) error
+	RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -224,6 +233,40 @@ func (p *peerConnection) FetchNodeData(hashes []common.Hash) error {
 	return nil
 }
 
+// SupportsStateRanges reports whether the remote peer serves state ranges.
+func (p *peerConnection) SupportsStateRanges() bool {
+	rp, ok := p.peer.(RangePeer)
+	return ok && rp.SupportsStateRanges()
+}
+
+// FetchStateRange sends a range based state retrieval request to the remote
+// peer. It shares the activity state and throughput of node data retrievals.
+func (p *peerConnection) FetchStateRange(root common.Hash, req *rangeReq) error {
+	rp, ok := p.peer.(RangePeer)
+	if !ok {
+		panic(fmt.Sprintf("state range fetch requested on peer without support %s", p.id))
+	}
+	// Short circuit if the peer is already fetching
+	if !atomic.CompareAndSwapInt32(&p.stateIdle, 0, 1) {
+		return errAlreadyFetching
+	}
+	p.stateStarted = time.Now()
+
+	switch {
+	case req.accounts != nil:
+		go rp.RequestAccountRange(req.id, root, req.accounts.next, req.accounts.last, softResponseLimit)
+	case len(req.storage) > 0:
+		accounts := make([]common.Hash, 0, len(req.storage))
+		for _, task := range req.storage {
+			accounts = append(accounts, task.account)
+		}
+		go rp.RequestStorageRanges(req.id, root, accounts, req.storage[0].next, softResponseLimit)
+	default:
+		go rp.RequestByteCodes(req.id, req.codes, softResponseLimit)
+	}
+	return nil
+}
+
 // SetHeadersIdle sets the peer to idle, allowing it to execute new header retrieval
 // requests. Its estimated header retrieval throughput is updated with that measured
 // just now.
@@ -524,6 +567,21 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 	return ps.idlePeers(config.ProtocolV111, config.ProtocolV111, idle, throughput)
 }
 
+// StateRangeIdlePeers retrieves a flat list of all the currently node-data-idle
+// peers serving state ranges within the active peer set, ordered by their
+// reputation.
+func (ps *peerSet) StateRangeIdlePeers() ([]*peerConnection, int) {
+	idle := func(p *peerConnection) bool {
+		return atomic.LoadInt32(&p.stateIdle) == 0 && p.SupportsStateRanges()
+	}
+	throughput := func(p *peerConnection) float64 {
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+		return p.stateThroughput
+	}
+	return ps.idlePeers(config.ProtocolV111, config.ProtocolV111, idle, throughput)
+}
+
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
 // protocol version constraints, using the provided function to check idleness.
 // The resulting set of peers are sorted by their measure throughput.
@@ -610,3 +668,18 @@ func (ps *PeerSyn) RequestReceipts(hashes []common.Hash) error {
 	log.Debug("Fetching batch of receipts", "id", ps.GetID(), "count", len(hashes))
 	return p2p.SendData(ps.Peer, p2p.GetReceiptsMsg, hashes)
 }
+func (ps *PeerSyn) SupportsStateRanges() bool {
+	return ps.HasCap(p2p.StateRangeCap)
+}
+func (ps *PeerSyn) RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error {
+	log.Debug("Fetching range of accounts", "id", ps.GetID(), "root", root, "origin", origin, "limit", limit)
+	return p2p.SendData(ps.Peer, p2p.GetAccountRangeMsg, &getAccountRangeData{ID: id, Root: root, Origin: origin, Limit: limit, Bytes: bytes})
+}
+func (ps *PeerSyn) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin common.Hash, bytes uint64) error {
+	log.Debug("Fetching batch of storage ranges", "id", ps.GetID(), "root", root, "accounts", len(accounts), "origin", origin)
+	return p2p.SendData(ps.Peer, p2p.GetStorageRangesMsg, &getStorageRangesData{ID: id, Root: root, Accounts: accounts, Origin: origin, Bytes: bytes})
+}
+func (ps *PeerSyn) RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	log.Debug("Fetching batch of contract codes", "id", ps.GetID(), "count", len(hashes))
+	return p2p.SendData(ps.Peer, p2p.GetByteCodesMsg, &getByteCodesData{ID: id, Hashes: hashes, Bytes: bytes})
+}
@@ -0,0 +1,758 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package synctrl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/common/rlp"
+	"github.com/hpb-project/go-hpb/common/trie"
+)
+
+const (
+	stateRangeChunks   = 16   // Number of account ranges the state trie is split into
+	maxPendingAccounts = 4096 // Accounts of a range waiting for storage or code before pausing it
+	maxStorageFetch    = 128  // Amount of storage tries to request at once
+	maxCodeFetch       = 64   // Amount of contract codes to request at once
+)
+
+var (
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+	emptyCode = crypto.Keccak256Hash(nil)
+	maxHash   = common.HexToHash("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+	errNoRangePeers = errors.New("no peers serving the state ranges")
+	errRangeStitch  = errors.New("state ranges don't match the state root")
+	errEmptyRange   = errors.New("peer doesn't have the requested state")
+)
+
+// rangeReq is a range based state retrieval, sent in place of a batch of trie
+// node hashes. Exactly one of the account, storage or code fields is set.
+type rangeReq struct {
+	id       uint64
+	accounts *accountTask   // Account range to retrieve
+	storage  []*storageTask // Storage tries to retrieve, only the first may be resumed
+	codes    []common.Hash  // Contract codes to retrieve
+	response *rangePack     // Response data of the peer (nil for timeouts)
+}
+
+// accountTask is a contiguous range of the account trie being retrieved.
+type accountTask struct {
+	next    common.Hash     // Hash of the next account to retrieve
+	last    common.Hash     // Hash of the last account of the range
+	trie    *trie.RangeTrie // Part of the account trie spanning the range
+	pending []*accountEntry // Retrieved accounts waiting for their storage and code
+	busy    bool            // Whether the range is currently being retrieved
+	done    bool            // Whether all accounts of the range were retrieved
+}
+
+// accountEntry is a retrieved account. It's only added to the account trie once
+// all its storage and code is stored, so that any subtrie present in the database
+// is complete, which the trie node sync relies on.
+type accountEntry struct {
+	task *accountTask
+	hash common.Hash
+	body []byte
+	deps int // Number of storage tries and codes still missing
+}
+
+// storageTask is a storage trie being retrieved, shared by all the accounts with
+// the same storage root.
+type storageTask struct {
+	account common.Hash     // Hash of an account holding the storage
+	root    common.Hash     // Root of the storage trie
+	next    common.Hash     // Hash of the next slot to retrieve
+	trie    *trie.RangeTrie // Storage trie rebuilt from the retrieved slots
+	entries []*accountEntry // Accounts waiting for the storage trie
+}
+
+// rangeSync retrieves a state trie as contiguous ranges of accounts and storage
+// slots, verified against the state root by the merkle proofs of their edges,
+// and rebuilds the tries locally. Anything it fails to retrieve is left to the
+// trie node sync following it.
+type rangeSync struct {
+	s *stateSync
+
+	accounts  []*accountTask                  // Account ranges covering the state trie
+	storage   map[common.Hash]*storageTask    // Storage tries being retrieved, by root
+	queue     []*storageTask                  // Storage tries waiting for retrieval
+	codes     map[common.Hash][]*accountEntry // Contract codes being retrieved
+	codeQueue []common.Hash                   // Contract codes waiting for retrieval
+	stored    map[common.Hash]struct{}        // Storage roots and codes in the uncommitted batch
+
+	failed   map[string]struct{} // Peers not serving the state being synced
+	inflight int                 // Number of requests in flight
+	nextID   uint64              // Id of the last request sent
+
+	batch               hpbdb.Batch
+	accountsUncommitted int
+	slotsUncommitted    int
+	codesUncommitted    int
+}
+
+// newRangeSync creates a range based retrieval of the state trie of s, split
+// into stateRangeChunks account ranges retrieved concurrently.
+func newRangeSync(s *stateSync) *rangeSync {
+	r := &rangeSync{
+		s:       s,
+		storage: make(map[common.Hash]*storageTask),
+		codes:   make(map[common.Hash][]*accountEntry),
+		stored:  make(map[common.Hash]struct{}),
+		failed:  make(map[string]struct{}),
+	}
+	step := new(big.Int).Div(new(big.Int).Lsh(common.Big1, 256), big.NewInt(stateRangeChunks))
+	for i := 0; i < stateRangeChunks; i++ {
+		next := common.BigToHash(new(big.Int).Mul(step, big.NewInt(int64(i))))
+		last := common.BigToHash(new(big.Int).Sub(new(big.Int).Mul(step, big.NewInt(int64(i+1))), common.Big1))
+		r.accounts = append(r.accounts, &accountTask{
+			next: next,
+			last: last,
+			trie: trie.NewRangeTrie(next[:], last[:]),
+		})
+	}
+	return r
+}
+
+// run retrieves the state ranges until the whole state is rebuilt, the sync is
+// cancelled or no peer is left to serve the remaining ranges.
+func (r *rangeSync) run(newPeer <-chan *peerConnection) error {
+	r.batch = r.s.syn.stateDB.NewBatch()
+
+	for !r.finished() {
+		if err := r.commit(false); err != nil {
+			return err
+		}
+		r.assignTasks()
+
+		// Leave the rest to the trie node sync if nobody can serve it
+		if r.inflight == 0 {
+			if err := r.commit(true); err != nil {
+				return err
+			}
+			return errNoRangePeers
+		}
+		select {
+		case <-newPeer:
+			// New peer arrived, try to assign it download tasks
+
+		case <-r.s.cancel:
+			return errCancelStateFetch
+
+		case req := <-r.s.deliver:
+			r.inflight--
+			r.process(req)
+		}
+	}
+	return r.stitch()
+}
+
+// finished returns whether all the account ranges were retrieved.
+func (r *rangeSync) finished() bool {
+	for _, task := range r.accounts {
+		if !task.done || len(task.pending) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// stitch joins the retrieved account ranges into the state trie and writes its
+// root into the database if it matches the synced one.
+func (r *rangeSync) stitch() error {
+	tries := make([]*trie.RangeTrie, len(r.accounts))
+	for i, task := range r.accounts {
+		tries[i] = task.trie
+	}
+	root, err := trie.StitchRanges(r.batch, tries)
+	if err != nil {
+		return err
+	}
+	if root != r.s.root {
+		log.Warn("State ranges don't match the state root", "have", root, "want", r.s.root)
+		if err := r.commit(true); err != nil {
+			return err
+		}
+		return errRangeStitch
+	}
+	return r.commit(true)
+}
+
+func (r *rangeSync) commit(force bool) error {
+	if !force && r.batch.ValueSize() < hpbdb.IdealBatchSize {
+		return nil
+	}
+	start := time.Now()
+	if err := r.batch.Write(); err != nil {
+		return fmt.Errorf("DB write error: %v", err)
+	}
+	r.batch = r.s.syn.stateDB.NewBatch()
+	r.stored = make(map[common.Hash]struct{})
+	r.updateStats(time.Since(start))
+	return nil
+}
+
+// assignTasks assigns a range request to every idle peer serving state ranges
+// which didn't fail to serve the synced state yet.
+func (r *rangeSync) assignTasks() {
+	peers, _ := r.s.syn.peers.StateRangeIdlePeers()
+	for _, p := range peers {
+		if _, ok := r.failed[p.id]; ok {
+			continue
+		}
+		req := r.fillTasks()
+		if req == nil {
+			return
+		}
+		p.log.Trace("Requesting new state range", "id", req.id, "accounts", req.accounts != nil, "storage", len(req.storage), "codes", len(req.codes))
+		select {
+		case r.s.syn.trackStateReq <- &stateReq{peer: p, timeout: r.s.syn.requestTTL(), ranged: req}:
+			r.inflight++
+			p.FetchStateRange(r.s.root, req)
+		case <-r.s.cancel:
+			return
+		}
+	}
+}
+
+// fillTasks creates the next range request. Codes and storage are preferred to
+// new accounts, as they allow the already retrieved accounts to be stored.
+func (r *rangeSync) fillTasks() *rangeReq {
+	r.nextID++
+	req := &rangeReq{id: r.nextID}
+
+	if len(r.codeQueue) > 0 {
+		n := len(r.codeQueue)
+		if n > maxCodeFetch {
+			n = maxCodeFetch
+		}
+		req.codes = append([]common.Hash{}, r.codeQueue[:n]...)
+		r.codeQueue = r.codeQueue[n:]
+		return req
+	}
+	if len(r.queue) > 0 {
+		// A partially retrieved storage trie is resumed on its own, any number
+		// of fresh ones are requested together
+		if r.queue[0].next != (common.Hash{}) {
+			req.storage = []*storageTask{r.queue[0]}
+			r.queue = r.queue[1:]
+			return req
+		}
+		var rest []*storageTask
+		for _, task := range r.queue {
+			if len(req.storage) < maxStorageFetch && task.next == (common.Hash{}) {
+				req.storage = append(req.storage, task)
+			} else {
+				rest = append(rest, task)
+			}
+		}
+		r.queue = rest
+		return req
+	}
+	for _, task := range r.accounts {
+		if !task.busy && !task.done && len(task.pending) < maxPendingAccounts {
+			task.busy = true
+			req.accounts = task
+			return req
+		}
+	}
+	return nil
+}
+
+// requeue puts the tasks of a failed request back into the retrieval queues.
+func (r *rangeSync) requeue(req *rangeReq) {
+	if req.accounts != nil {
+		req.accounts.busy = false
+	}
+	r.queue = append(append([]*storageTask{}, req.storage...), r.queue...)
+	r.codeQueue = append(r.codeQueue, req.codes...)
+}
+
+// process injects a delivered state range into the rebuilt tries. Peers not
+// having the state are not asked again, peers delivering invalid ranges are
+// dropped.
+func (r *rangeSync) process(req *stateReq) {
+	ranged := req.ranged
+	if req.timedOut() {
+		req.peer.SetNodeDataIdle(0)
+		if !req.dropped {
+			log.Debug("State range request timed out", "peer", req.peer.id)
+		}
+		r.failed[req.peer.id] = struct{}{}
+		r.requeue(ranged)
+		return
+	}
+	req.peer.SetNodeDataIdle(ranged.response.Items())
+
+	var err error
+	switch res := ranged.response; {
+	case res.accounts != nil:
+		err = r.processAccounts(ranged.accounts, res.accounts)
+	case res.storage != nil:
+		err = r.processStorage(ranged.storage, res.storage)
+	default:
+		err = r.processCodes(ranged.codes, res.codes)
+	}
+	switch err {
+	case nil:
+		// If we're inside the critical section, reset fail counter since we progressed.
+		if atomic.LoadUint32(&r.s.syn.fsPivotFails) > 1 {
+			log.Trace("Fast-sync progressed, resetting fail counter", "previous", atomic.LoadUint32(&r.s.syn.fsPivotFails))
+			atomic.StoreUint32(&r.s.syn.fsPivotFails, 1) // Don't ever reset to 0, as that will unlock the pivot block
+		}
+	case errEmptyRange:
+		log.Debug("Peer doesn't serve the synced state", "peer", req.peer.id, "root", r.s.root)
+		r.failed[req.peer.id] = struct{}{}
+		r.requeue(ranged)
+	default:
+		log.Warn("Invalid state range delivered, dropping peer", "peer", req.peer.id, "err", err)
+		r.failed[req.peer.id] = struct{}{}
+		r.requeue(ranged)
+		r.s.syn.dropPeer(req.peer.id)
+	}
+}
+
+// processAccounts verifies a delivered account range and queues the storage and
+// code retrievals of its accounts.
+func (r *rangeSync) processAccounts(task *accountTask, res *accountRangeData) error {
+	if len(res.Accounts) == 0 && len(res.Proof) == 0 {
+		return errEmptyRange
+	}
+	keys := make([][]byte, len(res.Accounts))
+	vals := make([][]byte, len(res.Accounts))
+	for i, acc := range res.Accounts {
+		keys[i], vals[i] = acc.Hash[:], acc.Body
+	}
+	last := task.next
+	if len(res.Accounts) > 0 {
+		last = res.Accounts[len(res.Accounts)-1].Hash
+	}
+	more, err := trie.VerifyRangeProof(r.s.root, task.next[:], last[:], keys, vals, res.Proof)
+	if err != nil {
+		return err
+	}
+	// Decode all the accounts before touching the task, an unproven range may
+	// span the whole trie, only the part within the task is retained
+	var (
+		entries  []*accountEntry
+		accounts []*state.Account
+	)
+	for _, acc := range res.Accounts {
+		if bytes.Compare(acc.Hash[:], task.next[:]) < 0 {
+			continue
+		}
+		if bytes.Compare(acc.Hash[:], task.last[:]) > 0 {
+			break
+		}
+		account := new(state.Account)
+		if err := rlp.DecodeBytes(acc.Body, account); err != nil {
+			return fmt.Errorf("invalid account %x: %v", acc.Hash, err)
+		}
+		entries = append(entries, &accountEntry{task: task, hash: acc.Hash, body: acc.Body})
+		accounts = append(accounts, account)
+	}
+	task.busy = false
+	for i, entry := range entries {
+		if accounts[i].Root != emptyRoot {
+			r.addStorage(entry, accounts[i].Root)
+		}
+		if hash := common.BytesToHash(accounts[i].CodeHash); hash != emptyCode {
+			r.addCode(entry, hash)
+		}
+		task.pending = append(task.pending, entry)
+	}
+	r.accountsUncommitted += len(entries)
+
+	if next, overflow := incHash(last); !more || overflow || bytes.Compare(last[:], task.last[:]) >= 0 {
+		task.done = true
+	} else {
+		task.next = next
+	}
+	return r.flush(task)
+}
+
+// processStorage verifies delivered storage ranges and completes the storage
+// tries retrieved entirely.
+func (r *rangeSync) processStorage(tasks []*storageTask, res *storageRangesData) error {
+	if len(res.Slots) == 0 {
+		return errEmptyRange
+	}
+	if len(res.Slots) > len(tasks) {
+		return fmt.Errorf("unrequested storage ranges: have %d, want %d", len(res.Slots), len(tasks))
+	}
+	// Verify all the ranges before touching any task, only the last one may be
+	// incomplete, proven by its edges
+	var (
+		keys = make([][][]byte, len(res.Slots))
+		vals = make([][][]byte, len(res.Slots))
+		more = make([]bool, len(res.Slots))
+	)
+	for i, slots := range res.Slots {
+		task := tasks[i]
+
+		var proof []rlp.RawValue
+		if i == len(res.Slots)-1 {
+			proof = res.Proof
+		}
+		if len(proof) == 0 && task.next != (common.Hash{}) {
+			return fmt.Errorf("unproven storage range of %x", task.account)
+		}
+		keys[i] = make([][]byte, len(slots))
+		vals[i] = make([][]byte, len(slots))
+		for j, slot := range slots {
+			keys[i][j], vals[i][j] = slot.Hash[:], slot.Body
+		}
+		last := task.next[:]
+		if len(slots) > 0 {
+			last = keys[i][len(slots)-1]
+		}
+		var err error
+		if more[i], err = trie.VerifyRangeProof(task.root, task.next[:], last, keys[i], vals[i], proof); err != nil {
+			return fmt.Errorf("invalid storage range of %x: %v", task.account, err)
+		}
+	}
+	var resumed []*storageTask
+	for i, task := range tasks[:len(res.Slots)] {
+		for j := range keys[i] {
+			if err := task.trie.Update(keys[i][j], vals[i][j]); err != nil {
+				return err
+			}
+		}
+		r.slotsUncommitted += len(keys[i])
+
+		if more[i] {
+			next, overflow := incHash(common.BytesToHash(keys[i][len(keys[i])-1]))
+			if !overflow {
+				task.next = next
+				if err := task.trie.Commit(r.batch); err != nil {
+					return err
+				}
+				resumed = append(resumed, task)
+				continue
+			}
+		}
+		if err := task.trie.Finish(r.batch); err != nil {
+			return err
+		}
+		root, err := trie.StitchRanges(r.batch, []*trie.RangeTrie{task.trie})
+		if err != nil {
+			return err
+		}
+		if root != task.root {
+			return fmt.Errorf("storage trie mismatch of %x: have %x, want %x", task.account, root, task.root)
+		}
+		delete(r.storage, task.root)
+		r.stored[task.root] = struct{}{}
+		if err := r.resolve(task.entries); err != nil {
+			return err
+		}
+	}
+	// Resume the incomplete storage tries first, followed by the undelivered ones
+	r.queue = append(append(resumed, tasks[len(res.Slots):]...), r.queue...)
+	return nil
+}
+
+// processCodes stores delivered contract codes, requeuing the missing ones.
+func (r *rangeSync) processCodes(hashes []common.Hash, res *byteCodesData) error {
+	if len(res.Codes) == 0 {
+		return errEmptyRange
+	}
+	requested := make(map[common.Hash]struct{}, len(hashes))
+	for _, hash := range hashes {
+		requested[hash] = struct{}{}
+	}
+	delivered := make([]common.Hash, len(res.Codes))
+	for i, code := range res.Codes {
+		delivered[i] = crypto.Keccak256Hash(code)
+		if _, ok := requested[delivered[i]]; !ok {
+			return fmt.Errorf("unrequested contract code %x", delivered[i])
+		}
+	}
+	for i, code := range res.Codes {
+		hash := delivered[i]
+		if _, ok := requested[hash]; !ok {
+			continue // Duplicate delivery
+		}
+		delete(requested, hash)
+		if err := r.batch.Put(hash[:], code); err != nil {
+			return err
+		}
+		r.stored[hash] = struct{}{}
+		r.codesUncommitted++
+
+		entries := r.codes[hash]
+		delete(r.codes, hash)
+		if err := r.resolve(entries); err != nil {
+			return err
+		}
+	}
+	for _, hash := range hashes {
+		if _, ok := requested[hash]; ok {
+			r.codeQueue = append(r.codeQueue, hash)
+		}
+	}
+	return nil
+}
+
+// addStorage queues the retrieval of the storage trie of an account, unless it's
+// already stored or being retrieved for another account.
+func (r *rangeSync) addStorage(entry *accountEntry, root common.Hash) {
+	if r.has(root) {
+		return
+	}
+	entry.deps++
+	if task, ok := r.storage[root]; ok {
+		task.entries = append(task.entries, entry)
+		return
+	}
+	task := &storageTask{
+		account: entry.hash,
+		root:    root,
+		trie:    trie.NewRangeTrie(common.Hash{}.Bytes(), maxHash[:]),
+		entries: []*accountEntry{entry},
+	}
+	r.storage[root] = task
+	r.queue = append(r.queue, task)
+}
+
+// addCode queues the retrieval of the code of an account, unless it's already
+// stored or being retrieved for another account.
+func (r *rangeSync) addCode(entry *accountEntry, hash common.Hash) {
+	if r.has(hash) {
+		return
+	}
+	entry.deps++
+	if entries, ok := r.codes[hash]; ok {
+		r.codes[hash] = append(entries, entry)
+		return
+	}
+	r.codes[hash] = []*accountEntry{entry}
+	r.codeQueue = append(r.codeQueue, hash)
+}
+
+// has returns whether a storage root or code is already stored locally.
+func (r *rangeSync) has(hash common.Hash) bool {
+	if _, ok := r.stored[hash]; ok {
+		return true
+	}
+	ok, _ := r.s.syn.stateDB.Has(hash[:])
+	return ok
+}
+
+// resolve marks a storage trie or code of the given accounts stored, adding the
+// accounts without any other missing data to the account trie.
+func (r *rangeSync) resolve(entries []*accountEntry) error {
+	for _, entry := range entries {
+		entry.deps--
+	}
+	for _, entry := range entries {
+		if err := r.flush(entry.task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush adds the leading accounts of a range with all their data stored to its
+// account trie, keeping the insertion order.
+func (r *rangeSync) flush(task *accountTask) error {
+	added := 0
+	for len(task.pending) > 0 && task.pending[0].deps == 0 {
+		entry := task.pending[0]
+		if err := task.trie.Update(entry.hash[:], entry.body); err != nil {
+			return err
+		}
+		task.pending[0] = nil
+		task.pending = task.pending[1:]
+		added++
+	}
+	if task.done && len(task.pending) == 0 {
+		return task.trie.Finish(r.batch)
+	}
+	if added > 0 {
+		return task.trie.Commit(r.batch)
+	}
+	return nil
+}
+
+// updateStats bumps the state sync progress counters and displays a log message
+// for the user to see.
+func (r *rangeSync) updateStats(duration time.Duration) {
+	r.s.syn.syncStatsLock.Lock()
+	defer r.s.syn.syncStatsLock.Unlock()
+
+	written := r.accountsUncommitted + r.slotsUncommitted + r.codesUncommitted
+	r.s.syn.syncStatsState.processed += uint64(written)
+
+	if written > 0 {
+		log.Info("Imported new state ranges", "accounts", r.accountsUncommitted, "slots", r.slotsUncommitted, "codes", r.codesUncommitted, "elapsed", common.PrettyDuration(duration), "processed", r.s.syn.syncStatsState.processed, "storage", len(r.storage), "pending", len(r.codes))
+	}
+	r.accountsUncommitted, r.slotsUncommitted, r.codesUncommitted = 0, 0, 0
+}
+
+// incHash returns the hash following h, reporting whether it overflowed.
+func incHash(h common.Hash) (common.Hash, bool) {
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i]++
+		if h[i] != 0 {
+			return h, false
+		}
+	}
+	return h, true
+}
+
+// responseLimit returns the size at which to stop serving a state range request.
+func responseLimit(bytes uint64) uint64 {
+	if bytes == 0 || bytes > softResponseLimit {
+		return softResponseLimit
+	}
+	return bytes
+}
+
+// serveAccountRange retrieves a range of accounts from the state trie with the
+// requested root, along with the merkle proofs of its edges. An empty response
+// is returned if the state is not available.
+func serveAccountRange(db trie.Database, req *getAccountRangeData) *accountRangeData {
+	res := &accountRangeData{ID: req.ID}
+	tr, err := trie.New(req.Root, db)
+	if err != nil {
+		return res
+	}
+	var (
+		limit = responseLimit(req.Bytes)
+		size  uint64
+		last  common.Hash
+	)
+	it := trie.NewIterator(tr.NodeIterator(req.Origin[:]))
+	for it.Next() {
+		last = common.BytesToHash(it.Key)
+		res.Accounts = append(res.Accounts, &accountData{Hash: last, Body: common.CopyBytes(it.Value)})
+		size += uint64(common.HashLength + len(it.Value))
+		if bytes.Compare(last[:], req.Limit[:]) >= 0 || size >= limit {
+			break
+		}
+	}
+	if it.Err != nil {
+		return &accountRangeData{ID: req.ID}
+	}
+	if len(res.Accounts) == 0 {
+		res.Proof = tr.Prove(req.Origin[:])
+	} else {
+		res.Proof = tr.ProveRange(req.Origin[:], last[:])
+	}
+	if res.Proof == nil {
+		return &accountRangeData{ID: req.ID}
+	}
+	return res
+}
+
+// serveStorageRanges retrieves the storage slots of a batch of accounts of the
+// state trie with the requested root. Only the storage of the last account may
+// be truncated or start past the first slot, in which case the merkle proofs of
+// its edges are attached.
+func serveStorageRanges(db trie.Database, req *getStorageRangesData) *storageRangesData {
+	res := &storageRangesData{ID: req.ID}
+	accTrie, err := trie.New(req.Root, db)
+	if err != nil {
+		return res
+	}
+	var (
+		limit = responseLimit(req.Bytes)
+		size  uint64
+	)
+	for i, hash := range req.Accounts {
+		if size >= limit || i >= maxStorageFetch {
+			break
+		}
+		blob, err := accTrie.TryGet(hash[:])
+		if err != nil || len(blob) == 0 {
+			break
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			break
+		}
+		stTrie, err := trie.New(account.Root, db)
+		if err != nil {
+			break
+		}
+		var (
+			origin    common.Hash
+			slots     []*storageData
+			last      common.Hash
+			truncated bool
+		)
+		if i == 0 {
+			origin = req.Origin
+		}
+		it := trie.NewIterator(stTrie.NodeIterator(origin[:]))
+		for it.Next() {
+			last = common.BytesToHash(it.Key)
+			slots = append(slots, &storageData{Hash: last, Body: common.CopyBytes(it.Value)})
+			size += uint64(common.HashLength + len(it.Value))
+			if size >= limit {
+				truncated = true
+				break
+			}
+		}
+		if it.Err != nil {
+			break
+		}
+		if !truncated && origin == (common.Hash{}) {
+			res.Slots = append(res.Slots, slots)
+			continue
+		}
+		if len(slots) == 0 {
+			res.Proof = stTrie.Prove(origin[:])
+		} else {
+			res.Proof = stTrie.ProveRange(origin[:], last[:])
+		}
+		if res.Proof != nil {
+			res.Slots = append(res.Slots, slots)
+		}
+		break
+	}
+	return res
+}
+
+// serveByteCodes retrieves the requested contract codes, skipping unknown ones.
+func serveByteCodes(db trie.Database, req *getByteCodesData) *byteCodesData {
+	res := &byteCodesData{ID: req.ID}
+	var (
+		limit = responseLimit(req.Bytes)
+		size  uint64
+	)
+	for i, hash := range req.Hashes {
+		if size >= limit || i >= MaxStateFetch {
+			break
+		}
+		if code, err := db.Get(hash[:]); err == nil && len(code) > 0 {
+			res.Codes = append(res.Codes, code)
+			size += uint64(len(code))
+		}
+	}
+	return res
+}
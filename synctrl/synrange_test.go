@@ -0,0 +1,179 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package synctrl
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/blockchain/state"
+	hpbdb "github.com/hpb-project/go-hpb/blockchain/storage"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/rlp"
+	"github.com/hpb-project/go-hpb/common/trie"
+)
+
+// makeRangeTestState creates a state with plain accounts and contracts, some of
+// them sharing their code and storage, some with a large storage.
+func makeRangeTestState(t *testing.T) (*hpbdb.MemDatabase, common.Hash) {
+	db, _ := hpbdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	for i := 0; i < 500; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.SetBalance(addr, big.NewInt(int64(i)))
+		statedb.SetNonce(addr, uint64(i))
+
+		switch i % 10 {
+		case 0:
+			// Contracts with the same code and storage
+			statedb.SetCode(addr, []byte{0x60, 0x00})
+			statedb.SetState(addr, common.Hash{1}, common.Hash{2})
+		case 1:
+			// Contracts with unique code and storage
+			statedb.SetCode(addr, []byte{0x60, byte(i)})
+			for j := 0; j < 20; j++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(i+j+1))))
+			}
+		}
+	}
+	// A contract with a storage spanning many responses
+	large := common.BigToAddress(big.NewInt(1000))
+	statedb.SetCode(large, []byte{0x60, 0xff})
+	for j := 0; j < 2000; j++ {
+		statedb.SetState(large, common.BigToHash(new(big.Int).SetUint64(uint64(j))), common.Hash{0xff})
+	}
+	root, err := statedb.CommitTo(db, false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	return db, root
+}
+
+// syncRanges runs a range sync of root from src into dst, serving the requests
+// straight from src with the given response size limit.
+func syncRanges(t *testing.T, src trie.Database, dst hpbdb.Database, root common.Hash, limit uint64) error {
+	r := newRangeSync(&stateSync{syn: &Syncer{stateDB: dst}, root: root})
+	r.batch = dst.NewBatch()
+
+	for !r.finished() {
+		req := r.fillTasks()
+		if req == nil {
+			t.Fatalf("range sync stalled")
+		}
+		var err error
+		switch {
+		case req.accounts != nil:
+			err = r.processAccounts(req.accounts, serveAccountRange(src, &getAccountRangeData{ID: req.id, Root: root, Origin: req.accounts.next, Limit: req.accounts.last, Bytes: limit}))
+		case len(req.storage) > 0:
+			accounts := make([]common.Hash, len(req.storage))
+			for i, task := range req.storage {
+				accounts[i] = task.account
+			}
+			err = r.processStorage(req.storage, serveStorageRanges(src, &getStorageRangesData{ID: req.id, Root: root, Accounts: accounts, Origin: req.storage[0].next, Bytes: limit}))
+		default:
+			err = r.processCodes(req.codes, serveByteCodes(src, &getByteCodesData{ID: req.id, Hashes: req.codes, Bytes: limit}))
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.commit(false); err != nil {
+			return err
+		}
+	}
+	return r.stitch()
+}
+
+// checkRangeTestState verifies that dst holds the complete state of src.
+func checkRangeTestState(t *testing.T, src, dst trie.Database, root common.Hash) {
+	srcTrie, _ := trie.New(root, src)
+	dstTrie, err := trie.New(root, dst)
+	if err != nil {
+		t.Fatalf("synced state missing: %v", err)
+	}
+	srcIt, dstIt := trie.NewIterator(srcTrie.NodeIterator(nil)), trie.NewIterator(dstTrie.NodeIterator(nil))
+	for srcIt.Next() {
+		if !dstIt.Next() || !bytes.Equal(srcIt.Key, dstIt.Key) || !bytes.Equal(srcIt.Value, dstIt.Value) {
+			t.Fatalf("account %x mismatch", srcIt.Key)
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(dstIt.Value, &account); err != nil {
+			t.Fatalf("account %x undecodable: %v", dstIt.Key, err)
+		}
+		storage, err := trie.New(account.Root, dst)
+		if err != nil {
+			t.Fatalf("account %x storage missing: %v", dstIt.Key, err)
+		}
+		it := storage.NodeIterator(nil)
+		for it.Next(true) {
+		}
+		if it.Error() != nil {
+			t.Fatalf("account %x storage incomplete: %v", dstIt.Key, it.Error())
+		}
+		if hash := common.BytesToHash(account.CodeHash); hash != emptyCode {
+			if ok, _ := dst.Has(hash[:]); !ok {
+				t.Fatalf("account %x code missing", dstIt.Key)
+			}
+		}
+	}
+	if dstIt.Next() {
+		t.Fatalf("unexpected account %x", dstIt.Key)
+	}
+	if dstIt.Err != nil {
+		t.Fatalf("synced state incomplete: %v", dstIt.Err)
+	}
+}
+
+// Tests that a state is rebuilt from its ranges, regardless of how many are
+// needed to deliver it.
+func TestRangeSync(t *testing.T) {
+	src, root := makeRangeTestState(t)
+	for _, limit := range []uint64{100, 1000, 10000, softResponseLimit} {
+		dst, _ := hpbdb.NewMemDatabase()
+		if err := syncRanges(t, src, dst, root, limit); err != nil {
+			t.Fatalf("limit %d: range sync failed: %v", limit, err)
+		}
+		checkRangeTestState(t, src, dst, root)
+	}
+}
+
+// Tests that missing and tampered state ranges are rejected.
+func TestRangeSyncInvalid(t *testing.T) {
+	src, root := makeRangeTestState(t)
+
+	r := newRangeSync(&stateSync{syn: &Syncer{}, root: root})
+	task := r.accounts[0]
+
+	res := serveAccountRange(src, &getAccountRangeData{Root: common.Hash{1}, Limit: task.last})
+	if err := r.processAccounts(task, res); err != errEmptyRange {
+		t.Fatalf("missing state error mismatch: have %v, want %v", err, errEmptyRange)
+	}
+	res = serveAccountRange(src, &getAccountRangeData{Root: root, Limit: task.last, Bytes: 1000})
+	res.Accounts[1].Body = common.CopyBytes(res.Accounts[0].Body)
+	if err := r.processAccounts(task, res); err == nil {
+		t.Fatalf("tampered account range accepted")
+	}
+	res = serveAccountRange(src, &getAccountRangeData{Root: root, Limit: task.last, Bytes: 1000})
+	res.Accounts = append(res.Accounts[:2], res.Accounts[3:]...)
+	if err := r.processAccounts(task, res); err == nil {
+		t.Fatalf("gapped account range accepted")
+	}
+	if len(task.pending) > 0 || task.next != (common.Hash{}) {
+		t.Fatalf("rejected range modified the task")
+	}
+}
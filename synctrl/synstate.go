@@ -40,14 +40,29 @@ type stateReq struct {
 	timer    *time.Timer                // Timer to fire when the RTT timeout expires
 	peer     *peerConnection            // Peer that we're requesting from
 	response [][]byte                   // Response data of the peer (nil for timeouts)
+	ranged   *rangeReq                  // Range based retrieval in place of the items (nil for trie nodes)
 	dropped  bool                       // Flag whether the peer dropped off early
 }
 
 // timedOut returns if this request timed out.
 func (req *stateReq) timedOut() bool {
+	if req.ranged != nil {
+		return req.ranged.response == nil
+	}
 	return req.response == nil
 }
 
+// answeredBy returns whether the delivered packet is a response to this request.
+func (req *stateReq) answeredBy(pack dataPack) bool {
+	switch pack := pack.(type) {
+	case *statePack:
+		return req.ranged == nil
+	case *rangePack:
+		return req.ranged != nil && req.ranged.id == pack.id
+	}
+	return false
+}
+
 // stateSyncStats is a collection of progress stats to report during a state trie
 // sync to RPC requests as well as to display in user logs.
 type stateSyncStats struct {
@@ -60,11 +75,13 @@ type stateSyncStats struct {
 // stateSync schedules requests for downloading a particular state trie defined
 // by a given state root.
 type stateSync struct {
-	syn *Syncer // syncer instance to access and manage current peerset
+	syn  *Syncer     // syncer instance to access and manage current peerset
+	root common.Hash // State root currently being synced
 
 	sched  *trie.TrieSync             // State trie sync scheduler defining the tasks
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
 	tasks  map[common.Hash]*stateTask // Set of tasks currently queued for retrieval
+	ranges *rangeSync                 // Range based retrieval preceding the trie node sync
 
 	numUncommitted   int
 	bytesUncommitted int
@@ -85,8 +102,9 @@ type stateTask struct {
 // newStateSync creates a new state trie download scheduler. This method does not
 // yet start the sync. The user needs to call run to initiate.
 func newStateSync(syn *Syncer, root common.Hash) *stateSync {
-	return &stateSync{
+	s := &stateSync{
 		syn:     syn,
+		root:    root,
 		sched:   state.NewStateSync(root, syn.stateDB),
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
@@ -94,6 +112,8 @@ func newStateSync(syn *Syncer, root common.Hash) *stateSync {
 		cancel:  make(chan struct{}),
 		done:    make(chan struct{}),
 	}
+	s.ranges = newRangeSync(s)
+	return s
 }
 
 // run starts the task assignment and response processing loop, blocking until
@@ -128,6 +148,18 @@ func (s *stateSync) loop() error {
 	peerSub := s.syn.peers.SubscribeNewPeers(newPeer)
 	defer peerSub.Unsubscribe()
 
+	// Retrieve the state in contiguous ranges from the peers serving them, the
+	// trie node sync below fills in anything the range sync didn't finish.
+	if s.sched.Pending() > 0 {
+		switch err := s.ranges.run(newPeer); err {
+		case nil:
+		case errNoRangePeers, errRangeStitch:
+			log.Info("Range state sync incomplete, syncing trie nodes", "err", err)
+		default:
+			return err
+		}
+		s.sched = state.NewStateSync(s.root, s.syn.stateDB)
+	}
 	// Keep assigning new tasks until the sync completes or aborts
 	for s.sched.Pending() > 0 {
 		if err := s.commit(false); err != nil {
@@ -60,13 +60,19 @@ func (this *SynCtrl) txsyncLoop() {
 
 	// send starts a sending a pack of transactions from the sync.
 	send := func(s *txsync) {
-		// Fill pack with transactions up to the target size.
+		// Fill pack with transactions up to the target size, only counting
+		// the hashes for peers accepting announcements.
 		size := common.StorageSize(0)
 		pack.p = s.p
 		pack.txs = pack.txs[:0]
+		announce := s.p.HasCap(p2p.TxAnnounceCap)
 		for i := 0; i < len(s.txs) && size < txsyncPackSize; i++ {
 			pack.txs = append(pack.txs, s.txs[i])
-			size += s.txs[i].Size()
+			if announce {
+				size += common.HashLength
+			} else {
+				size += s.txs[i].Size()
+			}
 		}
 		// Remove the transactions that will be sent.
 		s.txs = s.txs[:copy(s.txs, s.txs[len(pack.txs):])]
@@ -74,9 +80,17 @@ func (this *SynCtrl) txsyncLoop() {
 			delete(pending, s.p.ID())
 		}
 		// Send the pack in the background.
-		log.Trace("Sending batch of transactions", "count", len(pack.txs), "bytes", size)
+		log.Trace("Sending batch of transactions", "count", len(pack.txs), "bytes", size, "announce", announce)
 		sending = true
-		go func() { done <- sendTransactions(pack.p, pack.txs) }()
+		if announce {
+			hashes := make([]common.Hash, len(pack.txs))
+			for i, tx := range pack.txs {
+				hashes[i] = tx.Hash()
+			}
+			go func() { done <- sendTxHashes(pack.p, hashes) }()
+		} else {
+			go func() { done <- sendTransactions(pack.p, pack.txs) }()
+		}
 	}
 
 	// pick chooses the next pending sync.
@@ -121,7 +135,19 @@ func (this *SynCtrl) txRoutingLoop() {
 	for {
 		select {
 		case event := <-this.txCh:
-			routTx(event.Tx.Hash(), event.Tx)
+			// Route the transactions queued up meanwhile together, batching
+			// their announcements
+			txs := types.Transactions{event.Tx}
+		gather:
+			for len(txs) < txRouteBatch {
+				select {
+				case event := <-this.txCh:
+					txs = append(txs, event.Tx)
+				default:
+					break gather
+				}
+			}
+			this.routTxs(txs)
 		}
 	}
 }
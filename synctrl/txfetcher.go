@@ -0,0 +1,316 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package synctrl
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/log"
+)
+
+const (
+	txArriveTimeout = 500 * time.Millisecond // Time allowance before an announced transaction is explicitly requested
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	txAnnounceLimit = 4096                   // Maximum number of unique transactions a peer may have announced
+	txFetchLimit    = 256                    // Maximum number of transactions to request from a peer at once
+)
+
+// txHasFn is a callback type to check whether a transaction is already known locally.
+type txHasFn func(common.Hash) bool
+
+// txRequesterFn is a callback type for sending a transaction retrieval request.
+type txRequesterFn func(peer string, hashes []common.Hash) error
+
+// txAnnounce is the hash notification of the availability of a transaction
+// at a peer.
+type txAnnounce struct {
+	hash   common.Hash // Hash of the transaction being announced
+	time   time.Time   // Timestamp of the announcement, or of the retrieval once fetching
+	origin string      // Identifier of the peer originating the notification
+}
+
+// txNotification is a batch of transaction announcements of a single peer.
+type txNotification struct {
+	origin string
+	hashes []common.Hash
+	time   time.Time
+}
+
+// txDelivery is a batch of transactions received from a peer, either pushed or
+// explicitly requested.
+type txDelivery struct {
+	origin string
+	hashes []common.Hash
+}
+
+// TxFetcher retrieves the transactions announced by hash. Every announced
+// transaction is requested from a single announcer at a time once it didn't
+// arrive directly within txArriveTimeout, and retried from the other announcers
+// if the request times out.
+type TxFetcher struct {
+	// Various event channels
+	notify  chan *txNotification
+	deliver chan *txDelivery
+	drop    chan string
+	quit    chan struct{}
+
+	// Announce states
+	announces map[string]int                // Per peer announce counts to prevent memory exhaustion
+	announced map[common.Hash][]*txAnnounce // Announced transactions, not being fetched from these announcers
+	fetching  map[common.Hash]*txAnnounce   // Announced transactions, currently fetching
+
+	// Callbacks
+	hasTx    txHasFn       // Checks whether a transaction is already known locally
+	fetchTxs txRequesterFn // Requests a batch of transactions from a peer
+
+	// Testing hooks
+	fetchingHook func(string, []common.Hash) // Method to call upon starting a transaction fetch
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve announced transactions.
+func NewTxFetcher(hasTx txHasFn, fetchTxs txRequesterFn) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txNotification),
+		deliver:   make(chan *txDelivery),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		announces: make(map[string]int),
+		announced: make(map[common.Hash][]*txAnnounce),
+		fetching:  make(map[common.Hash]*txAnnounce),
+		hasTx:     hasTx,
+		fetchTxs:  fetchTxs,
+	}
+}
+
+// start boots up the transaction fetcher, processing announcements and
+// deliveries until termination requested.
+func (this *TxFetcher) start() {
+	go this.loop()
+}
+
+// stop terminates the transaction fetcher, canceling all pending retrievals.
+func (this *TxFetcher) stop() {
+	close(this.quit)
+}
+
+// Notify announces the fetcher of the availability of a batch of transactions
+// at a peer.
+func (this *TxFetcher) Notify(peer string, hashes []common.Hash, time time.Time) error {
+	select {
+	case this.notify <- &txNotification{origin: peer, hashes: hashes, time: time}:
+		return nil
+	case <-this.quit:
+		return errTerminated
+	}
+}
+
+// Deliver informs the fetcher of transactions received from a peer, whether
+// requested or not, so no one else is asked for them.
+func (this *TxFetcher) Deliver(peer string, hashes []common.Hash) error {
+	select {
+	case this.deliver <- &txDelivery{origin: peer, hashes: hashes}:
+		return nil
+	case <-this.quit:
+		return errTerminated
+	}
+}
+
+// Drop removes all the announcements of a disconnected peer, retrying its
+// pending retrievals from the other announcers.
+func (this *TxFetcher) Drop(peer string) error {
+	select {
+	case this.drop <- peer:
+		return nil
+	case <-this.quit:
+		return errTerminated
+	}
+}
+
+// loop is the main fetcher loop, checking and processing various notification
+// events.
+func (this *TxFetcher) loop() {
+	fetchTimer := time.NewTimer(0)
+	defer fetchTimer.Stop()
+
+	for {
+		select {
+		case <-this.quit:
+			// Fetcher terminating, abort all operations
+			return
+
+		case notification := <-this.notify:
+			txAnnounceInMeter.Mark(int64(len(notification.hashes)))
+			for _, hash := range notification.hashes {
+				// Make sure the peer isn't DOSing us
+				if this.announces[notification.origin] >= txAnnounceLimit {
+					log.Debug("Peer exceeded outstanding transaction announces", "peer", notification.origin, "limit", txAnnounceLimit)
+					txAnnounceDOSMeter.Mark(1)
+					break
+				}
+				if this.announcedBy(hash, notification.origin) || this.hasTx(hash) {
+					continue
+				}
+				this.announces[notification.origin]++
+				this.announced[hash] = append(this.announced[hash], &txAnnounce{hash: hash, time: notification.time, origin: notification.origin})
+			}
+
+		case delivery := <-this.deliver:
+			// Transactions arrived, remove all traces of their announcements
+			for _, hash := range delivery.hashes {
+				if announce, ok := this.fetching[hash]; ok && announce.origin == delivery.origin {
+					txFetchInMeter.Mark(1)
+				}
+				this.forgetHash(hash)
+			}
+
+		case peer := <-this.drop:
+			// Peer disconnected, forget its announcements and retry its retrievals
+			for hash, announce := range this.fetching {
+				if announce.origin == peer {
+					delete(this.fetching, hash)
+				}
+			}
+			for hash, announces := range this.announced {
+				for i, announce := range announces {
+					if announce.origin == peer {
+						announces = append(announces[:i], announces[i+1:]...)
+						break
+					}
+				}
+				if len(announces) == 0 {
+					delete(this.announced, hash)
+				} else {
+					this.announced[hash] = announces
+				}
+			}
+			delete(this.announces, peer)
+
+		case <-fetchTimer.C:
+			// Drop the timed out retrievals, they're retried from other announcers
+			for hash, announce := range this.fetching {
+				if time.Since(announce.time) > txFetchTimeout {
+					log.Trace("Transaction retrieval timed out", "peer", announce.origin, "hash", hash)
+					txFetchTimeoutMeter.Mark(1)
+					this.forgetAnnounce(announce)
+					delete(this.fetching, hash)
+				}
+			}
+			// Request the transactions which didn't arrive directly in time
+			request := make(map[string][]common.Hash)
+			for hash, announces := range this.announced {
+				if _, ok := this.fetching[hash]; ok || time.Since(announces[0].time) < txArriveTimeout-gatherSlack {
+					continue
+				}
+				if this.hasTx(hash) {
+					this.forgetHash(hash)
+					continue
+				}
+				// Pick a random announcer to retrieve from, keeping the others for retries
+				i := rand.Intn(len(announces))
+				announce := announces[i]
+				if len(request[announce.origin]) >= txFetchLimit {
+					continue
+				}
+				if len(announces) == 1 {
+					delete(this.announced, hash)
+				} else {
+					this.announced[hash] = append(announces[:i], announces[i+1:]...)
+				}
+				announce.time = time.Now()
+				this.fetching[hash] = announce
+				request[announce.origin] = append(request[announce.origin], hash)
+			}
+			// Send out all transaction requests
+			for peer, hashes := range request {
+				log.Trace("Fetching announced transactions", "peer", peer, "count", len(hashes))
+				txFetchOutMeter.Mark(int64(len(hashes)))
+
+				peer, hashes := peer, hashes
+				go func() {
+					if this.fetchingHook != nil {
+						this.fetchingHook(peer, hashes)
+					}
+					if err := this.fetchTxs(peer, hashes); err != nil {
+						log.Debug("Failed to request transactions", "peer", peer, "err", err)
+					}
+				}()
+			}
+		}
+		this.rescheduleFetch(fetchTimer)
+	}
+}
+
+// announcedBy returns whether a transaction was already announced by the peer.
+func (this *TxFetcher) announcedBy(hash common.Hash, peer string) bool {
+	if announce, ok := this.fetching[hash]; ok && announce.origin == peer {
+		return true
+	}
+	for _, announce := range this.announced[hash] {
+		if announce.origin == peer {
+			return true
+		}
+	}
+	return false
+}
+
+// rescheduleFetch resets the specified fetch timer to the next announce or
+// retrieval timeout.
+func (this *TxFetcher) rescheduleFetch(fetch *time.Timer) {
+	// Short circuit if no transactions are announced
+	if len(this.announced) == 0 && len(this.fetching) == 0 {
+		return
+	}
+	// Otherwise find the earliest expiring announcement or retrieval
+	earliest := time.Now().Add(txFetchTimeout)
+	for hash, announces := range this.announced {
+		if _, ok := this.fetching[hash]; ok {
+			continue
+		}
+		if deadline := announces[0].time.Add(txArriveTimeout); deadline.Before(earliest) {
+			earliest = deadline
+		}
+	}
+	for _, announce := range this.fetching {
+		if deadline := announce.time.Add(txFetchTimeout); deadline.Before(earliest) {
+			earliest = deadline
+		}
+	}
+	fetch.Reset(earliest.Sub(time.Now()))
+}
+
+// forgetAnnounce releases an announcement from the count of its peer.
+func (this *TxFetcher) forgetAnnounce(announce *txAnnounce) {
+	if this.announces[announce.origin]--; this.announces[announce.origin] <= 0 {
+		delete(this.announces, announce.origin)
+	}
+}
+
+// forgetHash removes all traces of a transaction announcement from the fetcher's
+// internal state.
+func (this *TxFetcher) forgetHash(hash common.Hash) {
+	for _, announce := range this.announced[hash] {
+		this.forgetAnnounce(announce)
+	}
+	delete(this.announced, hash)
+
+	if announce, ok := this.fetching[hash]; ok {
+		this.forgetAnnounce(announce)
+		delete(this.fetching, hash)
+	}
+}
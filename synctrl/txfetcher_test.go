@@ -0,0 +1,199 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package synctrl
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hpb-project/go-hpb/common"
+)
+
+// txFetcherTester is a test simulator for mocking out the local transaction pool
+// and the remote peers.
+type txFetcherTester struct {
+	fetcher *TxFetcher
+
+	known    map[common.Hash]bool  // Transactions already in the pool
+	requests chan txFetcherRequest // Retrieval requests sent out by the fetcher
+	lock     sync.RWMutex
+}
+
+type txFetcherRequest struct {
+	peer   string
+	hashes []common.Hash
+}
+
+// newTxFetcherTester creates a new transaction fetcher test mocker.
+func newTxFetcherTester() *txFetcherTester {
+	tester := &txFetcherTester{
+		known:    make(map[common.Hash]bool),
+		requests: make(chan txFetcherRequest, 16),
+	}
+	tester.fetcher = NewTxFetcher(tester.hasTx, tester.fetchTxs)
+	tester.fetcher.start()
+	return tester
+}
+
+func (f *txFetcherTester) hasTx(hash common.Hash) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.known[hash]
+}
+
+func (f *txFetcherTester) fetchTxs(peer string, hashes []common.Hash) error {
+	f.requests <- txFetcherRequest{peer, hashes}
+	return nil
+}
+
+// expectRequest waits for a retrieval request, checking its peer and contents.
+func (f *txFetcherTester) expectRequest(t *testing.T, peer string, hashes []common.Hash) {
+	select {
+	case req := <-f.requests:
+		if peer != "" && req.peer != peer {
+			t.Fatalf("request peer mismatch: have %s, want %s", req.peer, peer)
+		}
+		if len(req.hashes) != len(hashes) {
+			t.Fatalf("request count mismatch: have %d, want %d", len(req.hashes), len(hashes))
+		}
+		want := make(map[common.Hash]bool)
+		for _, hash := range hashes {
+			want[hash] = true
+		}
+		for _, hash := range req.hashes {
+			if !want[hash] {
+				t.Fatalf("unexpected request for %x", hash)
+			}
+		}
+	case <-time.After(txArriveTimeout + time.Second):
+		t.Fatalf("request timeout")
+	}
+}
+
+// expectNoRequest checks that no retrieval request is sent within the timeout.
+func (f *txFetcherTester) expectNoRequest(t *testing.T, timeout time.Duration) {
+	select {
+	case req := <-f.requests:
+		t.Fatalf("unexpected request to %s for %d transactions", req.peer, len(req.hashes))
+	case <-time.After(timeout):
+	}
+}
+
+func testTxHashes(n int) []common.Hash {
+	hashes := make([]common.Hash, n)
+	for i := range hashes {
+		hashes[i] = common.BytesToHash([]byte{byte(i + 1)})
+	}
+	return hashes
+}
+
+// Tests that announced transactions are requested once they didn't arrive in
+// time, skipping the ones already known locally.
+func TestTxFetcherAnnounce(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.stop()
+
+	hashes := testTxHashes(4)
+	tester.lock.Lock()
+	tester.known[hashes[3]] = true
+	tester.lock.Unlock()
+
+	tester.fetcher.Notify("A", hashes, time.Now())
+	tester.expectNoRequest(t, txArriveTimeout/2)
+	tester.expectRequest(t, "A", hashes[:3])
+}
+
+// Tests that transactions arriving directly aren't requested anymore.
+func TestTxFetcherDeliverBeforeFetch(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.stop()
+
+	hashes := testTxHashes(2)
+	tester.fetcher.Notify("A", hashes, time.Now())
+	tester.fetcher.Notify("B", hashes, time.Now())
+	tester.fetcher.Deliver("C", hashes[:1])
+
+	tester.expectRequest(t, "", hashes[1:])
+	tester.expectNoRequest(t, txArriveTimeout)
+}
+
+// Tests that the same transaction announced by multiple peers is only requested
+// from one of them at a time, and retried from the others if the retrieval
+// times out.
+func TestTxFetcherTimeoutRetry(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.stop()
+
+	hashes := testTxHashes(1)
+	tester.fetcher.Notify("A", hashes, time.Now())
+	tester.fetcher.Notify("B", hashes, time.Now())
+
+	var first txFetcherRequest
+	select {
+	case first = <-tester.requests:
+	case <-time.After(txArriveTimeout + time.Second):
+		t.Fatalf("request timeout")
+	}
+	tester.expectNoRequest(t, txFetchTimeout-time.Second)
+
+	retry := "A"
+	if first.peer == "A" {
+		retry = "B"
+	}
+	tester.expectRequest(t, retry, hashes)
+}
+
+// Tests that the retrievals of a dropped peer are retried from the others.
+func TestTxFetcherDropRetry(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.stop()
+
+	hashes := testTxHashes(1)
+	tester.fetcher.Notify("A", hashes, time.Now())
+	tester.expectRequest(t, "A", hashes)
+
+	tester.fetcher.Notify("B", hashes, time.Now())
+	tester.fetcher.Drop("A")
+	tester.expectRequest(t, "B", hashes)
+}
+
+// Tests that a peer can't announce more transactions than the limit.
+func TestTxFetcherAnnounceLimit(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.stop()
+
+	hashes := make([]common.Hash, txAnnounceLimit+10)
+	for i := range hashes {
+		hashes[i][0], hashes[i][1], hashes[i][2] = byte(i>>16), byte(i>>8), byte(i)
+	}
+	tester.fetcher.Notify("A", hashes, time.Now())
+
+	requested := 0
+	for requested < txAnnounceLimit {
+		select {
+		case req := <-tester.requests:
+			requested += len(req.hashes)
+		case <-time.After(txArriveTimeout + time.Second):
+			t.Fatalf("request timeout with %d requested", requested)
+		}
+	}
+	tester.expectNoRequest(t, txArriveTimeout)
+	if requested != txAnnounceLimit {
+		t.Fatalf("requested transaction count mismatch: have %d, want %d", requested, txAnnounceLimit)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the txpool.
+
+package txpool
+
+import (
+	"github.com/hpb-project/go-hpb/common/metrics"
+)
+
+var (
+	// Metrics for the pending pool
+	pendingGauge          = metrics.NewGauge("txpool/pending")
+	pendingDiscardMeter   = metrics.NewMeter("txpool/pending/discard")   // Dropped for a stale nonce
+	pendingReplaceMeter   = metrics.NewMeter("txpool/pending/replace")   // Replaced by a higher priced transaction
+	pendingRateLimitMeter = metrics.NewMeter("txpool/pending/ratelimit") // Dropped due to rate limiting
+	pendingNofundsMeter   = metrics.NewMeter("txpool/pending/nofunds")   // Dropped due to out-of-funds
+
+	// Metrics for the queued pool
+	queuedGauge          = metrics.NewGauge("txpool/queued")
+	queuedDiscardMeter   = metrics.NewMeter("txpool/queued/discard")
+	queuedReplaceMeter   = metrics.NewMeter("txpool/queued/replace")
+	queuedRateLimitMeter = metrics.NewMeter("txpool/queued/ratelimit")
+	queuedNofundsMeter   = metrics.NewMeter("txpool/queued/nofunds")
+
+	// General tx metrics
+	underpricedMeter = metrics.NewMeter("txpool/underpriced")
+)
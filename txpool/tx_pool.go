@@ -44,7 +44,6 @@ var (
 )
 
 var INSTANCE = atomic.Value{}
-var STOPPED = atomic.Value{}
 
 // blockChain provides the state of blockchain and current gas limit to do
 // some pre checks in tx pool.
@@ -60,6 +59,7 @@ type blockChain interface {
 type TxPool struct {
 	wg           sync.WaitGroup
 	stopCh       chan struct{}
+	stopOnce     sync.Once
 	chain        blockChain
 	chainHeadSub sub.Subscription
 	chainHeadCh  chan bc.ChainHeadEvent
@@ -79,6 +79,9 @@ type TxPool struct {
 
 	priced *txPricedList // All transactions sorted by price
 
+	locals  *accountSet // Set of local transaction to exempt from eviction rules
+	journal *txJournal  // Journal of local transaction to back up to disk
+
 	smu sync.RWMutex // mutex for below.
 
 	currentState  *state.StateDB      // Current state in the blockchain head
@@ -91,11 +94,25 @@ const (
 	TxpoolEventtype event.EventType = 0x01
 )
 
-//NewTxPool Create the transaction pool and start main process loop.
+//NewTxPool Create the transaction pool of the node, or return it if already created.
 func NewTxPool(config config.TxPoolConfiguration, chainConfig *config.ChainConfig, blockChain blockChain) *TxPool {
 	if INSTANCE.Load() != nil {
 		return INSTANCE.Load().(*TxPool)
 	}
+	pool := New(config, chainConfig, blockChain)
+
+	INSTANCE.Store(pool)
+	return pool
+}
+
+// New creates a transaction pool on top of the given chain without registering
+// it as the pool of the node.
+func New(config config.TxPoolConfiguration, chainConfig *config.ChainConfig, blockChain blockChain) *TxPool {
+	//1.Sanitize the input to ensure no vulnerable gas prices or intervals are set
+	if config.Rejournal < time.Second {
+		log.Warn("Sanitizing invalid txpool journal time", "provided", config.Rejournal, "updated", time.Second)
+		config.Rejournal = time.Second
+	}
 	//2.Create the transaction pool with its initial settings
 	pool := &TxPool{
 		config:      config,
@@ -106,15 +123,27 @@ func NewTxPool(config config.TxPoolConfiguration, chainConfig *config.ChainConfi
 		stopCh:      make(chan struct{}),
 	}
 
+	pool.locals = newAccountSet(pool.signer)
 	pool.priced = newTxPricedList(&pool.all)
 
-	INSTANCE.Store(pool)
 	return pool
 }
 
 // Start start txpool.
 func (pool *TxPool) Start() {
 	pool.reset(nil, pool.chain.CurrentBlock().Header())
+
+	// If local transactions and journaling is enabled, load from disk
+	if !pool.config.NoLocals && pool.config.Journal != "" {
+		pool.journal = newTxJournal(pool.config.Journal)
+
+		if err := pool.journal.load(pool.AddLocal); err != nil {
+			log.Warn("Failed to load transaction journal", "err", err)
+		}
+		if err := pool.journal.rotate(pool.local()); err != nil {
+			log.Warn("Failed to rotate transaction journal", "err", err)
+		}
+	}
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
 	// Register Publish TxPre publisher
@@ -136,13 +165,16 @@ func GetTxPool() *TxPool {
 
 //Stop the transaction pool.
 func (pool *TxPool) Stop() {
-	if STOPPED.Load() == nil {
+	pool.stopOnce.Do(func() {
 		//1.stop main process loop
 		pool.stopCh <- struct{}{}
 		//2.wait quit
 		pool.wg.Wait()
-		STOPPED.Store(true)
-	}
+
+		if pool.journal != nil {
+			pool.journal.close()
+		}
+	})
 }
 
 //Main process loop.
@@ -156,6 +188,9 @@ func (pool *TxPool) loop() {
 	report := time.NewTicker(statsReportInterval)
 	defer report.Stop()
 
+	journal := time.NewTicker(pool.config.Rejournal)
+	defer journal.Stop()
+
 	// Track the previous head headers for transaction reorgs
 	head := pool.chain.CurrentBlock()
 
@@ -179,6 +214,10 @@ func (pool *TxPool) loop() {
 			pool.queue.Range(func(k, v interface{}) bool {
 				var tmpBeatsV time.Time
 				addr := k.(common.Address)
+				// Skip local transactions from the eviction mechanism
+				if pool.locals.contains(addr) {
+					return true
+				}
 				t, ok := pool.beats.Load(addr)
 				if ok {
 					tmpBeatsV = t.(time.Time)
@@ -201,6 +240,8 @@ func (pool *TxPool) loop() {
 			// Handle stats reporting ticks
 		case <-report.C:
 			pending, queued := pool.Stats()
+			pendingGauge.Update(int64(pending))
+			queuedGauge.Update(int64(queued))
 			stales := pool.priced.stales
 
 			if pending != prevPending || queued != prevQueued || stales != prevStales {
@@ -208,6 +249,14 @@ func (pool *TxPool) loop() {
 				prevPending, prevQueued, prevStales = pending, queued, stales
 			}
 
+			// Handle local transaction journal rotation
+		case <-journal.C:
+			if pool.journal != nil {
+				if err := pool.journal.rotate(pool.local()); err != nil {
+					log.Warn("Failed to rotate local tx journal", "err", err)
+				}
+			}
+
 			//stop signal
 		case <-pool.stopCh:
 			return
@@ -286,7 +335,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// Inject any transactions discarded due to reorgs
 	log.Debug("txpool reset Reinjecting stale transactions", "count", len(reinject))
 
-	pool.addTxsLocked(reinject)
+	pool.addTxsLocked(reinject, false)
 
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
@@ -311,7 +360,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.promoteExecutables(nil)
 }
 
-func (pool *TxPool) softvalidateTx(tx *types.Transaction) error {
+func (pool *TxPool) softvalidateTx(tx *types.Transaction, local bool) error {
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > maxTransactionSize {
 		log.Trace("ErrOversizedData maxTransactionSize", "ErrOversizedData", ErrOversizedData)
@@ -337,8 +386,8 @@ func (pool *TxPool) softvalidateTx(tx *types.Transaction) error {
 		return ErrGasLimit
 	}
 
-	// Check gasPrice.
-	if pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
+	// Check gasPrice, local transactions are exempt from the price limit.
+	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
 		log.Trace("ErrUnderpriced", "ErrUnderpriced", ErrUnderpriced)
 		return ErrUnderpriced
 	}
@@ -363,7 +412,7 @@ func (pool *TxPool) softvalidateTx(tx *types.Transaction) error {
 
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
-func (pool *TxPool) validateTx(tx *types.Transaction) error {
+func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > maxTransactionSize {
 		log.Trace("ErrOversizedData maxTransactionSize", "ErrOversizedData", ErrOversizedData)
@@ -395,8 +444,8 @@ func (pool *TxPool) validateTx(tx *types.Transaction) error {
 		return ErrGasLimit
 	}
 
-	// Check gasPrice.
-	if pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
+	// Check gasPrice, local transactions are exempt from the price limit.
+	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
 		log.Trace("ErrUnderpriced", "ErrUnderpriced", ErrUnderpriced)
 		return ErrUnderpriced
 	}
@@ -431,22 +480,38 @@ func (pool *TxPool) AddTxs(txs []*types.Transaction) error {
 	if len(txs) == 0 {
 		return nil
 	}
+	// Recover all the senders in one batch instead of one by one on validation,
+	// outside of the lock like GoTxsAsynSender
+	types.RecoverSenders(pool.signer, txs)
+
 	pool.smu.RLock()
 	defer pool.smu.RUnlock()
 
 	for _, tx := range txs {
 		// If the transaction fails basic validation, discard it
-		if err := pool.softvalidateTx(tx); err != nil {
+		if err := pool.softvalidateTx(tx, false); err != nil {
 			log.Trace("Discarding invalid transaction", "hash", tx.Hash(), "err", err)
 			return err
 		}
 	}
 
-	return pool.addTxsLocked(txs)
+	return pool.addTxsLocked(txs, false)
 }
 
-// AddTx attempts to queue a transactions if valid.
+// AddTx attempts to queue a remote transaction if valid.
 func (pool *TxPool) AddTx(tx *types.Transaction) error {
+	return pool.addTx(tx, false)
+}
+
+// AddLocal enqueues a single transaction submitted through the local APIs,
+// marking the sender as a local one. Local senders are exempt from the price
+// limit and from eviction, and their transactions are journaled to disk.
+func (pool *TxPool) AddLocal(tx *types.Transaction) error {
+	return pool.addTx(tx, !pool.config.NoLocals)
+}
+
+// addTx validates and enqueues a single transaction into the pool.
+func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 	hash := tx.Hash()
 	if _, ok := pool.all.Load(hash); ok {
 		log.Trace("Discarding already known transaction", "hash", hash)
@@ -456,12 +521,12 @@ func (pool *TxPool) AddTx(tx *types.Transaction) error {
 	pool.smu.RLock()
 	defer pool.smu.RUnlock()
 	// If the transaction fails basic validation, discard it
-	if err := pool.softvalidateTx(tx); err != nil {
+	if err := pool.softvalidateTx(tx, local); err != nil {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
 		return err
 	}
 
-	recerr := pool.addTxLocked(tx)
+	recerr := pool.addTxLocked(tx, local)
 	if recerr != nil {
 		return recerr
 	}
@@ -470,11 +535,11 @@ func (pool *TxPool) AddTx(tx *types.Transaction) error {
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid,
 // whilst assuming the transaction pool lock is already held.
-func (pool *TxPool) addTxsLocked(txs []*types.Transaction) error {
+func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) error {
 	// Add the batch of transaction, tracking the accepted ones
 	dirty := make(map[common.Address]struct{})
 	for _, tx := range txs {
-		if replace, err := pool.add(tx); err == nil {
+		if replace, err := pool.add(tx, local); err == nil {
 
 			if !replace {
 				from, err := types.Sender(pool.signer, tx) // already validated
@@ -499,20 +564,18 @@ func (pool *TxPool) addTxsLocked(txs []*types.Transaction) error {
 	return nil
 }
 
-// GoTxsAsynSender attempts to queue a batch of transactions if they are valid,
-// whilst assuming the transaction pool lock is already held.
+// GoTxsAsynSender queues the sender recovery of a batch of transactions ahead
+// of adding them to the pool.
 func (pool *TxPool) GoTxsAsynSender(txs []*types.Transaction) error {
-	for _, tx := range txs {
-		types.ASynSender(pool.signer, tx)
-	}
+	types.RecoverSenders(pool.signer, txs)
 	return nil
 }
 
 // addTx enqueues a single transaction into the pool if it is valid.
-func (pool *TxPool) addTxLocked(tx *types.Transaction) error {
+func (pool *TxPool) addTxLocked(tx *types.Transaction, local bool) error {
 
 	// Try to inject the transaction and update any state
-	replace, err := pool.add(tx)
+	replace, err := pool.add(tx, local)
 	if err != nil {
 		return err
 	}
@@ -535,22 +598,23 @@ func (pool *TxPool) addTxLocked(tx *types.Transaction) error {
 // If a newly added transaction is marked as local, its sending account will be
 // whitelisted, preventing any associated transaction from being dropped out of
 // the pool due to pricing constraints.
-
-func (pool *TxPool) add(tx *types.Transaction) (bool, error) {
+func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	hash := tx.Hash()
 	from, _ := types.Sender(pool.signer, tx) // already validated
 
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(allCnt) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
-		if pool.priced.Underpriced(tx) {
+		if !local && pool.priced.Underpriced(tx, pool.locals) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "price", tx.GasPrice())
+			underpricedMeter.Mark(1)
 			return false, ErrUnderpriced
 		}
 		// New transaction is better than our worse ones, make room for it
-		drop := pool.priced.Discard(int(allCnt) - int(pool.config.GlobalSlots+pool.config.GlobalQueue-1))
+		drop := pool.priced.Discard(int(allCnt)-int(pool.config.GlobalSlots+pool.config.GlobalQueue-1), pool.locals)
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
+			underpricedMeter.Mark(1)
 			pool.removeTxLocked(tx.Hash())
 		}
 	}
@@ -571,6 +635,7 @@ func (pool *TxPool) add(tx *types.Transaction) (bool, error) {
 			}
 			// New transaction is better, replace old one
 			if old != nil {
+				pendingReplaceMeter.Mark(1)
 				pool.all.Delete(old.Hash())
 				atomic.AddInt64(&allCnt, -1)
 				pool.priced.Removed(allCnt)
@@ -578,6 +643,7 @@ func (pool *TxPool) add(tx *types.Transaction) (bool, error) {
 			pool.all.Store(tx.Hash(), tx)
 			atomic.AddInt64(&allCnt, 1)
 			pool.priced.Put(tx)
+			pool.journalTx(from, tx, local)
 
 			log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 			return old != nil, nil
@@ -588,10 +654,27 @@ func (pool *TxPool) add(tx *types.Transaction) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	pool.journalTx(from, tx, local)
+
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from, "to", tx.To())
 	return replace, nil
 }
 
+// journalTx marks the sender of a local transaction as local and adds the
+// transaction to the disk journal.
+func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction, local bool) {
+	if local {
+		pool.locals.add(from)
+	}
+	// Only journal if it's enabled and the transaction is local
+	if pool.journal == nil || !pool.locals.contains(from) {
+		return
+	}
+	if err := pool.journal.insert(tx); err != nil {
+		log.Warn("Failed to journal local transaction", "err", err)
+	}
+}
+
 // enqueueTx inserts a new transaction into the non-executable transaction queue.
 //
 // Note, this method assumes the pool lock is held!
@@ -612,6 +695,7 @@ func (pool *TxPool) enqueueTxLocked(hash common.Hash, tx *types.Transaction) (bo
 
 	// Discard any previous transaction and mark this
 	if old != nil {
+		queuedReplaceMeter.Mark(1)
 		pool.all.Delete(old.Hash())
 		atomic.AddInt64(&allCnt, -1)
 		pool.priced.Removed(allCnt)
@@ -649,6 +733,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			for _, tx := range list.Forward(pool.currentState.GetNonce(addr)) {
 				hash := tx.Hash()
 				log.Trace("Removed old queued transaction", "hash", hash)
+				queuedDiscardMeter.Mark(1)
 				pool.all.Delete(hash)
 				atomic.AddInt64(&allCnt, -1)
 				pool.priced.Removed(allCnt)
@@ -659,6 +744,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			for _, tx := range drops {
 				hash := tx.Hash()
 				log.Trace("Removed unpayable queued transaction", "hash", hash)
+				queuedNofundsMeter.Mark(1)
 				pool.all.Delete(hash)
 				atomic.AddInt64(&allCnt, -1)
 				pool.priced.Removed(allCnt)
@@ -682,6 +768,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 				atomic.AddInt64(&allCnt, -1)
 				pool.priced.Removed(allCnt)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
+				queuedRateLimitMeter.Mark(1)
 			}
 
 			// Delete the entire queue entry if it became empty.
@@ -720,6 +807,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			for _, tx := range list.Forward(nonce) {
 				hash := tx.Hash()
 				log.Trace("Removed old pending transaction", "hash", hash)
+				pendingDiscardMeter.Mark(1)
 				pool.all.Delete(hash)
 				atomic.AddInt64(&allCnt, -1)
 				pool.priced.Removed(allCnt)
@@ -729,6 +817,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			for _, tx := range drops {
 				hash := tx.Hash()
 				log.Trace("Removed unpayable pending transaction", "hash", hash)
+				pendingNofundsMeter.Mark(1)
 				pool.all.Delete(hash)
 				atomic.AddInt64(&allCnt, -1)
 				pool.priced.Removed(allCnt)
@@ -815,7 +904,7 @@ func (pool *TxPool) keepFit() {
 		pool.pending.Range(func(k, v interface{}) bool {
 			addr := k.(common.Address)
 			list := v.(*txList)
-			if uint64(list.Len()) > pool.config.AccountSlots {
+			if !pool.locals.contains(addr) && uint64(list.Len()) > pool.config.AccountSlots {
 				spammers.Push(addr, float32(list.Len()))
 			}
 			return true
@@ -863,6 +952,7 @@ func (pool *TxPool) keepFit() {
 										pool.pendingState.SetNonce(offenders[i], nonce)
 									}
 									log.Trace("Removed fairness-exceeding pending transaction", "tx.Nonce()", tx.Nonce(), "hash", hash)
+									pendingRateLimitMeter.Mark(1)
 								}
 							}
 						}
@@ -904,6 +994,7 @@ func (pool *TxPool) keepFit() {
 									pool.pendingState.SetNonce(addr, nonce)
 								}
 								log.Trace("Removed fairness-exceeding pending transaction", "tx.Nonce()", tx.Nonce(), "hash", hash)
+								pendingRateLimitMeter.Mark(1)
 							}
 						}
 					}
@@ -927,6 +1018,9 @@ func (pool *TxPool) keepFit() {
 		addresses := make(addresssByHeartbeat, 0, normalQueueLen)
 		pool.queue.Range(func(k, v interface{}) bool {
 			addr := k.(common.Address)
+			if pool.locals.contains(addr) {
+				return true
+			}
 			if v, ok := pool.beats.Load(addr); ok {
 				tm := v.(time.Time)
 				addresses = append(addresses, addressByHeartbeat{addr, tm})
@@ -953,6 +1047,7 @@ func (pool *TxPool) keepFit() {
 					for _, tx := range list.Flatten() {
 						pool.removeTxLocked(tx.Hash())
 						log.Debug("Removed fairness-exceeding Queue transaction", "hash", tx.Hash())
+						queuedRateLimitMeter.Mark(1)
 					}
 					drop -= size
 					userlk.Unlock()
@@ -964,6 +1059,7 @@ func (pool *TxPool) keepFit() {
 				for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 					pool.removeTxLocked(txs[i].Hash())
 					log.Debug("Removed fairness-exceeding Queue transaction", "hash", txs[i].Hash())
+					queuedRateLimitMeter.Mark(1)
 					drop--
 				}
 
@@ -1021,6 +1117,29 @@ func (pool *TxPool) removeTxLocked(hash common.Hash) {
 	}
 }
 
+// local retrieves all currently known local transactions, groupped by origin
+// account and sorted by nonce. The returned transaction set is a copy and can be
+// freely modified by calling code.
+func (pool *TxPool) local() map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for _, addr := range pool.locals.flatten() {
+		ul, ok := pool.userlock.Load(addr)
+		if !ok {
+			continue
+		}
+		userlk := ul.(*sync.RWMutex)
+		userlk.RLock()
+		if lv, ok := pool.pending.Load(addr); ok {
+			txs[addr] = append(txs[addr], lv.(*txList).Flatten()...)
+		}
+		if lv, ok := pool.queue.Load(addr); ok {
+			txs[addr] = append(txs[addr], lv.(*txList).Flatten()...)
+		}
+		userlk.RUnlock()
+	}
+	return txs
+}
+
 // addressByHeartbeat is an account address tagged with its last activity timestamp.
 type addressByHeartbeat struct {
 	address   common.Address
@@ -1033,6 +1152,61 @@ func (a addresssByHeartbeat) Len() int           { return len(a) }
 func (a addresssByHeartbeat) Less(i, j int) bool { return a[i].heartbeat.Before(a[j].heartbeat) }
 func (a addresssByHeartbeat) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
+// accountSet is simply a set of addresses to check for existence, and a signer
+// capable of deriving addresses from transactions.
+type accountSet struct {
+	accounts map[common.Address]struct{}
+	signer   types.Signer
+	lock     sync.RWMutex
+}
+
+// newAccountSet creates a new address set with an associated signer for sender
+// derivations.
+func newAccountSet(signer types.Signer) *accountSet {
+	return &accountSet{
+		accounts: make(map[common.Address]struct{}),
+		signer:   signer,
+	}
+}
+
+// contains checks if a given address is contained within the set.
+func (as *accountSet) contains(addr common.Address) bool {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	_, exist := as.accounts[addr]
+	return exist
+}
+
+// containsTx checks if the sender of a given tx is within the set. If the sender
+// cannot be derived, this method returns false.
+func (as *accountSet) containsTx(tx *types.Transaction) bool {
+	if addr, err := types.Sender(as.signer, tx); err == nil {
+		return as.contains(addr)
+	}
+	return false
+}
+
+// add inserts a new address into the set to track.
+func (as *accountSet) add(addr common.Address) {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	as.accounts[addr] = struct{}{}
+}
+
+// flatten returns the list of addresses within this set.
+func (as *accountSet) flatten() []common.Address {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	accounts := make([]common.Address, 0, len(as.accounts))
+	for addr := range as.accounts {
+		accounts = append(accounts, addr)
+	}
+	return accounts
+}
+
 //For RPC
 
 // Stats retrieves the current pool stats, namely the number of pending and the
@@ -1137,7 +1311,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	defer pool.smu.Unlock()
 
 	pool.gasPrice = price
-	for _, tx := range pool.priced.Cap(price) {
+	for _, tx := range pool.priced.Cap(price, pool.locals) {
 		pool.removeTxLocked(tx.Hash())
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
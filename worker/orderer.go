@@ -0,0 +1,210 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package worker
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/log"
+	"github.com/hpb-project/go-hpb/config"
+)
+
+// TxOrderer yields pending transactions in the order they are committed into a
+// new block. The transactions of a single sender are always yielded by nonce.
+type TxOrderer interface {
+	// Peek returns the next transaction to commit, nil if none is left.
+	Peek() *types.Transaction
+
+	// Shift replaces the next transaction with the following one of its sender.
+	Shift()
+
+	// Pop removes the next transaction, skipping all the remaining ones of its
+	// sender. It's used when a transaction can't be executed.
+	Pop()
+}
+
+// txOrdering creates the TxOrderer of a new block from the pending transactions
+// of the pool, grouped by sender and sorted by nonce. The pending map is reowned.
+type txOrdering func(signer types.Signer, pending map[common.Address]types.Transactions) TxOrderer
+
+// newTxOrdering creates the transaction ordering policy configured for block
+// production.
+func newTxOrdering(cfg config.MinerConfig) (txOrdering, error) {
+	var less txHeadLess
+	switch cfg.TxOrder {
+	case "", config.TxOrderPrice:
+		less = byPrice
+	case config.TxOrderArrival:
+		less = byArrival
+	case config.TxOrderPriceArrival:
+		less = byPriceArrival
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering %q", cfg.TxOrder)
+	}
+	if cfg.SenderTxCap < 0 {
+		return nil, fmt.Errorf("invalid sender transaction cap %d", cfg.SenderTxCap)
+	}
+	priority := make(map[common.Address]bool)
+	for _, addr := range cfg.PriorityContracts {
+		priority[addr] = true
+	}
+	return func(signer types.Signer, pending map[common.Address]types.Transactions) TxOrderer {
+		if cfg.SenderTxCap > 0 {
+			for addr, txs := range pending {
+				if len(txs) > cfg.SenderTxCap {
+					pending[addr] = txs[:cfg.SenderTxCap]
+				}
+			}
+		}
+		// Plain price ordering is what the chain always did, keep using it
+		if len(priority) == 0 && (cfg.TxOrder == "" || cfg.TxOrder == config.TxOrderPrice) {
+			return types.NewTransactionsByPriceAndNonce(signer, pending)
+		}
+		return newTxsByPolicy(signer, pending, less, priority)
+	}, nil
+}
+
+// minerTxOrdering returns the transaction ordering configured for the node,
+// falling back to ordering by gas price if the configuration is invalid.
+func minerTxOrdering() txOrdering {
+	ordering, err := newTxOrdering(config.GetHpbConfigInstance().Miner)
+	if err != nil {
+		log.Error("Invalid transaction ordering, ordering by gas price", "err", err)
+		ordering, _ = newTxOrdering(config.DefaultMinerConfig)
+	}
+	return ordering
+}
+
+// txHead is the next transaction of a sender, with its ordering keys cached.
+type txHead struct {
+	tx       *types.Transaction
+	from     common.Address
+	price    *big.Int
+	priority bool // Whether the transaction calls a priority contract
+}
+
+// txHeadLess reports whether a should be committed before b.
+type txHeadLess func(a, b *txHead) bool
+
+func byPrice(a, b *txHead) bool {
+	return a.price.Cmp(b.price) > 0
+}
+
+func byArrival(a, b *txHead) bool {
+	return a.tx.Time().Before(b.tx.Time())
+}
+
+func byPriceArrival(a, b *txHead) bool {
+	if cmp := a.price.Cmp(b.price); cmp != 0 {
+		return cmp > 0
+	}
+	return byArrival(a, b)
+}
+
+// txHeads is a heap of the next transaction of every sender, ordering the ones
+// calling priority contracts first, then by the policy.
+type txHeads struct {
+	heads []*txHead
+	less  txHeadLess
+}
+
+func (h *txHeads) Len() int { return len(h.heads) }
+func (h *txHeads) Less(i, j int) bool {
+	if h.heads[i].priority != h.heads[j].priority {
+		return h.heads[i].priority
+	}
+	return h.less(h.heads[i], h.heads[j])
+}
+func (h *txHeads) Swap(i, j int) { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+
+func (h *txHeads) Push(x interface{}) {
+	h.heads = append(h.heads, x.(*txHead))
+}
+
+func (h *txHeads) Pop() interface{} {
+	old := h.heads
+	n := len(old)
+	x := old[n-1]
+	h.heads = old[0 : n-1]
+	return x
+}
+
+// txsByPolicy is a TxOrderer yielding the transactions by a configurable
+// ordering of the senders' next transactions.
+type txsByPolicy struct {
+	txs      map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads    *txHeads                              // Next transaction for each unique account
+	priority map[common.Address]bool               // Contracts whose transactions go first
+}
+
+// newTxsByPolicy creates a transaction set retrieving the transactions in the
+// order of less, honouring their nonces.
+func newTxsByPolicy(signer types.Signer, txs map[common.Address]types.Transactions, less txHeadLess, priority map[common.Address]bool) *txsByPolicy {
+	set := &txsByPolicy{
+		txs:      make(map[common.Address]types.Transactions, len(txs)),
+		heads:    &txHeads{heads: make([]*txHead, 0, len(txs)), less: less},
+		priority: priority,
+	}
+	for _, accTxs := range txs {
+		if len(accTxs) == 0 {
+			continue
+		}
+		// Ensure the sender address is from the signer
+		from, _ := types.Sender(signer, accTxs[0])
+		set.txs[from] = accTxs[1:]
+		set.heads.heads = append(set.heads.heads, set.newHead(from, accTxs[0]))
+	}
+	heap.Init(set.heads)
+	return set
+}
+
+func (t *txsByPolicy) newHead(from common.Address, tx *types.Transaction) *txHead {
+	head := &txHead{tx: tx, from: from, price: tx.GasPrice()}
+	if to := tx.To(); to != nil {
+		head.priority = t.priority[*to]
+	}
+	return head
+}
+
+// Peek returns the next transaction by the ordering policy.
+func (t *txsByPolicy) Peek() *types.Transaction {
+	if t.heads.Len() == 0 {
+		return nil
+	}
+	return t.heads.heads[0].tx
+}
+
+// Shift replaces the current head with the next one from the same account.
+func (t *txsByPolicy) Shift() {
+	from := t.heads.heads[0].from
+	if txs := t.txs[from]; len(txs) > 0 {
+		t.heads.heads[0], t.txs[from] = t.newHead(from, txs[0]), txs[1:]
+		heap.Fix(t.heads, 0)
+	} else {
+		heap.Pop(t.heads)
+	}
+}
+
+// Pop removes the current head, *not* replacing it with the next one from the
+// same account.
+func (t *txsByPolicy) Pop() {
+	heap.Pop(t.heads)
+}
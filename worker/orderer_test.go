@@ -0,0 +1,161 @@
+// Copyright 2018 The go-hpb Authors
+// Modified based on go-ethereum, which Copyright (C) 2014 The go-ethereum Authors.
+//
+// The go-hpb is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hpb is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hpb. If not, see <http://www.gnu.org/licenses/>.
+
+package worker
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/hpb-project/go-hpb/blockchain/types"
+	"github.com/hpb-project/go-hpb/common"
+	"github.com/hpb-project/go-hpb/common/crypto"
+	"github.com/hpb-project/go-hpb/config"
+)
+
+var (
+	ordererSigner   = types.NewBoeSigner(config.MainnetChainConfig.ChainId)
+	ordererContract = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+)
+
+// orderTestTx creates a signed transaction, arriving after all previously
+// created ones.
+func orderTestTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, to common.Address, price int64) *types.Transaction {
+	tx, err := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(1), big.NewInt(21000), big.NewInt(price), nil, types.TxExdata{}), ordererSigner, key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+// orderTestKeys generates n sender keys.
+func orderTestKeys(n int) []*ecdsa.PrivateKey {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	return keys
+}
+
+// checkOrder drains the pending transactions by the configured policy and
+// compares them to the expected order.
+func checkOrder(t *testing.T, cfg config.MinerConfig, pending map[common.Address]types.Transactions, want types.Transactions) {
+	ordering, err := newTxOrdering(cfg)
+	if err != nil {
+		t.Fatalf("failed to create ordering: %v", err)
+	}
+	orderer := ordering(ordererSigner, pending)
+
+	var have types.Transactions
+	for tx := orderer.Peek(); tx != nil; tx = orderer.Peek() {
+		have = append(have, tx)
+		orderer.Shift()
+	}
+	if len(have) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("transaction %d mismatch: have nonce %d price %v, want nonce %d price %v", i, have[i].Nonce(), have[i].GasPrice(), want[i].Nonce(), want[i].GasPrice())
+		}
+	}
+}
+
+func pendingOf(txs ...*types.Transaction) map[common.Address]types.Transactions {
+	pending := make(map[common.Address]types.Transactions)
+	for _, tx := range txs {
+		from, _ := types.Sender(ordererSigner, tx)
+		pending[from] = append(pending[from], tx)
+	}
+	return pending
+}
+
+// Tests that first come first served ordering interleaves the senders by the
+// arrival of their transactions, ignoring the prices.
+func TestTxOrderArrival(t *testing.T) {
+	keys := orderTestKeys(2)
+	a0 := orderTestTx(t, keys[0], 0, common.Address{}, 1)
+	b0 := orderTestTx(t, keys[1], 0, common.Address{}, 10)
+	a1 := orderTestTx(t, keys[0], 1, common.Address{}, 100)
+	b1 := orderTestTx(t, keys[1], 1, common.Address{}, 1000)
+
+	checkOrder(t, config.MinerConfig{TxOrder: config.TxOrderArrival}, pendingOf(a0, a1, b0, b1), types.Transactions{a0, b0, a1, b1})
+}
+
+// Tests that price then arrival ordering only uses the arrival among equal prices.
+func TestTxOrderPriceArrival(t *testing.T) {
+	keys := orderTestKeys(3)
+	a0 := orderTestTx(t, keys[0], 0, common.Address{}, 5)
+	b0 := orderTestTx(t, keys[1], 0, common.Address{}, 5)
+	c0 := orderTestTx(t, keys[2], 0, common.Address{}, 10)
+	a1 := orderTestTx(t, keys[0], 1, common.Address{}, 1)
+
+	checkOrder(t, config.MinerConfig{TxOrder: config.TxOrderPriceArrival}, pendingOf(a0, a1, b0, c0), types.Transactions{c0, a0, b0, a1})
+}
+
+// Tests that the senders are capped at the configured number of transactions.
+func TestTxOrderSenderCap(t *testing.T) {
+	keys := orderTestKeys(2)
+	var a, b types.Transactions
+	for i := 0; i < 5; i++ {
+		a = append(a, orderTestTx(t, keys[0], uint64(i), common.Address{}, 1))
+		b = append(b, orderTestTx(t, keys[1], uint64(i), common.Address{}, 1))
+	}
+	pending := pendingOf(append(append(types.Transactions{}, a...), b...)...)
+	checkOrder(t, config.MinerConfig{TxOrder: config.TxOrderArrival, SenderTxCap: 2}, pending, types.Transactions{a[0], b[0], a[1], b[1]})
+}
+
+// Tests that the transactions calling priority contracts go first, without
+// breaking the nonce order of their senders.
+func TestTxOrderPriorityLane(t *testing.T) {
+	keys := orderTestKeys(3)
+	a0 := orderTestTx(t, keys[0], 0, common.Address{}, 100)
+	b0 := orderTestTx(t, keys[1], 0, ordererContract, 1)
+	c0 := orderTestTx(t, keys[2], 0, common.Address{}, 10)
+	c1 := orderTestTx(t, keys[2], 1, ordererContract, 1)
+
+	cfg := config.MinerConfig{TxOrder: config.TxOrderPrice, PriorityContracts: []common.Address{ordererContract}}
+	checkOrder(t, cfg, pendingOf(a0, b0, c0, c1), types.Transactions{b0, a0, c0, c1})
+}
+
+// Tests that popping a transaction skips the rest of its sender.
+func TestTxOrderPop(t *testing.T) {
+	keys := orderTestKeys(2)
+	a0 := orderTestTx(t, keys[0], 0, common.Address{}, 1)
+	b0 := orderTestTx(t, keys[1], 0, common.Address{}, 1)
+	a1 := orderTestTx(t, keys[0], 1, common.Address{}, 1)
+
+	ordering, _ := newTxOrdering(config.MinerConfig{TxOrder: config.TxOrderArrival})
+	orderer := ordering(ordererSigner, pendingOf(a0, a1, b0))
+	if tx := orderer.Peek(); tx != a0 {
+		t.Fatalf("first transaction mismatch")
+	}
+	orderer.Pop()
+	if tx := orderer.Peek(); tx != b0 {
+		t.Fatalf("second transaction mismatch")
+	}
+	orderer.Shift()
+	if tx := orderer.Peek(); tx != nil {
+		t.Fatalf("popped sender transaction returned: nonce %d", tx.Nonce())
+	}
+}
+
+func TestTxOrderInvalid(t *testing.T) {
+	if _, err := newTxOrdering(config.MinerConfig{TxOrder: "random"}); err == nil {
+		t.Fatalf("unknown ordering accepted")
+	}
+}
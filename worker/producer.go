@@ -105,7 +105,9 @@ func (self *CpuAgent) mine(work *Work, stop <-chan struct{}) {
 		log.Info("Successfully sealed new block", "number -> ", result.Number(), "hash -> ", result.Hash(), "difficulty -> ", result.Difficulty())
 		self.returnCh <- &Result{work, result}
 	} else {
-		if err != nil {
+		if err == consensus.ErrWaitTransactions {
+			log.Debug("Block sealing postponed", "err", err)
+		} else if err != nil {
 			log.Warn("Block sealing failed", "err", err)
 		}
 		self.returnCh <- nil
@@ -96,6 +96,8 @@ type worker struct {
 
 	mux          *sub.TypeMux
 	pool         *txpool.TxPool
+	txCh         chan bc.TxPreEvent
+	txSub        sub.Subscription
 	chainHeadCh  chan bc.ChainHeadEvent
 	chainHeadSub sub.Subscription
 	chainSideCh  chan bc.ChainSideEvent
@@ -111,6 +113,7 @@ type worker struct {
 
 	coinbase common.Address
 	extra    []byte
+	ordering txOrdering // Ordering policy of the transactions in new blocks
 
 	currentMu sync.Mutex
 	current   *Work
@@ -140,9 +143,15 @@ func newWorker(config *config.ChainConfig, engine consensus.Engine, coinbase com
 		coinbase:       coinbase,
 		producers:      make(map[Producer]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(bc.InstanceBlockChain(), miningLogAtDepth),
+		ordering:       minerTxOrdering(),
 	}
 
 	worker.pool = txpool.GetTxPool()
+	if config.Dev {
+		// Developer chains seal on demand, wake up on every new transaction
+		worker.txCh = make(chan bc.TxPreEvent, txChanSize)
+		worker.txSub = worker.pool.SubscribeTxPreEvent(worker.txCh)
+	}
 	worker.chainHeadSub = bc.InstanceBlockChain().SubscribeChainHeadEvent(worker.chainHeadCh)
 	worker.chainSideSub = bc.InstanceBlockChain().SubscribeChainSideEvent(worker.chainSideCh)
 	// goto listen the event
@@ -240,10 +249,14 @@ func (self *worker) unregister(producer Producer) {
 
 func (self *worker) eventListener() {
 
-	//defer self.txSub.Unsubscribe()
 	defer self.chainHeadSub.Unsubscribe()
 	defer self.chainSideSub.Unsubscribe()
 
+	var txErr <-chan error
+	if self.txSub != nil {
+		defer self.txSub.Unsubscribe()
+		txErr = self.txSub.Err()
+	}
 	for {
 		// A real event arrived, process interesting content
 		select {
@@ -251,6 +264,14 @@ func (self *worker) eventListener() {
 		case <-self.chainHeadCh:
 			self.startNewMinerRound()
 
+		// Handle TxPreEvent, only subscribed on developer chains
+		case <-self.txCh:
+			// Empty blocks are not sealed without a block period, so the
+			// producers are idle until a transaction is pending
+			if atomic.LoadInt32(&self.mining) == 1 && atomic.LoadInt32(&self.atWork) == 0 {
+				self.startNewMinerRound()
+			}
+
 		// Handle ChainSideEvent
 		case ev := <-self.chainSideCh:
 			self.uncleMu.Lock()
@@ -261,6 +282,8 @@ func (self *worker) eventListener() {
 			return
 		case <-self.chainSideSub.Err():
 			return
+		case <-txErr:
+			return
 		}
 	}
 }
@@ -402,7 +425,7 @@ func (self *worker) startNewMinerRound() {
 		Time:       big.NewInt(tstamp),
 	}
 	var extra *types.ExtraDetail
-	if header.Number.Uint64() >= consensus.StageNumberRealRandom {
+	if header.Number.Uint64() >= self.config.StageNumberRealRandom() {
 		extra, _ = types.NewExtraDetail(types.ExtraVersion)
 	} else {
 		extra, _ = types.NewExtraDetail(0)
@@ -442,7 +465,7 @@ func (self *worker) startNewMinerRound() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
+	txs := self.ordering(self.current.signer, pending)
 	maxtxs := self.calMaxTxs(parent)
 	work.commitTransactions(self.mux, txs, self.coinbase, maxtxs)
 	log.Debug("worker startNewMinerRound after commitTransactions", "time", time.Now().Unix())
@@ -496,7 +519,7 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *sub.TypeMux, txs *types.TransactionsByPriceAndNonce, coinbase common.Address, maxTxs int) {
+func (env *Work) commitTransactions(mux *sub.TypeMux, txs TxOrderer, coinbase common.Address, maxTxs int) {
 	gp := new(bc.GasPool).AddGas(env.header.GasLimit)
 
 	var coalescedLogs []*types.Log
@@ -581,7 +604,7 @@ func (env *Work) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	var err error
 	snap := env.state.Snapshot()
 	blockchain := bc.InstanceBlockChain()
-	bNewVersion := env.header.Number.Uint64() > consensus.NewContractVersion
+	bNewVersion := env.header.Number.Uint64() > env.config.NewContractVersion()
 	if bNewVersion {
 		if (tx.To() == nil && len(tx.Data()) > 0) || (tx.To() != nil && len(env.state.GetCode(*tx.To())) > 0) {
 			_, receipt, _, err = bc.ApplyTransaction(env.config, blockchain, &coinbase, gp, env.state, env.header, tx, env.header.GasUsed)